@@ -1,11 +1,13 @@
 package audit
 
 import (
-	"encoding/json"
-	"fmt"
+	"crypto/rand"
+	"encoding/hex"
 	"os"
 	"path/filepath"
 	"time"
+
+	"github.com/vee-sh/veessh/internal/util"
 )
 
 // Entry represents a single audit log entry
@@ -15,10 +17,15 @@ type Entry struct {
 	Protocol    string    `json:"protocol"`
 	Host        string    `json:"host"`
 	User        string    `json:"user,omitempty"`
-	Action      string    `json:"action"` // "connect", "disconnect", "error"
+	Action      string    `json:"action"` // "connect", "disconnect", "error", "reconnect", "record", "hostkey"
 	Duration    string    `json:"duration,omitempty"`
 	Error       string    `json:"error,omitempty"`
 	ExitCode    int       `json:"exitCode,omitempty"`
+	Attempt     int       `json:"attempt,omitempty"` // Restart number of a reconnecting tunnel
+	Recording   string    `json:"recording,omitempty"` // File the session is recorded to
+	Session     string    `json:"session,omitempty"` // Shared by the entries of one connection (see sessions.go)
+	Prev        string    `json:"prev,omitempty"` // Hash of the previous entry (see chain.go)
+	Hash        string    `json:"hash,omitempty"` // Must stay the last field
 }
 
 // Logger handles audit logging
@@ -60,21 +67,74 @@ func NewLogger() (*Logger, error) {
 	return &Logger{path: path, file: file}, nil
 }
 
-// Log writes an audit entry
+// Log appends an audit entry, chained to the one before it, and ships it to
+// the sinks (see sink.go). The log is locked meanwhile, so that concurrent
+// veessh processes keep one chain, and rotated first when it is due (see
+// rotate.go).
 func (l *Logger) Log(entry Entry) error {
 	if entry.Timestamp.IsZero() {
 		entry.Timestamp = time.Now()
 	}
+	err := l.write(&entry)
+	ship(entry)
+	return err
+}
 
-	data, err := json.Marshal(entry)
+func (l *Logger) write(entry *Entry) error {
+	lock, err := util.Lock(l.path, true)
 	if err != nil {
 		return err
 	}
+	defer lock.Unlock()
+
+	// Another process may have rotated the file we opened
+	if err := l.reopen(); err != nil {
+		return err
+	}
+	prev, err := l.rotate(entry.Timestamp)
+	if err != nil {
+		return err
+	}
+	if prev == "" {
+		if prev, err = lastHash(l.path); err != nil {
+			return err
+		}
+	}
 
-	_, err = fmt.Fprintln(l.file, string(data))
+	line, err := chain(entry, prev)
+	if err != nil {
+		return err
+	}
+	_, err = l.file.Write(append(line, '\n'))
 	return err
 }
 
+// reopen opens the log again when the file at its path is no longer the
+// one l writes to
+func (l *Logger) reopen() error {
+	if l.file != nil {
+		open, err1 := l.file.Stat()
+		current, err2 := os.Stat(l.path)
+		if err1 == nil && err2 == nil && os.SameFile(open, current) {
+			return nil
+		}
+		l.file.Close()
+	}
+	file, err := os.OpenFile(l.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	l.file = file
+	return nil
+}
+
+// newSession returns a random session ID
+func newSession() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
 // Close closes the audit log file
 func (l *Logger) Close() error {
 	if l.file != nil {
@@ -83,11 +143,13 @@ func (l *Logger) Close() error {
 	return nil
 }
 
-// LogConnect logs a connection start
-func LogConnect(profile, protocol, host, user string) {
+// LogConnect logs a connection start and returns its session ID, for the
+// entries that follow
+func LogConnect(profile, protocol, host, user string) string {
+	session := newSession()
 	logger, err := NewLogger()
 	if err != nil {
-		return // Silent fail - audit is optional
+		return session // Silent fail - audit is optional
 	}
 	defer logger.Close()
 
@@ -98,11 +160,13 @@ func LogConnect(profile, protocol, host, user string) {
 		Host:      host,
 		User:      user,
 		Action:    "connect",
+		Session:   session,
 	})
+	return session
 }
 
 // LogDisconnect logs a connection end
-func LogDisconnect(profile, protocol, host, user string, startTime time.Time, exitCode int, connErr error) {
+func LogDisconnect(session, profile, protocol, host, user string, startTime time.Time, exitCode int, connErr error) {
 	logger, err := NewLogger()
 	if err != nil {
 		return // Silent fail - audit is optional
@@ -118,6 +182,7 @@ func LogDisconnect(profile, protocol, host, user string, startTime time.Time, ex
 		Action:    "disconnect",
 		Duration:  time.Since(startTime).Round(time.Second).String(),
 		ExitCode:  exitCode,
+		Session:   session,
 	}
 
 	if connErr != nil {
@@ -128,46 +193,90 @@ func LogDisconnect(profile, protocol, host, user string, startTime time.Time, ex
 	logger.Log(entry)
 }
 
-// ReadEntries reads audit log entries (last N entries)
-func ReadEntries(limit int) ([]Entry, error) {
-	path, err := DefaultPath()
+// LogReconnect logs a supervised tunnel restarting its connection
+func LogReconnect(session, profile, protocol, host, user string, attempt int, reason error) {
+	logger, err := NewLogger()
 	if err != nil {
-		return nil, err
+		return // Silent fail - audit is optional
 	}
+	defer logger.Close()
 
-	data, err := os.ReadFile(path)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return []Entry{}, nil
-		}
-		return nil, err
+	entry := Entry{
+		Timestamp: time.Now(),
+		Profile:   profile,
+		Protocol:  protocol,
+		Host:      host,
+		User:      user,
+		Action:    "reconnect",
+		Attempt:   attempt,
+		Session:   session,
+	}
+	if reason != nil {
+		entry.Error = reason.Error()
 	}
 
-	var entries []Entry
-	lines := splitLines(data)
+	logger.Log(entry)
+}
 
-	for _, line := range lines {
-		if len(line) == 0 {
-			continue
-		}
-		var entry Entry
-		if err := json.Unmarshal(line, &entry); err != nil {
-			continue // Skip malformed lines
-		}
-		entries = append(entries, entry)
+// LogRecording logs that a session is being recorded, and where
+func LogRecording(session, profile, protocol, host, user, path string) {
+	logger, err := NewLogger()
+	if err != nil {
+		return // Silent fail - audit is optional
 	}
+	defer logger.Close()
 
-	// Return last N entries
-	if limit > 0 && len(entries) > limit {
-		entries = entries[len(entries)-limit:]
+	logger.Log(Entry{
+		Timestamp: time.Now(),
+		Profile:   profile,
+		Protocol:  protocol,
+		Host:      host,
+		User:      user,
+		Action:    "record",
+		Recording: path,
+		Session:   session,
+	})
+}
+
+// LogRecordingFailed logs that the recording of a session stopped early
+func LogRecordingFailed(session, profile, protocol, host, user, path string, recErr error) {
+	logger, err := NewLogger()
+	if err != nil {
+		return // Silent fail - audit is optional
 	}
+	defer logger.Close()
 
-	// Reverse to show most recent first
-	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
-		entries[i], entries[j] = entries[j], entries[i]
+	logger.Log(Entry{
+		Timestamp: time.Now(),
+		Profile:   profile,
+		Protocol:  protocol,
+		Host:      host,
+		User:      user,
+		Action:    "record",
+		Recording: path,
+		Error:     recErr.Error(),
+		Session:   session,
+	})
+}
+
+// LogHostKey logs a host key that did not match its pin, or could not be
+// checked, before connecting
+func LogHostKey(profile, protocol, host, user, problem string) {
+	logger, err := NewLogger()
+	if err != nil {
+		return // Silent fail - audit is optional
 	}
+	defer logger.Close()
 
-	return entries, nil
+	logger.Log(Entry{
+		Timestamp: time.Now(),
+		Profile:   profile,
+		Protocol:  protocol,
+		Host:      host,
+		User:      user,
+		Action:    "hostkey",
+		Error:     problem,
+	})
 }
 
 func splitLines(data []byte) [][]byte {
@@ -161,3 +161,55 @@ func BenchmarkLog(b *testing.B) {
 	}
 }
 
+
+func TestReadEntriesFilters(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	path := filepath.Join(dir, "veessh", "audit.log")
+	os.MkdirAll(filepath.Dir(path), 0o700)
+
+	start := time.Now().Add(-time.Hour)
+	logger := &Logger{path: path}
+	for i, e := range []Entry{
+		{Profile: "web", Host: "web.example.com", Action: "connect"},
+		{Profile: "db", Host: "db.example.com", Action: "connect"},
+		{Profile: "web", Host: "web.example.com", Action: "error"},
+		{Profile: "web", Host: "web.example.com", Action: "connect"},
+	} {
+		e.Timestamp = start.Add(time.Duration(i) * time.Minute)
+		if err := logger.Log(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	logger.Close()
+
+	tests := []struct {
+		name   string
+		filter Filter
+		limit  int
+		want   int
+	}{
+		{"all", Filter{}, 0, 4},
+		{"limit", Filter{}, 2, 2},
+		{"profile", Filter{Profile: "web"}, 0, 3},
+		{"host", Filter{Host: "DB.example.com"}, 0, 1},
+		{"action", Filter{Actions: []string{"error"}}, 0, 1},
+		{"since", Filter{Since: start.Add(2 * time.Minute)}, 0, 2},
+		{"until", Filter{Until: start.Add(time.Minute)}, 0, 1},
+	}
+	for _, tt := range tests {
+		got, err := ReadEntries(tt.filter, tt.limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != tt.want {
+			t.Errorf("%s: %d entries, want %d", tt.name, len(got), tt.want)
+		}
+	}
+
+	// Most recent first
+	got, _ := ReadEntries(Filter{}, 1)
+	if len(got) != 1 || !got[0].Timestamp.Equal(start.Add(3*time.Minute)) {
+		t.Errorf("ReadEntries(limit 1) = %+v, want the last entry", got)
+	}
+}
@@ -0,0 +1,169 @@
+package audit
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// The entries form a hash chain: each carries the hash of the entry before
+// it in prev, and its own hash, the SHA-256 of its JSON line without the
+// hash field. Editing, removing or reordering entries breaks the chain,
+// which Verify detects. Rotation carries the chain over into the next file.
+//
+// The chain is not signed, so whoever can write the log can also rewrite
+// everything after an edit. The head hash Verify reports, kept elsewhere,
+// or a remote sink, catches that.
+
+// hashSuffix matches the hash field closing an entry line
+var hashSuffix = regexp.MustCompile(`,"hash":"([0-9a-f]{64})"}$`)
+
+// chain links entry to the entry hashed prev, filling in its prev and
+// hash, and returns its JSON line
+func chain(entry *Entry, prev string) ([]byte, error) {
+	entry.Prev = prev
+	entry.Hash = ""
+	body, err := json.Marshal(entry)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(body)
+	entry.Hash = hex.EncodeToString(sum[:])
+	return json.Marshal(entry)
+}
+
+// splitHash returns the hash recorded in an entry line and the hash of the
+// line without it, or ok false for an entry written before the chain
+func splitHash(line []byte) (recorded, actual string, ok bool) {
+	m := hashSuffix.FindSubmatchIndex(line)
+	if m == nil {
+		return "", "", false
+	}
+	body := append(bytes.Clone(line[:m[0]]), '}')
+	sum := sha256.Sum256(body)
+	return string(line[m[2]:m[3]]), hex.EncodeToString(sum[:]), true
+}
+
+// lastHash returns the hash of the last entry in the file at path, "" when
+// it has none
+func lastHash(path string) (string, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	// Entries are short; the last one is in the tail
+	const tail = 64 * 1024
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	offset := max(info.Size()-tail, 0)
+	data := make([]byte, info.Size()-offset)
+	if _, err := f.ReadAt(data, offset); err != nil && err != io.EOF {
+		return "", err
+	}
+	lines := splitLines(data)
+	for i := len(lines) - 1; i >= 0; i-- {
+		if len(bytes.TrimSpace(lines[i])) == 0 {
+			continue
+		}
+		hash, _, _ := splitHash(bytes.TrimSpace(lines[i]))
+		return hash, nil
+	}
+	return "", nil
+}
+
+// Problem is a place where the chain is broken
+type Problem struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Message string `json:"message"`
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("%s:%d: %s", filepath.Base(p.File), p.Line, p.Message)
+}
+
+// Verification is the result of checking the chain
+type Verification struct {
+	Files    int       `json:"files"`
+	Entries  int       `json:"entries"`
+	Unhashed int       `json:"unhashed"` // Entries from before the chain started
+	Head     string    `json:"head,omitempty"`
+	Problems []Problem `json:"problems,omitempty"`
+}
+
+// OK reports whether the chain is intact
+func (v Verification) OK() bool {
+	return len(v.Problems) == 0
+}
+
+// Verify checks the chain through the archives and the log at path, oldest
+// first. Entries written before the chain existed are counted, not
+// checked; they can only come first, in the oldest file, as every entry
+// written since is chained. The first chained entry may follow archives
+// that were pruned, but then nothing comes before it.
+func Verify(path string) (Verification, error) {
+	var v Verification
+	files, err := logFiles(path)
+	if err != nil {
+		return v, err
+	}
+	started := false
+	for i, file := range files {
+		v.Files++
+		line := 0
+		err := scanFile(file, func(data []byte) error {
+			line++
+			if len(bytes.TrimSpace(data)) == 0 {
+				return nil
+			}
+			v.Entries++
+			problem := func(format string, args ...any) {
+				v.Problems = append(v.Problems, Problem{File: file, Line: line, Message: fmt.Sprintf(format, args...)})
+			}
+
+			recorded, actual, ok := splitHash(data)
+			if !ok {
+				// Rotation came with the chain, so later files are all chained
+				if started || i > 0 {
+					problem("entry has no hash: inserted or edited")
+				} else {
+					v.Unhashed++
+				}
+				return nil
+			}
+			var e Entry
+			if err := json.Unmarshal(data, &e); err != nil {
+				problem("malformed entry: %v", err)
+			} else if started && e.Prev != v.Head {
+				problem("previous hash does not match: entries before it were removed, inserted or reordered")
+			} else if !started && e.Prev != "" && v.Entries > 1 {
+				// Chained entries follow unchained ones with no prev; the
+				// entry before it had a hash, which was stripped
+				problem("previous hash does not match: the entry before it was edited or removed")
+			}
+			if recorded != actual {
+				problem("hash does not match: entry was edited")
+			}
+			started = true
+			v.Head = recorded
+			return nil
+		})
+		if err != nil {
+			return v, fmt.Errorf("%s: %w", file, err)
+		}
+	}
+	return v, nil
+}
@@ -0,0 +1,148 @@
+package audit
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeLog logs n entries to a log at path, as separate processes would
+func writeLog(t *testing.T, path string, n int, start time.Time) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		l := &Logger{path: path}
+		if err := l.Log(Entry{Timestamp: start.Add(time.Duration(i) * time.Minute), Profile: "web", Host: "web.example.com", Action: "connect"}); err != nil {
+			t.Fatal(err)
+		}
+		l.Close()
+	}
+}
+
+func TestVerifyDetectsTampering(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	// An entry from before the chain, then chained ones
+	os.WriteFile(path, []byte(`{"timestamp":"2025-01-01T00:00:00Z","profile":"old","protocol":"ssh","host":"h","action":"connect"}`+"\n"), 0o600)
+	writeLog(t, path, 4, time.Now())
+
+	v, err := Verify(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !v.OK() || v.Entries != 5 || v.Unhashed != 1 || v.Head == "" {
+		t.Fatalf("intact log: %+v", v)
+	}
+
+	data, _ := os.ReadFile(path)
+	lines := bytes.SplitAfter(data, []byte("\n"))
+	tests := []struct {
+		name   string
+		edit   func() []byte
+		reason string
+	}{
+		{"edited", func() []byte {
+			return bytes.Replace(data, []byte("web.example.com"), []byte("evil.example.com"), 1)
+		}, "edited"},
+		{"removed", func() []byte {
+			return bytes.Join(append(append([][]byte{}, lines[:2]...), lines[3:]...), nil)
+		}, "removed"},
+		{"swapped", func() []byte {
+			return bytes.Join([][]byte{lines[0], lines[1], lines[3], lines[2], lines[4]}, nil)
+		}, "reordered"},
+	}
+	for _, tt := range tests {
+		os.WriteFile(path, tt.edit(), 0o600)
+		v, err := Verify(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v.OK() || !strings.Contains(v.Problems[0].Message, tt.reason) {
+			t.Errorf("%s: problems %v", tt.name, v.Problems)
+		}
+	}
+}
+
+func TestRotationContinuesChain(t *testing.T) {
+	defer SetRotation(DefaultRotation)
+	SetRotation(Rotation{MaxSize: 600, Keep: 2})
+
+	path := filepath.Join(t.TempDir(), "audit.log")
+	writeLog(t, path, 12, time.Now())
+
+	archives, err := archives(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(archives) != 2 {
+		t.Fatalf("%d archives, want 2 kept: %v", len(archives), archives)
+	}
+	// Rotated before the entry that would go past the limit
+	if info, _ := os.Stat(path); info.Size() >= 2*600 {
+		t.Errorf("log not rotated: %d bytes", info.Size())
+	}
+
+	v, err := Verify(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !v.OK() || v.Files != 3 || v.Unhashed != 0 {
+		t.Errorf("rotated log: %+v", v)
+	}
+}
+
+func TestRotationByAge(t *testing.T) {
+	defer SetRotation(DefaultRotation)
+	SetRotation(Rotation{MaxAge: time.Hour})
+
+	path := filepath.Join(t.TempDir(), "audit.log")
+	writeLog(t, path, 1, time.Now().Add(-2*time.Hour))
+	writeLog(t, path, 1, time.Now())
+
+	if archives, _ := archives(path); len(archives) != 1 {
+		t.Errorf("archives = %v, want one", archives)
+	}
+}
+
+func TestVerifyDetectsStrippedHash(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "audit.log")
+	writeLog(t, path, 3, time.Now())
+	data, _ := os.ReadFile(path)
+	lines := bytes.SplitAfter(data, []byte("\n"))
+
+	// The first entry edited, its prev and hash removed to pass as an
+	// entry from before the chain
+	var e Entry
+	if err := json.Unmarshal(lines[0], &e); err != nil {
+		t.Fatal(err)
+	}
+	e.Host, e.Prev, e.Hash = "evil.example.com", "", ""
+	forged, _ := json.Marshal(e)
+	os.WriteFile(path, bytes.Join([][]byte{forged, []byte("\n"), lines[1], lines[2]}, nil), 0o600)
+	if v, err := Verify(path); err != nil || v.OK() {
+		t.Errorf("stripped first entry: %+v, %v", v, err)
+	}
+
+	// An entry without a hash in a file after the first: rotation came
+	// with the chain, so it was stripped too
+	archive := filepath.Join(dir, "audit-"+time.Now().UTC().Format(archiveTime)+".log.gz")
+	f, err := os.Create(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := gzip.NewWriter(f)
+	zw.Write([]byte(`{"timestamp":"2025-01-01T00:00:00Z","profile":"old","protocol":"ssh","host":"h","action":"connect"}` + "\n"))
+	zw.Close()
+	f.Close()
+	os.WriteFile(path, append(forged, '\n'), 0o600)
+	if files, _ := logFiles(path); len(files) != 2 {
+		t.Fatalf("log files = %v, want the archive and the log", files)
+	}
+	if v, err := Verify(path); err != nil || v.OK() {
+		t.Errorf("unhashed entry after rotation: %+v, %v", v, err)
+	}
+}
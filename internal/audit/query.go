@@ -0,0 +1,82 @@
+package audit
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Filter selects audit entries; empty fields match everything
+type Filter struct {
+	Profile string
+	Host    string
+	Actions []string
+	Since   time.Time // Entries at or after
+	Until   time.Time // Entries before
+}
+
+// Match reports whether e passes the filter
+func (f Filter) Match(e Entry) bool {
+	switch {
+	case f.Profile != "" && e.Profile != f.Profile:
+		return false
+	case f.Host != "" && !strings.EqualFold(e.Host, f.Host):
+		return false
+	case len(f.Actions) > 0 && !slices.Contains(f.Actions, e.Action):
+		return false
+	case !f.Since.IsZero() && e.Timestamp.Before(f.Since):
+		return false
+	case !f.Until.IsZero() && !e.Timestamp.Before(f.Until):
+		return false
+	}
+	return true
+}
+
+// ReadEntries returns the last limit entries passing filter (all when
+// limit is 0), most recent first. It reads the log and then its archives
+// one line at a time, newest first, and stops once it has enough.
+func ReadEntries(filter Filter, limit int) ([]Entry, error) {
+	path, err := DefaultPath()
+	if err != nil {
+		return nil, err
+	}
+	files, err := logFiles(path)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []Entry{}
+	for i := len(files) - 1; i >= 0; i-- {
+		if !filter.Since.IsZero() && archivedBefore(files[i], filter.Since) {
+			break
+		}
+		var matched []Entry
+		err := scanFile(files[i], func(line []byte) error {
+			var e Entry
+			if len(line) == 0 || json.Unmarshal(line, &e) != nil {
+				return nil // Skip malformed lines
+			}
+			if filter.Match(e) {
+				matched = append(matched, e)
+				// Keep only the last ones this file can contribute
+				if limit > 0 && len(matched) > 2*limit {
+					matched = slices.Clone(matched[len(matched)-limit:])
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		if need := limit - len(entries); limit > 0 && len(matched) > need {
+			matched = matched[len(matched)-need:]
+		}
+		slices.Reverse(matched)
+		entries = append(entries, matched...)
+		if limit > 0 && len(entries) >= limit {
+			break
+		}
+	}
+	return entries, nil
+}
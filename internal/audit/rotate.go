@@ -0,0 +1,199 @@
+package audit
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Rotation controls when the log is moved into a compressed archive next
+// to it, audit-<time>.log.gz
+type Rotation struct {
+	MaxSize int64         // Rotate once the log is this large; 0 for no limit
+	MaxAge  time.Duration // Rotate once its first entry is this old; 0 for no limit
+	Keep    int           // Archives to keep; 0 keeps all
+}
+
+// DefaultRotation rotates monthly or at 10 MiB and keeps every archive
+var DefaultRotation = Rotation{MaxSize: 10 << 20, MaxAge: 30 * 24 * time.Hour}
+
+var rotation = DefaultRotation
+
+// SetRotation changes when the log is rotated, for the rest of the process
+func SetRotation(r Rotation) {
+	rotation = r
+}
+
+// archiveTime is the rotation time in archive names, in UTC
+const archiveTime = "20060102T150405.000Z"
+
+// rotate archives the log when it is due, and returns the hash of its last
+// entry to continue the chain with; "" when it did not rotate. The caller
+// holds the lock.
+func (l *Logger) rotate(now time.Time) (string, error) {
+	info, err := l.file.Stat()
+	if err != nil || info.Size() == 0 {
+		return "", err
+	}
+	due := rotation.MaxSize > 0 && info.Size() >= rotation.MaxSize
+	if !due && rotation.MaxAge > 0 {
+		first, err := firstTimestamp(l.path)
+		if err != nil {
+			return "", err
+		}
+		due = !first.IsZero() && now.Sub(first) >= rotation.MaxAge
+	}
+	if !due {
+		return "", nil
+	}
+
+	last, err := lastHash(l.path)
+	if err != nil {
+		return "", err
+	}
+	archive := filepath.Join(filepath.Dir(l.path), "audit-"+now.UTC().Format(archiveTime)+".log.gz")
+	if err := compress(l.path, archive); err != nil {
+		return "", err
+	}
+	l.file.Close()
+	l.file = nil
+	if err := os.Remove(l.path); err != nil {
+		return "", err
+	}
+	if err := l.reopen(); err != nil {
+		return "", err
+	}
+	pruneArchives(l.path, rotation.Keep)
+	return last, nil
+}
+
+// compress writes a gzip copy of src to dst, atomically
+func compress(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	tmp, err := os.CreateTemp(filepath.Dir(dst), ".audit-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	zw := gzip.NewWriter(tmp)
+	if _, err := io.Copy(zw, in); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0o600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), dst)
+}
+
+// pruneArchives removes all but the newest keep archives
+func pruneArchives(path string, keep int) {
+	if keep <= 0 {
+		return
+	}
+	archives, err := archives(path)
+	if err != nil || len(archives) <= keep {
+		return
+	}
+	for _, a := range archives[:len(archives)-keep] {
+		os.Remove(a)
+	}
+}
+
+// archives returns the archives of the log at path, oldest first
+func archives(path string) ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(filepath.Dir(path), "audit-*.log.gz"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(matches)
+	return matches, nil
+}
+
+// logFiles returns the archives and then the log itself
+func logFiles(path string) ([]string, error) {
+	files, err := archives(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(path); err == nil {
+		files = append(files, path)
+	}
+	return files, nil
+}
+
+// archivedBefore reports whether file is an archive rotated before t, so
+// that it holds no entries from t on
+func archivedBefore(file string, t time.Time) bool {
+	name := filepath.Base(file)
+	if !strings.HasPrefix(name, "audit-") || !strings.HasSuffix(name, ".log.gz") {
+		return false
+	}
+	rotated, err := time.Parse(archiveTime, strings.TrimSuffix(strings.TrimPrefix(name, "audit-"), ".log.gz"))
+	return err == nil && rotated.Before(t)
+}
+
+// scanFile calls fn with each line of a log or archive
+func scanFile(file string, fn func(line []byte) error) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if strings.HasSuffix(file, ".gz") {
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		r = zr
+	}
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		if err := fn(sc.Bytes()); err != nil {
+			return err
+		}
+	}
+	return sc.Err()
+}
+
+// firstTimestamp returns the time of the first entry of the log at path
+func firstTimestamp(path string) (time.Time, error) {
+	var first time.Time
+	err := scanFile(path, func(line []byte) error {
+		var e Entry
+		if json.Unmarshal(line, &e) == nil {
+			first = e.Timestamp
+			return io.EOF
+		}
+		return nil
+	})
+	if err == io.EOF {
+		err = nil
+	}
+	return first, err
+}
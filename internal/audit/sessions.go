@@ -0,0 +1,100 @@
+package audit
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+)
+
+// Session is a connection put together from its audit entries
+type Session struct {
+	ID         string        `json:"id,omitempty"`
+	Profile    string        `json:"profile"`
+	Protocol   string        `json:"protocol"`
+	Host       string        `json:"host"`
+	User       string        `json:"user,omitempty"`
+	Start      time.Time     `json:"start"`
+	End        time.Time     `json:"-"` // Zero while open, or when the end was not logged
+	Duration   time.Duration `json:"-"`
+	ExitCode   int           `json:"exitCode,omitempty"`
+	Error      string        `json:"error,omitempty"`
+	Reconnects int           `json:"reconnects,omitempty"`
+	Recording  string        `json:"recording,omitempty"`
+}
+
+// Open reports whether the session has no logged end
+func (s Session) Open() bool {
+	return s.End.IsZero()
+}
+
+// MarshalJSON writes Duration as a string, e.g. "1h2m3s", and leaves out
+// the end of open sessions
+func (s Session) MarshalJSON() ([]byte, error) {
+	type session Session
+	out := struct {
+		session
+		End      *time.Time `json:"end,omitempty"`
+		Duration string     `json:"duration,omitempty"`
+		Open     bool       `json:"open,omitempty"`
+	}{session: session(s), Open: s.Open()}
+	if !s.Open() {
+		out.End = &s.End
+		out.Duration = s.Duration.String()
+	}
+	return json.Marshal(out)
+}
+
+// Sessions pairs connect entries with the entries that follow them, most
+// recent session first. Entries carrying a session ID are paired by it;
+// older entries without one pair a disconnect with the earliest open
+// connect of the same profile. Entries may be in either order.
+func Sessions(entries []Entry) []Session {
+	sorted := append([]Entry(nil), entries...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Timestamp.Before(sorted[j].Timestamp) })
+
+	var sessions []*Session
+	byID := map[string]*Session{}
+	open := map[string][]*Session{} // Sessions without an ID, by profile
+	for _, e := range sorted {
+		if e.Action == "connect" {
+			s := &Session{ID: e.Session, Profile: e.Profile, Protocol: e.Protocol, Host: e.Host, User: e.User, Start: e.Timestamp}
+			sessions = append(sessions, s)
+			if e.Session != "" {
+				byID[e.Session] = s
+			} else {
+				open[e.Profile] = append(open[e.Profile], s)
+			}
+			continue
+		}
+
+		var s *Session
+		if e.Session != "" {
+			s = byID[e.Session]
+		} else if q := open[e.Profile]; len(q) > 0 {
+			s = q[0]
+			if e.Action == "disconnect" || e.Action == "error" {
+				open[e.Profile] = q[1:]
+			}
+		}
+		if s == nil {
+			continue // Its connect is outside the entries
+		}
+		switch e.Action {
+		case "disconnect", "error":
+			s.End = e.Timestamp
+			s.Duration = e.Timestamp.Sub(s.Start).Round(time.Second)
+			s.ExitCode = e.ExitCode
+			s.Error = e.Error
+		case "reconnect":
+			s.Reconnects++
+		case "record":
+			s.Recording = e.Recording
+		}
+	}
+
+	out := make([]Session, len(sessions))
+	for i, s := range sessions {
+		out[len(sessions)-1-i] = *s
+	}
+	return out
+}
@@ -0,0 +1,44 @@
+package audit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSessions(t *testing.T) {
+	t0 := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	at := func(m int) time.Time { return t0.Add(time.Duration(m) * time.Minute) }
+	entries := []Entry{
+		// Two overlapping sessions of one profile, told apart by ID
+		{Timestamp: at(0), Profile: "web", Action: "connect", Session: "a"},
+		{Timestamp: at(1), Profile: "web", Action: "connect", Session: "b"},
+		{Timestamp: at(1), Profile: "web", Action: "record", Session: "b", Recording: "/r/web.cast"},
+		{Timestamp: at(2), Profile: "web", Action: "disconnect", Session: "b", ExitCode: 1},
+		{Timestamp: at(5), Profile: "web", Action: "error", Session: "a", Error: "broken pipe"},
+		// Entries from before session IDs
+		{Timestamp: at(10), Profile: "db", Action: "connect"},
+		{Timestamp: at(12), Profile: "db", Action: "reconnect"},
+		{Timestamp: at(20), Profile: "db", Action: "disconnect"},
+		// Still open
+		{Timestamp: at(30), Profile: "db", Action: "connect", Session: "c"},
+		// Its connect is not in the entries
+		{Timestamp: at(31), Profile: "web", Action: "disconnect", Session: "z"},
+	}
+
+	got := Sessions(entries)
+	if len(got) != 4 {
+		t.Fatalf("got %d sessions, want 4: %+v", len(got), got)
+	}
+	if s := got[0]; s.ID != "c" || !s.Open() {
+		t.Errorf("latest session = %+v, want open c", s)
+	}
+	if s := got[1]; s.Profile != "db" || s.Duration != 10*time.Minute || s.Reconnects != 1 {
+		t.Errorf("db session = %+v", s)
+	}
+	if s := got[2]; s.ID != "b" || s.Duration != time.Minute || s.ExitCode != 1 || s.Recording != "/r/web.cast" {
+		t.Errorf("session b = %+v", s)
+	}
+	if s := got[3]; s.ID != "a" || s.Duration != 5*time.Minute || s.Error != "broken pipe" {
+		t.Errorf("session a = %+v", s)
+	}
+}
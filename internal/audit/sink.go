@@ -0,0 +1,389 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/vee-sh/veessh/internal/config"
+	"github.com/vee-sh/veessh/internal/util"
+)
+
+// Besides the local log, entries are shipped to the sinks configured under
+// audit.sinks: syslog, journald or a webhook. Shipping happens in the
+// background and never holds up logging, and so connecting: every sink has
+// a queue and a goroutine sending what is queued in batches, retrying a
+// failed batch a few times. Entries a sink cannot take, or that no longer
+// fit its queue, are spooled to a file next to the log and sent first by
+// the next veessh shipping to that sink, or by 'veessh audit ship'.
+//
+// Delivery is at least once: a batch that failed part way is sent again
+// whole. Receivers can tell repeats apart by the entries' hash.
+
+// Sink delivers audit entries somewhere besides the local log
+type Sink interface {
+	Name() string
+	Send(ctx context.Context, entries []Entry) error
+	Close() error
+}
+
+// NewSink returns the sink c configures
+func NewSink(c config.AuditSink) (Sink, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	switch c.Type {
+	case config.SinkSyslog:
+		return newSyslogSink(c), nil
+	case config.SinkJournald:
+		return newJournaldSink(c), nil
+	default:
+		return newWebhookSink(c), nil
+	}
+}
+
+const (
+	queueSize    = 1000
+	maxBatch     = 100
+	sendTimeout  = 10 * time.Second
+	sendAttempts = 3
+)
+
+// retryWait is the wait before the first retry, doubling for later ones
+var retryWait = 500 * time.Millisecond
+
+// aLongTimeAgo is a deadline in the past, to interrupt a blocked write
+var aLongTimeAgo = time.Unix(1, 0)
+
+type shipper struct {
+	sink   Sink
+	spool  string // "" when entries that cannot be sent are dropped
+	queue  chan Entry
+	ctx    context.Context // Cancelled when Flush gives up waiting
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+var (
+	shipMu   sync.Mutex
+	shippers []*shipper // Taking new entries
+	started  []*shipper // All not yet flushed
+)
+
+// SetSinks ships the entries logged from now on to sinks, for the rest of
+// the process. Call Flush before exiting.
+func SetSinks(sinks []Sink) {
+	dir := ""
+	if path, err := DefaultPath(); err == nil {
+		dir = spoolDir(path)
+	}
+	setSinks(dir, sinks)
+}
+
+func setSinks(dir string, sinks []Sink) {
+	shipMu.Lock()
+	defer shipMu.Unlock()
+	for _, s := range shippers {
+		close(s.queue)
+	}
+	shippers = nil
+	for _, sink := range sinks {
+		s := &shipper{sink: sink, queue: make(chan Entry, queueSize), done: make(chan struct{})}
+		if dir != "" {
+			s.spool = spoolFile(dir, sink.Name())
+		}
+		s.ctx, s.cancel = context.WithCancel(context.Background())
+		shippers = append(shippers, s)
+		started = append(started, s)
+		go s.run()
+	}
+}
+
+// ship queues e for every sink
+func ship(e Entry) {
+	shipMu.Lock()
+	defer shipMu.Unlock()
+	for _, s := range shippers {
+		select {
+		case s.queue <- e:
+		default:
+			// The sink is falling behind
+			s.keep([]Entry{e})
+		}
+	}
+}
+
+// Flush waits up to timeout for the sinks to deliver the entries queued so
+// far, then gives up and spools what is left for next time. Sinks take no
+// entries after it.
+func Flush(timeout time.Duration) {
+	shipMu.Lock()
+	for _, s := range shippers {
+		close(s.queue)
+	}
+	all := started
+	shippers, started = nil, nil
+	shipMu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		for _, s := range all {
+			<-s.done
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		for _, s := range all {
+			s.cancel()
+		}
+		<-done
+	}
+}
+
+func (s *shipper) run() {
+	defer close(s.done)
+	defer s.sink.Close()
+
+	// What earlier processes could not send goes first
+	if pending, err := takeSpool(s.spool); err == nil && len(pending) > 0 {
+		s.deliver(pending)
+	}
+	for e := range s.queue {
+		batch := []Entry{e}
+	more:
+		for len(batch) < maxBatch {
+			select {
+			case e, ok := <-s.queue:
+				if !ok {
+					break more
+				}
+				batch = append(batch, e)
+			default:
+				break more
+			}
+		}
+		s.deliver(batch)
+	}
+}
+
+// deliver sends entries in batches and spools them from the first batch
+// that could not be sent on
+func (s *shipper) deliver(entries []Entry) {
+	for len(entries) > 0 {
+		batch := entries[:min(len(entries), maxBatch)]
+		if s.send(batch) != nil {
+			s.keep(entries)
+			return
+		}
+		entries = entries[len(batch):]
+	}
+}
+
+// send sends a batch, retrying with growing waits
+func (s *shipper) send(batch []Entry) error {
+	wait := retryWait
+	for attempt := 1; ; attempt++ {
+		if err := s.ctx.Err(); err != nil {
+			return err
+		}
+		ctx, cancel := context.WithTimeout(s.ctx, sendTimeout)
+		err := s.sink.Send(ctx, batch)
+		cancel()
+		if err == nil || attempt == sendAttempts {
+			return err
+		}
+		select {
+		case <-time.After(wait):
+		case <-s.ctx.Done():
+			return err
+		}
+		wait *= 2
+	}
+}
+
+// keep spools entries to send later
+func (s *shipper) keep(entries []Entry) {
+	if s.spool != "" {
+		appendSpool(s.spool, entries)
+	}
+}
+
+// Pending returns how many entries are spooled for sink
+func Pending(sink Sink) (int, error) {
+	file, err := spoolOf(sink)
+	if err != nil {
+		return 0, err
+	}
+	lock, err := util.Lock(file, false)
+	if err != nil {
+		return 0, err
+	}
+	defer lock.Unlock()
+	entries, err := readSpool(file)
+	return len(entries), err
+}
+
+// Ship sends the entries spooled for sink now, without retrying, and
+// returns how many it sent. Entries it could not send stay spooled.
+func Ship(ctx context.Context, sink Sink) (int, error) {
+	file, err := spoolOf(sink)
+	if err != nil {
+		return 0, err
+	}
+	entries, err := takeSpool(file)
+	if err != nil {
+		return 0, err
+	}
+	sent := 0
+	for sent < len(entries) {
+		batch := entries[sent:min(len(entries), sent+maxBatch)]
+		if err := sink.Send(ctx, batch); err != nil {
+			return sent, errors.Join(err, appendSpool(file, entries[sent:]))
+		}
+		sent += len(batch)
+	}
+	return sent, nil
+}
+
+func spoolOf(sink Sink) (string, error) {
+	path, err := DefaultPath()
+	if err != nil {
+		return "", err
+	}
+	return spoolFile(spoolDir(path), sink.Name()), nil
+}
+
+// spoolDir returns the directory of the spools of the log at path
+func spoolDir(path string) string {
+	return filepath.Join(filepath.Dir(path), "spool")
+}
+
+var unsafeName = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// spoolFile returns the spool of the sink called name
+func spoolFile(dir, name string) string {
+	return filepath.Join(dir, unsafeName.ReplaceAllString(name, "_")+".jsonl")
+}
+
+// appendSpool adds entries to the spool file
+func appendSpool(file string, entries []Entry) error {
+	lock, err := util.Lock(file, true)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+	f, err := os.OpenFile(file, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, e := range entries {
+		if err := enc.Encode(e); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// takeSpool removes the spool file and returns its entries
+func takeSpool(file string) ([]Entry, error) {
+	if file == "" {
+		return nil, nil
+	}
+	lock, err := util.Lock(file, true)
+	if err != nil {
+		return nil, err
+	}
+	defer lock.Unlock()
+	entries, err := readSpool(file)
+	if err != nil || len(entries) == 0 {
+		return nil, err
+	}
+	return entries, os.Remove(file)
+}
+
+func readSpool(file string) ([]Entry, error) {
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entries []Entry
+	for _, line := range splitLines(data) {
+		var e Entry
+		if len(bytes.TrimSpace(line)) == 0 || json.Unmarshal(line, &e) != nil {
+			continue // Skip malformed lines
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// fields returns the non-empty fields of e other than its time and action,
+// keyed as in its JSON
+func fields(e Entry) [][2]string {
+	var out [][2]string
+	add := func(key, value string) {
+		if value != "" {
+			out = append(out, [2]string{key, value})
+		}
+	}
+	add("profile", e.Profile)
+	add("protocol", e.Protocol)
+	add("host", e.Host)
+	add("user", e.User)
+	add("duration", e.Duration)
+	if e.ExitCode != 0 {
+		add("exitCode", fmt.Sprint(e.ExitCode))
+	}
+	add("error", e.Error)
+	if e.Attempt != 0 {
+		add("attempt", fmt.Sprint(e.Attempt))
+	}
+	add("recording", e.Recording)
+	add("session", e.Session)
+	add("prev", e.Prev)
+	add("hash", e.Hash)
+	return out
+}
+
+// summary describes e in a line, for sinks that show a message
+func summary(e Entry) string {
+	switch e.Action {
+	case "connect":
+		target := e.Host
+		if e.User != "" {
+			target = e.User + "@" + e.Host
+		}
+		return fmt.Sprintf("connect %s -> %s (%s)", e.Profile, target, e.Protocol)
+	case "disconnect":
+		return fmt.Sprintf("disconnect %s (duration: %s, exit: %d)", e.Profile, e.Duration, e.ExitCode)
+	case "error":
+		return fmt.Sprintf("error %s: %s", e.Profile, e.Error)
+	case "reconnect":
+		return fmt.Sprintf("reconnect %s: %s (attempt %d)", e.Profile, e.Error, e.Attempt)
+	case "record":
+		return fmt.Sprintf("record %s -> %s", e.Profile, e.Recording)
+	case "hostkey":
+		return fmt.Sprintf("hostkey %s: %s", e.Profile, e.Error)
+	}
+	return fmt.Sprintf("%s %s", e.Action, e.Profile)
+}
@@ -0,0 +1,106 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"net"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/vee-sh/veessh/internal/config"
+)
+
+// journaldSocket is where journald takes entries in its native protocol
+const journaldSocket = "/run/systemd/journal/socket"
+
+// journaldSink sends entries to journald as native protocol datagrams,
+// with the entry's fields as VEESSH_* journal fields
+type journaldSink struct {
+	name    string
+	address string
+	conn    net.Conn
+}
+
+func newJournaldSink(c config.AuditSink) *journaldSink {
+	s := &journaldSink{name: c.Label(), address: c.Address}
+	if s.address == "" {
+		s.address = journaldSocket
+	}
+	return s
+}
+
+func (s *journaldSink) Name() string { return s.name }
+
+func (s *journaldSink) Send(ctx context.Context, entries []Entry) error {
+	if s.conn == nil {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "unixgram", s.address)
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+	}
+	conn := s.conn
+	stop := context.AfterFunc(ctx, func() { conn.SetWriteDeadline(aLongTimeAgo) })
+	defer stop()
+	for _, e := range entries {
+		if _, err := conn.Write(journaldMessage(e)); err != nil {
+			s.Close()
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *journaldSink) Close() error {
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// journaldMessage formats e in journald's native protocol: KEY=value lines,
+// or for values with newlines the key, a newline, the value's length as a
+// little endian uint64, and the value
+func journaldMessage(e Entry) []byte {
+	var b bytes.Buffer
+	field := func(key, value string) {
+		if !strings.Contains(value, "\n") {
+			b.WriteString(key + "=" + value + "\n")
+			return
+		}
+		b.WriteString(key + "\n")
+		binary.Write(&b, binary.LittleEndian, uint64(len(value)))
+		b.WriteString(value + "\n")
+	}
+	field("MESSAGE", summary(e))
+	field("PRIORITY", strconv.Itoa(severity(e)))
+	field("SYSLOG_FACILITY", strconv.Itoa(facilityAuthpriv))
+	field("SYSLOG_IDENTIFIER", "veessh")
+	field("VEESSH_ACTION", e.Action)
+	field("VEESSH_TIMESTAMP", e.Timestamp.UTC().Format("2006-01-02T15:04:05.000000Z07:00"))
+	for _, f := range fields(e) {
+		field("VEESSH_"+journaldKey(f[0]), f[1])
+	}
+	return b.Bytes()
+}
+
+// journaldKey turns a JSON key into a journal field name: exitCode becomes
+// EXIT_CODE
+func journaldKey(key string) string {
+	var b strings.Builder
+	for i, r := range key {
+		if unicode.IsUpper(r) && i > 0 {
+			b.WriteByte('_')
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+	return b.String()
+}
@@ -0,0 +1,256 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vee-sh/veessh/internal/config"
+)
+
+var testEntry = Entry{
+	Timestamp: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC),
+	Profile:   "web",
+	Protocol:  "ssh",
+	Host:      "web.example.com",
+	User:      "alice",
+	Action:    "error",
+	Error:     "exit status 255 [\"denied\"]\nretry later",
+	Session:   "0123456789abcdef",
+}
+
+func newTestSink(t *testing.T, c config.AuditSink) Sink {
+	t.Helper()
+	sink, err := NewSink(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sink.Close() })
+	return sink
+}
+
+func TestSyslogUDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	sink := newTestSink(t, config.AuditSink{Type: config.SinkSyslog, Address: pc.LocalAddr().String()})
+	if err := sink.Send(context.Background(), []Entry{testEntry}); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 4096)
+	pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := pc.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := string(buf[:n])
+	for _, want := range []string{
+		"<84>1 2026-03-01T12:00:00.000000Z ",
+		" veessh ",
+		` error [veessh@32473 profile="web" protocol="ssh" host="web.example.com" user="alice" error="exit status 255 [\"denied\"\]` + "\nretry later\"",
+		` session="0123456789abcdef"] error web: exit status 255`,
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("message %q does not contain %q", msg, want)
+		}
+	}
+}
+
+func TestSyslogTCPFraming(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	sink := newTestSink(t, config.AuditSink{Type: config.SinkSyslog, Network: "tcp", Address: ln.Addr().String()})
+	second := testEntry
+	second.Action, second.Error = "connect", ""
+	if err := sink.Send(context.Background(), []Entry{testEntry, second}); err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	r := bufio.NewReader(conn)
+	for _, want := range []string{"<84>1 ", "<86>1 "} {
+		var n int
+		if _, err := fmt.Fscan(r, &n); err != nil {
+			t.Fatal(err)
+		}
+		r.ReadByte() // The space after the length
+		msg := make([]byte, n)
+		if _, err := io.ReadFull(r, msg); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(string(msg), want) {
+			t.Errorf("message %q, want prefix %q", msg, want)
+		}
+	}
+}
+
+func TestJournald(t *testing.T) {
+	// Socket paths are short; TempDir may be too long
+	dir, err := os.MkdirTemp("", "vj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "s")
+	pc, err := net.ListenPacket("unixgram", socket)
+	if err != nil {
+		t.Skip("no unixgram sockets:", err)
+	}
+	defer pc.Close()
+	sink := newTestSink(t, config.AuditSink{Type: config.SinkJournald, Address: socket})
+	if err := sink.Send(context.Background(), []Entry{testEntry}); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 4096)
+	pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := pc.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := string(buf[:n])
+	value := "exit status 255 [\"denied\"]\nretry later"
+	for _, want := range []string{
+		"PRIORITY=4\n",
+		"SYSLOG_IDENTIFIER=veessh\n",
+		"VEESSH_PROFILE=web\n",
+		"VEESSH_SESSION=0123456789abcdef\n",
+		"VEESSH_ERROR\n" + string([]byte{byte(len(value)), 0, 0, 0, 0, 0, 0, 0}) + value + "\n",
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("message %q does not contain %q", msg, want)
+		}
+	}
+}
+
+// webhook is a test endpoint that fails while down is set
+type webhook struct {
+	mu      sync.Mutex
+	down    bool
+	entries []Entry
+	auth    string
+}
+
+func (w *webhook) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.auth = r.Header.Get("Authorization")
+	if w.down {
+		http.Error(rw, "down", http.StatusServiceUnavailable)
+		return
+	}
+	var batch []Entry
+	if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.entries = append(w.entries, batch...)
+}
+
+func (w *webhook) received() []Entry {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]Entry(nil), w.entries...)
+}
+
+func TestWebhookShipsAndSpools(t *testing.T) {
+	defer func(d time.Duration) { retryWait = d }(retryWait)
+	retryWait = time.Millisecond
+	t.Setenv("AUDIT_TOKEN", "s3cret")
+
+	hook := &webhook{down: true}
+	server := httptest.NewServer(hook)
+	defer server.Close()
+	newSink := func() Sink {
+		return newTestSink(t, config.AuditSink{Name: "central", Type: config.SinkWebhook, URL: server.URL, BatchSize: 2, Headers: map[string]string{"Authorization": "Bearer $AUDIT_TOKEN"}})
+	}
+
+	dir := t.TempDir()
+	spool := filepath.Join(dir, "spool")
+	path := filepath.Join(dir, "audit.log")
+
+	// While the endpoint is down, entries end up in the spool
+	setSinks(spool, []Sink{newSink()})
+	writeLog(t, path, 3, time.Now())
+	Flush(5 * time.Second)
+	if got := hook.received(); len(got) != 0 {
+		t.Fatalf("received %d entries while down", len(got))
+	}
+	pending, err := readSpool(spoolFile(spool, "central"))
+	if err != nil || len(pending) != 3 {
+		t.Fatalf("spooled %d entries (%v), want 3", len(pending), err)
+	}
+
+	// Once it is back they are sent first, in order, with the chain
+	hook.mu.Lock()
+	hook.down = false
+	hook.mu.Unlock()
+	setSinks(spool, []Sink{newSink()})
+	writeLog(t, path, 1, time.Now().Add(time.Hour))
+	Flush(5 * time.Second)
+	got := hook.received()
+	if len(got) != 4 {
+		t.Fatalf("received %d entries, want 4", len(got))
+	}
+	for i := 1; i < len(got); i++ {
+		if got[i].Prev != got[i-1].Hash || got[i].Hash == "" {
+			t.Errorf("entry %d does not follow entry %d in the chain", i, i-1)
+		}
+	}
+	if hook.auth != "Bearer s3cret" {
+		t.Errorf("Authorization = %q", hook.auth)
+	}
+	if _, err := os.Stat(spoolFile(spool, "central")); !os.IsNotExist(err) {
+		t.Errorf("spool left behind: %v", err)
+	}
+}
+
+// stuckSink never answers until its context ends
+type stuckSink struct{}
+
+func (stuckSink) Name() string { return "stuck" }
+func (stuckSink) Close() error { return nil }
+func (stuckSink) Send(ctx context.Context, entries []Entry) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestFlushDoesNotWaitForStuckSinks(t *testing.T) {
+	dir := t.TempDir()
+	spool := filepath.Join(dir, "spool")
+	setSinks(spool, []Sink{stuckSink{}})
+	start := time.Now()
+	writeLog(t, filepath.Join(dir, "audit.log"), 2, start)
+	if time.Since(start) > time.Second {
+		t.Errorf("logging took %s with a stuck sink", time.Since(start))
+	}
+	Flush(100 * time.Millisecond)
+	if time.Since(start) > 2*time.Second {
+		t.Errorf("Flush took %s", time.Since(start))
+	}
+	if pending, _ := readSpool(spoolFile(spool, "stuck")); len(pending) != 2 {
+		t.Errorf("spooled %d entries, want 2", len(pending))
+	}
+}
@@ -0,0 +1,81 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/vee-sh/veessh/internal/config"
+	"github.com/vee-sh/veessh/internal/version"
+)
+
+// defaultBatchSize is how many entries a webhook request carries
+const defaultBatchSize = 50
+
+// webhookSink POSTs entries to a URL as a JSON array
+type webhookSink struct {
+	name    string
+	url     string
+	headers map[string]string
+	batch   int
+	client  *http.Client
+}
+
+func newWebhookSink(c config.AuditSink) *webhookSink {
+	s := &webhookSink{name: c.Label(), url: c.URL, headers: map[string]string{}, batch: c.BatchSize, client: &http.Client{}}
+	if s.batch == 0 {
+		s.batch = defaultBatchSize
+	}
+	for k, v := range c.Headers {
+		s.headers[k] = os.ExpandEnv(v)
+	}
+	return s
+}
+
+func (s *webhookSink) Name() string { return s.name }
+
+func (s *webhookSink) Send(ctx context.Context, entries []Entry) error {
+	for len(entries) > 0 {
+		n := min(len(entries), s.batch)
+		if err := s.post(ctx, entries[:n]); err != nil {
+			return err
+		}
+		entries = entries[n:]
+	}
+	return nil
+}
+
+func (s *webhookSink) post(ctx context.Context, entries []Entry) error {
+	body, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "veessh/"+version.Version)
+	for k, v := range s.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s: %s", s.url, resp.Status)
+	}
+	return nil
+}
+
+func (s *webhookSink) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
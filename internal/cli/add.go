@@ -20,6 +20,7 @@ var (
 	addUser           string
 	addIdentity       string
 	addUseAgent       bool
+	addForwardAgent   bool
 	addExtra          []string
 	addGroup          string
 	addDesc           string
@@ -36,7 +37,10 @@ var (
 	addGCPProject     string
 	addGCPZone        string
 	addGCPTunnel      bool
-	addExtends        string
+	addExtends        []string
+	addMerge          map[string]string
+	addSSHClient      string
+	addHostKeyPolicy  string
 )
 
 var cmdAdd = &cobra.Command{
@@ -72,9 +76,15 @@ Examples:
   # GCP Compute Engine
   veessh add gce-web --type gcloud --host my-vm --gcp-project myproject --gcp-zone us-central1-a
 
+  # In-process SSH client (no OpenSSH or sshpass needed)
+  veessh add legacy --host legacy.example.com --user root --ssh-client native --ask-password
+
   # Profile inheritance (inherit from template)
   veessh add prod-template --host example.com --user deploy --identity ~/.ssh/deploy_key
-  veessh add prod-web --extends prod-template --host web.example.com`,
+  veessh add prod-web --extends prod-template --host web.example.com
+
+  # Mixins: later parents override earlier ones
+  veessh add prod-db --extends base-ssh,prod-bastion --host db.example.com`,
 	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		name := args[0]
@@ -93,7 +103,8 @@ Examples:
 			Port:            addPort,
 			Username:        addUser,
 			IdentityFile:    addIdentity,
-			UseAgent:        addUseAgent,
+			UseAgent:        optionalBool(cmd, "agent", addUseAgent, len(addExtends) == 0),
+			ForwardAgent:    optionalBool(cmd, "forward-agent", addForwardAgent, false),
 			ExtraArgs:       addExtra,
 			Group:           addGroup,
 			Description:     addDesc,
@@ -108,9 +119,12 @@ Examples:
 			DynamicForwards: addDynamicForward,
 			GCPProject:      addGCPProject,
 			GCPZone:         addGCPZone,
-			GCPUseTunnel:    addGCPTunnel,
-			Extends:         addExtends,
+			GCPUseTunnel:    optionalBool(cmd, "gcp-tunnel", addGCPTunnel, false),
+			Extends:         config.Parents(addExtends),
+			SSHClient:       addSSHClient,
+			HostKeyPolicy:   addHostKeyPolicy,
 		}
+		p.Merge = parseMergeFlag(addMerge)
 		if err := (&p).Validate(); err != nil {
 			return err
 		}
@@ -141,6 +155,7 @@ func init() {
 	cmdAdd.Flags().StringVar(&addUser, "user", "", "username")
 	cmdAdd.Flags().StringVar(&addIdentity, "identity", "", "path to identity (private key) file")
 	cmdAdd.Flags().BoolVar(&addUseAgent, "agent", true, "use SSH agent if available")
+	cmdAdd.Flags().BoolVar(&addForwardAgent, "forward-agent", false, "forward the SSH agent to the host")
 	cmdAdd.Flags().StringSliceVar(&addExtra, "extra", nil, "extra args to pass to the client (repeatable)")
 	cmdAdd.Flags().StringVar(&addGroup, "group", "", "group name for organizing profiles")
 	cmdAdd.Flags().StringVar(&addDesc, "desc", "", "description")
@@ -166,8 +181,13 @@ func init() {
 	cmdAdd.Flags().StringVar(&addGCPZone, "gcp-zone", "", "GCP zone (for gcloud)")
 	cmdAdd.Flags().BoolVar(&addGCPTunnel, "gcp-tunnel", false, "use IAP tunnel (for gcloud)")
 
+	// SSH client selection
+	cmdAdd.Flags().StringVar(&addSSHClient, "ssh-client", "", "SSH client: openssh|native (default: openssh if installed)")
+	cmdAdd.Flags().StringVar(&addHostKeyPolicy, "host-key-policy", "", "on a host key that does not match its pin: strict|warn|off (default: the config's)")
+
 	// Profile inheritance
-	cmdAdd.Flags().StringVar(&addExtends, "extends", "", "inherit from another profile")
+	cmdAdd.Flags().StringSliceVar(&addExtends, "extends", nil, "inherit from other profiles, applied in order (e.g. base-ssh,prod-bastion)")
+	cmdAdd.Flags().StringToStringVar(&addMerge, "merge", nil, "how inherited lists combine, e.g. tags=append,localForwards=remove (append|replace|remove)")
 }
 
 func promptPassword(prompt string) (string, error) {
@@ -181,6 +201,28 @@ func promptPassword(prompt string) (string, error) {
 	return strings.TrimSpace(string(bytePassword)), nil
 }
 
+// optionalBool returns the value of a boolean flag for a tri-state profile
+// field: set when the user gave the flag (or always is true), nil to inherit
+func optionalBool(cmd *cobra.Command, name string, value, always bool) *bool {
+	if always || cmd.Flags().Changed(name) {
+		return config.Bool(value)
+	}
+	return nil
+}
+
+// parseMergeFlag converts --merge field=mode pairs into merge directives
+// (checked by Profile.Validate)
+func parseMergeFlag(m map[string]string) map[string]config.MergeMode {
+	if len(m) == 0 {
+		return nil
+	}
+	out := map[string]config.MergeMode{}
+	for field, mode := range m {
+		out[field] = config.MergeMode(strings.ToLower(mode))
+	}
+	return out
+}
+
 func portString(p int) string {
 	if p <= 0 {
 		return "default"
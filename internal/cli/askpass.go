@@ -0,0 +1,71 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+
+	"github.com/vee-sh/veessh/internal/connectors"
+	"github.com/vee-sh/veessh/internal/credentials"
+)
+
+var cmdAskPass = &cobra.Command{
+	Use:    "askpass [prompt]",
+	Short:  "SSH_ASKPASS helper that answers prompts from stored secrets",
+	Hidden: true,
+	Args:   cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		prompt := ""
+		if len(args) > 0 {
+			prompt = args[0]
+		}
+
+		if name := os.Getenv(connectors.AskPassProfileEnv); name != "" {
+			if secrets, err := credentials.GetSecrets(name); err == nil {
+				target := os.Getenv(connectors.AskPassTargetEnv)
+				if answer, err := secrets.AnswerPrompt(prompt, target); err == nil {
+					fmt.Println(answer)
+					return nil
+				}
+			}
+		}
+
+		// Anything we can't answer (host key confirmations, unknown
+		// questions, prompts of jump hosts) is asked on the terminal
+		answer, err := promptTTY(prompt)
+		if err != nil {
+			return err
+		}
+		fmt.Println(answer)
+		return nil
+	},
+}
+
+// promptTTY asks on the controlling terminal, hiding the input unless the
+// prompt is a yes/no question
+func promptTTY(prompt string) (string, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return "", fmt.Errorf("no terminal to ask %q: %w", strings.TrimSpace(prompt), err)
+	}
+	defer tty.Close()
+
+	fmt.Fprint(tty, prompt)
+	if strings.Contains(prompt, "yes/no") {
+		line, err := bufio.NewReader(tty).ReadString('\n')
+		if err != nil && line == "" {
+			return "", err
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+	b, err := term.ReadPassword(int(tty.Fd()))
+	fmt.Fprintln(tty)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
@@ -1,15 +1,27 @@
 package cli
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"os"
+	"time"
 
 	"github.com/spf13/cobra"
 
 	"github.com/vee-sh/veessh/internal/audit"
+	"github.com/vee-sh/veessh/internal/config"
 )
 
-var auditLimit int
+var (
+	auditLimit    int
+	auditProfile  string
+	auditHost     string
+	auditActions  []string
+	auditSince    string
+	auditUntil    string
+	auditSessions bool
+)
 
 var cmdAudit = &cobra.Command{
 	Use:   "audit",
@@ -21,20 +33,39 @@ The audit log records:
   - Disconnection times and duration
   - Profile, host, and user
   - Exit codes and errors
+  - Restarts of reconnecting tunnels
+  - Where recorded sessions are saved
+  - Host keys that did not match their pin
+
+The log is rotated into compressed archives next to it (monthly or at
+10 MiB; see audit: in the config), which are searched too. Its entries
+are hash chained; 'veessh audit verify' checks the chain. Entries can also
+be shipped to syslog, journald or a webhook (audit.sinks in the config);
+'veessh audit ship' sends what they could not take yet.
+
+--since and --until take a date (2006-01-02), a time (RFC 3339) or an age
+such as 12h or 7d.
 
 Examples:
-  veessh audit             # Show last 50 entries
-  veessh audit -n 10       # Show last 10 entries
-  veessh audit --json      # JSON output`,
+  veessh audit                         # Show last 50 entries
+  veessh audit -n 10                   # Show last 10 entries
+  veessh audit --profile web --since 7d
+  veessh audit --action error,reconnect
+  veessh audit --sessions              # Connections with their duration
+  veessh audit --json                  # JSON output`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		entries, err := audit.ReadEntries(auditLimit)
+		filter, err := auditFilter()
 		if err != nil {
 			return err
 		}
 
-		if len(entries) == 0 {
-			fmt.Println("No audit entries found.")
-			return nil
+		if auditSessions {
+			return showAuditSessions(cmd, filter)
+		}
+
+		entries, err := audit.ReadEntries(filter, auditLimit)
+		if err != nil {
+			return err
 		}
 
 		if OutputJSON() {
@@ -43,6 +74,11 @@ Examples:
 			return enc.Encode(entries)
 		}
 
+		if len(entries) == 0 {
+			fmt.Println("No audit entries found.")
+			return nil
+		}
+
 		fmt.Println("Connection Audit Log:")
 		fmt.Println()
 		for _, e := range entries {
@@ -54,6 +90,12 @@ Examples:
 				fmt.Printf("  %s  [DISCONNECT]  %s (duration: %s, exit: %d)\n", ts, e.Profile, e.Duration, e.ExitCode)
 			case "error":
 				fmt.Printf("  %s  [ERROR]       %s: %s\n", ts, e.Profile, e.Error)
+			case "reconnect":
+				fmt.Printf("  %s  [RECONNECT]   %s: %s (attempt %d)\n", ts, e.Profile, e.Error, e.Attempt)
+			case "record":
+				fmt.Printf("  %s  [RECORD]      %s -> %s\n", ts, e.Profile, e.Recording)
+			case "hostkey":
+				fmt.Printf("  %s  [HOSTKEY]     %s: %s\n", ts, e.Profile, e.Error)
 			}
 		}
 
@@ -61,7 +103,304 @@ Examples:
 	},
 }
 
-func init() {
-	cmdAudit.Flags().IntVarP(&auditLimit, "limit", "n", 50, "number of entries to show")
+// showAuditSessions pairs the entries into sessions. The action filter
+// applies to how sessions ended: error, disconnect, or open.
+func showAuditSessions(cmd *cobra.Command, filter audit.Filter) error {
+	ended := filter.Actions
+	filter.Actions = nil
+	entries, err := audit.ReadEntries(filter, 0)
+	if err != nil {
+		return err
+	}
+	var sessions []audit.Session
+	for _, s := range audit.Sessions(entries) {
+		if len(ended) > 0 && !sessionEndedWith(s, ended) {
+			continue
+		}
+		sessions = append(sessions, s)
+		if auditLimit > 0 && len(sessions) == auditLimit {
+			break
+		}
+	}
+
+	if OutputJSON() {
+		if sessions == nil {
+			sessions = []audit.Session{}
+		}
+		enc := json.NewEncoder(cmd.OutOrStdout())
+		enc.SetIndent("", "  ")
+		return enc.Encode(sessions)
+	}
+
+	if len(sessions) == 0 {
+		fmt.Fprintln(cmd.OutOrStdout(), "No sessions found.")
+		return nil
+	}
+	out := cmd.OutOrStdout()
+	fmt.Fprintf(out, "%-19s  %-20s  %-30s  %-10s  %s\n", "STARTED", "PROFILE", "HOST", "DURATION", "RESULT")
+	for _, s := range sessions {
+		duration, result := s.Duration.String(), fmt.Sprintf("exit %d", s.ExitCode)
+		switch {
+		case s.Open():
+			duration, result = "-", "open"
+		case s.Error != "":
+			result = s.Error
+		}
+		if s.Reconnects > 0 {
+			result += fmt.Sprintf(", %d reconnects", s.Reconnects)
+		}
+		if s.Recording != "" {
+			result += ", recorded"
+		}
+		fmt.Fprintf(out, "%-19s  %-20s  %-30s  %-10s  %s\n", s.Start.Format("2006-01-02 15:04:05"), s.Profile, s.Host, duration, result)
+	}
+	return nil
 }
 
+func sessionEndedWith(s audit.Session, actions []string) bool {
+	end := "disconnect"
+	switch {
+	case s.Open():
+		end = "open"
+	case s.Error != "":
+		end = "error"
+	}
+	for _, a := range actions {
+		if a == end {
+			return true
+		}
+	}
+	return false
+}
+
+// auditFilter builds the filter of the audit flags
+func auditFilter() (audit.Filter, error) {
+	filter := audit.Filter{Profile: auditProfile, Host: auditHost, Actions: auditActions}
+	var err error
+	if auditSince != "" {
+		if filter.Since, err = parseAuditTime(auditSince); err != nil {
+			return filter, fmt.Errorf("--since: %w", err)
+		}
+	}
+	if auditUntil != "" {
+		if filter.Until, err = parseAuditTime(auditUntil); err != nil {
+			return filter, fmt.Errorf("--until: %w", err)
+		}
+	}
+	return filter, nil
+}
+
+// parseAuditTime parses a date, an RFC 3339 time, or an age before now
+func parseAuditTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, s, time.Local); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation(time.DateTime, s, time.Local); err == nil {
+		return t, nil
+	}
+	age, err := parseAge(s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q (use e.g. 2006-01-02, 2006-01-02T15:04:05Z or 7d)", s)
+	}
+	return time.Now().Add(-age), nil
+}
+
+var cmdAuditVerify = &cobra.Command{
+	Use:   "verify",
+	Short: "Check the audit log's hash chain",
+	Long: `Check that no audit entry was edited, removed, inserted or reordered.
+
+Every entry carries the hash of the entry before it, across rotated
+archives. Entries written before veessh chained them are counted but
+cannot be checked, and the chain may start after archives pruned with
+audit.keep.
+
+The chain is not signed: someone who can write the log can rewrite it
+from an edit onwards. Compare the reported head hash with one noted
+earlier to catch that.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path, err := audit.DefaultPath()
+		if err != nil {
+			return err
+		}
+		v, err := audit.Verify(path)
+		if err != nil {
+			return err
+		}
+
+		if OutputJSON() {
+			enc := json.NewEncoder(cmd.OutOrStdout())
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(v); err != nil {
+				return err
+			}
+		} else {
+			out := cmd.OutOrStdout()
+			for _, p := range v.Problems {
+				fmt.Fprintf(out, "%s\n", p)
+			}
+			fmt.Fprintf(out, "Checked %d entries in %d file(s)", v.Entries, v.Files)
+			if v.Unhashed > 0 {
+				fmt.Fprintf(out, ", %d from before the chain", v.Unhashed)
+			}
+			fmt.Fprintln(out)
+			if v.Head != "" {
+				fmt.Fprintf(out, "Head: %s\n", v.Head)
+			}
+		}
+		if !v.OK() {
+			return fmt.Errorf("audit log chain is broken in %d place(s)", len(v.Problems))
+		}
+		return nil
+	},
+}
+
+// configureAudit applies the audit: section of the config to the audit log
+// and starts shipping entries to its sinks
+func configureAudit(cfg config.Config) {
+	r := audit.DefaultRotation
+	if a := cfg.Audit; a != nil {
+		if a.MaxSizeMB > 0 {
+			r.MaxSize = int64(a.MaxSizeMB) << 20
+		}
+		if a.MaxAgeDays > 0 {
+			r.MaxAge = time.Duration(a.MaxAgeDays) * 24 * time.Hour
+		}
+		r.Keep = a.Keep
+	}
+	audit.SetRotation(r)
+	if sinks := auditSinks(cfg); len(sinks) > 0 {
+		audit.SetSinks(sinks)
+	}
+}
+
+// auditSinks builds the sinks of the config. One that is misconfigured is
+// left out with a warning; it must not keep anyone from connecting.
+func auditSinks(cfg config.Config) []audit.Sink {
+	if cfg.Audit == nil {
+		return nil
+	}
+	var sinks []audit.Sink
+	seen := map[string]bool{}
+	for _, c := range cfg.Audit.Sinks {
+		sink, err := audit.NewSink(c)
+		if err == nil && seen[c.Label()] {
+			sink.Close()
+			err = fmt.Errorf("another sink is called %q; give this one a name", c.Label())
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: audit sink %s: %v\n", c.Label(), err)
+			continue
+		}
+		seen[c.Label()] = true
+		sinks = append(sinks, sink)
+	}
+	return sinks
+}
+
+var auditShipTest bool
+
+var cmdAuditShip = &cobra.Command{
+	Use:   "ship",
+	Short: "Send the entries spooled for the audit sinks",
+	Long: `Send the entries the audit sinks (audit.sinks in the config) could not
+take when they were logged.
+
+veessh ships every entry it logs to the sinks in the background. Entries a
+sink does not take within a few tries, for instance while it is down, are
+spooled next to the audit log and sent the next time veessh connects.
+This sends them now and reports what is still pending.
+
+With --test, a test entry is sent to each sink instead, to check the
+configuration; it is not written to the audit log.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfgPath, err := config.DefaultPath()
+		if err != nil {
+			return err
+		}
+		cfg, err := config.Load(cfgPath)
+		if err != nil {
+			return err
+		}
+		sinks := auditSinks(cfg)
+		if len(sinks) == 0 {
+			return fmt.Errorf("no audit sinks configured (see audit.sinks in 'veessh config schema')")
+		}
+
+		type result struct {
+			Sink    string `json:"sink"`
+			Sent    int    `json:"sent"`
+			Pending int    `json:"pending"`
+			Error   string `json:"error,omitempty"`
+		}
+		var results []result
+		failed := 0
+		for _, sink := range sinks {
+			r := result{Sink: sink.Name()}
+			ctx, cancel := context.WithTimeout(cmd.Context(), 30*time.Second)
+			if auditShipTest {
+				test := audit.Entry{Timestamp: time.Now(), Profile: "veessh-test", Action: "test"}
+				if err = sink.Send(ctx, []audit.Entry{test}); err == nil {
+					r.Sent = 1
+				}
+			} else {
+				r.Sent, err = audit.Ship(ctx, sink)
+			}
+			cancel()
+			sink.Close()
+			if err != nil {
+				r.Error = err.Error()
+				failed++
+			}
+			r.Pending, _ = audit.Pending(sink)
+			results = append(results, r)
+		}
+
+		if OutputJSON() {
+			enc := json.NewEncoder(cmd.OutOrStdout())
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(results); err != nil {
+				return err
+			}
+		} else {
+			out := cmd.OutOrStdout()
+			for _, r := range results {
+				switch {
+				case r.Error != "":
+					fmt.Fprintf(out, "%s: failed: %s\n", r.Sink, r.Error)
+				case auditShipTest:
+					fmt.Fprintf(out, "%s: test entry sent\n", r.Sink)
+				case r.Sent == 0:
+					fmt.Fprintf(out, "%s: nothing to send\n", r.Sink)
+				default:
+					fmt.Fprintf(out, "%s: sent %d entries\n", r.Sink, r.Sent)
+				}
+				if r.Pending > 0 {
+					fmt.Fprintf(out, "%s: %d entries pending\n", r.Sink, r.Pending)
+				}
+			}
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d of %d audit sinks failed", failed, len(sinks))
+		}
+		return nil
+	},
+}
+
+func init() {
+	cmdAudit.Flags().IntVarP(&auditLimit, "limit", "n", 50, "number of entries to show (0 for all)")
+	cmdAudit.Flags().StringVar(&auditProfile, "profile", "", "only entries of this profile")
+	cmdAudit.Flags().StringVar(&auditHost, "host", "", "only entries for this host")
+	cmdAudit.Flags().StringSliceVar(&auditActions, "action", nil, "only these actions: connect, disconnect, error, reconnect, record, hostkey (with --sessions: disconnect, error, open)")
+	cmdAudit.Flags().StringVar(&auditSince, "since", "", "only entries from this time on")
+	cmdAudit.Flags().StringVar(&auditUntil, "until", "", "only entries before this time")
+	cmdAudit.Flags().BoolVar(&auditSessions, "sessions", false, "pair connects with their disconnects and show sessions")
+	cmdAuditShip.Flags().BoolVar(&auditShipTest, "test", false, "send a test entry to each sink")
+	cmdAudit.AddCommand(cmdAuditVerify)
+	cmdAudit.AddCommand(cmdAuditShip)
+}
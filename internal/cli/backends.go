@@ -74,8 +74,8 @@ The active backend is determined by:
 		kr := credentials.NewKeyringBackend()
 		// Try to test keyring availability
 		testProfile := "__veessh_test_backend__"
-		if err := kr.SetPassword(testProfile, "test"); err == nil {
-			kr.DeletePassword(testProfile)
+		if err := kr.SetSecret(testProfile, credentials.SecretPassword, "test"); err == nil {
+			kr.DeleteSecrets(testProfile)
 			fmt.Print(" [✓ Available]")
 			if currentBackendName == "keyring" {
 				fmt.Print(" [ACTIVE]")
@@ -90,7 +90,7 @@ The active backend is determined by:
 		fmt.Println()
 
 		// File backend
-		fmt.Print("  file       - Encrypted file (~/.config/veessh/passwords.enc)")
+		fmt.Print("  file       - Encrypted file, master passphrase protected (~/.config/veessh/passwords.enc)")
 		fmt.Print(" [✓ Always available]")
 		if currentBackendName == "file" {
 			fmt.Print(" [ACTIVE]")
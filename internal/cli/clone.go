@@ -2,6 +2,7 @@ package cli
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/spf13/cobra"
 
@@ -52,7 +53,7 @@ Examples:
 		newProfile := source
 		newProfile.Name = newName
 		newProfile.UseCount = 0
-		newProfile.LastUsed = source.LastUsed // Reset or keep? Let's reset
+		newProfile.LastUsed = time.Time{} // usage belongs to the source
 		newProfile.Favorite = false
 
 		// Apply overrides
@@ -0,0 +1,177 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/vee-sh/veessh/internal/config"
+)
+
+var cmdConfig = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect the configuration files",
+	Long: `Inspect the configuration files.
+
+Profiles and templates are read from, lowest precedence first:
+  1. ~/.config/veessh/config.yaml (the main config)
+  2. ~/.config/veessh/conf.d/*.yaml, in lexical order
+  3. .veessh.yaml in the current directory or the nearest parent that
+     has one, up to the home directory or the repository root (set
+     ` + config.NoProjectEnv + `=1 to ignore it)
+
+Files listed under include: in any of them are read just before the
+file that includes them. A profile defined in several files is taken
+whole from the file with the highest precedence, except that the
+project file (and what it includes) cannot redefine profiles and
+templates of the other files; it can only add its own. A project file
+that another user owns or can write to is ignored. Only the main config
+is written; favorites and usage stats of every profile are kept in the
+state store (~/.local/state/veessh/state.json).
+
+Every file carries the schema version it was written for (version:).
+Older files are upgraded when they are read; the main config is written
+back in the current version on the next change.
+
+Subcommands:
+  sources  - List the files in precedence order and report conflicts
+  validate - Check every profile and report problems with file and line
+  schema   - Print a JSON Schema of config.yaml for editors`,
+}
+
+var cmdConfigSources = &cobra.Command{
+	Use:   "sources",
+	Short: "List config files in precedence order and report conflicts",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfgPath, err := config.DefaultPath()
+		if err != nil {
+			return fmt.Errorf("failed to determine config path: %w", err)
+		}
+		cfg, err := config.Load(cfgPath)
+		if err != nil {
+			return err
+		}
+
+		if OutputJSON() {
+			enc := json.NewEncoder(cmd.OutOrStdout())
+			enc.SetIndent("", "  ")
+			return enc.Encode(map[string]any{"files": cfg.Layers(), "conflicts": cfg.Conflicts()})
+		}
+
+		fmt.Println("Config files (later files take precedence):")
+		for i, l := range cfg.Layers() {
+			note := fmt.Sprintf("%d profile(s)", len(l.Profiles))
+			if len(l.Templates) > 0 {
+				note += fmt.Sprintf(", %d template(s)", len(l.Templates))
+			}
+			if _, err := os.Stat(l.Path); err != nil {
+				note = "not created yet"
+			}
+			if l.Ignored != "" {
+				note = "ignored: " + l.Ignored
+			}
+			fmt.Printf("  %d. %-8s %s (%s)\n", i+1, l.Kind, l.Path, note)
+		}
+
+		conflicts := cfg.Conflicts()
+		if len(conflicts) == 0 {
+			fmt.Println("\nNo conflicts.")
+			return nil
+		}
+		fmt.Println("\nConflicts:")
+		for _, c := range conflicts {
+			fmt.Printf("  %s %q: %s overrides %s\n", c.Kind, c.Name, c.Winner, strings.Join(c.Shadowed, ", "))
+		}
+		return nil
+	},
+}
+
+var cmdConfigValidate = &cobra.Command{
+	Use:   "validate",
+	Short: "Check the config for problems",
+	Long: `Check every profile and report problems with the file and line that
+define them.
+
+Errors:
+  - profiles that fail validation (missing host, unknown protocol, ...)
+  - extends naming profiles that do not exist, and inheritance cycles
+  - malformed localForwards, remoteForwards and dynamicForwards
+
+Warnings:
+  - identity files that do not exist
+  - profiles connecting to the same host, port and user
+  - unknown keys (likely typos)
+
+Exits with an error when there are errors.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfgPath, err := config.DefaultPath()
+		if err != nil {
+			return fmt.Errorf("failed to determine config path: %w", err)
+		}
+		cfg, err := config.Load(cfgPath)
+		if err != nil {
+			return err
+		}
+
+		issues := cfg.Check()
+		errs := 0
+		for _, i := range issues {
+			if i.Severity == config.SeverityError {
+				errs++
+			}
+		}
+
+		if OutputJSON() {
+			if issues == nil {
+				issues = []config.Issue{}
+			}
+			enc := json.NewEncoder(cmd.OutOrStdout())
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(map[string]any{"issues": issues}); err != nil {
+				return err
+			}
+		} else {
+			for _, i := range issues {
+				fmt.Println(i)
+			}
+			if len(issues) == 0 {
+				fmt.Printf("%d profile(s), no problems found.\n", len(cfg.Profiles))
+			} else {
+				fmt.Printf("\n%d error(s), %d warning(s)\n", errs, len(issues)-errs)
+			}
+		}
+		if errs > 0 {
+			return fmt.Errorf("config has %d error(s)", errs)
+		}
+		return nil
+	},
+}
+
+var cmdConfigSchema = &cobra.Command{
+	Use:   "schema",
+	Short: "Print a JSON Schema of config.yaml",
+	Long: `Print a JSON Schema of config.yaml, for editors to complete and check it.
+
+Example (with the YAML language server, e.g. in VS Code or Neovim):
+  veessh config schema > ~/.config/veessh/config.schema.json
+  # then add as the first line of config.yaml:
+  # yaml-language-server: $schema=config.schema.json`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		enc := json.NewEncoder(cmd.OutOrStdout())
+		enc.SetIndent("", "  ")
+		enc.SetEscapeHTML(false)
+		return enc.Encode(config.Schema())
+	},
+}
+
+func init() {
+	cmdConfig.AddCommand(cmdConfigSources)
+	cmdConfig.AddCommand(cmdConfigValidate)
+	cmdConfig.AddCommand(cmdConfigSchema)
+}
@@ -9,8 +9,9 @@ import (
 )
 
 var (
-	connectNoForward  bool
-	connectWithForward bool
+	connectNoForward bool
+	connectForwards  []string
+	connectRecord    bool
 )
 
 var cmdConnect = &cobra.Command{
@@ -18,14 +19,24 @@ var cmdConnect = &cobra.Command{
 	Short: "Connect using a profile",
 	Long: `Connect to a remote host using a saved profile.
 
-Port forwarding can be toggled at connect time:
-  --forward     Enable port forwards defined in profile
-  --no-forward  Disable port forwards for this connection
+By default the profile's forwards are opened, except named forwards with
+enabled: false. They can be picked at connect time:
+  --forward pg,redis  Open only the named forwards (from the forwards: list)
+  --forward all       Open every forward, including disabled ones
+  --no-forward        Open no forwards
+
+Connecting fails before anything is started when a local port of a
+forward is already in use.
+
+Sessions to profiles with record: true, or with a tag listed under
+recording.tags in the config, are recorded; --record records this one too.
+See 'veessh recordings'.
 
 Examples:
   veessh connect mybox
   veessh connect mybox --no-forward    # Skip port forwarding
-  veessh connect mybox --forward       # Ensure forwards are enabled`,
+  veessh connect db --forward pg,redis # Only the pg and redis forwards
+  veessh connect mybox --record        # Record this session`,
 	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		name := args[0]
@@ -42,18 +53,29 @@ Examples:
 			return fmt.Errorf("profile %q not found", name)
 		}
 
-		// Handle port-forward toggle
-		if connectNoForward {
-			p.LocalForwards = nil
-			p.RemoteForwards = nil
-			p.DynamicForwards = nil
+		// Pick the forwards to open
+		switch {
+		case connectNoForward && len(connectForwards) > 0:
+			return fmt.Errorf("--forward and --no-forward cannot be combined")
+		case connectNoForward:
+			err = p.SelectForwards([]string{})
+		case len(connectForwards) > 0:
+			err = p.SelectForwards(connectForwards)
+		}
+		if err != nil {
+			return err
+		}
+
+		if connectRecord {
+			p.Record = config.Bool(true)
 		}
 
-		return executeConnection(cmd.Context(), p, true)
+		return executeConnection(cmd.Context(), cfg, p, true)
 	},
 }
 
 func init() {
 	cmdConnect.Flags().BoolVar(&connectNoForward, "no-forward", false, "disable port forwarding for this connection")
-	cmdConnect.Flags().BoolVar(&connectWithForward, "forward", false, "enable port forwarding (default if configured)")
+	cmdConnect.Flags().StringSliceVar(&connectForwards, "forward", nil, "open only these named forwards (comma-separated, or 'all')")
+	cmdConnect.Flags().BoolVar(&connectRecord, "record", false, "record this session (see 'veessh recordings')")
 }
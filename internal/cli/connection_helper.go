@@ -4,45 +4,78 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net"
 	"os"
-	"os/exec"
+	"syscall"
 	"time"
 
+	"golang.org/x/term"
+
 	"github.com/vee-sh/veessh/internal/audit"
 	"github.com/vee-sh/veessh/internal/config"
 	"github.com/vee-sh/veessh/internal/connectors"
 	"github.com/vee-sh/veessh/internal/credentials"
+	"github.com/vee-sh/veessh/internal/recording"
+	"github.com/vee-sh/veessh/internal/state"
 )
 
 // executeConnection handles common connection logic for connect, pick, and root commands
-func executeConnection(ctx context.Context, p config.Profile, updateUsageStats bool) error {
-	conn, err := connectors.Get(p.Protocol)
+func executeConnection(ctx context.Context, cfg config.Config, p config.Profile, updateUsageStats bool) error {
+	conn, err := connectors.ForProfile(p)
 	if err != nil {
 		return err
 	}
+	if err := checkForwardPorts(p); err != nil {
+		return err
+	}
+
+	// Retrieve stored secrets (non-fatal if fails)
+	secrets, err := credentials.GetSecrets(p.Name)
+	if err != nil {
+		// Non-fatal: log but continue (secrets might not be stored)
+		fmt.Fprintf(os.Stderr, "Warning: failed to retrieve stored secrets: %v\n", err)
+		secrets = credentials.Secrets{}
+	}
 
-	// Retrieve password (non-fatal if fails)
-	password, err := credentials.GetPassword(p.Name)
+	configureAudit(cfg)
+	ctx, err = checkHostKey(ctx, cfg, p, os.Stderr)
 	if err != nil {
-		// Non-fatal: log but continue (password might not be stored)
-		fmt.Fprintf(os.Stderr, "Warning: failed to retrieve password: %v\n", err)
-		password = ""
+		return err
+	}
+
+	// Record the session when the profile or its tags ask for it. Without
+	// the recording there is no connection.
+	var rec *recording.Recorder
+	if cfg.ShouldRecord(p) {
+		if rec, err = startRecording(cfg, p); err != nil {
+			return fmt.Errorf("failed to start recording %s: %w", p.Name, err)
+		}
+		defer rec.Close()
+		ctx = recording.NewContext(ctx, rec)
+		fmt.Fprintf(os.Stderr, "Recording this session to %s\n", rec.Path())
 	}
 
 	// Audit log: connection start
 	startTime := time.Now()
-	audit.LogConnect(p.Name, string(p.Protocol), p.Host, p.Username)
+	session := audit.LogConnect(p.Name, string(p.Protocol), p.Host, p.Username)
+	if rec != nil {
+		audit.LogRecording(session, p.Name, string(p.Protocol), p.Host, p.Username, rec.Path())
+	}
 
 	// Execute connection
 	var exitCode int
 	var connErr error
-	if err := conn.Exec(ctx, p, password); err != nil {
+	err = conn.Exec(ctx, p, secrets)
+	if rec != nil && rec.Err() != nil {
+		fmt.Fprintf(os.Stderr, "Warning: the recording stopped early: %v\n", rec.Err())
+		audit.LogRecordingFailed(session, p.Name, string(p.Protocol), p.Host, p.Username, rec.Path(), rec.Err())
+	}
+	if err != nil {
+		err = hostKeyRefused(ctx, p, err, sshFailed)
 		connErr = err
-		if exitErr, ok := err.(*exec.ExitError); ok {
-			exitCode = exitErr.ExitCode()
-		}
+		exitCode = connectors.ExitCode(err)
 		// Audit log: connection end with error
-		audit.LogDisconnect(p.Name, string(p.Protocol), p.Host, p.Username, startTime, exitCode, connErr)
+		audit.LogDisconnect(session, p.Name, string(p.Protocol), p.Host, p.Username, startTime, exitCode, connErr)
 
 		if errors.Is(err, context.Canceled) {
 			return context.Canceled
@@ -51,25 +84,61 @@ func executeConnection(ctx context.Context, p config.Profile, updateUsageStats b
 	}
 
 	// Audit log: successful disconnect
-	audit.LogDisconnect(p.Name, string(p.Protocol), p.Host, p.Username, startTime, 0, nil)
+	audit.LogDisconnect(session, p.Name, string(p.Protocol), p.Host, p.Username, startTime, 0, nil)
 
 	// Update usage tracking if requested
 	if updateUsageStats {
-		p.LastUsed = time.Now()
-		p.UseCount++
-		
-		cfgPath, err := config.DefaultPath()
-		if err == nil {
-			cfg, err := config.Load(cfgPath)
-			if err == nil {
-				cfg.UpsertProfile(p)
-				if err := config.Save(cfgPath, cfg); err != nil {
-					// Non-fatal, but report it
-					fmt.Printf("warning: failed to update usage stats: %v\n", err)
-				}
-			}
+		err := state.Update("", func(s *state.State) error {
+			s.RecordConnection(state.Connection{Profile: p.Name, Time: startTime, Duration: time.Since(startTime)})
+			return nil
+		})
+		if err != nil {
+			// Non-fatal, but report it
+			fmt.Printf("warning: failed to update usage stats: %v\n", err)
 		}
 	}
 
 	return nil
 }
+
+// startRecording creates the recording of a session to p, sized like the
+// terminal
+func startRecording(cfg config.Config, p config.Profile) (*recording.Recorder, error) {
+	dir, err := cfg.RecordingDir()
+	if err != nil {
+		return nil, err
+	}
+	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil {
+		width, height = 80, 24
+	}
+	title := p.Name
+	if p.Host != "" {
+		title += " (" + p.Host + ")"
+	}
+	return recording.Create(dir, p.Name, "veessh connect "+title, width, height)
+}
+
+// checkForwardPorts fails when a local port the profile forwards is taken,
+// before ssh starts and reports it halfway through the login
+func checkForwardPorts(p config.Profile) error {
+	switch p.Protocol {
+	case config.ProtocolSSH, config.ProtocolSFTP:
+	default:
+		return nil
+	}
+	for _, f := range p.ActiveForwards() {
+		if f.Kind() == config.ForwardRemote || f.LocalPort == 0 {
+			continue
+		}
+		l, err := net.Listen("tcp", f.BindAddr())
+		if errors.Is(err, syscall.EADDRINUSE) {
+			return fmt.Errorf("forward %s: local port %d is already in use; pick other forwards with --forward or skip them with --no-forward", f.Label(), f.LocalPort)
+		}
+		if err != nil {
+			return fmt.Errorf("forward %s: %w", f.Label(), err)
+		}
+		l.Close()
+	}
+	return nil
+}
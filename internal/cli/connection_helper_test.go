@@ -0,0 +1,36 @@
+package cli
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/vee-sh/veessh/internal/config"
+)
+
+func TestCheckForwardPorts(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	defer l.Close()
+	taken := l.Addr().(*net.TCPAddr).Port
+
+	p := config.Profile{Name: "db", Protocol: config.ProtocolSSH, Host: "h", Forwards: []config.Forward{
+		{Name: "pg", Bind: "127.0.0.1", LocalPort: taken, RemoteHost: "db", RemotePort: 5432},
+	}}
+	if err := checkForwardPorts(p); err == nil || !strings.Contains(err.Error(), "forward pg: local port") {
+		t.Errorf("checkForwardPorts() = %v, want port in use", err)
+	}
+
+	// Remote forwards listen on the host, and other protocols don't forward
+	p.Forwards[0].Type = config.ForwardRemote
+	if err := checkForwardPorts(p); err != nil {
+		t.Errorf("checkForwardPorts(remote) = %v", err)
+	}
+	p.Forwards[0].Type = ""
+	p.Protocol = config.ProtocolTelnet
+	if err := checkForwardPorts(p); err != nil {
+		t.Errorf("checkForwardPorts(telnet) = %v", err)
+	}
+}
@@ -27,6 +27,8 @@ Checks performed:
   - Port is reachable (TCP connect)
   - SSH agent is running (if useAgent is enabled)
   - Required tools are installed (ssh, sftp, telnet)
+  - Profiles defined in more than one config file
+  - Problems reported by 'veessh config validate'
 
 Examples:
   veessh doctor           # Check all profiles
@@ -47,6 +49,7 @@ Examples:
 		fmt.Println("=== System Checks ===")
 		checkTools()
 		checkSSHAgent()
+		checkConfigFiles(cfg)
 		fmt.Println()
 
 		if len(args) == 1 {
@@ -106,6 +109,24 @@ func checkTools() {
 	}
 }
 
+func checkConfigFiles(cfg config.Config) {
+	layers := cfg.Layers()
+	if len(layers) > 1 {
+		fmt.Printf("  [OK]   Config: %d files (see 'veessh config sources')\n", len(layers))
+	}
+	for _, l := range layers {
+		if l.Ignored != "" {
+			fmt.Printf("  [WARN] Project file not read: %s\n", l.Ignored)
+		}
+	}
+	for _, c := range cfg.Conflicts() {
+		fmt.Printf("  [WARN] %s %q from %s overrides %s\n", c.Kind, c.Name, c.Winner, strings.Join(c.Shadowed, ", "))
+	}
+	if issues := cfg.Check(); len(issues) > 0 {
+		fmt.Printf("  [WARN] Config: %d problem(s) (see 'veessh config validate')\n", len(issues))
+	}
+}
+
 func checkSSHAgent() {
 	authSock := os.Getenv("SSH_AUTH_SOCK")
 	if authSock == "" {
@@ -20,6 +20,11 @@ var (
 	editTags        []string
 	editClearTags   bool
 	editAskPassword bool
+	editSSHClient   string
+	editHKPolicy    string
+	editUseAgent    bool
+	editFwdAgent    bool
+	editMerge       map[string]string
 )
 
 var cmdEdit = &cobra.Command{
@@ -41,7 +46,8 @@ Examples:
 		if err != nil {
 			return err
 		}
-		p, ok := cfg.GetProfile(name)
+		// Edit the profile as stored, so Extends and merge directives survive
+		p, ok := cfg.Profiles[name]
 		if !ok {
 			return fmt.Errorf("profile %q not found", name)
 		}
@@ -68,17 +74,42 @@ Examples:
 		if cmd.Flags().Changed("proxy-jump") {
 			p.ProxyJump = editProxyJump
 		}
+		if cmd.Flags().Changed("ssh-client") {
+			p.SSHClient = editSSHClient
+		}
+		if cmd.Flags().Changed("host-key-policy") {
+			p.HostKeyPolicy = editHKPolicy
+		}
+		if cmd.Flags().Changed("agent") {
+			p.UseAgent = config.Bool(editUseAgent)
+		}
+		if cmd.Flags().Changed("forward-agent") {
+			p.ForwardAgent = config.Bool(editFwdAgent)
+		}
+		if cmd.Flags().Changed("merge") {
+			if p.Merge == nil {
+				p.Merge = map[string]config.MergeMode{}
+			}
+			for field, mode := range parseMergeFlag(editMerge) {
+				p.Merge[field] = mode
+			}
+		}
 		if editClearTags {
 			p.Tags = nil
 		} else if cmd.Flags().Changed("tags") {
 			p.Tags = editTags
 		}
 
-		if err := (&p).Validate(); err != nil {
+		cfg.UpsertProfile(p)
+		resolved, _ := cfg.GetProfile(name)
+		resolved.Merge = p.Merge
+		if err := (&resolved).Validate(); err != nil {
 			return err
 		}
-
-		cfg.UpsertProfile(p)
+		if len(p.Extends) == 0 {
+			p.Port = resolved.Port // default port filled in by Validate
+			cfg.UpsertProfile(p)
+		}
 		if err := config.Save(cfgPath, cfg); err != nil {
 			return err
 		}
@@ -111,6 +142,11 @@ func init() {
 	cmdEdit.Flags().StringVar(&editProxyJump, "proxy-jump", "", "proxy jump host")
 	cmdEdit.Flags().StringSliceVar(&editTags, "tags", nil, "tags (replaces existing)")
 	cmdEdit.Flags().BoolVar(&editClearTags, "clear-tags", false, "remove all tags")
+	cmdEdit.Flags().StringVar(&editSSHClient, "ssh-client", "", "SSH client: openssh|native")
+	cmdEdit.Flags().StringVar(&editHKPolicy, "host-key-policy", "", "on a host key that does not match its pin: strict|warn|off (empty for the config's)")
+	cmdEdit.Flags().BoolVar(&editUseAgent, "agent", true, "use SSH agent (--agent=false turns off an inherited agent)")
+	cmdEdit.Flags().BoolVar(&editFwdAgent, "forward-agent", true, "forward the SSH agent (--forward-agent=false turns off an inherited setting)")
+	cmdEdit.Flags().StringToStringVar(&editMerge, "merge", nil, "how inherited lists combine, e.g. tags=append (append|replace|remove)")
 	cmdEdit.Flags().BoolVar(&editAskPassword, "ask-password", false, "prompt to update password")
 }
 
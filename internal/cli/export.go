@@ -9,15 +9,30 @@ import (
 	"gopkg.in/yaml.v3"
 
 	"github.com/vee-sh/veessh/internal/config"
+	"github.com/vee-sh/veessh/internal/sshconfig"
 )
 
 var exportFile string
+var exportFormat string
 
 var cmdExport = &cobra.Command{
 	Use:   "export",
-	Short: "Export profiles to a YAML file (no passwords)",
+	Short: "Export profiles to a YAML file or OpenSSH config (no passwords)",
+	Long: `Export profiles to a file.
+
+Formats:
+  yaml        - veessh profiles, for 'veessh import' (default)
+  ssh-config  - OpenSSH Host entries with Extends chains flattened; written
+                to stdout when --file is not given
+
+To keep ~/.ssh/config in sync with your profiles, use 'veessh sync-ssh-config'.`,
+	Example: `  veessh export --file profiles.yaml
+  veessh export --format ssh-config > hosts.conf`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		if exportFile == "" {
+		if exportFormat != "yaml" && exportFormat != "ssh-config" {
+			return fmt.Errorf("unknown format %q (must be: yaml or ssh-config)", exportFormat)
+		}
+		if exportFile == "" && exportFormat == "yaml" {
 			return errors.New("--file is required")
 		}
 		cfgPath, err := config.DefaultPath()
@@ -28,6 +43,25 @@ var cmdExport = &cobra.Command{
 		if err != nil {
 			return err
 		}
+
+		if exportFormat == "ssh-config" {
+			data, report := sshconfig.Render(cfg)
+			for _, e := range report {
+				if e.Action == sshconfig.ActionDropped {
+					fmt.Fprintf(os.Stderr, "skipped %s: %s\n", e.Profile, e.Detail)
+				}
+			}
+			if exportFile == "" {
+				_, err := cmd.OutOrStdout().Write(data)
+				return err
+			}
+			if err := os.WriteFile(exportFile, data, 0o600); err != nil {
+				return err
+			}
+			fmt.Printf("exported %d hosts to %s\n", countExported(report), exportFile)
+			return nil
+		}
+
 		data, err := yaml.Marshal(cfg)
 		if err != nil {
 			return err
@@ -40,6 +74,17 @@ var cmdExport = &cobra.Command{
 	},
 }
 
+func countExported(report []sshconfig.ReportEntry) int {
+	n := 0
+	for _, e := range report {
+		if e.Action == sshconfig.ActionExported {
+			n++
+		}
+	}
+	return n
+}
+
 func init() {
 	cmdExport.Flags().StringVar(&exportFile, "file", "", "output file path")
+	cmdExport.Flags().StringVar(&exportFormat, "format", "yaml", "output format: yaml|ssh-config")
 }
@@ -6,6 +6,7 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/vee-sh/veessh/internal/config"
+	"github.com/vee-sh/veessh/internal/state"
 )
 
 var favUnset bool
@@ -24,20 +25,21 @@ var cmdFavorite = &cobra.Command{
 		if err != nil {
 			return err
 		}
-		p, ok := cfg.GetProfile(name)
-		if !ok {
+		if _, ok := cfg.Profiles[name]; !ok {
 			return fmt.Errorf("profile %q not found", name)
 		}
-		p.Favorite = !favUnset
-		cfg.UpsertProfile(p)
-		if err := config.Save(cfgPath, cfg); err != nil {
+		err = state.Update("", func(s *state.State) error {
+			s.SetFavorite(name, !favUnset)
+			return nil
+		})
+		if err != nil {
 			return err
 		}
-		state := "favorited"
+		verb := "favorited"
 		if favUnset {
-			state = "unfavorited"
+			verb = "unfavorited"
 		}
-		fmt.Printf("%s %q\n", state, name)
+		fmt.Printf("%s %q\n", verb, name)
 		return nil
 	},
 }
@@ -0,0 +1,224 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/vee-sh/veessh/internal/config"
+	"github.com/vee-sh/veessh/internal/fleet"
+)
+
+// fleetFlags are the flags of commands that run on many profiles at once:
+// which profiles (--tag, --group, --profiles) and how (--parallel,
+// --timeout, --fail-fast)
+type fleetFlags struct {
+	tags     []string
+	groups   []string
+	profiles []string
+	parallel int
+	timeout  time.Duration
+	failFast bool
+}
+
+func (f *fleetFlags) register(cmd *cobra.Command, verb string) {
+	cmd.Flags().StringSliceVar(&f.tags, "tag", nil, verb+" profiles with these tag(s), require all")
+	cmd.Flags().StringSliceVar(&f.groups, "group", nil, verb+" profiles in any of these group(s)")
+	cmd.Flags().StringSliceVar(&f.profiles, "profiles", nil, verb+" these profiles")
+	cmd.Flags().IntVarP(&f.parallel, "parallel", "P", 10, "hosts at a time")
+	cmd.Flags().DurationVar(&f.timeout, "timeout", 0, "time limit per host (0 for none)")
+	cmd.Flags().BoolVar(&f.failFast, "fail-fast", false, "start no more hosts after the first failure")
+}
+
+// enabled reports whether profiles were selected with the flags
+func (f *fleetFlags) enabled() bool {
+	return len(f.tags) > 0 || len(f.groups) > 0 || len(f.profiles) > 0
+}
+
+func (f *fleetFlags) options() fleet.Options {
+	return fleet.Options{Parallel: f.parallel, Timeout: f.timeout, FailFast: f.failFast}
+}
+
+// selectProfiles returns the profiles named with --profiles followed by
+// those matching --tag and --group. Named profiles must have one of
+// protocols; matching ones without are skipped.
+func (f *fleetFlags) selectProfiles(cmd *cobra.Command, cfg config.Config, protocols ...config.Protocol) ([]config.Profile, error) {
+	var out []config.Profile
+	seen := map[string]bool{}
+	for _, name := range f.profiles {
+		p, ok := cfg.GetProfile(name)
+		if !ok {
+			return nil, fmt.Errorf("profile %q not found", name)
+		}
+		if !slices.Contains(protocols, p.Protocol) {
+			return nil, fmt.Errorf("%s only supports %s profiles (got %s for %s)", cmd.Name(), protocolList(protocols), p.Protocol, name)
+		}
+		if !seen[p.Name] {
+			seen[p.Name] = true
+			out = append(out, p)
+		}
+	}
+	if len(f.tags) > 0 || len(f.groups) > 0 {
+		for _, p := range cfg.FindProfiles(f.tags, f.groups) {
+			if seen[p.Name] {
+				continue
+			}
+			if !slices.Contains(protocols, p.Protocol) {
+				fmt.Fprintf(cmd.ErrOrStderr(), "Skipping %s: %s only supports %s profiles (got %s)\n", p.Name, cmd.Name(), protocolList(protocols), p.Protocol)
+				continue
+			}
+			seen[p.Name] = true
+			out = append(out, p)
+		}
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("no %s profiles match", protocolList(protocols))
+	}
+	return out, nil
+}
+
+func protocolList(protocols []config.Protocol) string {
+	names := make([]string, len(protocols))
+	for i, p := range protocols {
+		names[i] = strings.ToUpper(string(p))
+	}
+	return strings.Join(names, "/")
+}
+
+// fleetOutput shows the output of each profile on cmd's output, prefixed
+// with the profile name as it arrives ("prefix") or all at once when the
+// profile is done ("group"). Nothing is shown with --json.
+func fleetOutput(cmd *cobra.Command, profiles []config.Profile, mode string, opts *fleet.Options) {
+	if OutputJSON() {
+		return
+	}
+	stdout, stderr := cmd.OutOrStdout(), cmd.ErrOrStderr()
+	switch mode {
+	case "prefix":
+		width := 0
+		for _, p := range profiles {
+			width = max(width, len(p.Name))
+		}
+		var mu sync.Mutex
+		writers := map[string][2]*fleet.PrefixWriter{}
+		opts.Output = func(p config.Profile) (io.Writer, io.Writer) {
+			prefix := fmt.Sprintf("%-*s | ", width, p.Name)
+			w := [2]*fleet.PrefixWriter{fleet.NewPrefixWriter(stdout, &mu, prefix), fleet.NewPrefixWriter(stderr, &mu, prefix)}
+			mu.Lock()
+			writers[p.Name] = w
+			mu.Unlock()
+			return w[0], w[1]
+		}
+		opts.Done = func(r fleet.Result) {
+			mu.Lock()
+			w := writers[r.Profile]
+			mu.Unlock()
+			w[0].Flush()
+			w[1].Flush()
+		}
+	case "group":
+		var mu sync.Mutex
+		outputs := map[string]*lockedBuffer{}
+		opts.Output = func(p config.Profile) (io.Writer, io.Writer) {
+			buf := &lockedBuffer{}
+			mu.Lock()
+			outputs[p.Name] = buf
+			mu.Unlock()
+			return buf, buf
+		}
+		opts.Done = func(r fleet.Result) {
+			mu.Lock()
+			buf := outputs[r.Profile]
+			mu.Unlock()
+			fmt.Fprintf(stdout, "==> %s (%s): %s\n", r.Profile, r.Host, describeResult(r))
+			out := buf.String()
+			if out != "" && !strings.HasSuffix(out, "\n") {
+				out += "\n"
+			}
+			fmt.Fprint(stdout, out)
+		}
+	}
+}
+
+// reportFleet prints the results, as JSON with --json, and fails if any
+// profile failed
+func reportFleet(cmd *cobra.Command, results []fleet.Result) error {
+	failed := 0
+	for _, r := range results {
+		if !r.OK() && r.Status != fleet.StatusSkipped {
+			failed++
+		}
+	}
+	if OutputJSON() {
+		enc := json.NewEncoder(cmd.OutOrStdout())
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(results); err != nil {
+			return err
+		}
+	} else {
+		printFleetSummary(cmd.OutOrStdout(), results)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d hosts failed", failed, len(results))
+	}
+	return nil
+}
+
+func printFleetSummary(w io.Writer, results []fleet.Result) {
+	counts := map[string]int{}
+	fmt.Fprintf(w, "\n%-20s  %-30s  %-8s  %-5s  %s\n", "PROFILE", "HOST", "STATUS", "EXIT", "DURATION")
+	for _, r := range results {
+		counts[r.Status]++
+		exit := "-"
+		if r.ExitCode >= 0 {
+			exit = fmt.Sprint(r.ExitCode)
+		}
+		fmt.Fprintf(w, "%-20s  %-30s  %-8s  %-5s  %s\n", r.Profile, r.Host, r.Status, exit, r.Duration.Round(time.Millisecond))
+		if r.Error != "" {
+			fmt.Fprintf(w, "  %s\n", r.Error)
+		}
+	}
+	var parts []string
+	for _, status := range []string{fleet.StatusOK, fleet.StatusFailed, fleet.StatusTimeout, fleet.StatusError, fleet.StatusSkipped} {
+		if counts[status] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[status], status))
+		}
+	}
+	fmt.Fprintf(w, "%d hosts: %s\n", len(results), strings.Join(parts, ", "))
+}
+
+func describeResult(r fleet.Result) string {
+	switch r.Status {
+	case fleet.StatusOK, fleet.StatusFailed:
+		return fmt.Sprintf("exit %d in %s", r.ExitCode, r.Duration.Round(time.Millisecond))
+	case fleet.StatusSkipped:
+		return r.Status
+	}
+	return r.Error
+}
+
+// lockedBuffer collects the stdout and stderr of a command in the order they
+// were written
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
@@ -0,0 +1,225 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/vee-sh/veessh/internal/config"
+	"github.com/vee-sh/veessh/internal/connectors"
+	"github.com/vee-sh/veessh/internal/fleet"
+)
+
+// copyCommandFunc builds the scp or rsync command copying srcPath to
+// dstPath, one of them on p
+type copyCommandFunc func(ctx context.Context, p config.Profile, srcPath, dstPath string, srcIsRemote bool, sshOpts ...string) *exec.Cmd
+
+// fleetCopy is a copy between this host and many profiles. The remote side
+// is written as :<path>, without a profile.
+type fleetCopy struct {
+	tool      string // "scp" or "rsync"
+	build     copyCommandFunc
+	recursive bool // Directories may be copied
+	verify    bool
+}
+
+// failed is the exit status with which the tool reports failing itself
+func (fc fleetCopy) failed() int {
+	if fc.tool == "scp" {
+		return scpFailed
+	}
+	return sshFailed
+}
+
+// isFleetPath reports whether arg is the :<path> remote side of a fleet copy
+func isFleetPath(arg string) bool {
+	return strings.HasPrefix(arg, ":")
+}
+
+// run pushes src to :dst on every profile, or pulls :src from every profile
+// into a subdirectory of dst named after it
+func (fc fleetCopy) run(cmd *cobra.Command, flags *fleetFlags, cfg config.Config, src, dst string) error {
+	push := isFleetPath(dst)
+	if push == isFleetPath(src) {
+		return fmt.Errorf("with --tag, --group or --profiles one path must be remote, written as :<path>")
+	}
+	profiles, err := flags.selectProfiles(cmd, cfg, config.ProtocolSSH, config.ProtocolSFTP)
+	if err != nil {
+		return err
+	}
+
+	var local fleet.Manifest
+	var localIsDir bool
+	if push {
+		dst = dst[1:]
+		info, err := os.Stat(src)
+		if err != nil {
+			return err
+		}
+		localIsDir = info.IsDir()
+		if localIsDir && !fc.recursive {
+			return fmt.Errorf("%s is a directory (use -r)", src)
+		}
+		if fc.verify {
+			if local, err = fleet.LocalManifest(src); err != nil {
+				return fmt.Errorf("failed to checksum %s: %w", src, err)
+			}
+		}
+	} else {
+		src = src[1:]
+	}
+
+	opts := flags.options()
+	fleetOutput(cmd, profiles, "prefix", &opts)
+	ctx := cmd.Context()
+	results := fleet.Run(ctx, profiles, opts, func(ctx context.Context, p config.Profile, stdout, stderr io.Writer) error {
+		ctx, err := checkHostKey(ctx, cfg, p, stderr)
+		if err != nil {
+			return err
+		}
+		var c *exec.Cmd
+		var localRoot string
+		var dstIsDir bool
+		if push {
+			// Where the copy lands depends on whether dst is a directory
+			// already, which the copy may change
+			if fc.verify {
+				if dstIsDir, err = remoteIsDir(ctx, p, dst); err != nil {
+					return err
+				}
+			}
+			c = fc.build(ctx, p, src, dst, false, "-o", "BatchMode=yes")
+		} else {
+			dir, err := pullDir(dst, p.Name)
+			if err != nil {
+				return err
+			}
+			if err := os.MkdirAll(dir, 0o755); err != nil {
+				return err
+			}
+			c = fc.build(ctx, p, src, dir+string(filepath.Separator), true, "-o", "BatchMode=yes")
+			// The copy lands in dir under its own name, except for rsync of
+			// a directory ending in /, whose contents land in dir
+			localRoot = dir
+			if copiesInto(fc.tool, src, true, dir, true) {
+				localRoot = filepath.Join(dir, path.Base(strings.TrimRight(src, "/")))
+			}
+		}
+		c.Stdout = stdout
+		c.Stderr = stderr
+		c.WaitDelay = time.Second
+		if err := c.Run(); err != nil || !fc.verify {
+			return hostKeyRefused(ctx, p, err, fc.failed())
+		}
+
+		if push {
+			target := dst
+			if copiesInto(fc.tool, src, localIsDir, dst, dstIsDir) {
+				target = path.Join(dst, localBase(src))
+			}
+			remote, err := remoteManifest(ctx, p, target)
+			if err != nil {
+				return err
+			}
+			return local.Verify(remote)
+		}
+		remote, err := remoteManifest(ctx, p, src)
+		if err != nil {
+			return err
+		}
+		copied, err := fleet.LocalManifest(localRoot)
+		if err != nil {
+			return fmt.Errorf("failed to checksum %s: %w", localRoot, err)
+		}
+		return remote.Verify(copied)
+	})
+	if ctx.Err() != nil {
+		return context.Canceled
+	}
+	return reportFleet(cmd, results)
+}
+
+// copiesInto reports whether the copy of src is made inside dst under the
+// name of src, rather than as dst itself, following the rules of tool:
+// scp copies into dst when it is a directory already or ends in /, and
+// rsync copies a directory into dst unless the directory ends in / (or is
+// . or .., see rsyncCommand), when it copies its contents.
+func copiesInto(tool, src string, srcIsDir bool, dst string, dstIsDir bool) bool {
+	if tool == "rsync" && srcIsDir {
+		return !strings.HasSuffix(src, "/") && src != "." && src != ".."
+	}
+	return dstIsDir || strings.HasSuffix(dst, "/")
+}
+
+// pullDir returns the directory under dst that a pull from the profile
+// named name goes to. Separators in the name are replaced, as for
+// recordings, so that the copy stays under dst.
+func pullDir(dst, name string) (string, error) {
+	dir := strings.NewReplacer("/", "_", string(filepath.Separator), "_").Replace(name)
+	if dir == "." || dir == ".." {
+		return "", fmt.Errorf("profile name %q cannot name a directory", name)
+	}
+	return filepath.Join(dst, dir), nil
+}
+
+// remoteIsDir reports whether remotePath is a directory on p
+func remoteIsDir(ctx context.Context, p config.Profile, remotePath string) (bool, error) {
+	var stderr bytes.Buffer
+	c := remoteCommand(ctx, p, []string{"test -d " + remoteShellPath(remotePath)}, "-o", "BatchMode=yes")
+	c.Stderr = &stderr
+	err := c.Run()
+	switch {
+	case err == nil:
+		return true, nil
+	case connectors.ExitCode(err) == 1:
+		return false, nil
+	case strings.TrimSpace(stderr.String()) != "":
+		return false, fmt.Errorf("failed to check %s: %s", remotePath, strings.TrimSpace(stderr.String()))
+	}
+	return false, fmt.Errorf("failed to check %s: %w", remotePath, err)
+}
+
+// localBase returns the name a local path is copied under
+func localBase(src string) string {
+	if abs, err := filepath.Abs(src); err == nil {
+		src = abs
+	}
+	return filepath.Base(src)
+}
+
+// remoteManifest checksums the file or directory at path on p
+func remoteManifest(ctx context.Context, p config.Profile, remotePath string) (fleet.Manifest, error) {
+	var stdout, stderr bytes.Buffer
+	c := remoteCommand(ctx, p, []string{fleet.ManifestScript(remoteShellPath(remotePath))}, "-o", "BatchMode=yes")
+	c.Stdout = &stdout
+	c.Stderr = &stderr
+	if err := c.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("failed to checksum %s: %s", remotePath, msg)
+		}
+		return nil, fmt.Errorf("failed to checksum %s: %w", remotePath, err)
+	}
+	return fleet.ParseManifest(&stdout)
+}
+
+// remoteShellPath quotes a remote path for the remote shell, keeping a
+// leading ~ meaning the home directory
+func remoteShellPath(remotePath string) string {
+	quote := func(s string) string { return "'" + strings.ReplaceAll(s, "'", `'"'"'`) + "'" }
+	switch {
+	case remotePath == "" || remotePath == "~":
+		return `"$HOME"`
+	case strings.HasPrefix(remotePath, "~/"):
+		return `"$HOME"/` + quote(remotePath[2:])
+	}
+	return quote(remotePath)
+}
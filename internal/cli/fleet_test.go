@@ -0,0 +1,71 @@
+package cli
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestCopiesInto(t *testing.T) {
+	tests := []struct {
+		tool     string
+		src      string
+		srcIsDir bool
+		dst      string
+		dstIsDir bool
+		want     bool
+	}{
+		{"scp", "app.conf", false, "/etc/app/", false, true},
+		{"scp", "app.conf", false, "/etc/app/app.conf", false, false},
+		{"scp", "app.conf", false, "/etc/app", true, true},
+		{"scp", "conf", true, "/etc/app/", false, true},
+		{"scp", "conf", true, "/etc/app", false, false},
+		{"scp", "conf", true, "/etc/app", true, true},
+		{"rsync", "app.conf", false, "/etc/app/", false, true},
+		{"rsync", "app.conf", false, "/etc/app.conf", false, false},
+		{"rsync", "app.conf", false, "/etc/app", true, true},
+		{"rsync", "dist", true, "/var/www", false, true},
+		{"rsync", "dist/", true, "/var/www/", false, false},
+		{"rsync", "dist/", true, "/var/www", true, false},
+		{"rsync", ".", true, "/var/www/", false, false},
+	}
+
+	for _, tt := range tests {
+		if got := copiesInto(tt.tool, tt.src, tt.srcIsDir, tt.dst, tt.dstIsDir); got != tt.want {
+			t.Errorf("copiesInto(%s, %q, %v, %q, %v) = %v, want %v", tt.tool, tt.src, tt.srcIsDir, tt.dst, tt.dstIsDir, got, tt.want)
+		}
+	}
+}
+
+func TestPullDir(t *testing.T) {
+	tests := []struct {
+		name, want string
+		wantErr    bool
+	}{
+		{"web-1", filepath.Join("out", "web-1"), false},
+		{"../../etc", filepath.Join("out", ".._.._etc"), false},
+		{"a/b", filepath.Join("out", "a_b"), false},
+		{"..", "", true},
+		{".", "", true},
+	}
+	for _, tt := range tests {
+		got, err := pullDir("out", tt.name)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("pullDir(out, %q) = %q, %v; want %q", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+func TestRemoteShellPath(t *testing.T) {
+	tests := map[string]string{
+		"/var/log/app.log": `'/var/log/app.log'`,
+		"~":                `"$HOME"`,
+		"":                 `"$HOME"`,
+		"~/app/it's":       `"$HOME"/'app/it'"'"'s'`,
+		"logs/$(reboot)":   `'logs/$(reboot)'`,
+	}
+	for in, want := range tests {
+		if got := remoteShellPath(in); got != want {
+			t.Errorf("remoteShellPath(%q) = %s, want %s", in, got, want)
+		}
+	}
+}
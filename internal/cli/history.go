@@ -9,11 +9,13 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/vee-sh/veessh/internal/config"
+	"github.com/vee-sh/veessh/internal/state"
 )
 
 var (
 	historyLimit int
 	historyStats bool
+	historyLog   bool
 )
 
 var cmdHistory = &cobra.Command{
@@ -21,9 +23,13 @@ var cmdHistory = &cobra.Command{
 	Short: "Show connection history and statistics",
 	Long: `Display recent connections and usage statistics.
 
+Usage is kept in the state store (~/.local/state/veessh/state.json), not
+in config.yaml.
+
 Examples:
-  veessh history            # Show recent connections
-  veessh history -n 5       # Show last 5 connections
+  veessh history            # Show recently used profiles
+  veessh history -n 5       # Show last 5 profiles
+  veessh history --log      # Show every connection, newest first
   veessh history --stats    # Show usage statistics
   veessh history --json     # JSON output`,
 	RunE: func(cmd *cobra.Command, args []string) error {
@@ -38,6 +44,9 @@ Examples:
 
 		profiles := cfg.ListProfiles()
 
+		if historyLog {
+			return showConnectionLog(cmd, cfg)
+		}
 		if historyStats {
 			return showStats(cmd, profiles)
 		}
@@ -109,6 +118,40 @@ func showHistory(cmd *cobra.Command, profiles []config.Profile) error {
 	return nil
 }
 
+func showConnectionLog(cmd *cobra.Command, cfg config.Config) error {
+	st, err := state.Load("")
+	if err != nil {
+		return err
+	}
+	recent := st.Recent(historyLimit)
+
+	if OutputJSON() {
+		enc := json.NewEncoder(cmd.OutOrStdout())
+		enc.SetIndent("", "  ")
+		return enc.Encode(recent)
+	}
+	if len(recent) == 0 {
+		fmt.Println("No connection history.")
+		return nil
+	}
+
+	fmt.Println("Connections:")
+	fmt.Println()
+	for _, c := range recent {
+		target := "(profile removed)"
+		if p, ok := cfg.GetProfile(c.Profile); ok {
+			target = p.Username + "@" + p.Host
+		}
+		fmt.Printf("  %s  %-20s  %s  (%s)\n",
+			c.Time.Local().Format("2006-01-02 15:04"),
+			c.Profile,
+			target,
+			c.Duration.Round(time.Second),
+		)
+	}
+	return nil
+}
+
 func showStats(cmd *cobra.Command, profiles []config.Profile) error {
 	if len(profiles) == 0 {
 		fmt.Println("No profiles configured.")
@@ -242,5 +285,6 @@ func formatTimeAgo(t time.Time) string {
 func init() {
 	cmdHistory.Flags().IntVarP(&historyLimit, "limit", "n", 10, "number of entries to show")
 	cmdHistory.Flags().BoolVar(&historyStats, "stats", false, "show usage statistics")
+	cmdHistory.Flags().BoolVar(&historyLog, "log", false, "show individual connections instead of profiles")
 }
 
@@ -1,12 +1,24 @@
 package cli
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
+	"golang.org/x/crypto/ssh"
 
+	"github.com/vee-sh/veessh/internal/audit"
 	"github.com/vee-sh/veessh/internal/config"
+	"github.com/vee-sh/veessh/internal/connectors"
 	"github.com/vee-sh/veessh/internal/hostkeys"
 )
 
@@ -15,36 +27,73 @@ var cmdHostkey = &cobra.Command{
 	Short: "Manage host key verification",
 	Long: `Manage host key fingerprints for verification.
 
+Connect, run, scp and rsync check the key of a host with pinned keys as
+hostKeyPolicy says, in the config or per profile:
+  strict  - the connection accepts the pinned keys only: OpenSSH is given
+            them as its known_hosts, the native client checks them itself
+            (the default)
+  warn    - fetch the host's key beforehand and compare it; on a mismatch
+            warn and connect anyway
+  off     - do not check
+Mismatches are written to the audit log. Hosts behind ProxyJump are
+fetched through the jump hosts with ssh in batch mode.
+
+Keys pinned by profile name apply to that profile only; keys pinned by
+host[:port] apply to every profile connecting there without pins of its
+own. A host can have keys of several types pinned. IPv6 addresses are
+given in brackets with a port, e.g. [2001:db8::1]:2222.
+
 Subcommands:
   show    - Display a host's current key fingerprint
   pin     - Pin a host's current key for future verification
+  unpin   - Remove pinned keys
+  rotate  - Plan or finish the replacement of a pinned key
   verify  - Verify a host's key against pinned fingerprint
   list    - List all pinned keys`,
 }
 
+var (
+	hostkeyTypes     []string
+	hostkeyAll       bool
+	hostkeyComment   string
+	hostkeyUnpinType string
+	rotateNext       string
+	rotateType       string
+	rotateValidUntil string
+	rotateDone       bool
+)
+
+// pinnableTypes are the host key types 'hostkey pin --all' asks for
+var pinnableTypes = []string{
+	ssh.KeyAlgoED25519,
+	ssh.KeyAlgoECDSA256,
+	ssh.KeyAlgoECDSA384,
+	ssh.KeyAlgoECDSA521,
+	ssh.KeyAlgoRSA,
+}
+
 var cmdHostkeyShow = &cobra.Command{
 	Use:   "show <profile|host[:port]>",
 	Short: "Show a host's SSH key fingerprint",
 	Args:  cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		target := args[0]
-		host, port, err := resolveHostPort(target)
+		t, err := resolveHostKeyTarget(args[0])
 		if err != nil {
 			return err
 		}
 
-		fmt.Printf("Fetching host key from %s:%d...\n", host, port)
-		keyType, fingerprint, err := hostkeys.GetHostFingerprint(host, port)
+		fmt.Printf("Fetching host key from %s...\n", t.address())
+		keyType, fingerprint, err := hostkeys.Fetch(cmd.Context(), t.host, t.port, t.proxyJump)
 		if err != nil {
 			return err
 		}
 
-		fmt.Printf("\nHost:        %s:%d\n", host, port)
+		fmt.Printf("\nHost:        %s\n", t.address())
 		fmt.Printf("Key Type:    %s\n", keyType)
 		fmt.Printf("Fingerprint: %s\n", fingerprint)
 
 		// Check if in known_hosts
-		inKnown, _ := hostkeys.IsHostInKnownHosts(host, port)
+		inKnown, _ := hostkeys.IsHostInKnownHosts(t.host, t.port)
 		if inKnown {
 			fmt.Println("Status:      In known_hosts")
 		} else {
@@ -60,63 +109,314 @@ var cmdHostkeyPin = &cobra.Command{
 	Short: "Pin a host's current key fingerprint",
 	Long: `Pin a host's SSH key fingerprint for future verification.
 
-When connecting to a host with a pinned key, veessh can verify
-the key hasn't changed (protection against MITM attacks).`,
+When connecting to a host with a pinned key, veessh verifies the key
+hasn't changed (protection against MITM attacks); see 'veessh hostkey'.
+
+By default the key the host prefers is pinned; --type pins the host's key
+of that type instead, and --all every key it has. Pinning a type again
+replaces its pinned key.`,
+	Example: `  veessh hostkey pin web
+  veessh hostkey pin web --type ssh-ed25519 --type ssh-rsa
+  veessh hostkey pin '[2001:db8::1]:2222' --all`,
 	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		target := args[0]
-		host, port, err := resolveHostPort(target)
+		t, err := resolveHostKeyTarget(args[0])
 		if err != nil {
 			return err
 		}
 
-		fmt.Printf("Fetching host key from %s:%d...\n", host, port)
-		keyType, fingerprint, err := hostkeys.GetHostFingerprint(host, port)
+		types := hostkeyTypes
+		if hostkeyAll {
+			types = pinnableTypes
+		} else if len(types) == 0 {
+			types = []string{""} // The host's choice
+		}
+
+		fmt.Printf("Fetching host key from %s...\n", t.address())
+		var keys []hostkeys.Key
+		for _, keyType := range types {
+			var key ssh.PublicKey
+			key, err = hostkeys.FetchKey(cmd.Context(), t.host, t.port, t.proxyJump, keyType)
+			if err != nil {
+				if hostkeyAll {
+					continue // The host has no key of this type
+				}
+				return err
+			}
+			k := hostkeys.KeyOf(key, time.Now())
+			k.Comment = hostkeyComment
+			keys = append(keys, k)
+			fmt.Printf("Key Type:    %s\n", k.Type)
+			fmt.Printf("Fingerprint: %s\n", k.Fingerprint)
+		}
+		if len(keys) == 0 {
+			return fmt.Errorf("failed to get any host key from %s: %w", t.address(), err)
+		}
+
+		err = hostkeys.UpdateStore(func(s *hostkeys.Store) error {
+			for _, k := range keys {
+				s.Pin(t.profile, t.host, t.port, k)
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to pin key: %w", err)
+		}
+
+		if t.profile != "" {
+			fmt.Printf("\nKey pinned successfully for profile %s!\n", t.profile)
+		} else {
+			fmt.Println("\nKey pinned successfully!")
+		}
+		return nil
+	},
+}
+
+var cmdHostkeyUnpin = &cobra.Command{
+	Use:   "unpin <profile|host[:port]>",
+	Short: "Remove pinned host keys",
+	Long: `Remove the keys pinned for a host, or with --type only those of one type.
+
+For a profile this removes the keys that apply to it: its own if it has
+any, else those pinned for its host, which every profile connecting there
+shares.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		t, err := resolveHostKeyTarget(args[0])
 		if err != nil {
 			return err
 		}
 
-		fmt.Printf("Key Type:    %s\n", keyType)
-		fmt.Printf("Fingerprint: %s\n", fingerprint)
+		var removed int
+		var scope string
+		err = hostkeys.UpdateStore(func(s *hostkeys.Store) error {
+			pin := s.Find(t.profile, t.host, t.port)
+			if pin == nil {
+				return fmt.Errorf("no key is pinned for %s", t.address())
+			}
+			scope = pinScope(*pin)
+			removed = s.Unpin(pin.Profile, pin.Host, pin.Port, hostkeyUnpinType)
+			if removed == 0 {
+				return fmt.Errorf("no %s key is pinned for %s", hostkeyUnpinType, t.address())
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
 
-		if err := hostkeys.PinKey(host, port, keyType, fingerprint, ""); err != nil {
-			return fmt.Errorf("failed to pin key: %w", err)
+		fmt.Printf("Unpinned %d key(s) of %s (%s).\n", removed, t.address(), scope)
+		return nil
+	},
+}
+
+var cmdHostkeyRotate = &cobra.Command{
+	Use:   "rotate <profile|host[:port]>",
+	Short: "Plan or finish the replacement of a pinned host key",
+	Long: `Replace a pinned host key without a window in which connecting fails.
+
+Before the host's key is changed, pin the new key with --next, as its
+public key, a .pub file or its fingerprint. It is accepted from then on,
+alongside the current key, which stays accepted until --valid-until if
+given, else until the rotation is finished. Once the host presents the new key, finish the rotation with
+--done, which checks that it does and drops the old key. With only the
+fingerprint pinned, strict connections through OpenSSH fail the first
+time the host presents the new key, which is then recorded.
+
+--type picks the key type to rotate when several are pinned. --valid-until
+takes a date, an RFC 3339 time, or a time from now such as 30d or 12h.`,
+	Example: `  veessh hostkey rotate web --next ssh_host_ed25519_key.pub --valid-until 14d
+  veessh hostkey rotate web --next SHA256:8Yk0f3Jx...
+  veessh hostkey rotate web --done`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if rotateDone == (rotateNext != "") {
+			return fmt.Errorf("give either --next or --done")
+		}
+		t, err := resolveHostKeyTarget(args[0])
+		if err != nil {
+			return err
+		}
+		s, err := hostkeys.LoadStore()
+		if err != nil {
+			return err
+		}
+		pin := s.Find(t.profile, t.host, t.port)
+		if pin == nil {
+			return fmt.Errorf("no key is pinned for %s; pin one first", t.address())
+		}
+		if rotateDone {
+			keyType, err := rotationType(*pin, rotateType, true)
+			if err != nil {
+				return err
+			}
+			return finishRotation(cmd, t, *pin, keyType)
+		}
+
+		next, err := parseNextKey(rotateNext)
+		if err != nil {
+			return err
+		}
+		if next.Type == "" {
+			if next.Type, err = rotationType(*pin, rotateType, false); err != nil {
+				return err
+			}
+		} else if rotateType != "" && rotateType != next.Type {
+			return fmt.Errorf("the next key is a %s key, not %s", next.Type, rotateType)
+		}
+		var validUntil time.Time
+		if rotateValidUntil != "" {
+			if validUntil, err = parseValidUntil(rotateValidUntil); err != nil {
+				return err
+			}
+		}
+		next.PinnedAt, next.Comment = time.Now(), hostkeyComment
+		err = hostkeys.UpdateStore(func(s *hostkeys.Store) error {
+			return s.PlanRotation(pin.Profile, pin.Host, pin.Port, next, validUntil)
+		})
+		if err != nil {
+			return err
 		}
 
-		fmt.Println("\nKey pinned successfully!")
+		fmt.Printf("Next %s key of %s pinned: %s\n", next.Type, t.address(), next.Fingerprint)
+		if validUntil.IsZero() {
+			fmt.Println("The current key stays accepted until the rotation is finished.")
+		} else {
+			fmt.Printf("The current key is accepted until %s.\n", validUntil.Format("2006-01-02 15:04"))
+		}
+		fmt.Printf("Once the host presents the new key, run 'veessh hostkey rotate %s --done'.\n", args[0])
 		return nil
 	},
 }
 
+// parseNextKey reads the next key of a rotation: a public key, a .pub file
+// holding one, or a fingerprint, which leaves the type to be picked
+func parseNextKey(s string) (hostkeys.Key, error) {
+	if strings.HasPrefix(s, "SHA256:") {
+		return hostkeys.Key{Fingerprint: s}, nil
+	}
+	data := []byte(s)
+	if !strings.Contains(s, " ") {
+		if b, err := os.ReadFile(s); err == nil {
+			data = b
+		}
+	}
+	key, _, _, _, err := ssh.ParseAuthorizedKey(data)
+	if err != nil {
+		return hostkeys.Key{}, fmt.Errorf("invalid next key %q (want a public key, a .pub file or a SHA256:... fingerprint as 'ssh-keygen -lf' prints it)", s)
+	}
+	return hostkeys.KeyOf(key, time.Time{}), nil
+}
+
+// rotationType returns the key type of pin to rotate: keyType if given,
+// else the only type pinned, or the only type with a planned rotation
+// when finishing one
+func rotationType(pin hostkeys.Pin, keyType string, done bool) (string, error) {
+	if keyType != "" {
+		return keyType, nil
+	}
+	var types []string
+	for _, k := range pin.Keys {
+		if k.Next == done && !slices.Contains(types, k.Type) {
+			types = append(types, k.Type)
+		}
+	}
+	switch {
+	case len(types) == 1:
+		return types[0], nil
+	case len(types) == 0 && done:
+		return "", fmt.Errorf("no rotation is planned for %s", pin.Address())
+	}
+	return "", fmt.Errorf("%s has keys of several types pinned (%s); pick one with --type", pin.Address(), strings.Join(types, ", "))
+}
+
+// finishRotation makes the next key of keyType the pinned one once the
+// host presents it
+func finishRotation(cmd *cobra.Command, t hostKeyTarget, pin hostkeys.Pin, keyType string) error {
+	i := slices.IndexFunc(pin.Keys, func(k hostkeys.Key) bool { return k.Type == keyType && k.Next })
+	if i < 0 {
+		return fmt.Errorf("no rotation of the %s key of %s is planned", keyType, t.address())
+	}
+	fmt.Printf("Fetching host key from %s...\n", t.address())
+	key, err := hostkeys.FetchKey(cmd.Context(), t.host, t.port, t.proxyJump, keyType)
+	if err != nil {
+		return err
+	}
+	current := hostkeys.Fingerprint(key)
+	if !hostkeys.SameFingerprint(current, pin.Keys[i].Fingerprint) {
+		return fmt.Errorf("%s presents %s, not the next key %s; finish the rotation once the host has the new key", t.address(), current, pin.Keys[i].Fingerprint)
+	}
+
+	err = hostkeys.UpdateStore(func(s *hostkeys.Store) error {
+		if _, err := s.FinishRotation(pin.Profile, pin.Host, pin.Port, keyType); err != nil {
+			return err
+		}
+		s.AddPublicKey(pin.Profile, pin.Host, pin.Port, key)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Rotation finished: the %s key of %s is now %s.\n", keyType, t.address(), current)
+	return nil
+}
+
+// parseValidUntil parses a date, an RFC 3339 time, or an age from now
+func parseValidUntil(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, s, time.Local); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation(time.DateTime, s, time.Local); err == nil {
+		return t, nil
+	}
+	age, err := parseAge(s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q (use e.g. 2006-01-02, 2006-01-02T15:04:05Z or 14d)", s)
+	}
+	return time.Now().Add(age), nil
+}
+
 var cmdHostkeyVerify = &cobra.Command{
 	Use:   "verify <profile|host[:port]>",
 	Short: "Verify a host's key against pinned fingerprint",
 	Args:  cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		target := args[0]
-		host, port, err := resolveHostPort(target)
+		t, err := resolveHostKeyTarget(args[0])
 		if err != nil {
 			return err
 		}
 
-		fmt.Printf("Verifying host key for %s:%d...\n", host, port)
-
-		matched, pinnedFP, currentFP, err := hostkeys.VerifyPinnedKey(host, port)
+		s, err := hostkeys.LoadStore()
 		if err != nil {
 			return err
 		}
-
-		if pinnedFP == "" {
+		pin := s.Find(t.profile, t.host, t.port)
+		if pin == nil {
 			fmt.Println("No pinned key found for this host.")
 			fmt.Println("Use 'veessh hostkey pin' to pin the current key.")
 			return nil
 		}
 
-		fmt.Printf("Pinned:  %s\n", pinnedFP)
-		fmt.Printf("Current: %s\n", currentFP)
+		fmt.Printf("Verifying host key for %s...\n", t.address())
+		now := time.Now()
+		keyType, currentFP, matched, err := pin.Verify(cmd.Context(), t.proxyJump, now)
+		if err != nil {
+			return err
+		}
+
+		for _, k := range pin.Keys {
+			fmt.Printf("Pinned:  %s %s%s\n", k.Type, k.Fingerprint, keyState(k, now))
+		}
+		fmt.Printf("Current: %s %s\n", keyType, currentFP)
 
 		if matched {
 			fmt.Println("\n[OK] Host key matches pinned fingerprint!")
+			if slices.ContainsFunc(pin.Keys, func(k hostkeys.Key) bool { return k.Next && hostkeys.SameFingerprint(k.Fingerprint, currentFP) }) {
+				fmt.Printf("The host presents the next key; finish the rotation with 'veessh hostkey rotate %s --done'.\n", args[0])
+			}
 		} else {
 			fmt.Println("\n[WARNING] Host key does NOT match pinned fingerprint!")
 			fmt.Println("This could indicate a man-in-the-middle attack or server key change.")
@@ -130,24 +430,36 @@ var cmdHostkeyList = &cobra.Command{
 	Use:   "list",
 	Short: "List all pinned host keys",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		keys, err := hostkeys.LoadPinnedKeys()
+		s, err := hostkeys.LoadStore()
 		if err != nil {
 			return err
 		}
 
-		if len(keys) == 0 {
+		if OutputJSON() {
+			pins := s.Pins
+			if pins == nil {
+				pins = []hostkeys.Pin{}
+			}
+			enc := json.NewEncoder(cmd.OutOrStdout())
+			enc.SetIndent("", "  ")
+			return enc.Encode(pins)
+		}
+
+		if len(s.Pins) == 0 {
 			fmt.Println("No pinned keys.")
 			return nil
 		}
 
+		now := time.Now()
 		fmt.Println("Pinned host keys:")
 		fmt.Println()
-		for _, k := range keys {
-			fmt.Printf("  %s:%d\n", k.Host, k.Port)
-			fmt.Printf("    Type: %s\n", k.KeyType)
-			fmt.Printf("    Fingerprint: %s\n", k.Fingerprint)
-			if k.Comment != "" {
-				fmt.Printf("    Comment: %s\n", k.Comment)
+		for _, pin := range s.Pins {
+			fmt.Printf("  %s (%s)\n", pin.Address(), pinScope(pin))
+			for _, k := range pin.Keys {
+				fmt.Printf("    %s %s%s\n", k.Type, k.Fingerprint, keyState(k, now))
+				if k.Comment != "" {
+					fmt.Printf("      Comment: %s\n", k.Comment)
+				}
 			}
 			fmt.Println()
 		}
@@ -156,36 +468,266 @@ var cmdHostkeyList = &cobra.Command{
 	},
 }
 
-func resolveHostPort(target string) (host string, port int, err error) {
-	port = 22
+// pinScope says whom pin applies to
+func pinScope(pin hostkeys.Pin) string {
+	if pin.Profile != "" {
+		return "profile " + pin.Profile
+	}
+	return "all profiles"
+}
+
+// keyState describes where k stands in a rotation, if anywhere
+func keyState(k hostkeys.Key, now time.Time) string {
+	var state []string
+	if k.Next {
+		state = append(state, "next")
+	}
+	switch {
+	case k.ValidUntil.IsZero():
+	case k.Valid(now):
+		state = append(state, "valid until "+k.ValidUntil.Format("2006-01-02 15:04"))
+	default:
+		state = append(state, "expired "+k.ValidUntil.Format("2006-01-02 15:04"))
+	}
+	if len(state) == 0 {
+		return ""
+	}
+	return " [" + strings.Join(state, ", ") + "]"
+}
 
+// hostKeyTarget is what a hostkey command works on: a profile's host, or
+// a host given as host[:port]
+type hostKeyTarget struct {
+	profile   string // Empty for a host given directly
+	host      string
+	port      int
+	proxyJump string
+}
+
+func (t hostKeyTarget) address() string {
+	return net.JoinHostPort(t.host, strconv.Itoa(t.port))
+}
+
+func resolveHostKeyTarget(target string) (hostKeyTarget, error) {
 	// Check if it's a profile name
 	cfgPath, err := config.DefaultPath()
 	if err == nil {
 		cfg, err := config.Load(cfgPath)
 		if err == nil {
 			if p, ok := cfg.GetProfile(target); ok {
-				if p.Port > 0 {
-					port = p.Port
+				t := hostKeyTarget{profile: p.Name, host: p.Host, port: p.Port, proxyJump: p.ProxyJump}
+				if t.port <= 0 {
+					t.port = 22
 				}
-				return p.Host, port, nil
+				return t, nil
 			}
 		}
 	}
 
-	// Parse as host:port
-	if h, p, err := net.SplitHostPort(target); err == nil {
-		fmt.Sscanf(p, "%d", &port)
-		return h, port, nil
+	// Parse as host[:port], [v6host]:port or a bare IPv6 address
+	host, port, err := hostkeys.SplitAddress(target)
+	if err != nil {
+		return hostKeyTarget{}, err
 	}
+	return hostKeyTarget{host: host, port: port}, nil
+}
 
-	// Just a hostname
-	return target, port, nil
+// checkHostKey applies the keys pinned for p's host as the profile's host
+// key policy says, and returns the context to connect with. strict has the
+// connection itself accept the pinned keys only (see hostkeys.NewContext);
+// keys pinned without the public key OpenSSH needs get it first, from the
+// host presenting a key of their fingerprint. warn fetches the host's key
+// beforehand and writes a warning to w on a mismatch, as ssh cannot be
+// told to merely warn. Hosts without a pin are not checked.
+func checkHostKey(ctx context.Context, cfg config.Config, p config.Profile, w io.Writer) (context.Context, error) {
+	switch p.Protocol {
+	case config.ProtocolSSH, config.ProtocolSFTP, config.ProtocolMosh:
+	default:
+		return ctx, nil // No SSH host key
+	}
+	port := p.Port
+	if port <= 0 {
+		port = 22
+	}
+
+	store, err := hostkeys.LoadStore()
+	if err != nil {
+		// The host may have keys pinned
+		if policy := cfg.HostKeyPolicyOf(p, true); policy != config.HostKeyOff {
+			return ctx, hostKeyProblem(policy, p, w, false, "cannot read the pinned keys: %v", err)
+		}
+		return ctx, nil
+	}
+	pin := store.Find(p.Name, p.Host, port)
+	policy := cfg.HostKeyPolicyOf(p, pin != nil)
+	if pin == nil || policy == config.HostKeyOff {
+		return ctx, nil
+	}
+	addr := net.JoinHostPort(p.Host, strconv.Itoa(port))
+	now := time.Now()
+	types := pin.Types(now)
+	if len(types) == 0 {
+		return ctx, hostKeyProblem(policy, p, w, false, "every key pinned for %s has expired", addr)
+	}
+
+	if policy == config.HostKeyStrict {
+		for _, keyType := range types {
+			if hasPublicKey(*pin, keyType, now) {
+				continue
+			}
+			if err := addPublicKey(ctx, pin, p.ProxyJump, keyType, now); err != nil {
+				if ctx.Err() != nil {
+					return ctx, context.Canceled
+				}
+				var mismatch *hostkeys.MismatchError
+				return ctx, hostKeyProblem(policy, p, w, errors.As(err, &mismatch), "%s", hostKeyMessage(err, p))
+			}
+		}
+		enforced, err := hostkeys.NewContext(ctx, *pin, now)
+		if err != nil {
+			return ctx, hostKeyProblem(policy, p, w, false, "cannot enforce the pinned keys of %s: %v", addr, err)
+		}
+		return enforced, nil
+	}
+
+	key, err := hostkeys.FetchKey(ctx, p.Host, port, p.ProxyJump, types...)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx, context.Canceled
+		}
+		return ctx, hostKeyProblem(policy, p, w, false, "cannot check the pinned keys of %s: %v", addr, err)
+	}
+	if !pin.Accepts(key.Type(), hostkeys.Fingerprint(key), now) {
+		return ctx, hostKeyProblem(policy, p, w, true, "%s", hostKeyMessage(pin.Mismatch(key, now), p))
+	}
+	return ctx, nil
+}
+
+// hasPublicKey reports whether a key of keyType valid at now is pinned
+// with its public key
+func hasPublicKey(pin hostkeys.Pin, keyType string, now time.Time) bool {
+	return slices.ContainsFunc(pin.Keys, func(k hostkeys.Key) bool {
+		return k.Type == keyType && k.Valid(now) && k.PublicKey != ""
+	})
+}
+
+// addPublicKey fetches the host's key of keyType and records it as the
+// public key of the key of pin with its fingerprint. A key pin does not
+// accept is a *hostkeys.MismatchError.
+func addPublicKey(ctx context.Context, pin *hostkeys.Pin, proxyJump, keyType string, now time.Time) error {
+	key, err := hostkeys.FetchKey(ctx, pin.Host, pin.Port, proxyJump, keyType)
+	if err != nil {
+		return fmt.Errorf("cannot check the pinned keys of %s: %w", pin.Address(), err)
+	}
+	if !pin.Accepts(key.Type(), hostkeys.Fingerprint(key), now) {
+		return pin.Mismatch(key, now)
+	}
+	err = hostkeys.UpdateStore(func(s *hostkeys.Store) error {
+		s.AddPublicKey(pin.Profile, pin.Host, pin.Port, key)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("cannot record the public key of %s: %w", pin.Address(), err)
+	}
+	for i, k := range pin.Keys {
+		if k.Type == key.Type() && hostkeys.SameFingerprint(k.Fingerprint, hostkeys.Fingerprint(key)) {
+			pin.Keys[i].PublicKey = hostkeys.KeyOf(key, k.PinnedAt).PublicKey
+		}
+	}
+	return nil
+}
+
+// hostKeyMessage explains err, adding what to do about a mismatch
+func hostKeyMessage(err error, p config.Profile) string {
+	var mismatch *hostkeys.MismatchError
+	if !errors.As(err, &mismatch) {
+		return err.Error()
+	}
+	return fmt.Sprintf("%v (possible man-in-the-middle attack; if the key was changed on purpose, pin it again with 'veessh hostkey pin %s')", mismatch, p.Name)
+}
+
+// Exit statuses with which tools report failing themselves, on a refused
+// host key among others
+const (
+	sshFailed = 255 // Also rsync's
+	scpFailed = 1
+)
+
+// hostKeyRefused follows up err, the failure of a connection to p made
+// with ctx. With a pin enforced it may have been the host key: a key the
+// pin does not accept is audited, and a key pinned without its public key
+// (a next key given by fingerprint) gets it for the next connection.
+// failed is the exit status the tool reports its own failures with;
+// other statuses are the remote command's.
+func hostKeyRefused(ctx context.Context, p config.Profile, err error, failed int) error {
+	pin := hostkeys.FromContext(ctx)
+	if err == nil || pin == nil || ctx.Err() != nil {
+		return err
+	}
+	var mismatch *hostkeys.MismatchError
+	if !errors.As(err, &mismatch) {
+		// OpenSSH only says it refused; ask the host what it presents
+		if connectors.ExitCode(err) != failed {
+			return err
+		}
+		now := time.Now()
+		key, fetchErr := hostkeys.FetchKey(ctx, pin.Host, pin.Port, p.ProxyJump, pin.Types(now)...)
+		if fetchErr != nil {
+			return err
+		}
+		if pin.Accepts(key.Type(), hostkeys.Fingerprint(key), now) {
+			if hasKey(*pin, key) {
+				return err // Not the host key
+			}
+			if addPublicKey(ctx, pin, p.ProxyJump, key.Type(), now) == nil {
+				return fmt.Errorf("%w; %s presents its pinned %s key %s, which it had not before, so connect again", err, pin.Address(), key.Type(), hostkeys.Fingerprint(key))
+			}
+			return err
+		}
+		mismatch = pin.Mismatch(key, now)
+	}
+	msg := hostKeyMessage(mismatch, p)
+	audit.LogHostKey(p.Name, string(p.Protocol), p.Host, p.Username, fmt.Sprintf("%s (hostKeyPolicy %s)", msg, config.HostKeyStrict))
+	return fmt.Errorf("host key check failed: %s", msg)
+}
+
+// hasKey reports whether key is pinned with its public key
+func hasKey(pin hostkeys.Pin, key ssh.PublicKey) bool {
+	return slices.ContainsFunc(pin.Keys, func(k hostkeys.Key) bool {
+		return k.PublicKey != "" && hostkeys.SameFingerprint(k.Fingerprint, hostkeys.Fingerprint(key))
+	})
+}
+
+// hostKeyProblem reports a failed host key check: strict refuses to go on,
+// warn warns. Mismatches are audited, and so is every refusal.
+func hostKeyProblem(policy string, p config.Profile, w io.Writer, mismatch bool, format string, args ...any) error {
+	msg := fmt.Sprintf(format, args...)
+	strict := policy == config.HostKeyStrict
+	if mismatch || strict {
+		audit.LogHostKey(p.Name, string(p.Protocol), p.Host, p.Username, fmt.Sprintf("%s (hostKeyPolicy %s)", msg, policy))
+	}
+	if strict {
+		return fmt.Errorf("host key check failed: %s; not connecting as hostKeyPolicy is strict", msg)
+	}
+	fmt.Fprintf(w, "Warning: %s\n", msg)
+	return nil
 }
 
 func init() {
+	cmdHostkeyPin.Flags().StringSliceVar(&hostkeyTypes, "type", nil, "pin the host's key of this type (repeatable), e.g. ssh-ed25519")
+	cmdHostkeyPin.Flags().BoolVar(&hostkeyAll, "all", false, "pin every key the host has")
+	cmdHostkeyPin.Flags().StringVar(&hostkeyComment, "comment", "", "note kept with the pinned key")
+	cmdHostkeyUnpin.Flags().StringVar(&hostkeyUnpinType, "type", "", "only unpin keys of this type")
+	cmdHostkeyRotate.Flags().StringVar(&rotateNext, "next", "", "the host's new key: its public key, .pub file or fingerprint (SHA256:...)")
+	cmdHostkeyRotate.Flags().StringVar(&rotateType, "type", "", "key type to rotate, when several are pinned")
+	cmdHostkeyRotate.Flags().StringVar(&rotateValidUntil, "valid-until", "", "stop accepting the current key then, e.g. 2026-12-01 or 14d")
+	cmdHostkeyRotate.Flags().BoolVar(&rotateDone, "done", false, "finish the rotation once the host presents the new key")
+	cmdHostkeyRotate.Flags().StringVar(&hostkeyComment, "comment", "", "note kept with the next key")
+	cmdHostkeyPin.MarkFlagsMutuallyExclusive("type", "all")
 	cmdHostkey.AddCommand(cmdHostkeyShow)
 	cmdHostkey.AddCommand(cmdHostkeyPin)
+	cmdHostkey.AddCommand(cmdHostkeyUnpin)
+	cmdHostkey.AddCommand(cmdHostkeyRotate)
 	cmdHostkey.AddCommand(cmdHostkeyVerify)
 	cmdHostkey.AddCommand(cmdHostkeyList)
 }
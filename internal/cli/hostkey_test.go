@@ -0,0 +1,200 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"io"
+	"net"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/vee-sh/veessh/internal/audit"
+	"github.com/vee-sh/veessh/internal/config"
+	"github.com/vee-sh/veessh/internal/hostkeys"
+)
+
+// startHostKeyServer starts an SSH server that only shows its host key
+func startHostKeyServer(t *testing.T) (port int, key ssh.PublicKey) {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &ssh.ServerConfig{
+		PasswordCallback: func(ssh.ConnMetadata, []byte) (*ssh.Permissions, error) {
+			return nil, errors.New("denied")
+		},
+	}
+	cfg.AddHostKey(signer)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			nc, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				ssh.NewServerConn(nc, cfg)
+				nc.Close()
+			}()
+		}
+	}()
+	return l.Addr().(*net.TCPAddr).Port, signer.PublicKey()
+}
+
+func TestCheckHostKey(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	port, key := startHostKeyServer(t)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+
+	current := hostkeys.Key{Type: key.Type(), Fingerprint: hostkeys.Fingerprint(key)}
+	stale := hostkeys.Key{Type: key.Type(), Fingerprint: "SHA256:c3RhbGUga2V5"}
+	err = hostkeys.UpdateStore(func(s *hostkeys.Store) error {
+		s.Pin("", "127.0.0.1", port, current)
+		s.Pin("", "localhost", port, stale)
+		s.Pin("", "localhost", closed, current)
+		// Its own pin overrides the host's
+		s.Pin("db", "127.0.0.1", port, stale)
+		s.Pin("api", "localhost", port, current)
+		// Mid-rotation to the key the host has
+		s.Pin("rotating", "127.0.0.1", port, stale)
+		return s.PlanRotation("rotating", "127.0.0.1", port, current, time.Now().Add(time.Hour))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name, profile, host, policy string
+		port                        int
+		wantErr, wantWarn           string
+		audited                     bool
+	}{
+		{"matching pin", "web", "127.0.0.1", config.HostKeyStrict, port, "", "", false},
+		{"no pin", "web", "127.0.0.2", config.HostKeyStrict, port, "", "", false},
+		{"mismatch strict", "web", "localhost", config.HostKeyStrict, port, "not the pinned SHA256:c3RhbGUga2V5", "", true},
+		{"mismatch warn", "web", "localhost", config.HostKeyWarn, port, "", "not the pinned SHA256:c3RhbGUga2V5", true},
+		{"mismatch default", "web", "localhost", "", port, "not the pinned", "", true},
+		{"matching default", "web", "127.0.0.1", "", port, "", "", false},
+		{"no pin default", "web", "127.0.0.2", "", port, "", "", false},
+		{"mismatch off", "web", "localhost", config.HostKeyOff, port, "", "", false},
+		{"unreachable strict", "web", "localhost", config.HostKeyStrict, closed, "cannot check", "", true},
+		{"unreachable warn", "web", "localhost", config.HostKeyWarn, closed, "", "cannot check", false},
+		{"profile pin mismatch", "db", "127.0.0.1", config.HostKeyStrict, port, "not the pinned", "", true},
+		{"profile pin matching", "api", "localhost", config.HostKeyStrict, port, "", "", false},
+		{"next key during rotation", "rotating", "127.0.0.1", config.HostKeyStrict, port, "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before, _ := audit.ReadEntries(audit.Filter{Actions: []string{"hostkey"}}, 0)
+			p := config.Profile{Name: tt.profile, Protocol: config.ProtocolSSH, Host: tt.host, Port: tt.port, HostKeyPolicy: tt.policy}
+			var warn bytes.Buffer
+			ctx, err := checkHostKey(context.Background(), config.Config{}, p, &warn)
+
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("error = %v, want %q", err, tt.wantErr)
+			}
+			if tt.wantWarn == "" && warn.Len() > 0 || !strings.Contains(warn.String(), tt.wantWarn) {
+				t.Errorf("warning = %q, want %q", warn.String(), tt.wantWarn)
+			}
+			after, _ := audit.ReadEntries(audit.Filter{Actions: []string{"hostkey"}}, 0)
+			if audited := len(after) > len(before); audited != tt.audited {
+				t.Errorf("audited = %v, want %v", audited, tt.audited)
+			}
+			// Strict connections enforce the pin themselves
+			strict := tt.policy == config.HostKeyStrict || tt.policy == ""
+			if enforced := hostkeys.FromContext(ctx) != nil; enforced != (err == nil && strict && tt.host != "127.0.0.2") {
+				t.Errorf("pin enforced = %v", enforced)
+			}
+		})
+	}
+
+	// Keys pinned by fingerprint got their public key from the host
+	s, err := hostkeys.LoadStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k := s.Find("", "127.0.0.1", port).Keys[0]; k.PublicKey == "" {
+		t.Error("the public key of the matching pin was not recorded")
+	}
+	if k := s.Find("", "localhost", port).Keys[0]; k.PublicKey != "" {
+		t.Error("a public key was recorded for a mismatching pin")
+	}
+}
+
+func TestHostKeyRefused(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	port, key := startHostKeyServer(t)
+	_, other := startHostKeyServer(t)
+	now := time.Now()
+	err := hostkeys.UpdateStore(func(s *hostkeys.Store) error {
+		s.Pin("web", "127.0.0.1", port, hostkeys.KeyOf(other, now))
+		s.Pin("rotating", "127.0.0.1", port, hostkeys.KeyOf(other, now))
+		return s.PlanRotation("rotating", "127.0.0.1", port, hostkeys.Key{Type: key.Type(), Fingerprint: hostkeys.Fingerprint(key)}, time.Time{})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sshFailure := exec.Command("sh", "-c", "exit 255").Run()
+	commandFailure := exec.Command("sh", "-c", "exit 1").Run()
+
+	connect := func(name string) (context.Context, config.Profile) {
+		p := config.Profile{Name: name, Protocol: config.ProtocolSSH, Host: "127.0.0.1", Port: port, HostKeyPolicy: config.HostKeyStrict}
+		// Pinned with their public keys, the host is left to the connection
+		ctx, err := checkHostKey(context.Background(), config.Config{}, p, io.Discard)
+		if err != nil || hostkeys.FromContext(ctx) == nil {
+			t.Fatalf("checkHostKey(%s) = %v, want the pin enforced", name, err)
+		}
+		return ctx, p
+	}
+
+	ctx, p := connect("web")
+	if err := hostKeyRefused(ctx, p, commandFailure, sshFailed); err != commandFailure {
+		t.Errorf("a failing remote command became %v", err)
+	}
+	before, _ := audit.ReadEntries(audit.Filter{Actions: []string{"hostkey"}}, 0)
+	if err := hostKeyRefused(ctx, p, sshFailure, sshFailed); err == nil || !strings.Contains(err.Error(), "not the pinned "+hostkeys.Fingerprint(other)) {
+		t.Errorf("refused key: error = %v", err)
+	}
+	if after, _ := audit.ReadEntries(audit.Filter{Actions: []string{"hostkey"}}, 0); len(after) != len(before)+1 {
+		t.Error("the refused key was not audited")
+	}
+
+	// The next key was pinned by fingerprint, which OpenSSH cannot check
+	ctx, p = connect("rotating")
+	if err := hostKeyRefused(ctx, p, sshFailure, sshFailed); err == nil || !strings.Contains(err.Error(), "connect again") {
+		t.Errorf("next key: error = %v", err)
+	}
+	s, err := hostkeys.LoadStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range s.Find("rotating", "127.0.0.1", port).Keys {
+		if k.Next && k.PublicKey == "" {
+			t.Error("the public key of the next key was not recorded")
+		}
+	}
+}
@@ -1,16 +1,13 @@
 package cli
 
 import (
+	"encoding/json"
 	"fmt"
-	"os"
-	"path/filepath"
-	"strconv"
-	"strings"
 
-	"github.com/kevinburke/ssh_config"
 	"github.com/spf13/cobra"
 
 	"github.com/vee-sh/veessh/internal/config"
+	"github.com/vee-sh/veessh/internal/sshconfig"
 )
 
 var importSSHFile string
@@ -22,20 +19,32 @@ var importSSHDryRun bool
 var cmdImportSSH = &cobra.Command{
 	Use:   "import-ssh",
 	Short: "Import profiles from an OpenSSH config file (~/.ssh/config)",
+	Long: `Import profiles from an OpenSSH client config file.
+
+Include directives are followed. Every concrete Host alias becomes a
+profile. Wildcard blocks (Host *.prod, Match host web-*) become parent
+profiles that the matching hosts extend, and each host keeps only what
+differs from its parent, so it resolves to exactly what ssh would use.
+
+Hostname, User, Port, IdentityFile, ProxyJump, Local/Remote/DynamicForward,
+SetEnv, RemoteCommand and ForwardAgent map to profile fields; any other
+option is kept as '-o Key=Value' in extraArgs. Match blocks that depend on
+runtime conditions (exec, user, localnetwork, ...) cannot be imported.
+
+The report lists every profile imported, every block merged into another
+profile and everything dropped, with the reason and ssh config location.`,
+	Example: `  veessh import-ssh --dry-run
+  veessh import-ssh --file ~/.ssh/config.work --group work --prefix work-
+  veessh import-ssh --overwrite --json`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		if importSSHFile == "" {
-			home, err := os.UserHomeDir()
+			path, err := sshconfig.DefaultPath()
 			if err != nil {
 				return err
 			}
-			importSSHFile = filepath.Join(home, ".ssh", "config")
-		}
-		f, err := os.Open(importSSHFile)
-		if err != nil {
-			return err
+			importSSHFile = path
 		}
-		defer f.Close()
-		cfgSSH, err := ssh_config.Decode(f)
+		cfgSSH, err := sshconfig.ParseFile(importSSHFile)
 		if err != nil {
 			return err
 		}
@@ -52,49 +61,60 @@ var cmdImportSSH = &cobra.Command{
 			cfg.Profiles = map[string]config.Profile{}
 		}
 
+		result := sshconfig.Import(cfgSSH, sshconfig.ImportOptions{Prefix: importSSHPrefix, Group: importSSHGroup})
+		report := result.Report
+
 		imported := 0
 		skipped := 0
-		for _, pat := range cfgSSH.Hosts {
-			for _, pattern := range pat.Patterns {
-				host := pattern.String()
-				if strings.ContainsAny(host, "*? ") { // skip wildcards for now
-					continue
-				}
-				name := importSSHPrefix + host
-				if _, exists := cfg.Profiles[name]; exists && !importSSHOverwrite {
-					skipped++
-					continue
-				}
-				get := func(key string) string { v, _ := cfgSSH.Get(host, key); return v }
-				p := config.Profile{
-					Name:         name,
-					Protocol:     config.ProtocolSSH,
-					Host:         valueOr(get("Hostname"), host),
-					Username:     get("User"),
-					Group:        importSSHGroup,
-					IdentityFile: expandTilde(get("IdentityFile")),
-					Description:  "imported from ssh config",
-				}
-				if port := get("Port"); port != "" {
-					if v, err := strconv.Atoi(port); err == nil {
-						p.Port = v
-					}
-				}
-				if pj := get("ProxyJump"); pj != "" {
-					p.ProxyJump = pj
-				}
-				if !importSSHDryRun {
-					cfg.UpsertProfile(p)
-					imported++
-				}
+		for _, p := range result.Profiles {
+			if _, exists := cfg.Profiles[p.Name]; exists && !importSSHOverwrite {
+				skipped++
+				report = append(report, sshconfig.ReportEntry{
+					Action:  sshconfig.ActionDropped,
+					Profile: p.Name,
+					Detail:  "profile already exists (use --overwrite to replace it)",
+				})
+				continue
+			}
+			imported++
+			if !importSSHDryRun {
+				cfg.UpsertProfile(p)
 			}
 		}
-		if !importSSHDryRun {
+		if !importSSHDryRun && imported > 0 {
 			if err := config.Save(cfgPath, cfg); err != nil {
 				return err
 			}
 		}
-		fmt.Printf("imported %d, skipped %d\n", imported, skipped)
+
+		if OutputJSON() {
+			enc := json.NewEncoder(cmd.OutOrStdout())
+			enc.SetIndent("", "  ")
+			return enc.Encode(map[string]any{
+				"files":    cfgSSH.Files,
+				"imported": imported,
+				"skipped":  skipped,
+				"dryRun":   importSSHDryRun,
+				"report":   report,
+			})
+		}
+
+		for _, e := range report {
+			name := e.Profile
+			if name == "" {
+				name = "-"
+			}
+			line := fmt.Sprintf("%-9s %-24s %s", e.Action, name, e.Detail)
+			if e.Source != "" {
+				line += " (" + e.Source + ")"
+			}
+			fmt.Println(line)
+		}
+		if importSSHDryRun {
+			fmt.Printf("dry run: would import %d, skipped %d, from %d file(s)\n", imported, skipped, len(cfgSSH.Files))
+		} else {
+			fmt.Printf("imported %d, skipped %d, from %d file(s)\n", imported, skipped, len(cfgSSH.Files))
+		}
 		return nil
 	},
 }
@@ -104,25 +124,5 @@ func init() {
 	cmdImportSSH.Flags().StringVar(&importSSHGroup, "group", "", "group to assign to imported profiles")
 	cmdImportSSH.Flags().StringVar(&importSSHPrefix, "prefix", "", "name prefix for imported profiles")
 	cmdImportSSH.Flags().BoolVar(&importSSHOverwrite, "overwrite", false, "overwrite existing profiles")
-	cmdImportSSH.Flags().BoolVar(&importSSHDryRun, "dry-run", false, "parse and show stats without writing")
-}
-
-func valueOr(v string, d string) string {
-	if strings.TrimSpace(v) == "" {
-		return d
-	}
-	return v
+	cmdImportSSH.Flags().BoolVar(&importSSHDryRun, "dry-run", false, "parse and show the report without writing")
 }
-
-func expandTilde(p string) string {
-	if p == "" {
-		return p
-	}
-	if strings.HasPrefix(p, "~/") {
-		if home, err := os.UserHomeDir(); err == nil {
-			return filepath.Join(home, p[2:])
-		}
-	}
-	return p
-}
-
@@ -27,24 +27,9 @@ var cmdList = &cobra.Command{
 		}
 		profiles := cfg.ListProfiles()
 		if len(listTagFilters) > 0 {
-			want := map[string]struct{}{}
-			for _, t := range listTagFilters {
-				want[strings.ToLower(t)] = struct{}{}
-			}
 			filtered := make([]config.Profile, 0, len(profiles))
 			for _, p := range profiles {
-				hasAll := true
-				lowTags := map[string]struct{}{}
-				for _, tg := range p.Tags {
-					lowTags[strings.ToLower(tg)] = struct{}{}
-				}
-				for t := range want {
-					if _, ok := lowTags[t]; !ok {
-						hasAll = false
-						break
-					}
-				}
-				if hasAll {
+				if p.HasTags(listTagFilters...) {
 					filtered = append(filtered, p)
 				}
 			}
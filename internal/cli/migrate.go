@@ -12,8 +12,9 @@ import (
 
 var cmdMigrate = &cobra.Command{
 	Use:   "migrate [from-backend] [to-backend]",
-	Short: "Migrate passwords from one backend to another",
-	Long: `Migrate passwords from one backend to another.
+	Short: "Migrate passwords and other secrets from one backend to another",
+	Long: `Migrate stored secrets (passwords, key passphrases, TOTP seeds and sudo
+passwords) from one backend to another.
 
 Backends: 1password, keyring, file, auto
 
@@ -24,7 +25,7 @@ Examples:
   # Migrate from file to keyring
   veessh migrate file keyring
 
-  # Migrate all passwords to file backend
+  # Migrate all secrets to file backend
   veessh migrate auto file`,
 	Args: cobra.ExactArgs(2),
 	RunE: runMigrate,
@@ -111,45 +112,47 @@ func runMigrate(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("invalid destination backend: %s", toBackend)
 	}
 
-	// Migrate passwords
+	// Migrate every secret kind of every profile
 	migrated := 0
 	failed := 0
 	skipped := 0
 
-	fmt.Printf("Migrating passwords from %s to %s...\n\n", fromBackend, toBackend)
+	fmt.Printf("Migrating secrets from %s to %s...\n\n", fromBackend, toBackend)
 
 	for profileName := range cfg.Profiles {
-		// Get password from source
-		password, err := sourceBackend.GetPassword(profileName)
+		// Get secrets from source
+		secrets, err := credentials.LoadSecrets(sourceBackend, profileName)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "⚠️  Failed to read password for %s from %s: %v\n", profileName, fromBackend, err)
+			fmt.Fprintf(os.Stderr, "⚠️  Failed to read secrets for %s from %s: %v\n", profileName, fromBackend, err)
 			failed++
 			continue
 		}
 
-		if password == "" {
-			// No password stored, skip
-			skipped++
-			continue
+		for _, kind := range credentials.SecretKinds {
+			value := secrets.Get(kind)
+			if value == "" {
+				// Nothing stored, skip
+				continue
+			}
+
+			// Check if secret already exists in destination
+			existing, err := destBackend.GetSecret(profileName, kind)
+			if err == nil && existing != "" {
+				fmt.Printf("⏭️  %s: %s already exists in destination, skipping\n", profileName, kind.Description())
+				skipped++
+				continue
+			}
+
+			// Write to destination
+			if err := destBackend.SetSecret(profileName, kind, value); err != nil {
+				fmt.Fprintf(os.Stderr, "❌ Failed to write %s for %s to %s: %v\n", kind.Description(), profileName, toBackend, err)
+				failed++
+				continue
+			}
+
+			fmt.Printf("✅ %s: Migrated %s\n", profileName, kind.Description())
+			migrated++
 		}
-
-		// Check if password already exists in destination
-		existing, err := destBackend.GetPassword(profileName)
-		if err == nil && existing != "" {
-			fmt.Printf("⏭️  %s: Password already exists in destination, skipping\n", profileName)
-			skipped++
-			continue
-		}
-
-		// Write to destination
-		if err := destBackend.SetPassword(profileName, password); err != nil {
-			fmt.Fprintf(os.Stderr, "❌ Failed to write password for %s to %s: %v\n", profileName, toBackend, err)
-			failed++
-			continue
-		}
-
-		fmt.Printf("✅ %s: Migrated successfully\n", profileName)
-		migrated++
 	}
 
 	fmt.Printf("\nMigration complete:\n")
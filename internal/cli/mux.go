@@ -0,0 +1,168 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/vee-sh/veessh/internal/config"
+	"github.com/vee-sh/veessh/internal/mux"
+)
+
+var muxStopAll bool
+
+var cmdMux = &cobra.Command{
+	Use:   "mux",
+	Short: "Inspect and close shared SSH connections",
+	Long: `veessh shares one SSH connection per profile between connect, run, scp
+and rsync (OpenSSH ControlMaster). The first of them opens the master
+connection; the others reuse it and skip the handshake. The master stays
+open for the profile's controlPersist (default ` + mux.DefaultPersist + `) after the
+last use. Its control socket is kept in a private runtime directory.
+
+Set controlPersist: "no" on a profile, or VEESSH_NO_MUX=1 in the
+environment, to turn sharing off. Profiles that set ControlMaster or
+ControlPath in extraArgs keep their own.`,
+	Example: `  veessh mux status
+  veessh mux stop web
+  veessh mux stop --all`,
+}
+
+// muxMaster is a running master connection
+type muxMaster struct {
+	Profile string `json:"profile"`
+	Host    string `json:"host"`
+	PID     int    `json:"pid"`
+	Socket  string `json:"socket"`
+}
+
+var cmdMuxStatus = &cobra.Command{
+	Use:   "status [profile...]",
+	Short: "List running master connections",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		profiles, err := muxProfiles(args)
+		if err != nil {
+			return err
+		}
+		masters, err := runningMasters(cmd, profiles)
+		if err != nil {
+			return err
+		}
+
+		if OutputJSON() {
+			if masters == nil {
+				masters = []muxMaster{}
+			}
+			enc := json.NewEncoder(cmd.OutOrStdout())
+			enc.SetIndent("", "  ")
+			return enc.Encode(masters)
+		}
+
+		if len(masters) == 0 {
+			fmt.Fprintln(cmd.OutOrStdout(), "No master connections running.")
+			return nil
+		}
+		out := cmd.OutOrStdout()
+		fmt.Fprintf(out, "%-20s  %-8s  %-30s  %s\n", "PROFILE", "PID", "HOST", "SOCKET")
+		for _, m := range masters {
+			fmt.Fprintf(out, "%-20s  %-8d  %-30s  %s\n", m.Profile, m.PID, m.Host, m.Socket)
+		}
+		return nil
+	},
+}
+
+var cmdMuxStop = &cobra.Command{
+	Use:   "stop <profile>...",
+	Short: "Close master connections",
+	Long: `Close the master connections of profiles. Sessions still using a
+master are closed with it.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if muxStopAll != (len(args) == 0) {
+			return errors.New("name the profiles whose connections to close, or use --all")
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		profiles, err := muxProfiles(args)
+		if err != nil {
+			return err
+		}
+		masters, err := runningMasters(cmd, profiles)
+		if err != nil {
+			return err
+		}
+		running := make(map[string]muxMaster, len(masters))
+		for _, m := range masters {
+			running[m.Profile] = m
+		}
+
+		for _, p := range profiles {
+			m, ok := running[p.Name]
+			if !ok {
+				if !muxStopAll {
+					return fmt.Errorf("no master connection to %s is running", p.Name)
+				}
+				continue
+			}
+			if err := mux.Stop(cmd.Context(), p); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Closed master connection to %s (pid %d)\n", p.Name, m.PID)
+		}
+		return nil
+	},
+}
+
+// muxProfiles returns the named profiles, or every SSH and SFTP profile
+// without names
+func muxProfiles(names []string) ([]config.Profile, error) {
+	cfgPath, err := config.DefaultPath()
+	if err != nil {
+		return nil, fmt.Errorf("failed to determine config path: %w", err)
+	}
+	cfg, err := config.Load(cfgPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []config.Profile
+	if len(names) == 0 {
+		// Resolved, as the socket depends on inherited fields
+		for _, p := range cfg.FindProfiles(nil, nil) {
+			if p.Protocol == config.ProtocolSSH || p.Protocol == config.ProtocolSFTP {
+				out = append(out, p)
+			}
+		}
+		return out, nil
+	}
+	for _, name := range names {
+		p, ok := cfg.GetProfile(name)
+		if !ok {
+			return nil, fmt.Errorf("profile %q not found", name)
+		}
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+func runningMasters(cmd *cobra.Command, profiles []config.Profile) ([]muxMaster, error) {
+	var out []muxMaster
+	for _, p := range profiles {
+		pid, err := mux.Check(cmd.Context(), p)
+		if err != nil {
+			return nil, err
+		}
+		if pid > 0 {
+			out = append(out, muxMaster{Profile: p.Name, Host: p.Host, PID: pid, Socket: mux.SocketPath(p)})
+		}
+	}
+	return out, nil
+}
+
+func init() {
+	cmdMuxStop.Flags().BoolVar(&muxStopAll, "all", false, "close every master connection")
+	cmdMux.AddCommand(cmdMuxStatus)
+	cmdMux.AddCommand(cmdMuxStop)
+}
@@ -0,0 +1,40 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/vee-sh/veessh/internal/config"
+	"github.com/vee-sh/veessh/internal/mux"
+)
+
+func TestMuxProfilesResolved(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	t.Setenv(config.NoProjectEnv, "1")
+	cfg := config.Config{Profiles: map[string]config.Profile{
+		"base": {Name: "base", Protocol: config.ProtocolSSH, Username: "deploy", ProxyJump: "bastion"},
+		"web":  {Name: "web", Host: "web.example.com", Extends: config.Parents{"base"}},
+	}}
+	if err := config.Save("", cfg); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := cfg.GetProfile("web")
+
+	all, err := muxProfiles(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range all {
+		if p.Name == "web" {
+			if mux.SocketPath(p) != mux.SocketPath(want) {
+				t.Errorf("socket of web = %s, want %s as connect uses", mux.SocketPath(p), mux.SocketPath(want))
+			}
+			return
+		}
+	}
+	t.Errorf("web not listed: %+v", all)
+}
@@ -44,7 +44,7 @@ var cmdPick = &cobra.Command{
 			fmt.Println(p.Name)
 			return nil
 		}
-		return executeConnection(cmd.Context(), p, true)
+		return executeConnection(cmd.Context(), cfg, p, true)
 	},
 }
 
@@ -0,0 +1,185 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/vee-sh/veessh/internal/config"
+	"github.com/vee-sh/veessh/internal/recording"
+)
+
+var (
+	recordingsOlderThan string
+	recordingsDryRun    bool
+)
+
+var cmdRecordings = &cobra.Command{
+	Use:   "recordings",
+	Short: "List and prune recorded sessions",
+	Long: `veessh connect records the sessions of profiles with record: true, and
+of profiles with a tag listed under recording in the config:
+
+  recording:
+    tags: [prod]
+    dir: ~/recordings   # default ~/.local/state/veessh/recordings
+
+A recorded profile does not connect when its recording cannot be written.
+Recordings are asciicast v2 files: play them with 'veessh replay' or any
+asciinema player. They capture everything the session printed, including
+secrets shown on screen, so they are only readable by you.`,
+	Example: `  veessh recordings list
+  veessh recordings list web
+  veessh recordings prune --older-than 90d
+  veessh replay web`,
+}
+
+var cmdRecordingsList = &cobra.Command{
+	Use:   "list [profile...]",
+	Short: "List recordings, newest first",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		_, infos, err := loadRecordings(args)
+		if err != nil {
+			return err
+		}
+
+		if OutputJSON() {
+			if infos == nil {
+				infos = []recording.Info{}
+			}
+			enc := json.NewEncoder(cmd.OutOrStdout())
+			enc.SetIndent("", "  ")
+			return enc.Encode(infos)
+		}
+
+		if len(infos) == 0 {
+			fmt.Fprintln(cmd.OutOrStdout(), "No recordings found.")
+			return nil
+		}
+		out := cmd.OutOrStdout()
+		fmt.Fprintf(out, "%-19s  %-20s  %-10s  %-9s  %s\n", "STARTED", "PROFILE", "DURATION", "SIZE", "FILE")
+		for _, info := range infos {
+			fmt.Fprintf(out, "%-19s  %-20s  %-10s  %-9s  %s\n",
+				info.Start.Local().Format("2006-01-02 15:04:05"), info.Profile,
+				info.Duration.Round(time.Second), formatSize(info.Size), filepath.Base(info.Path))
+		}
+		return nil
+	},
+}
+
+var cmdRecordingsPrune = &cobra.Command{
+	Use:   "prune [profile...]",
+	Short: "Remove old recordings",
+	Long: `Remove recordings that started longer ago than --older-than, of the given
+profiles or of all.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if recordingsOlderThan == "" {
+			return fmt.Errorf("--older-than is required, e.g. --older-than 90d")
+		}
+		age, err := parseAge(recordingsOlderThan)
+		if err != nil {
+			return err
+		}
+		_, infos, err := loadRecordings(args)
+		if err != nil {
+			return err
+		}
+
+		cutoff := time.Now().Add(-age)
+		var count int
+		var size int64
+		for _, info := range infos {
+			if !info.Start.Before(cutoff) {
+				continue
+			}
+			if recordingsDryRun {
+				fmt.Fprintf(cmd.OutOrStdout(), "Would remove %s\n", info.Path)
+			} else if err := os.Remove(info.Path); err != nil {
+				return err
+			}
+			count++
+			size += info.Size
+		}
+		if recordingsDryRun {
+			fmt.Fprintf(cmd.OutOrStdout(), "Would remove %d recording(s), %s\n", count, formatSize(size))
+		} else {
+			fmt.Fprintf(cmd.OutOrStdout(), "Removed %d recording(s), %s\n", count, formatSize(size))
+		}
+		return nil
+	},
+}
+
+// loadRecordings returns the recording directory and its recordings of
+// profiles, or all of them
+func loadRecordings(profiles []string) (string, []recording.Info, error) {
+	cfgPath, err := config.DefaultPath()
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to determine config path: %w", err)
+	}
+	cfg, err := config.Load(cfgPath)
+	if err != nil {
+		return "", nil, err
+	}
+	dir, err := cfg.RecordingDir()
+	if err != nil {
+		return "", nil, err
+	}
+	infos, err := recording.List(dir)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(profiles) == 0 {
+		return dir, infos, nil
+	}
+	var out []recording.Info
+	for _, info := range infos {
+		if slices.Contains(profiles, info.Profile) {
+			out = append(out, info)
+		}
+	}
+	return dir, out, nil
+}
+
+// parseAge parses a duration that may also be given in days, e.g. 30d
+func parseAge(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid age %q (use e.g. 30d or 12h)", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid age %q (use e.g. 30d or 12h)", s)
+	}
+	return d, nil
+}
+
+// formatSize renders a byte count for display
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+func init() {
+	cmdRecordingsPrune.Flags().StringVar(&recordingsOlderThan, "older-than", "", "remove recordings older than this, e.g. 90d or 12h")
+	cmdRecordingsPrune.Flags().BoolVar(&recordingsDryRun, "dry-run", false, "only show what would be removed")
+	cmdRecordings.AddCommand(cmdRecordingsList)
+	cmdRecordings.AddCommand(cmdRecordingsPrune)
+}
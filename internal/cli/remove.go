@@ -32,7 +32,7 @@ var cmdRemove = &cobra.Command{
 			return err
 		}
 		if rmDeletePassword {
-			_ = credentials.DeletePassword(name)
+			_ = credentials.DeleteSecrets(name)
 		}
 		fmt.Printf("Removed profile %q\n", name)
 		return nil
@@ -40,5 +40,5 @@ var cmdRemove = &cobra.Command{
 }
 
 func init() {
-	cmdRemove.Flags().BoolVar(&rmDeletePassword, "delete-password", false, "also delete any stored password from keychain")
+	cmdRemove.Flags().BoolVar(&rmDeletePassword, "delete-password", false, "also delete any stored password and other secrets")
 }
@@ -0,0 +1,70 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/vee-sh/veessh/internal/recording"
+)
+
+var (
+	replaySpeed     float64
+	replayIdleLimit time.Duration
+)
+
+var cmdReplay = &cobra.Command{
+	Use:   "replay <recording>",
+	Short: "Play back a recorded session",
+	Long: `Play back a session recorded by veessh connect, with its original timing.
+
+The recording is a file path, a file name from 'veessh recordings list', or
+a profile name for its latest recording.`,
+	Example: `  veessh replay web
+  veessh replay web-20260301T101500.000Z.cast --speed 4
+  veessh replay ./session.cast --idle-limit 2s`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path, err := findRecording(args[0])
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		return recording.Play(cmd.Context(), cmd.OutOrStdout(), f, recording.PlayOptions{Speed: replaySpeed, MaxIdle: replayIdleLimit})
+	},
+}
+
+// findRecording resolves the argument of replay to a recording file
+func findRecording(arg string) (string, error) {
+	if _, err := os.Stat(arg); err == nil {
+		return arg, nil
+	}
+	dir, infos, err := loadRecordings(nil)
+	if err != nil {
+		return "", err
+	}
+	for _, name := range []string{arg, arg + recording.Ext} {
+		path := filepath.Join(dir, filepath.Base(name))
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	for _, info := range infos {
+		if info.Profile == arg {
+			return info.Path, nil // Newest first
+		}
+	}
+	return "", fmt.Errorf("no recording %q (see 'veessh recordings list')", arg)
+}
+
+func init() {
+	cmdReplay.Flags().Float64VarP(&replaySpeed, "speed", "s", 1, "playback speed, e.g. 2 for twice as fast")
+	cmdReplay.Flags().DurationVarP(&replayIdleLimit, "idle-limit", "i", 0, "shorten pauses to at most this long (0 keeps them)")
+}
@@ -6,9 +6,11 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"time"
 
 	"github.com/spf13/cobra"
 
+	"github.com/vee-sh/veessh/internal/audit"
 	"github.com/vee-sh/veessh/internal/config"
 	"github.com/vee-sh/veessh/internal/ui"
 	"github.com/vee-sh/veessh/internal/version"
@@ -32,16 +34,22 @@ func Execute() error {
 	rootCmd.SetVersionTemplate(versionTemplate)
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
 	defer stop()
-	return rootCmd.ExecuteContext(ctx)
+	err := rootCmd.ExecuteContext(ctx)
+	// Give the audit sinks a moment for the last entries; they spool the rest
+	audit.Flush(3 * time.Second)
+	return err
 }
 
 func addSubcommands() {
 	rootCmd.AddCommand(cmdAdd)
 	rootCmd.AddCommand(cmdEdit)
 	rootCmd.AddCommand(cmdClone)
+	rootCmd.AddCommand(cmdTemplate)
 	rootCmd.AddCommand(cmdList)
 	rootCmd.AddCommand(cmdShow)
 	rootCmd.AddCommand(cmdConnect)
+	rootCmd.AddCommand(cmdTunnel)
+	rootCmd.AddCommand(cmdMux)
 	rootCmd.AddCommand(cmdRun)
 	rootCmd.AddCommand(cmdTest)
 	rootCmd.AddCommand(cmdScp)
@@ -53,15 +61,24 @@ func addSubcommands() {
 	rootCmd.AddCommand(cmdFavorite)
 	rootCmd.AddCommand(cmdHistory)
 	rootCmd.AddCommand(cmdAudit)
+	rootCmd.AddCommand(cmdRecordings)
+	rootCmd.AddCommand(cmdReplay)
 	rootCmd.AddCommand(cmdHostkey)
 	rootCmd.AddCommand(cmdDoctor)
 	rootCmd.AddCommand(cmdExport)
 	rootCmd.AddCommand(cmdImport)
 	rootCmd.AddCommand(cmdImportSSH)
+	rootCmd.AddCommand(cmdSyncSSHConfig)
 	rootCmd.AddCommand(cmdEditConfig)
+	rootCmd.AddCommand(cmdConfig)
 	rootCmd.AddCommand(cmdSetBackend)
 	rootCmd.AddCommand(cmdMigrate)
 	rootCmd.AddCommand(cmdBackends)
+	rootCmd.AddCommand(cmdSecret)
+	rootCmd.AddCommand(cmdAskPass)
+	rootCmd.AddCommand(cmdUnlock)
+	rootCmd.AddCommand(cmdLock)
+	rootCmd.AddCommand(cmdAgent)
 	rootCmd.AddCommand(cmdCompletion)
 	rootCmd.AddCommand(cmdVersion)
 	rootCmd.AddCommand(cmdTUI)
@@ -143,5 +160,5 @@ func runInteractive(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	return executeConnection(cmd.Context(), p, true)
+	return executeConnection(cmd.Context(), cfg, p, true)
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"os"
 	"os/exec"
 	"strconv"
 	"strings"
@@ -11,6 +12,8 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/vee-sh/veessh/internal/config"
+	"github.com/vee-sh/veessh/internal/hostkeys"
+	"github.com/vee-sh/veessh/internal/mux"
 	"github.com/vee-sh/veessh/internal/util"
 )
 
@@ -20,6 +23,8 @@ var (
 	rsyncVerbose  bool
 	rsyncExclude  []string
 	rsyncProgress bool
+	rsyncVerify   bool
+	rsyncFleet    fleetFlags
 )
 
 var cmdRsync = &cobra.Command{
@@ -44,7 +49,19 @@ Examples:
   veessh rsync --dry-run ./dist/ mybox:/var/www/html/
 
   # Exclude patterns
-  veessh rsync --exclude "*.log" --exclude ".git/" ./project/ mybox:/app/`,
+  veessh rsync --exclude "*.log" --exclude ".git/" ./project/ mybox:/app/
+
+With --tag, --group or --profiles the sync is made with every matching
+profile in parallel, and the remote side is written as :<path>. Pulling
+syncs the remote path from each profile into a subdirectory of the local
+directory named after the profile. --verify compares SHA-256 checksums of
+the synced files on both ends (sha256sum or shasum must exist on the hosts).
+
+  # Deploy to every web server, 20 at a time
+  veessh rsync --group web -P 20 --verify ./dist/ :/var/www/html/
+
+  # Collect /etc/nginx from each host into ./nginx/<profile>/nginx
+  veessh rsync --tag prod :/etc/nginx ./nginx/`,
 	Args: cobra.ExactArgs(2),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		src := args[0]
@@ -58,6 +75,12 @@ Examples:
 		if err != nil {
 			return err
 		}
+		configureAudit(cfg)
+
+		if rsyncFleet.enabled() {
+			fc := fleetCopy{tool: "rsync", build: rsyncCommand, recursive: true, verify: rsyncVerify && !rsyncDryRun}
+			return fc.run(cmd, &rsyncFleet, cfg, src, dst)
+		}
 
 		// Parse source and destination
 		srcProfile, srcPath, srcIsRemote := parseScpPath(src)
@@ -86,11 +109,28 @@ Examples:
 			return fmt.Errorf("rsync only works with SSH/SFTP profiles (got %s)", p.Protocol)
 		}
 
-		return executeRsync(cmd.Context(), p, srcPath, dstPath, srcIsRemote, dstIsRemote)
+		ctx, err := checkHostKey(cmd.Context(), cfg, p, os.Stderr)
+		if err != nil {
+			return err
+		}
+		return executeRsync(ctx, p, srcPath, dstPath, srcIsRemote, dstIsRemote)
 	},
 }
 
 func executeRsync(ctx context.Context, p config.Profile, srcPath, dstPath string, srcIsRemote, dstIsRemote bool) error {
+	cmd := rsyncCommand(ctx, p, srcPath, dstPath, srcIsRemote)
+	if err := util.RunAttached(cmd); err != nil {
+		if errors.Is(err, context.Canceled) {
+			return context.Canceled
+		}
+		return hostKeyRefused(ctx, p, err, sshFailed)
+	}
+	return nil
+}
+
+// rsyncCommand builds the rsync command syncing srcPath to dstPath, one of
+// them on p, with sshOpts before the profile's ssh options
+func rsyncCommand(ctx context.Context, p config.Profile, srcPath, dstPath string, srcIsRemote bool, sshOpts ...string) *exec.Cmd {
 	rsyncArgs := []string{"-a"} // Archive mode (recursive, preserves permissions, etc.)
 
 	if rsyncVerbose {
@@ -111,6 +151,9 @@ func executeRsync(ctx context.Context, p config.Profile, srcPath, dstPath string
 
 	// Build SSH command for rsync with proper quoting
 	sshParts := []string{"ssh"}
+	for _, opt := range sshOpts {
+		sshParts = append(sshParts, shellQuoteForRsync(opt))
+	}
 	if p.Port > 0 && p.Port != 22 {
 		sshParts = append(sshParts, "-p", strconv.Itoa(p.Port))
 	}
@@ -120,6 +163,9 @@ func executeRsync(ctx context.Context, p config.Profile, srcPath, dstPath string
 	if p.ProxyJump != "" {
 		sshParts = append(sshParts, "-J", shellQuoteForRsync(p.ProxyJump))
 	}
+	for _, arg := range append(mux.Args(p), hostkeys.SSHArgs(ctx)...) {
+		sshParts = append(sshParts, shellQuoteForRsync(arg))
+	}
 	rsyncArgs = append(rsyncArgs, "-e", strings.Join(sshParts, " "))
 
 	// Build remote path with user@host prefix
@@ -147,14 +193,7 @@ func executeRsync(ctx context.Context, p config.Profile, srcPath, dstPath string
 
 	rsyncArgs = append(rsyncArgs, src, dst)
 
-	cmd := exec.CommandContext(ctx, "rsync", rsyncArgs...)
-	if err := util.RunAttached(cmd); err != nil {
-		if errors.Is(err, context.Canceled) {
-			return context.Canceled
-		}
-		return err
-	}
-	return nil
+	return exec.CommandContext(ctx, "rsync", rsyncArgs...)
 }
 
 // shellQuoteForRsync quotes a string for use in rsync's -e ssh command
@@ -174,5 +213,7 @@ func init() {
 	cmdRsync.Flags().BoolVar(&rsyncVerbose, "verbose", false, "verbose output")
 	cmdRsync.Flags().BoolVar(&rsyncProgress, "progress", false, "show transfer progress")
 	cmdRsync.Flags().StringSliceVar(&rsyncExclude, "exclude", nil, "exclude pattern (repeatable)")
+	cmdRsync.Flags().BoolVar(&rsyncVerify, "verify", false, "compare SHA-256 checksums after syncing (with --tag, --group or --profiles)")
+	rsyncFleet.register(cmdRsync, "sync with")
 }
 
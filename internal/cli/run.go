@@ -4,35 +4,64 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 
+	"github.com/vee-sh/veessh/internal/audit"
 	"github.com/vee-sh/veessh/internal/config"
+	"github.com/vee-sh/veessh/internal/credentials"
+	"github.com/vee-sh/veessh/internal/fleet"
+	"github.com/vee-sh/veessh/internal/hostkeys"
+	"github.com/vee-sh/veessh/internal/mux"
 	"github.com/vee-sh/veessh/internal/util"
 )
 
 var (
-	runTTY bool
+	runTTY    bool
+	runSudo   bool
+	runOutput string
+	runFleet  fleetFlags
 )
 
 var cmdRun = &cobra.Command{
-	Use:   "run <profile> <command> [args...]",
-	Short: "Execute a command on a remote host",
+	Use:   "run [profile] <command> [args...]",
+	Short: "Execute a command on remote hosts",
 	Long: `Execute a command on a remote host via SSH without an interactive shell.
 
+With --tag, --group or --profiles the command runs on every matching SSH
+profile instead, up to --parallel hosts at a time. Output is prefixed with
+the profile name as it arrives (--output prefix) or shown per host once it
+finishes (--output group), followed by a summary of exit codes and
+durations. --json prints the results instead. Hosts that fail do not stop
+the others unless --fail-fast is given; veessh exits non-zero if any host
+failed. Fleet runs cannot prompt, so ssh runs in batch mode.
+
 Examples:
   veessh run mybox uptime
   veessh run mybox "df -h"
   veessh run mybox ls -la /var/log
-  veessh run mybox --tty top           # Force TTY allocation`,
-	Args: cobra.MinimumNArgs(2),
+  veessh run mybox --tty top           # Force TTY allocation
+  veessh run mybox --sudo systemctl restart nginx  # Uses the stored sudo password
+  veessh run --tag prod --group web -P 20 -- uptime
+  veessh run --profiles web1,web2 --timeout 30s --output group -- df -h
+  veessh run --tag prod --json -- cat /etc/os-release`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if runFleet.enabled() {
+			return cobra.MinimumNArgs(1)(cmd, args)
+		}
+		return cobra.MinimumNArgs(2)(cmd, args)
+	},
 	RunE: func(cmd *cobra.Command, args []string) error {
-		name := args[0]
-		remoteCmd := args[1:]
+		if runSudo && runTTY {
+			// With a TTY the remote side would echo the password back
+			return fmt.Errorf("--sudo cannot be combined with --tty")
+		}
 
 		cfgPath, err := config.DefaultPath()
 		if err != nil {
@@ -42,7 +71,24 @@ Examples:
 		if err != nil {
 			return err
 		}
+		configureAudit(cfg)
+
+		if runFleet.enabled() {
+			if runTTY {
+				return fmt.Errorf("--tty cannot be used with more than one host")
+			}
+			if runOutput != "prefix" && runOutput != "group" {
+				return fmt.Errorf("invalid --output %q (must be prefix or group)", runOutput)
+			}
+			profiles, err := runFleet.selectProfiles(cmd, cfg, config.ProtocolSSH)
+			if err != nil {
+				return err
+			}
+			return executeFleetCommand(cmd, cfg, profiles, args)
+		}
 
+		name := args[0]
+		remoteCmd := args[1:]
 		p, ok := cfg.GetProfile(name)
 		if !ok {
 			return fmt.Errorf("profile %q not found", name)
@@ -52,12 +98,104 @@ Examples:
 			return fmt.Errorf("run command only supports SSH profiles (got %s)", p.Protocol)
 		}
 
-		return executeRemoteCommand(cmd.Context(), p, remoteCmd)
+		ctx, err := checkHostKey(cmd.Context(), cfg, p, os.Stderr)
+		if err != nil {
+			return err
+		}
+		return executeRemoteCommand(ctx, p, remoteCmd)
 	},
 }
 
 func executeRemoteCommand(ctx context.Context, p config.Profile, remoteCmd []string) error {
-	sshArgs := []string{}
+	var sudoPassword string
+	if runSudo {
+		var err error
+		if sudoPassword, err = storedSudoPassword(p); err != nil {
+			return err
+		}
+	}
+	cmd := remoteCommand(ctx, p, remoteCmd)
+	var err error
+	if runSudo {
+		cmd.Stdin = io.MultiReader(strings.NewReader(sudoPassword+"\n"), os.Stdin)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		err = cmd.Run()
+	} else {
+		err = util.RunAttached(cmd)
+	}
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			return context.Canceled
+		}
+		err = hostKeyRefused(ctx, p, err, sshFailed)
+		// Check for exit code and surface it, once the audit entries
+		// queued so far (such as a host key warning) are out
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			audit.Flush(3 * time.Second)
+			os.Exit(exitErr.ExitCode())
+		}
+		return err
+	}
+	return nil
+}
+
+// executeFleetCommand runs remoteCmd on every profile and prints the results
+func executeFleetCommand(cmd *cobra.Command, cfg config.Config, profiles []config.Profile, remoteCmd []string) error {
+	// Looked up one at a time up front, as backends may prompt to unlock
+	sudoPasswords := map[string]string{}
+	if runSudo {
+		for _, p := range profiles {
+			password, err := storedSudoPassword(p)
+			if err != nil {
+				return fmt.Errorf("%s: %w", p.Name, err)
+			}
+			sudoPasswords[p.Name] = password
+		}
+	}
+
+	opts := runFleet.options()
+	fleetOutput(cmd, profiles, runOutput, &opts)
+	ctx := cmd.Context()
+	results := fleet.Run(ctx, profiles, opts, func(ctx context.Context, p config.Profile, stdout, stderr io.Writer) error {
+		ctx, err := checkHostKey(ctx, cfg, p, stderr)
+		if err != nil {
+			return err
+		}
+		c := remoteCommand(ctx, p, remoteCmd, "-o", "BatchMode=yes")
+		if runSudo {
+			c.Stdin = strings.NewReader(sudoPasswords[p.Name] + "\n")
+		}
+		c.Stdout = stdout
+		c.Stderr = stderr
+		// Do not wait for the output of processes ssh leaves behind when it
+		// is killed on timeout
+		c.WaitDelay = time.Second
+		return hostKeyRefused(ctx, p, c.Run(), sshFailed)
+	})
+	if ctx.Err() != nil {
+		return context.Canceled
+	}
+	return reportFleet(cmd, results)
+}
+
+// storedSudoPassword returns the sudo password stored for p
+func storedSudoPassword(p config.Profile) (string, error) {
+	password, err := credentials.GetSecret(p.Name, credentials.SecretSudoPassword)
+	if err != nil {
+		return "", fmt.Errorf("failed to retrieve sudo password: %w", err)
+	}
+	if password == "" {
+		return "", fmt.Errorf("no sudo password stored for %q (set one with: veessh secret set %s sudo)", p.Name, p.Name)
+	}
+	return password, nil
+}
+
+// remoteCommand builds the ssh command that runs remoteCmd on p, with opts
+// before the profile's options. With --sudo the command expects the sudo
+// password as the first line of its stdin.
+func remoteCommand(ctx context.Context, p config.Profile, remoteCmd []string, opts ...string) *exec.Cmd {
+	sshArgs := append([]string{}, opts...)
 
 	if p.Port > 0 {
 		sshArgs = append(sshArgs, "-p", strconv.Itoa(p.Port))
@@ -74,6 +212,8 @@ func executeRemoteCommand(ctx context.Context, p config.Profile, remoteCmd []str
 	if runTTY {
 		sshArgs = append(sshArgs, "-t")
 	}
+	sshArgs = append(sshArgs, mux.Args(p)...)
+	sshArgs = append(sshArgs, hostkeys.SSHArgs(ctx)...)
 
 	// Add extra args from profile
 	if len(p.ExtraArgs) > 0 {
@@ -83,23 +223,19 @@ func executeRemoteCommand(ctx context.Context, p config.Profile, remoteCmd []str
 	sshArgs = append(sshArgs, p.Host)
 
 	// Add the remote command
-	sshArgs = append(sshArgs, strings.Join(remoteCmd, " "))
-
-	cmd := exec.CommandContext(ctx, "ssh", sshArgs...)
-	if err := util.RunAttached(cmd); err != nil {
-		if errors.Is(err, context.Canceled) {
-			return context.Canceled
-		}
-		// Check for exit code and surface it
-		if exitErr, ok := err.(*exec.ExitError); ok {
-			os.Exit(exitErr.ExitCode())
-		}
-		return err
+	command := strings.Join(remoteCmd, " ")
+	if runSudo {
+		// sudo reads the password from the first line of stdin
+		command = "sudo -S -p '' sh -c '" + strings.ReplaceAll(command, "'", `'"'"'`) + "'"
 	}
-	return nil
+	sshArgs = append(sshArgs, command)
+
+	return exec.CommandContext(ctx, "ssh", sshArgs...)
 }
 
 func init() {
 	cmdRun.Flags().BoolVarP(&runTTY, "tty", "t", false, "force TTY allocation (for interactive commands)")
+	cmdRun.Flags().BoolVar(&runSudo, "sudo", false, "run the command with sudo, answering its prompt with the stored sudo password")
+	cmdRun.Flags().StringVar(&runOutput, "output", "prefix", "output as it arrives prefixed by profile (prefix) or per host when done (group)")
+	runFleet.register(cmdRun, "run on")
 }
-
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"os"
 	"os/exec"
 	"strconv"
 	"strings"
@@ -11,12 +12,16 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/vee-sh/veessh/internal/config"
+	"github.com/vee-sh/veessh/internal/hostkeys"
+	"github.com/vee-sh/veessh/internal/mux"
 	"github.com/vee-sh/veessh/internal/util"
 )
 
 var (
 	scpRecursive bool
 	scpPreserve  bool
+	scpVerify    bool
+	scpFleet     fleetFlags
 )
 
 var cmdScp = &cobra.Command{
@@ -36,7 +41,21 @@ Examples:
   veessh scp ./dist/ mybox:/var/www/html/ -r
 
   # Preserve timestamps and permissions
-  veessh scp -rp mybox:/backup/ ./local-backup/`,
+  veessh scp -rp mybox:/backup/ ./local-backup/
+
+With --tag, --group or --profiles the copy is made with every matching
+profile in parallel, and the remote side is written as :<path>. Pushing
+copies the local path to each profile; pulling copies the remote path from
+each profile into a subdirectory of the local directory named after the
+profile. End a remote directory with / to copy into it. --verify compares
+SHA-256 checksums of the copied files on both ends (sha256sum or shasum
+must exist on the hosts).
+
+  # Roll out a config snippet
+  veessh scp --tag prod --verify ./app.conf :/etc/app/conf.d/
+
+  # Collect logs into ./logs/<profile>/app.log
+  veessh scp --group web -P 20 :/var/log/app.log ./logs/`,
 	Args: cobra.ExactArgs(2),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		src := args[0]
@@ -50,6 +69,12 @@ Examples:
 		if err != nil {
 			return err
 		}
+		configureAudit(cfg)
+
+		if scpFleet.enabled() {
+			fc := fleetCopy{tool: "scp", build: scpCommand, recursive: scpRecursive, verify: scpVerify}
+			return fc.run(cmd, &scpFleet, cfg, src, dst)
+		}
 
 		// Parse source and destination to find profile
 		srcProfile, srcPath, srcIsRemote := parseScpPath(src)
@@ -78,7 +103,11 @@ Examples:
 			return fmt.Errorf("scp only works with SSH/SFTP profiles (got %s)", p.Protocol)
 		}
 
-		return executeScp(cmd.Context(), p, srcPath, dstPath, srcIsRemote, dstIsRemote)
+		ctx, err := checkHostKey(cmd.Context(), cfg, p, os.Stderr)
+		if err != nil {
+			return err
+		}
+		return executeScp(ctx, p, srcPath, dstPath, srcIsRemote, dstIsRemote)
 	},
 }
 
@@ -94,7 +123,20 @@ func parseScpPath(path string) (profile, remotePath string, isRemote bool) {
 }
 
 func executeScp(ctx context.Context, p config.Profile, srcPath, dstPath string, srcIsRemote, dstIsRemote bool) error {
-	scpArgs := []string{}
+	cmd := scpCommand(ctx, p, srcPath, dstPath, srcIsRemote)
+	if err := util.RunAttached(cmd); err != nil {
+		if errors.Is(err, context.Canceled) {
+			return context.Canceled
+		}
+		return hostKeyRefused(ctx, p, err, scpFailed)
+	}
+	return nil
+}
+
+// scpCommand builds the scp command copying srcPath to dstPath, one of them
+// on p, with sshOpts before the profile's options
+func scpCommand(ctx context.Context, p config.Profile, srcPath, dstPath string, srcIsRemote bool, sshOpts ...string) *exec.Cmd {
+	scpArgs := append([]string{}, sshOpts...)
 
 	if scpRecursive {
 		scpArgs = append(scpArgs, "-r")
@@ -112,6 +154,8 @@ func executeScp(ctx context.Context, p config.Profile, srcPath, dstPath string,
 	if p.ProxyJump != "" {
 		scpArgs = append(scpArgs, "-o", "ProxyJump="+p.ProxyJump)
 	}
+	scpArgs = append(scpArgs, mux.Args(p)...)
+	scpArgs = append(scpArgs, hostkeys.SSHArgs(ctx)...)
 
 	// Build remote path with user@host prefix
 	remotePrefix := p.Host
@@ -130,18 +174,13 @@ func executeScp(ctx context.Context, p config.Profile, srcPath, dstPath string,
 
 	scpArgs = append(scpArgs, src, dst)
 
-	cmd := exec.CommandContext(ctx, "scp", scpArgs...)
-	if err := util.RunAttached(cmd); err != nil {
-		if errors.Is(err, context.Canceled) {
-			return context.Canceled
-		}
-		return err
-	}
-	return nil
+	return exec.CommandContext(ctx, "scp", scpArgs...)
 }
 
 func init() {
 	cmdScp.Flags().BoolVarP(&scpRecursive, "recursive", "r", false, "recursively copy directories")
 	cmdScp.Flags().BoolVarP(&scpPreserve, "preserve", "p", false, "preserve timestamps and permissions")
+	cmdScp.Flags().BoolVar(&scpVerify, "verify", false, "compare SHA-256 checksums after copying (with --tag, --group or --profiles)")
+	scpFleet.register(cmdScp, "copy to or from")
 }
 
@@ -0,0 +1,182 @@
+package cli
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+
+	"github.com/vee-sh/veessh/internal/config"
+	"github.com/vee-sh/veessh/internal/credentials"
+)
+
+var secretFromStdin bool
+
+var cmdSecret = &cobra.Command{
+	Use:   "secret",
+	Short: "Manage stored secrets of a profile",
+	Long: `Manage the secrets stored for a profile in the credential backend.
+
+Secret types:
+  password    - login password (same as add/edit --ask-password)
+  passphrase  - private key passphrase, used to unlock encrypted keys
+  totp        - TOTP seed (base32 or otpauth:// URI), used to answer
+                one-time password prompts
+  sudo        - sudo password, used by 'veessh run --sudo'
+
+Subcommands:
+  set     - Store a secret (prompted, or read from stdin)
+  delete  - Delete a secret
+  list    - Show which secrets are stored
+  otp     - Print the current TOTP code`,
+	Example: `  veessh secret set prod passphrase
+  veessh secret set prod totp --stdin < seed.txt
+  veessh secret list prod
+  veessh secret otp prod`,
+}
+
+var cmdSecretSet = &cobra.Command{
+	Use:   "set <profile> <type>",
+	Short: "Store a secret for a profile",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+		kind, err := credentials.ParseSecretKind(args[1])
+		if err != nil {
+			return err
+		}
+		if err := requireProfile(name); err != nil {
+			return err
+		}
+
+		var value string
+		if secretFromStdin || !term.IsTerminal(int(os.Stdin.Fd())) {
+			line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+			if err != nil && line == "" {
+				return fmt.Errorf("failed to read secret from stdin: %w", err)
+			}
+			// Only the line ending: passwords may start or end with spaces
+			value = strings.TrimRight(line, "\r\n")
+		} else {
+			value, err = promptPassword(fmt.Sprintf("Enter %s for %s: ", kind.Description(), name))
+			if err != nil {
+				return err
+			}
+		}
+		if value == "" {
+			return fmt.Errorf("empty %s, nothing stored", kind.Description())
+		}
+		if kind == credentials.SecretTOTPSeed {
+			if _, err := credentials.TOTPCode(value, time.Now()); err != nil {
+				return err
+			}
+		}
+
+		if err := credentials.SetSecret(name, kind, value); err != nil {
+			return err
+		}
+		fmt.Printf("Stored %s for %q\n", kind.Description(), name)
+		return nil
+	},
+}
+
+var cmdSecretDelete = &cobra.Command{
+	Use:   "delete <profile> <type>",
+	Short: "Delete a stored secret",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+		kind, err := credentials.ParseSecretKind(args[1])
+		if err != nil {
+			return err
+		}
+		if err := credentials.DeleteSecret(name, kind); err != nil {
+			return err
+		}
+		fmt.Printf("Deleted %s for %q\n", kind.Description(), name)
+		return nil
+	},
+}
+
+var cmdSecretList = &cobra.Command{
+	Use:   "list <profile>",
+	Short: "Show which secrets are stored for a profile",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+		secrets, err := credentials.GetSecrets(name)
+		if err != nil {
+			return err
+		}
+
+		if OutputJSON() {
+			stored := map[string]bool{}
+			for _, kind := range credentials.SecretKinds {
+				stored[string(kind)] = secrets.Get(kind) != ""
+			}
+			enc := json.NewEncoder(cmd.OutOrStdout())
+			enc.SetIndent("", "  ")
+			return enc.Encode(stored)
+		}
+
+		for _, kind := range credentials.SecretKinds {
+			status := "-"
+			if secrets.Get(kind) != "" {
+				status = "stored"
+			}
+			fmt.Printf("%-11s %-24s %s\n", kind, kind.Description(), status)
+		}
+		return nil
+	},
+}
+
+var cmdSecretOTP = &cobra.Command{
+	Use:   "otp <profile>",
+	Short: "Print the current TOTP code for a profile",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+		seed, err := credentials.GetSecret(name, credentials.SecretTOTPSeed)
+		if err != nil {
+			return err
+		}
+		if seed == "" {
+			return fmt.Errorf("no TOTP seed stored for %q (set one with: veessh secret set %s totp)", name, name)
+		}
+		code, err := credentials.TOTPCode(seed, time.Now())
+		if err != nil {
+			return err
+		}
+		fmt.Println(code)
+		return nil
+	},
+}
+
+// requireProfile checks that a profile exists in the config
+func requireProfile(name string) error {
+	cfgPath, err := config.DefaultPath()
+	if err != nil {
+		return fmt.Errorf("failed to determine config path: %w", err)
+	}
+	cfg, err := config.Load(cfgPath)
+	if err != nil {
+		return err
+	}
+	if _, ok := cfg.GetProfile(name); !ok {
+		return fmt.Errorf("profile %q not found", name)
+	}
+	return nil
+}
+
+func init() {
+	cmdSecretSet.Flags().BoolVar(&secretFromStdin, "stdin", false, "read the secret from stdin instead of prompting")
+	cmdSecret.AddCommand(cmdSecretSet)
+	cmdSecret.AddCommand(cmdSecretDelete)
+	cmdSecret.AddCommand(cmdSecretList)
+	cmdSecret.AddCommand(cmdSecretOTP)
+}
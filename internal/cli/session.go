@@ -11,19 +11,28 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/vee-sh/veessh/internal/config"
+	"github.com/vee-sh/veessh/internal/connectors"
 )
 
 var (
 	sessionName   string
 	sessionLayout string
+	sessionSync   bool
+	sessionTags   []string
 )
 
 var cmdSession = &cobra.Command{
 	Use:   "session <profile1> [profile2] [profile3] ...",
 	Short: "Open multiple profiles in tmux windows/panes",
-	Long: `Open multiple SSH connections in a tmux session.
+	Long: `Open multiple connections in a tmux session.
 
 Each profile becomes a separate tmux window. Use --layout to create panes instead.
+With --sync the profiles open as panes (tiled unless --layout is given) and
+input is broadcast to all of them, like cluster-ssh; toggle it in tmux with
+'setw synchronize-panes'.
+
+SSH, mosh, SSM and gcloud profiles can be opened. --tag opens every profile
+with the given tag(s), after the named ones.
 
 Examples:
   # Open profiles in separate tmux windows
@@ -36,8 +45,16 @@ Examples:
   veessh session web1 web2 web3 --layout tiled
 
   # Horizontal split layout
-  veessh session master worker --layout even-horizontal`,
-	Args: cobra.MinimumNArgs(1),
+  veessh session master worker --layout even-horizontal
+
+  # Type into every prod host at once
+  veessh session --tag prod --sync`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(sessionTags) > 0 {
+			return nil
+		}
+		return cobra.MinimumNArgs(1)(cmd, args)
+	},
 	RunE: func(cmd *cobra.Command, args []string) error {
 		// Check if tmux is available
 		if _, err := exec.LookPath("tmux"); err != nil {
@@ -54,30 +71,76 @@ Examples:
 		}
 
 		// Validate all profiles exist
-		var profiles []config.Profile
+		var panes []tmuxPane
+		seen := map[string]bool{}
 		for _, name := range args {
 			p, ok := cfg.GetProfile(name)
 			if !ok {
 				return fmt.Errorf("profile %q not found", name)
 			}
-			if p.Protocol != config.ProtocolSSH {
-				return fmt.Errorf("session only supports SSH profiles (got %s for %s)", p.Protocol, name)
+			command, err := sessionCommand(p)
+			if err != nil {
+				return err
+			}
+			seen[p.Name] = true
+			panes = append(panes, tmuxPane{name: p.Name, command: command})
+		}
+		if len(sessionTags) > 0 {
+			for _, p := range cfg.FindProfiles(sessionTags, nil) {
+				if seen[p.Name] {
+					continue
+				}
+				command, err := sessionCommand(p)
+				if err != nil {
+					fmt.Fprintf(cmd.ErrOrStderr(), "Skipping %s: %v\n", p.Name, err)
+					continue
+				}
+				panes = append(panes, tmuxPane{name: p.Name, command: command})
+			}
+			if len(panes) == 0 {
+				return fmt.Errorf("no profiles with tag(s) %s", strings.Join(sessionTags, ", "))
 			}
-			profiles = append(profiles, p)
 		}
 
 		sessName := sessionName
 		if sessName == "" {
-			sessName = "veessh-" + profiles[0].Name
+			sessName = "veessh-" + panes[0].name
 		}
 
+		if sessionSync && sessionLayout == "" {
+			sessionLayout = "tiled"
+		}
 		if sessionLayout != "" {
-			return createTmuxPanes(cmd.Context(), sessName, profiles)
+			return createTmuxPanes(cmd.Context(), sessName, panes)
 		}
-		return createTmuxWindows(cmd.Context(), sessName, profiles)
+		return createTmuxWindows(cmd.Context(), sessName, panes)
 	},
 }
 
+// tmuxPane is a profile opened in a tmux window or pane
+type tmuxPane struct {
+	name    string
+	command string // Shell command that connects
+}
+
+// sessionCommand returns the shell command that connects to p in a tmux
+// pane. SSH profiles use the system ssh; other protocols use the command
+// their connector runs.
+func sessionCommand(p config.Profile) (string, error) {
+	if p.Protocol == config.ProtocolSSH {
+		return buildSSHCommand(p), nil
+	}
+	conn, err := connectors.ForProfile(p)
+	if err != nil {
+		return "", err
+	}
+	c, ok := conn.(connectors.Commander)
+	if !ok {
+		return "", fmt.Errorf("session does not support %s profiles (%s)", p.Protocol, p.Name)
+	}
+	return connectors.CommandLine(c.Command(context.Background(), p)), nil
+}
+
 func buildSSHCommand(p config.Profile) string {
 	args := []string{"ssh"}
 	if p.Port > 0 && p.Port != 22 {
@@ -105,7 +168,7 @@ func shellQuoteArg(s string) string {
 	return "'" + strings.ReplaceAll(s, "'", "'\"'\"'") + "'"
 }
 
-func createTmuxWindows(ctx context.Context, sessName string, profiles []config.Profile) error {
+func createTmuxWindows(ctx context.Context, sessName string, panes []tmuxPane) error {
 	// Check if session already exists
 	checkCmd := exec.CommandContext(ctx, "tmux", "has-session", "-t", sessName)
 	sessionExists := checkCmd.Run() == nil
@@ -116,29 +179,27 @@ func createTmuxWindows(ctx context.Context, sessName string, profiles []config.P
 	}
 
 	// Create new session with first profile
-	firstSSH := buildSSHCommand(profiles[0])
-	newCmd := exec.CommandContext(ctx, "tmux", "new-session", "-d", "-s", sessName, "-n", profiles[0].Name, firstSSH)
+	newCmd := exec.CommandContext(ctx, "tmux", "new-session", "-d", "-s", sessName, "-n", panes[0].name, panes[0].command)
 	if err := newCmd.Run(); err != nil {
 		return fmt.Errorf("failed to create tmux session: %w", err)
 	}
 
 	// Add windows for remaining profiles
-	for _, p := range profiles[1:] {
-		sshCmd := buildSSHCommand(p)
-		winCmd := exec.CommandContext(ctx, "tmux", "new-window", "-t", sessName, "-n", p.Name, sshCmd)
+	for _, p := range panes[1:] {
+		winCmd := exec.CommandContext(ctx, "tmux", "new-window", "-t", sessName, "-n", p.name, p.command)
 		if err := winCmd.Run(); err != nil {
-			return fmt.Errorf("failed to create window for %s: %w", p.Name, err)
+			return fmt.Errorf("failed to create window for %s: %w", p.name, err)
 		}
 	}
 
 	// Select first window
 	exec.CommandContext(ctx, "tmux", "select-window", "-t", sessName+":0").Run()
 
-	fmt.Printf("Created session %q with %d windows\n", sessName, len(profiles))
+	fmt.Printf("Created session %q with %d windows\n", sessName, len(panes))
 	return attachTmux(ctx, sessName)
 }
 
-func createTmuxPanes(ctx context.Context, sessName string, profiles []config.Profile) error {
+func createTmuxPanes(ctx context.Context, sessName string, panes []tmuxPane) error {
 	// Check if session already exists
 	checkCmd := exec.CommandContext(ctx, "tmux", "has-session", "-t", sessName)
 	sessionExists := checkCmd.Run() == nil
@@ -149,26 +210,34 @@ func createTmuxPanes(ctx context.Context, sessName string, profiles []config.Pro
 	}
 
 	// Create new session with first profile
-	firstSSH := buildSSHCommand(profiles[0])
-	newCmd := exec.CommandContext(ctx, "tmux", "new-session", "-d", "-s", sessName, firstSSH)
+	newCmd := exec.CommandContext(ctx, "tmux", "new-session", "-d", "-s", sessName, panes[0].command)
 	if err := newCmd.Run(); err != nil {
 		return fmt.Errorf("failed to create tmux session: %w", err)
 	}
 
 	// Split panes for remaining profiles
-	for _, p := range profiles[1:] {
-		sshCmd := buildSSHCommand(p)
-		splitCmd := exec.CommandContext(ctx, "tmux", "split-window", "-t", sessName, sshCmd)
+	for _, p := range panes[1:] {
+		splitCmd := exec.CommandContext(ctx, "tmux", "split-window", "-t", sessName, p.command)
 		if err := splitCmd.Run(); err != nil {
-			return fmt.Errorf("failed to create pane for %s: %w", p.Name, err)
+			return fmt.Errorf("failed to create pane for %s: %w", p.name, err)
 		}
+		// Re-tile as we go, or tmux runs out of room to split after a few panes
+		exec.CommandContext(ctx, "tmux", "select-layout", "-t", sessName, "tiled").Run()
 	}
 
 	// Apply layout
 	layoutCmd := exec.CommandContext(ctx, "tmux", "select-layout", "-t", sessName, sessionLayout)
 	layoutCmd.Run()
 
-	fmt.Printf("Created session %q with %d panes (%s layout)\n", sessName, len(profiles), sessionLayout)
+	if sessionSync {
+		syncCmd := exec.CommandContext(ctx, "tmux", "set-window-option", "-t", sessName, "synchronize-panes", "on")
+		if err := syncCmd.Run(); err != nil {
+			return fmt.Errorf("failed to synchronize panes: %w", err)
+		}
+		fmt.Printf("Created session %q with %d panes (%s layout), input broadcast to all\n", sessName, len(panes), sessionLayout)
+	} else {
+		fmt.Printf("Created session %q with %d panes (%s layout)\n", sessName, len(panes), sessionLayout)
+	}
 	return attachTmux(ctx, sessName)
 }
 
@@ -205,4 +274,6 @@ func attachTmux(ctx context.Context, sessName string) error {
 func init() {
 	cmdSession.Flags().StringVarP(&sessionName, "name", "n", "", "tmux session name (default: veessh-<first-profile>)")
 	cmdSession.Flags().StringVarP(&sessionLayout, "layout", "l", "", "create panes with layout: tiled, even-horizontal, even-vertical, main-horizontal, main-vertical")
+	cmdSession.Flags().BoolVar(&sessionSync, "sync", false, "open panes and broadcast input to all of them")
+	cmdSession.Flags().StringSliceVar(&sessionTags, "tag", nil, "open profiles with these tag(s), require all")
 }
@@ -113,3 +113,13 @@ func TestShellQuoteArg(t *testing.T) {
 	}
 }
 
+
+func TestSessionCommand(t *testing.T) {
+	got, err := sessionCommand(config.Profile{Name: "m", Protocol: config.ProtocolMosh, Host: "web", Username: "alice"})
+	if err != nil || got != "mosh alice@web" {
+		t.Errorf("mosh: got %q, %v", got, err)
+	}
+	if _, err := sessionCommand(config.Profile{Name: "d", Protocol: config.ProtocolTelnet, Host: "switch"}); err == nil {
+		t.Error("telnet: expected an error")
+	}
+}
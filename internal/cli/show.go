@@ -3,6 +3,7 @@ package cli
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 
 	"github.com/spf13/cobra"
 	"gopkg.in/yaml.v3"
@@ -10,10 +11,22 @@ import (
 	"github.com/vee-sh/veessh/internal/config"
 )
 
+var showResolved bool
+var showExplain bool
+
 var cmdShow = &cobra.Command{
 	Use:   "show <name>",
 	Short: "Show a profile's details",
-	Args:  cobra.ExactArgs(1),
+	Long: `Show a profile's details.
+
+By default the profile is shown resolved, with everything it inherits
+through extends. --resolved=false shows it as stored in the config file.
+--explain annotates every field with the profile it came from; merged
+lists name each contributor, e.g. "base + web".`,
+	Example: `  veessh show web
+  veessh show web --resolved=false
+  veessh show web --resolved --explain`,
+	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		name := args[0]
 		cfgPath, err := config.DefaultPath()
@@ -24,20 +37,72 @@ var cmdShow = &cobra.Command{
 		if err != nil {
 			return err
 		}
-		p, ok := cfg.GetProfile(name)
+		if showExplain && !showResolved {
+			return fmt.Errorf("--explain needs the resolved profile")
+		}
+
+		var p config.Profile
+		var sources map[string]string
+		ok := false
+		switch {
+		case showExplain:
+			p, sources, ok = cfg.Explain(name)
+		case showResolved:
+			p, ok = cfg.GetProfile(name)
+		default:
+			p, ok = cfg.Profiles[name]
+		}
 		if !ok {
 			return fmt.Errorf("profile %q not found", name)
 		}
+
 		if OutputJSON() {
 			enc := json.NewEncoder(cmd.OutOrStdout())
 			enc.SetIndent("", "  ")
+			if showExplain {
+				return enc.Encode(map[string]any{"profile": p, "sources": sources})
+			}
 			return enc.Encode(p)
 		}
 		out, err := yaml.Marshal(p)
 		if err != nil {
 			return err
 		}
+		if showExplain {
+			fmt.Print(annotateSources(string(out), sources))
+			return nil
+		}
 		fmt.Print(string(out))
 		return nil
 	},
 }
+
+// annotateSources appends "# from <profile>" to each top-level YAML field
+func annotateSources(out string, sources map[string]string) string {
+	lines := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+	width := 0
+	for _, line := range lines {
+		if len(line) > width {
+			width = len(line)
+		}
+	}
+	var b strings.Builder
+	for _, line := range lines {
+		key, _, found := strings.Cut(line, ":")
+		src := ""
+		if found && !strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "-") {
+			src = sources[key]
+		}
+		if src == "" {
+			b.WriteString(line + "\n")
+			continue
+		}
+		fmt.Fprintf(&b, "%-*s  # from %s\n", width, line, src)
+	}
+	return b.String()
+}
+
+func init() {
+	cmdShow.Flags().BoolVar(&showResolved, "resolved", true, "show the profile with inherited values resolved")
+	cmdShow.Flags().BoolVar(&showExplain, "explain", false, "annotate each field with the profile it came from")
+}
@@ -0,0 +1,141 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/vee-sh/veessh/internal/config"
+	"github.com/vee-sh/veessh/internal/sshconfig"
+)
+
+var syncSSHFile string
+var syncSSHInclude bool
+var syncSSHRemove bool
+var syncSSHDryRun bool
+
+var cmdSyncSSHConfig = &cobra.Command{
+	Use:   "sync-ssh-config",
+	Short: "Write profiles into ~/.ssh/config as a managed block",
+	Long: `Write every SSH-reachable profile into your OpenSSH config, so git,
+ansible, VS Code Remote and anything else that reads ~/.ssh/config sees
+the same hosts as veessh.
+
+Hosts are written between "# BEGIN veessh managed block" and
+"# END veessh managed block" markers at the top of the file, so they take
+precedence over later wildcard blocks. Everything outside the markers is
+left untouched, and running the command again replaces the block.
+
+With --include the hosts go to ~/.ssh/veessh.conf instead, and the managed
+block in ~/.ssh/config only holds "Include veessh.conf".
+
+Extends chains are flattened; profiles without a host (parents) and
+non-SSH protocols are skipped.`,
+	Example: `  veessh sync-ssh-config --dry-run
+  veessh sync-ssh-config
+  veessh sync-ssh-config --include
+  veessh sync-ssh-config --remove`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		sshPath := syncSSHFile
+		if sshPath == "" {
+			path, err := sshconfig.DefaultPath()
+			if err != nil {
+				return err
+			}
+			sshPath = path
+		}
+		includePath := filepath.Join(filepath.Dir(sshPath), sshconfig.IncludeFile)
+
+		cfgPath, err := config.DefaultPath()
+		if err != nil {
+			return fmt.Errorf("failed to determine config path: %w", err)
+		}
+		cfg, err := config.Load(cfgPath)
+		if err != nil {
+			return err
+		}
+
+		existing, err := os.ReadFile(sshPath)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+
+		hosts, report := sshconfig.Render(cfg)
+		var block []byte
+		switch {
+		case syncSSHRemove:
+			block = nil
+		case syncSSHInclude:
+			block = sshconfig.ManagedBlock([]byte("Include " + sshconfig.IncludeFile + "\n"))
+		default:
+			block = sshconfig.ManagedBlock(hosts)
+		}
+		updated, err := sshconfig.ReplaceManagedBlock(existing, block)
+		if err != nil {
+			return fmt.Errorf("%s: %w", sshPath, err)
+		}
+
+		if syncSSHDryRun {
+			if syncSSHInclude && !syncSSHRemove {
+				fmt.Printf("# %s\n%s\n", includePath, hosts)
+			}
+			fmt.Printf("# %s\n%s", sshPath, updated)
+			return nil
+		}
+
+		const header = "# Generated by 'veessh sync-ssh-config --include', do not edit\n"
+		if syncSSHInclude && !syncSSHRemove {
+			if err := sshconfig.WriteFile(includePath, append([]byte(header+"\n"), hosts...)); err != nil {
+				return err
+			}
+		} else if data, err := os.ReadFile(includePath); err == nil && strings.HasPrefix(string(data), header) {
+			// Left over from include mode
+			if err := os.Remove(includePath); err != nil {
+				return err
+			}
+		}
+		if string(updated) != string(existing) {
+			if err := sshconfig.WriteFile(sshPath, updated); err != nil {
+				return err
+			}
+		}
+
+		if OutputJSON() {
+			enc := json.NewEncoder(cmd.OutOrStdout())
+			enc.SetIndent("", "  ")
+			return enc.Encode(map[string]any{
+				"file":   sshPath,
+				"hosts":  countExported(report),
+				"report": report,
+			})
+		}
+		for _, e := range report {
+			if e.Action == sshconfig.ActionDropped {
+				fmt.Printf("skipped %s: %s\n", e.Profile, e.Detail)
+			}
+		}
+		switch {
+		case syncSSHRemove:
+			fmt.Printf("Removed the veessh managed block from %s\n", sshPath)
+		case syncSSHInclude:
+			fmt.Printf("Wrote %d hosts to %s (included from %s)\n", countExported(report), includePath, sshPath)
+		default:
+			fmt.Printf("Wrote %d hosts to %s\n", countExported(report), sshPath)
+		}
+		return nil
+	},
+}
+
+func init() {
+	cmdSyncSSHConfig.Flags().StringVar(&syncSSHFile, "file", "", "ssh config file (default: ~/.ssh/config)")
+	cmdSyncSSHConfig.Flags().BoolVar(&syncSSHInclude, "include", false, "write hosts to veessh.conf next to the ssh config and Include it")
+	cmdSyncSSHConfig.Flags().BoolVar(&syncSSHRemove, "remove", false, "remove the managed block (and veessh.conf)")
+	cmdSyncSSHConfig.Flags().BoolVar(&syncSSHDryRun, "dry-run", false, "print the result without writing")
+}
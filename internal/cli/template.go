@@ -0,0 +1,195 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/vee-sh/veessh/internal/config"
+)
+
+var (
+	templateVars   map[string]string
+	templateRanges []string
+	templateDryRun bool
+	templateForce  bool
+)
+
+var cmdTemplate = &cobra.Command{
+	Use:   "template",
+	Short: "Stamp out profiles from parametrized templates",
+	Long: `Stamp out profiles from the templates defined in config.yaml.
+
+A template is a profile whose strings may use {{ .var }} placeholders,
+together with the variables it declares and their defaults (an empty
+default makes a variable required):
+
+  templates:
+    web:
+      description: web servers per environment
+      vars: {env: "", index: "1"}
+      profile:
+        name: "web-{{ .env }}-{{ .index }}"
+        host: "web{{ .index }}.{{ .env }}.example.com"
+        extends: [base-ssh, "{{ .env }}-bastion"]
+        tags: [web, "{{ .env }}"]
+        localForwards: ["80{{ .index }}:localhost:80"]
+
+Subcommands:
+  list   - List templates and their variables
+  apply  - Create profiles from a template`,
+}
+
+var cmdTemplateList = &cobra.Command{
+	Use:   "list",
+	Short: "List templates and their variables",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfgPath, err := config.DefaultPath()
+		if err != nil {
+			return fmt.Errorf("failed to determine config path: %w", err)
+		}
+		cfg, err := config.Load(cfgPath)
+		if err != nil {
+			return err
+		}
+
+		names := make([]string, 0, len(cfg.Templates))
+		for name := range cfg.Templates {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		if OutputJSON() {
+			enc := json.NewEncoder(cmd.OutOrStdout())
+			enc.SetIndent("", "  ")
+			return enc.Encode(cfg.Templates)
+		}
+		if len(names) == 0 {
+			fmt.Println("No templates defined. Add a 'templates:' section to config.yaml.")
+			return nil
+		}
+		for _, name := range names {
+			t := cfg.Templates[name]
+			var vars []string
+			for _, v := range t.VarNames() {
+				if t.Vars[v] == "" {
+					vars = append(vars, v+" (required)")
+				} else {
+					vars = append(vars, v+"="+t.Vars[v])
+				}
+			}
+			fmt.Printf("%-16s %s\n", name, t.Profile.Name)
+			if t.Description != "" {
+				fmt.Printf("  %s\n", t.Description)
+			}
+			if len(vars) > 0 {
+				fmt.Printf("  vars: %s\n", strings.Join(vars, ", "))
+			}
+		}
+		return nil
+	},
+}
+
+var cmdTemplateApply = &cobra.Command{
+	Use:   "apply <template>",
+	Short: "Create profiles from a template",
+	Long: `Create profiles from a template.
+
+--var sets a variable. --range steps a variable through integers and
+creates one profile per value; several ranges create every combination.
+A zero-padded start keeps its width, so index=01..12 yields 01 ... 12.
+
+Existing profiles are left alone unless --force is given.`,
+	Example: `  veessh template apply web --var env=prod --range index=1..12
+  veessh template apply web --var env=staging --range index=01..04 --dry-run`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfgPath, err := config.DefaultPath()
+		if err != nil {
+			return fmt.Errorf("failed to determine config path: %w", err)
+		}
+		cfg, err := config.Load(cfgPath)
+		if err != nil {
+			return err
+		}
+		tmpl, ok := cfg.Templates[args[0]]
+		if !ok {
+			return fmt.Errorf("template %q not found", args[0])
+		}
+
+		var ranges []config.Range
+		for _, spec := range templateRanges {
+			r, err := config.ParseRange(spec)
+			if err != nil {
+				return err
+			}
+			ranges = append(ranges, r)
+		}
+
+		var created []config.Profile
+		seen := map[string]bool{}
+		for _, vars := range config.Expand(templateVars, ranges) {
+			p, err := tmpl.Render(vars)
+			if err != nil {
+				return fmt.Errorf("template %s: %w", args[0], err)
+			}
+			if seen[p.Name] {
+				return fmt.Errorf("template %s: profile name %q is generated more than once; use every ranged variable in the name", args[0], p.Name)
+			}
+			seen[p.Name] = true
+			if _, exists := cfg.Profiles[p.Name]; exists && !templateForce {
+				return fmt.Errorf("profile %q already exists (use --force to overwrite)", p.Name)
+			}
+
+			// Validate the profile as it resolves, since host and protocol
+			// often come from a parent
+			cfg.UpsertProfile(p)
+			resolved, _ := cfg.GetProfile(p.Name)
+			resolved.Merge = p.Merge
+			if err := (&resolved).Validate(); err != nil {
+				return fmt.Errorf("profile %s: %w", p.Name, err)
+			}
+			if len(p.Extends) == 0 {
+				p.Port = resolved.Port
+				cfg.UpsertProfile(p)
+			}
+			created = append(created, p)
+		}
+
+		if !templateDryRun {
+			if err := config.Save(cfgPath, cfg); err != nil {
+				return err
+			}
+		}
+
+		if OutputJSON() {
+			enc := json.NewEncoder(cmd.OutOrStdout())
+			enc.SetIndent("", "  ")
+			return enc.Encode(created)
+		}
+		verb := "Created"
+		if templateDryRun {
+			verb = "Would create"
+		}
+		for _, p := range created {
+			resolved, _ := cfg.GetProfile(p.Name)
+			fmt.Printf("%s %s (%s@%s)\n", verb, p.Name, resolved.Username, resolved.Host)
+		}
+		fmt.Printf("%d profile(s) from template %s\n", len(created), args[0])
+		return nil
+	},
+}
+
+func init() {
+	cmdTemplateApply.Flags().StringToStringVar(&templateVars, "var", nil, "set a template variable (name=value, repeatable)")
+	cmdTemplateApply.Flags().StringArrayVar(&templateRanges, "range", nil, "step a variable through integers (name=from..to, repeatable)")
+	cmdTemplateApply.Flags().BoolVar(&templateDryRun, "dry-run", false, "show the profiles without saving them")
+	cmdTemplateApply.Flags().BoolVar(&templateForce, "force", false, "overwrite existing profiles")
+
+	cmdTemplate.AddCommand(cmdTemplateList)
+	cmdTemplate.AddCommand(cmdTemplateApply)
+}
@@ -1,12 +1,15 @@
 package cli
 
 import (
+	"context"
 	"fmt"
+	"io"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/spf13/cobra"
 
 	"github.com/vee-sh/veessh/internal/config"
+	"github.com/vee-sh/veessh/internal/credentials"
 	"github.com/vee-sh/veessh/internal/tui"
 )
 
@@ -21,6 +24,7 @@ The TUI provides an interactive way to:
   - Organize profiles into groups
   - Manage favorites and tags
   - Test connections
+  - Browse, upload and download files over SFTP
   - View connection history`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		cfgPath, err := config.DefaultPath()
@@ -34,6 +38,21 @@ The TUI provides an interactive way to:
 			return fmt.Errorf("failed to initialize TUI: %w", err)
 		}
 
+		// The alt screen owns the terminal, so the file backend cannot prompt
+		// for its master passphrase; it has to be unlocked beforehand
+		prompt := credentials.PassphrasePrompt
+		credentials.PassphrasePrompt = nil
+		defer func() { credentials.PassphrasePrompt = prompt }()
+
+		// Nor can the host key check print; warnings about mismatching
+		// keys still go to the audit log
+		tui.HostKeyCheck = func(ctx context.Context, cfg config.Config, p config.Profile) (context.Context, error) {
+			return checkHostKey(ctx, cfg, p, io.Discard)
+		}
+		tui.HostKeyRefused = func(ctx context.Context, p config.Profile, err error) error {
+			return hostKeyRefused(ctx, p, err, sshFailed)
+		}
+
 		// Start the TUI
 		p := tea.NewProgram(model, tea.WithAltScreen())
 		if _, err := p.Run(); err != nil {
@@ -0,0 +1,401 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/vee-sh/veessh/internal/audit"
+	"github.com/vee-sh/veessh/internal/config"
+	"github.com/vee-sh/veessh/internal/connectors"
+	"github.com/vee-sh/veessh/internal/credentials"
+	"github.com/vee-sh/veessh/internal/tunnel"
+	"github.com/vee-sh/veessh/internal/util"
+)
+
+var (
+	tunnelBackground    bool
+	tunnelReconnect     bool
+	tunnelCheckInterval time.Duration
+	tunnelMaxBackoff    time.Duration
+	tunnelLogFile       string
+	tunnelStopAll       bool
+)
+
+// How long 'veessh tunnel --background' waits for the forwards to listen
+const tunnelStartTimeout = 30 * time.Second
+
+var cmdTunnel = &cobra.Command{
+	Use:   "tunnel <profile> [forward...]",
+	Short: "Open a profile's port forwards without a shell",
+	Long: `Open the port forwards of an SSH or SFTP profile without starting a
+remote shell (ssh -N, or the native client).
+
+Without forward names the forwards that connect would open are used;
+with names, only those named forwards ('all' for every forward).
+
+The tunnel runs in the foreground until Ctrl+C, or with --background as
+a detached process that 'veessh tunnel list' shows and 'veessh tunnel
+stop' ends. Background tunnels cannot prompt, so keys must work without
+a passphrase prompt (or have their secrets stored with 'veessh secret')
+and the host key must already be known. Their output goes to a log file
+next to the tunnel records in the state directory.
+
+With --reconnect the tunnel survives network changes, like autossh: every
+--check-interval each forward is probed with its check command, or a TCP
+connect to its local port, and the connection is restarted with
+exponential backoff up to --max-backoff when it drops or two probes in a
+row fail. Restarts are recorded in the audit log.
+
+There is one tunnel per profile at a time.`,
+	Example: `  veessh tunnel db                    # Foreground, default forwards
+  veessh tunnel db pg redis -b        # Only pg and redis, in the background
+  veessh tunnel db -b --reconnect     # Restart the connection when it fails
+  veessh tunnel list
+  veessh tunnel stop db`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfgPath, err := config.DefaultPath()
+		if err != nil {
+			return fmt.Errorf("failed to determine config path: %w", err)
+		}
+		cfg, err := config.Load(cfgPath)
+		if err != nil {
+			return err
+		}
+		p, ok := cfg.GetProfile(args[0])
+		if !ok {
+			return fmt.Errorf("profile %q not found", args[0])
+		}
+		configureAudit(cfg)
+		if p.Protocol != config.ProtocolSSH && p.Protocol != config.ProtocolSFTP {
+			return fmt.Errorf("tunnels need an ssh or sftp profile; %s is %s", p.Name, p.Protocol)
+		}
+
+		var names []string
+		for _, arg := range args[1:] {
+			names = append(names, strings.Split(arg, ",")...)
+		}
+		if len(names) > 0 {
+			if err := p.SelectForwards(names); err != nil {
+				return err
+			}
+		}
+		forwards := tunnelForwards(p)
+		if len(forwards) == 0 {
+			return fmt.Errorf("profile %q has no forwards to open", p.Name)
+		}
+
+		dir, err := tunnel.Dir()
+		if err != nil {
+			return err
+		}
+		if t, running, _ := tunnel.Get(dir, p.Name); running {
+			return fmt.Errorf("a tunnel to %s is already running (pid %d); stop it with 'veessh tunnel stop %s'", p.Name, t.PID, p.Name)
+		}
+		if err := checkForwardPorts(p); err != nil {
+			return err
+		}
+		// A background tunnel checks again in the veessh running it, and
+		// enforces the pin there
+		ctx, err := checkHostKey(cmd.Context(), cfg, p, os.Stderr)
+		if err != nil {
+			return err
+		}
+
+		if tunnelBackground && tunnelLogFile == "" {
+			return startBackgroundTunnel(cmd, dir, p, args, forwards)
+		}
+		return runTunnel(ctx, dir, p, forwards)
+	},
+}
+
+var cmdTunnelList = &cobra.Command{
+	Use:   "list",
+	Short: "List running tunnels",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, err := tunnel.Dir()
+		if err != nil {
+			return err
+		}
+		tunnels, err := tunnel.List(dir)
+		if err != nil {
+			return err
+		}
+
+		if OutputJSON() {
+			if tunnels == nil {
+				tunnels = []tunnel.Tunnel{}
+			}
+			enc := json.NewEncoder(cmd.OutOrStdout())
+			enc.SetIndent("", "  ")
+			return enc.Encode(tunnels)
+		}
+
+		if len(tunnels) == 0 {
+			fmt.Fprintln(cmd.OutOrStdout(), "No tunnels running.")
+			return nil
+		}
+		out := cmd.OutOrStdout()
+		fmt.Fprintf(out, "%-20s  %-8s  %-10s  %-8s  %s\n", "PROFILE", "PID", "UPTIME", "RESTARTS", "FORWARDS")
+		for _, t := range tunnels {
+			var fwds []string
+			for _, f := range t.Forwards {
+				fwds = append(fwds, describeForward(f))
+			}
+			fmt.Fprintf(out, "%-20s  %-8d  %-10s  %-8d  %s\n", t.Profile, t.PID, t.Uptime().Round(time.Second), t.Restarts, strings.Join(fwds, ", "))
+		}
+		return nil
+	},
+}
+
+var cmdTunnelStop = &cobra.Command{
+	Use:   "stop <profile>...",
+	Short: "Stop running tunnels",
+	Args: func(cmd *cobra.Command, args []string) error {
+		if tunnelStopAll != (len(args) == 0) {
+			return errors.New("name the profiles whose tunnels to stop, or use --all")
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, err := tunnel.Dir()
+		if err != nil {
+			return err
+		}
+		var tunnels []tunnel.Tunnel
+		if tunnelStopAll {
+			if tunnels, err = tunnel.List(dir); err != nil {
+				return err
+			}
+		}
+		for _, name := range args {
+			t, running, err := tunnel.Get(dir, name)
+			if err != nil {
+				return err
+			}
+			if !running {
+				return fmt.Errorf("no tunnel to %s is running", name)
+			}
+			tunnels = append(tunnels, t)
+		}
+
+		for _, t := range tunnels {
+			if err := tunnel.Stop(dir, t, 5*time.Second); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Stopped tunnel to %s (pid %d)\n", t.Profile, t.PID)
+		}
+		return nil
+	},
+}
+
+// tunnelForwards lists the forwards a tunnel to p opens
+func tunnelForwards(p config.Profile) []tunnel.Forward {
+	var out []tunnel.Forward
+	for _, f := range p.ActiveForwards() {
+		tf := tunnel.Forward{Name: f.Name, Type: f.Kind(), Listen: f.BindAddr(), Port: f.LocalPort, Check: f.Check}
+		if f.Kind() != config.ForwardDynamic {
+			tf.Dest = f.DestAddr()
+		}
+		out = append(out, tf)
+	}
+	return out
+}
+
+func describeForward(f tunnel.Forward) string {
+	var s string
+	switch f.Type {
+	case config.ForwardDynamic:
+		s = f.Listen + " (SOCKS)"
+	case config.ForwardRemote:
+		s = f.Listen + " (remote) -> " + f.Dest
+	default:
+		s = f.Listen + " -> " + f.Dest
+	}
+	if f.Name != "" {
+		s = f.Name + " " + s
+	}
+	return s
+}
+
+// runTunnel keeps the forwards of p open until the connection ends, Ctrl+C
+// or 'veessh tunnel stop', enforcing the pin ctx carries
+func runTunnel(ctx context.Context, dir string, p config.Profile, forwards []tunnel.Forward) error {
+	conn, err := connectors.TunnelerFor(p)
+	if err != nil {
+		return err
+	}
+	// 'veessh tunnel stop' sends SIGTERM
+	runCtx, stop := signal.NotifyContext(ctx, syscall.SIGTERM)
+	defer stop()
+
+	started := time.Now()
+	h, err := tunnel.Register(dir, tunnel.Tunnel{
+		Profile:    p.Name,
+		Host:       p.Host,
+		PID:        os.Getpid(),
+		Forwards:   forwards,
+		Started:    started,
+		Background: tunnelLogFile != "",
+		Log:        tunnelLogFile,
+	})
+	if err != nil {
+		return err
+	}
+	defer h.Close()
+
+	secrets, err := credentials.GetSecrets(p.Name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to retrieve stored secrets: %v\n", err)
+		secrets = credentials.Secrets{}
+	}
+
+	fmt.Printf("%s Tunnel to %s (%s)\n", started.Format(time.DateTime), p.Name, p.Host)
+	for _, f := range forwards {
+		fmt.Printf("  %s\n", describeForward(f))
+	}
+	if tunnelLogFile == "" {
+		fmt.Println("Press Ctrl+C to close the tunnel")
+	}
+
+	session := audit.LogConnect(p.Name, string(p.Protocol), p.Host, p.Username)
+	if tunnelReconnect {
+		sup := &tunnel.Supervisor{
+			Run: func(ctx context.Context) error {
+				return hostKeyRefused(ctx, p, conn.Tunnel(ctx, p, secrets), sshFailed)
+			},
+			Forwards:   forwards,
+			Interval:   tunnelCheckInterval,
+			MaxBackoff: tunnelMaxBackoff,
+			OnRestart: func(attempt int, reason error, wait time.Duration) {
+				fmt.Printf("%s Tunnel to %s down: %v; reconnecting in %s (attempt %d)\n", time.Now().Format(time.DateTime), p.Name, reason, wait, attempt)
+				audit.LogReconnect(session, p.Name, string(p.Protocol), p.Host, p.Username, attempt, reason)
+				h.Update(func(t *tunnel.Tunnel) { t.Restarts = attempt })
+			},
+		}
+		err = sup.Supervise(runCtx)
+	} else {
+		err = hostKeyRefused(runCtx, p, conn.Tunnel(runCtx, p, secrets), sshFailed)
+	}
+	if runCtx.Err() != nil {
+		// Stopped on purpose
+		audit.LogDisconnect(session, p.Name, string(p.Protocol), p.Host, p.Username, started, 0, nil)
+		if ctx.Err() != nil {
+			return context.Canceled
+		}
+		fmt.Printf("%s Tunnel to %s stopped\n", time.Now().Format(time.DateTime), p.Name)
+		return nil
+	}
+	if err == nil {
+		err = errors.New("ssh exited")
+	}
+	audit.LogDisconnect(session, p.Name, string(p.Protocol), p.Host, p.Username, started, connectors.ExitCode(err), err)
+	return fmt.Errorf("tunnel to %s closed: %w", p.Name, err)
+}
+
+// startBackgroundTunnel runs the tunnel in a detached veessh and waits until
+// its forwards listen
+func startBackgroundTunnel(cmd *cobra.Command, dir string, p config.Profile, args []string, forwards []tunnel.Forward) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to locate veessh executable: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	logPath := tunnel.LogPath(dir, p.Name)
+	logFile, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+
+	childArgs := append(append([]string{"tunnel"}, args...), "--log-file", logPath)
+	if tunnelReconnect {
+		childArgs = append(childArgs, "--reconnect", "--check-interval", tunnelCheckInterval.String(), "--max-backoff", tunnelMaxBackoff.String())
+	}
+	child := exec.Command(exe, childArgs...)
+	child.Stdout = logFile
+	child.Stderr = logFile
+	util.Detach(child)
+	if err := child.Start(); err != nil {
+		return fmt.Errorf("failed to start tunnel: %w", err)
+	}
+	exited := make(chan error, 1)
+	go func() { exited <- child.Wait() }()
+
+	out := cmd.OutOrStdout()
+	deadline := time.After(tunnelStartTimeout)
+	for !tunnelListening(dir, p.Name, child.Process.Pid, forwards) {
+		select {
+		case err := <-exited:
+			if data, _ := os.ReadFile(logPath); len(strings.TrimSpace(string(data))) > 0 {
+				return fmt.Errorf("tunnel to %s failed:\n%s", p.Name, strings.TrimSpace(string(data)))
+			}
+			return fmt.Errorf("tunnel to %s failed: %v", p.Name, err)
+		case <-deadline:
+			fmt.Fprintf(out, "Tunnel to %s started (pid %d) but is not listening yet; see %s\n", p.Name, child.Process.Pid, logPath)
+			return nil
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+
+	fmt.Fprintf(out, "Tunnel to %s running in the background (pid %d)\n", p.Name, child.Process.Pid)
+	for _, f := range forwards {
+		fmt.Fprintf(out, "  %s\n", describeForward(f))
+	}
+	fmt.Fprintf(out, "Log: %s\nStop it with 'veessh tunnel stop %s'\n", logPath, p.Name)
+	return nil
+}
+
+// tunnelListening reports whether the tunnel run by pid is registered and
+// listens on all its local ports. Remote forwards cannot be checked from
+// here.
+func tunnelListening(dir, profile string, pid int, forwards []tunnel.Forward) bool {
+	t, running, _ := tunnel.Get(dir, profile)
+	if !running || t.PID != pid {
+		return false
+	}
+	for _, f := range forwards {
+		if f.Type != config.ForwardRemote && !portInUse(f.Listen) {
+			return false
+		}
+	}
+	return true
+}
+
+// portInUse reports whether something listens on addr, without connecting
+// to it
+func portInUse(addr string) bool {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return errors.Is(err, syscall.EADDRINUSE)
+	}
+	l.Close()
+	return false
+}
+
+func init() {
+	cmdTunnel.Flags().BoolVarP(&tunnelBackground, "background", "b", false, "run the tunnel as a detached background process")
+	cmdTunnel.Flags().BoolVarP(&tunnelReconnect, "reconnect", "r", false, "probe the forwards and reconnect when the tunnel fails")
+	cmdTunnel.Flags().DurationVar(&tunnelCheckInterval, "check-interval", tunnel.DefaultInterval, "time between health checks with --reconnect")
+	cmdTunnel.Flags().DurationVar(&tunnelMaxBackoff, "max-backoff", tunnel.DefaultMaxBackoff, "longest wait between reconnects")
+	// Set on the detached veessh that runs a background tunnel
+	cmdTunnel.Flags().StringVar(&tunnelLogFile, "log-file", "", "log file of a background tunnel")
+	cmdTunnel.Flags().MarkHidden("log-file")
+	cmdTunnelStop.Flags().BoolVar(&tunnelStopAll, "all", false, "stop every running tunnel")
+	cmdTunnel.AddCommand(cmdTunnelList)
+	cmdTunnel.AddCommand(cmdTunnelStop)
+}
@@ -0,0 +1,50 @@
+package cli
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/vee-sh/veessh/internal/config"
+	"github.com/vee-sh/veessh/internal/hostkeys"
+)
+
+func TestTunnelChecksHostKey(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	port, key := startHostKeyServer(t)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	free := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+
+	var cfg config.Config
+	cfg.UpsertProfile(config.Profile{Name: "db", Protocol: config.ProtocolSSH, Host: "127.0.0.1", Port: port, Forwards: []config.Forward{
+		{Name: "pg", Bind: "127.0.0.1", LocalPort: free, RemoteHost: "db", RemotePort: 5432},
+	}})
+	if err := config.Save("", cfg); err != nil {
+		t.Fatal(err)
+	}
+	err = hostkeys.UpdateStore(func(s *hostkeys.Store) error {
+		s.Pin("", "127.0.0.1", port, hostkeys.Key{Type: key.Type(), Fingerprint: "SHA256:c3RhbGUga2V5"})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Refused before any tunnel starts, in the foreground or not
+	for _, background := range []bool{false, true} {
+		tunnelBackground = background
+		cmdTunnel.SetContext(context.Background())
+		err := cmdTunnel.RunE(cmdTunnel, []string{"db"})
+		if err == nil || !strings.Contains(err.Error(), "host key check failed") {
+			t.Errorf("background %v: error = %v, want a host key refusal", background, err)
+		}
+	}
+	tunnelBackground = false
+}
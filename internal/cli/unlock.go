@@ -0,0 +1,113 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/vee-sh/veessh/internal/credentials"
+	"github.com/vee-sh/veessh/internal/util"
+)
+
+var unlockTimeout time.Duration
+
+var cmdUnlock = &cobra.Command{
+	Use:   "unlock",
+	Short: "Unlock the encrypted file backend for a while",
+	Long: `Ask for the master passphrase of the encrypted file backend once and
+cache the derived key in a background agent, so later commands do not
+prompt again until the timeout expires or 'veessh lock' is run.
+
+The agent listens on a unix socket in a directory only you can access
+($XDG_RUNTIME_DIR/veessh, or a private veessh-<uid> directory in the temp
+dir). It keeps keys in memory only and never writes the passphrase to disk.
+
+If no password file exists yet, a new master passphrase is set up. A file
+written by an older veessh is upgraded to the passphrase protected format.`,
+	Example: `  veessh unlock
+  veessh unlock --timeout 2h
+  veessh lock`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if running, _, _ := credentials.AgentStatus(); !running {
+			if err := startAgent(unlockTimeout); err != nil {
+				return err
+			}
+		}
+		if err := credentials.CacheFileBackendKey(); err != nil {
+			return err
+		}
+
+		_, _, expires := credentials.AgentStatus()
+		if expires.IsZero() {
+			fmt.Println("Unlocked until 'veessh lock'")
+		} else {
+			fmt.Printf("Unlocked until %s\n", expires.Format("15:04:05"))
+		}
+		return nil
+	},
+}
+
+var cmdLock = &cobra.Command{
+	Use:   "lock",
+	Short: "Forget the cached master passphrase key and stop the agent",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if running, _, _ := credentials.AgentStatus(); !running {
+			fmt.Println("Already locked")
+			return nil
+		}
+		if err := credentials.LockAgent(); err != nil {
+			return err
+		}
+		fmt.Println("Locked")
+		return nil
+	},
+}
+
+var cmdAgent = &cobra.Command{
+	Use:    "agent",
+	Short:  "Run the unlock agent (started by 'veessh unlock')",
+	Hidden: true,
+	Args:   cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return credentials.RunAgent(unlockTimeout, nil)
+	},
+}
+
+// startAgent launches a detached 'veessh agent' and waits until it answers
+func startAgent(timeout time.Duration) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to locate veessh executable: %w", err)
+	}
+	agent := exec.Command(exe, "agent", "--timeout", timeout.String())
+	util.Detach(agent)
+	if err := agent.Start(); err != nil {
+		return fmt.Errorf("failed to start unlock agent: %w", err)
+	}
+	exited := make(chan error, 1)
+	go func() { exited <- agent.Wait() }()
+
+	deadline := time.After(3 * time.Second)
+	for {
+		if running, _, _ := credentials.AgentStatus(); running {
+			return nil
+		}
+		select {
+		case err := <-exited:
+			return fmt.Errorf("unlock agent exited: %v", err)
+		case <-deadline:
+			return fmt.Errorf("unlock agent did not start on %s", credentials.AgentSocketPath())
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+}
+
+func init() {
+	cmdUnlock.Flags().DurationVar(&unlockTimeout, "timeout", 15*time.Minute, "forget the key after this long (0 = until 'veessh lock')")
+	cmdAgent.Flags().DurationVar(&unlockTimeout, "timeout", 15*time.Minute, "exit after this long (0 = never)")
+}
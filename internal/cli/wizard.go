@@ -197,7 +197,7 @@ func runOnboardingWizard(cfgPath string) error {
 		Port:         port,
 		Username:     strings.TrimSpace(answers.Username),
 		IdentityFile: strings.TrimSpace(answers.Identity),
-		UseAgent:     answers.UseAgent,
+		UseAgent:     config.Bool(answers.UseAgent),
 		Group:        strings.TrimSpace(answers.Group),
 		Description:  strings.TrimSpace(answers.Desc),
 	}
@@ -0,0 +1,79 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+)
+
+// Audit configures the audit log. It is read from the main config only.
+type Audit struct {
+	MaxSizeMB  int         `yaml:"maxSizeMB,omitempty"`  // Rotate the log at this size (default 10)
+	MaxAgeDays int         `yaml:"maxAgeDays,omitempty"` // Rotate the log when its first entry is this old (default 30)
+	Keep       int         `yaml:"keep,omitempty"`       // Compressed archives to keep; 0 keeps all
+	Sinks      []AuditSink `yaml:"sinks,omitempty"`      // Where entries are shipped besides the log
+}
+
+// Audit sink types
+const (
+	SinkSyslog   = "syslog"
+	SinkJournald = "journald"
+	SinkWebhook  = "webhook"
+)
+
+// AuditSink ships audit entries to syslog, journald or an HTTPS endpoint
+type AuditSink struct {
+	Name      string            `yaml:"name,omitempty"`      // Shown by 'veessh audit ship'; defaults to the type
+	Type      string            `yaml:"type"`                // syslog, journald or webhook
+	Network   string            `yaml:"network,omitempty"`   // syslog: udp (default), tcp or unix
+	Address   string            `yaml:"address,omitempty"`   // syslog: host:port or socket path; journald: socket path
+	URL       string            `yaml:"url,omitempty"`       // webhook: endpoint, https unless on this host
+	Headers   map[string]string `yaml:"headers,omitempty"`   // webhook: extra headers; $VAR is expanded from the environment
+	BatchSize int               `yaml:"batchSize,omitempty"` // webhook: entries per request (default 50)
+}
+
+// Label names the sink in messages
+func (s AuditSink) Label() string {
+	if s.Name != "" {
+		return s.Name
+	}
+	return s.Type
+}
+
+// Validate checks the settings of the sink's type
+func (s AuditSink) Validate() error {
+	switch s.Type {
+	case SinkSyslog:
+		switch s.Network {
+		case "", "udp", "tcp", "unix":
+		default:
+			return fmt.Errorf("invalid syslog network: %s (must be udp, tcp or unix)", s.Network)
+		}
+		if s.Network == "unix" && s.Address == "" {
+			return fmt.Errorf("syslog over unix needs an address (e.g. /dev/log)")
+		}
+	case SinkJournald:
+	case SinkWebhook:
+		u, err := url.Parse(s.URL)
+		if err != nil || u.Host == "" {
+			return fmt.Errorf("webhook needs a url, e.g. https://audit.example.com/veessh")
+		}
+		if u.Scheme != "https" && !(u.Scheme == "http" && isLoopback(u.Hostname())) {
+			return fmt.Errorf("webhook url must be https (http only to this host): %s", s.URL)
+		}
+		if s.BatchSize < 0 {
+			return fmt.Errorf("invalid batchSize: %d", s.BatchSize)
+		}
+	default:
+		return fmt.Errorf("invalid audit sink type: %q (must be syslog, journald or webhook)", s.Type)
+	}
+	return nil
+}
+
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,348 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Issue severities
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// Issue is a problem found by Check, located in the file that defines it
+type Issue struct {
+	File     string `json:"file,omitempty"`
+	Line     int    `json:"line,omitempty"`
+	Profile  string `json:"profile,omitempty"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+func (i Issue) String() string {
+	var b strings.Builder
+	if i.File != "" {
+		b.WriteString(i.File)
+		if i.Line > 0 {
+			fmt.Fprintf(&b, ":%d", i.Line)
+		}
+		b.WriteString(": ")
+	}
+	b.WriteString(i.Severity + ": ")
+	if i.Profile != "" {
+		fmt.Fprintf(&b, "profile %q: ", i.Profile)
+	}
+	b.WriteString(i.Message)
+	return b.String()
+}
+
+// Check looks for problems Load lets through: profile errors, unknown
+// parents and inheritance cycles, malformed forwards, missing identity
+// files, profiles that connect to the same place and unknown keys. Issues
+// are sorted by file and line.
+func (c *Config) Check() []Issue {
+	pos := c.positions()
+	var issues []Issue
+	report := func(name, field, severity, format string, args ...any) {
+		file, line := pos.at(c.Origin(name), name, field)
+		issues = append(issues, Issue{File: file, Line: line, Profile: name, Severity: severity, Message: fmt.Sprintf(format, args...)})
+	}
+
+	names := sortedKeys(c.Profiles)
+	type endpoint struct {
+		host, user string
+		port       int
+	}
+	seen := map[endpoint]string{}
+	for _, name := range names {
+		p := c.Profiles[name]
+		resolved, _ := c.GetProfile(name)
+		fwdErr := p.validateForwards()
+		if fwdErr != nil {
+			report(name, "forwards", SeverityError, "%v", fwdErr)
+		}
+		if err := resolved.Validate(); err != nil && (fwdErr == nil || err.Error() != fwdErr.Error()) {
+			report(name, "", SeverityError, "%v", err)
+		}
+
+		for _, parent := range p.Extends {
+			if _, ok := c.Profiles[parent]; !ok {
+				report(name, "extends", SeverityError, "extends unknown profile %q", parent)
+			}
+		}
+		if cycle := c.cycle(name); cycle != nil && cycle[0] == name {
+			report(name, "extends", SeverityError, "inheritance cycle: %s", strings.Join(cycle, " -> "))
+		}
+
+		for _, list := range []struct {
+			field   string
+			specs   []string
+			dynamic bool
+		}{
+			{"localForwards", p.LocalForwards, false},
+			{"remoteForwards", p.RemoteForwards, false},
+			{"dynamicForwards", p.DynamicForwards, true},
+		} {
+			for _, spec := range list.specs {
+				if _, err := ParseForward(spec, list.dynamic); err != nil {
+					report(name, list.field, SeverityError, "%v", err)
+				}
+			}
+		}
+
+		if p.IdentityFile != "" {
+			if _, err := os.Stat(expandHome(p.IdentityFile)); errors.Is(err, fs.ErrNotExist) {
+				report(name, "identityFile", SeverityWarning, "identity file %s does not exist", p.IdentityFile)
+			}
+		}
+
+		if resolved.Host != "" && resolved.Protocol != ProtocolSSM && resolved.Protocol != ProtocolGCloud {
+			e := endpoint{host: strings.ToLower(resolved.Host), user: resolved.Username, port: resolved.Port}
+			if first, ok := seen[e]; ok {
+				report(name, "host", SeverityWarning, "same host, port and user as profile %q", first)
+			} else {
+				seen[e] = name
+			}
+		}
+	}
+
+	if err := validHostKeyPolicy(c.HostKeyPolicy); err != nil && c.layers != nil {
+		file, line := pos.at(c.layers.main, "", "hostKeyPolicy")
+		issues = append(issues, Issue{File: file, Line: line, Severity: SeverityError, Message: err.Error()})
+	}
+	if c.Audit != nil && c.layers != nil {
+		seen := map[string]bool{}
+		for i, sink := range c.Audit.Sinks {
+			err := sink.Validate()
+			if err == nil && seen[sink.Label()] {
+				err = fmt.Errorf("another sink is called %q; give this one a name", sink.Label())
+			}
+			seen[sink.Label()] = true
+			if err != nil {
+				file, line := pos.at(c.layers.main, "", fmt.Sprintf("audit.sinks.%d", i))
+				issues = append(issues, Issue{File: file, Line: line, Severity: SeverityError, Message: fmt.Sprintf("audit sink %q: %v", sink.Label(), err)})
+			}
+		}
+	}
+
+	issues = append(issues, pos.unknown...)
+	sort.SliceStable(issues, func(i, j int) bool {
+		if issues[i].File != issues[j].File {
+			return issues[i].File < issues[j].File
+		}
+		return issues[i].Line < issues[j].Line
+	})
+	return issues
+}
+
+// cycle returns the inheritance cycle name is part of, starting and ending
+// with the alphabetically first profile on it, or nil
+func (c *Config) cycle(name string) []string {
+	var path []string
+	onPath := map[string]int{}
+	var walk func(n string) []string
+	walk = func(n string) []string {
+		if i, ok := onPath[n]; ok {
+			return append(append([]string(nil), path[i:]...), n)
+		}
+		p, ok := c.Profiles[n]
+		if !ok {
+			return nil
+		}
+		onPath[n] = len(path)
+		path = append(path, n)
+		defer func() {
+			path = path[:len(path)-1]
+			delete(onPath, n)
+		}()
+		for _, parent := range p.Extends {
+			if found := walk(parent); found != nil {
+				return found
+			}
+		}
+		return nil
+	}
+	found := walk(name)
+	if found == nil || !slices.Contains(found, name) {
+		return nil
+	}
+
+	// Rotate so that the cycle is reported once, from its first member
+	ring := found[:len(found)-1]
+	start := 0
+	for i, n := range ring {
+		if n < ring[start] {
+			start = i
+		}
+	}
+	out := append(append([]string(nil), ring[start:]...), ring[:start]...)
+	return append(out, out[0])
+}
+
+// positions maps profiles and their keys to lines in the files they came
+// from, and collects unknown keys on the way
+type positions struct {
+	lines   map[string]map[string]int // file -> "profile\x00field" -> line
+	unknown []Issue
+}
+
+func (p *positions) at(file, name, field string) (string, int) {
+	lines := p.lines[file]
+	if line, ok := lines[name+"\x00"+field]; ok {
+		return file, line
+	}
+	return file, lines[name+"\x00"]
+}
+
+func (c *Config) positions() *positions {
+	pos := &positions{lines: map[string]map[string]int{}}
+	for _, layer := range c.Layers() {
+		if layer.Ignored != "" {
+			continue
+		}
+		data, err := os.ReadFile(layer.Path)
+		if err != nil {
+			continue
+		}
+		var doc yaml.Node
+		if yaml.Unmarshal(data, &doc) != nil || len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+			continue
+		}
+		pos.file(layer.Path, doc.Content[0])
+	}
+	return pos
+}
+
+func (pos *positions) file(path string, root *yaml.Node) {
+	lines := map[string]int{}
+	pos.lines[path] = lines
+	unknown := func(key *yaml.Node, where string, known reflect.Type) {
+		msg := fmt.Sprintf("unknown key %q in %s", key.Value, where)
+		if guess := closest(key.Value, yamlKeys(known)); guess != "" {
+			msg += fmt.Sprintf(" (did you mean %q?)", guess)
+		}
+		pos.unknown = append(pos.unknown, Issue{File: path, Line: key.Line, Severity: SeverityWarning, Message: msg})
+	}
+
+	for i := 0; i < len(root.Content)-1; i += 2 {
+		key, val := root.Content[i], root.Content[i+1]
+		switch key.Value {
+		case "profiles":
+			eachEntry(val, func(name, value *yaml.Node) {
+				lines[name.Value+"\x00"] = name.Line
+				eachEntry(value, func(field, _ *yaml.Node) {
+					lines[name.Value+"\x00"+field.Value] = field.Line
+					if !isKey(field.Value, reflect.TypeOf(Profile{})) {
+						unknown(field, fmt.Sprintf("profile %q", name.Value), reflect.TypeOf(Profile{}))
+					}
+				})
+			})
+		case "templates":
+			eachEntry(val, func(name, value *yaml.Node) {
+				eachEntry(value, func(field, value *yaml.Node) {
+					if !isKey(field.Value, reflect.TypeOf(Template{})) {
+						unknown(field, fmt.Sprintf("template %q", name.Value), reflect.TypeOf(Template{}))
+					}
+					if field.Value != "profile" {
+						return
+					}
+					eachEntry(value, func(f, _ *yaml.Node) {
+						if !isKey(f.Value, reflect.TypeOf(Profile{})) {
+							unknown(f, fmt.Sprintf("template %q", name.Value), reflect.TypeOf(Profile{}))
+						}
+					})
+				})
+			})
+		case "hostKeyPolicy":
+			lines["\x00hostKeyPolicy"] = key.Line
+		case "audit":
+			eachEntry(val, func(field, value *yaml.Node) {
+				if field.Value == "sinks" && value.Kind == yaml.SequenceNode {
+					for i, sink := range value.Content {
+						lines[fmt.Sprintf("\x00audit.sinks.%d", i)] = sink.Line
+					}
+				}
+			})
+		default:
+			// Blocks only there to define anchors are fine
+			if !isKey(key.Value, reflect.TypeOf(Config{})) && val.Anchor == "" && !strings.HasPrefix(key.Value, "x-") {
+				unknown(key, "the config", reflect.TypeOf(Config{}))
+			}
+		}
+	}
+}
+
+// eachEntry calls fn for the keys of a mapping node other than merge keys
+func eachEntry(m *yaml.Node, fn func(key, value *yaml.Node)) {
+	if m.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i < len(m.Content)-1; i += 2 {
+		if m.Content[i].Value != "<<" {
+			fn(m.Content[i], m.Content[i+1])
+		}
+	}
+}
+
+func isKey(key string, t reflect.Type) bool {
+	return slices.Contains(yamlKeys(t), key)
+}
+
+// yamlKeys returns the YAML keys of struct type t in declaration order
+func yamlKeys(t reflect.Type) []string {
+	var keys []string
+	for i := 0; i < t.NumField(); i++ {
+		if f := t.Field(i); f.IsExported() && fieldKey(f) != "-" {
+			keys = append(keys, fieldKey(f))
+		}
+	}
+	return keys
+}
+
+// closest returns the key that key is most likely a misspelling of, or ""
+func closest(key string, keys []string) string {
+	norm := func(s string) string {
+		return strings.ToLower(strings.NewReplacer("_", "", "-", "").Replace(s))
+	}
+	best, bestDist := "", 3
+	for _, k := range keys {
+		a, b := norm(key), norm(k)
+		if a == b || len(a) >= 4 && len(b) >= 4 && (strings.HasPrefix(a, b) || strings.HasPrefix(b, a)) {
+			return k
+		}
+		if d := editDistance(strings.ToLower(key), strings.ToLower(k)); d < bestDist {
+			best, bestDist = k, d
+		}
+	}
+	return best
+}
+
+// editDistance is the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
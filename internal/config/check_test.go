@@ -0,0 +1,125 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	isolateState(t)
+	t.Setenv(NoProjectEnv, "1")
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	writeFile(t, path, `x-base: &base
+  protocol: ssh
+profiles:
+  a:
+    <<: *base
+    host: h.com
+    extends: [b, ghost]
+    identityFile: `+filepath.Join(dir, "missing_key")+`
+    localForwards: ["8080:x"]
+    hostname: typo
+  b: {protocol: ssh, host: h.com, extends: a}
+  c: {protocol: ssh, host: H.com, port: 22}
+  d: {protocol: bogus, host: z}
+  e: {protocol: ssm, host: i-123}
+  f: {protocol: ssm, host: i-123}
+stuff: 1
+hostKeyPolicy: paranoid
+audit:
+  sinks:
+    - {type: syslog, network: tcp, address: "logs:514"}
+    - {type: webhook, url: "http://audit.example.com/in"}
+    - {type: kafka}
+    - {type: syslog, network: unix, address: /dev/log}
+`)
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, i := range cfg.Check() {
+		if i.File != path {
+			t.Errorf("issue in %q: %v", i.File, i)
+		}
+		got = append(got, fmt.Sprintf("%d %s %s %s", i.Line, i.Severity, i.Profile, i.Message))
+	}
+	want := []string{
+		`7 error a extends unknown profile "ghost"`,
+		`7 error a inheritance cycle: a -> b -> a`,
+		`8 warning a identity file ` + filepath.Join(dir, "missing_key") + ` does not exist`,
+		`9 error a invalid forward "8080:x"`,
+		`10 warning  unknown key "hostname" in profile "a" (did you mean "host"?)`,
+		`11 warning b same host, port and user as profile "a"`,
+		`12 warning c same host, port and user as profile "a"`,
+		`13 error d unsupported protocol: bogus`,
+		`16 warning  unknown key "stuff" in the config`,
+		`17 error  invalid hostKeyPolicy: paranoid (must be strict, warn or off)`,
+		`21 error  audit sink "webhook": webhook url must be https (http only to this host): http://audit.example.com/in`,
+		`22 error  audit sink "kafka": invalid audit sink type: "kafka" (must be syslog, journald or webhook)`,
+		`23 error  audit sink "syslog": another sink is called "syslog"; give this one a name`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Check():\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestCheckClean(t *testing.T) {
+	cfg := Config{Profiles: map[string]Profile{
+		"base": {Name: "base", Protocol: ProtocolSSH, Host: "h.com"},
+		"app":  {Name: "app", Protocol: ProtocolSSH, Host: "h.com", Username: "app", Extends: Parents{"base"}, DynamicForwards: []string{"1080"}},
+	}}
+	if issues := cfg.Check(); len(issues) != 0 {
+		t.Errorf("Check() = %v", issues)
+	}
+}
+
+func TestCycle(t *testing.T) {
+	cfg := Config{Profiles: map[string]Profile{
+		"a": {Name: "a", Extends: Parents{"b"}},
+		"b": {Name: "b", Extends: Parents{"c"}},
+		"c": {Name: "c", Extends: Parents{"b"}},
+		"d": {Name: "d", Extends: Parents{"d"}},
+	}}
+	tests := map[string]string{"a": "", "b": "b c b", "c": "b c b", "d": "d d"}
+	for name, want := range tests {
+		if got := strings.Join(cfg.cycle(name), " "); got != want {
+			t.Errorf("cycle(%s) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestSchema(t *testing.T) {
+	data, err := json.Marshal(Schema())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var schema struct {
+		Properties map[string]any `json:"properties"`
+		Defs       map[string]struct {
+			Properties map[string]map[string]any `json:"properties"`
+		} `json:"$defs"`
+	}
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"version", "profiles", "templates", "include"} {
+		if _, ok := schema.Properties[key]; !ok {
+			t.Errorf("schema has no %q", key)
+		}
+	}
+	profile := schema.Defs["profile"].Properties
+	for _, key := range ProfileFields() {
+		if _, ok := profile[key]; !ok {
+			t.Errorf("profile schema has no %q", key)
+		}
+	}
+	if enum, _ := profile["protocol"]["enum"].([]any); len(enum) != 6 {
+		t.Errorf("protocol enum = %v", profile["protocol"]["enum"])
+	}
+}
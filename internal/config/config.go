@@ -3,14 +3,16 @@ package config
 import (
 	"errors"
 	"fmt"
-	"io/fs"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"sort"
 	"strings"
 	"time"
+	"unicode"
 
-	"gopkg.in/yaml.v3"
+	"github.com/vee-sh/veessh/internal/state"
 )
 
 type Protocol string
@@ -24,6 +26,12 @@ const (
 	ProtocolGCloud Protocol = "gcloud"
 )
 
+// SSH client implementations selectable per profile
+const (
+	SSHClientOpenSSH = "openssh" // system ssh binary (default when installed)
+	SSHClientNative  = "native"  // in-process client built on golang.org/x/crypto/ssh
+)
+
 type Profile struct {
 	Name            string    `yaml:"name"`
 	Protocol        Protocol  `yaml:"protocol"`
@@ -31,18 +39,24 @@ type Profile struct {
 	Port            int       `yaml:"port"`
 	Username        string    `yaml:"username"`
 	IdentityFile    string    `yaml:"identityFile"`
-	UseAgent        bool      `yaml:"useAgent"`
+	UseAgent        *bool     `yaml:"useAgent,omitempty"`
+	ForwardAgent    *bool     `yaml:"forwardAgent,omitempty"` // Forward the local SSH agent to the host
 	ExtraArgs       []string  `yaml:"extraArgs"`
 	Group           string    `yaml:"group"`
 	Description     string    `yaml:"description"`
-	Favorite        bool      `yaml:"favorite"`
-	LastUsed        time.Time `yaml:"lastUsed"`
-	UseCount        int       `yaml:"useCount"`
+	Favorite        bool      `yaml:"favorite,omitempty"` // Kept in the state store (see usage.go)
+	LastUsed        time.Time `yaml:"lastUsed,omitempty"` // Kept in the state store
+	UseCount        int       `yaml:"useCount,omitempty"` // Kept in the state store
 	ProxyJump       string    `yaml:"proxyJump"`
 	Tags            []string  `yaml:"tags"`
 	LocalForwards   []string  `yaml:"localForwards"`
 	RemoteForwards  []string  `yaml:"remoteForwards"`
 	DynamicForwards []string  `yaml:"dynamicForwards"`
+	Forwards        []Forward `yaml:"forwards,omitempty"`       // Named forwards, selectable with 'connect --forward' (see forward.go)
+	SSHClient       string    `yaml:"sshClient,omitempty"`      // "openssh" or "native"; empty picks openssh if installed
+	ControlPersist  string    `yaml:"controlPersist,omitempty"` // How long a shared connection stays open after its last use; "no" turns sharing off
+	Record          *bool     `yaml:"record,omitempty"`         // Record interactive sessions (see recording.go)
+	HostKeyPolicy   string    `yaml:"hostKeyPolicy,omitempty"`  // "strict", "warn" or "off"; overrides the config's (see hostkey.go)
 
 	// On-connect automation
 	RemoteCommand string   `yaml:"remoteCommand,omitempty"` // Command to run on connect (e.g., "tmux attach || tmux new")
@@ -60,15 +74,28 @@ type Profile struct {
 	// GCP gcloud specific
 	GCPProject   string `yaml:"gcpProject,omitempty"`
 	GCPZone      string `yaml:"gcpZone,omitempty"`
-	GCPUseTunnel bool   `yaml:"gcpUseTunnel,omitempty"` // Use IAP tunnel
+	GCPUseTunnel *bool  `yaml:"gcpUseTunnel,omitempty"` // Use IAP tunnel
 
 	// Profile inheritance
-	Extends string `yaml:"extends,omitempty"` // Name of parent profile to inherit from
+	Extends Parents              `yaml:"extends,omitempty"` // Parent profiles to inherit from, applied in order
+	Merge   map[string]MergeMode `yaml:"merge,omitempty"`   // How inherited lists combine, keyed by field (e.g. tags: append)
 }
 
 type Config struct {
-	DefaultBackend string            `yaml:"defaultBackend,omitempty"` // Default credential backend: "auto", "1password", "keyring", or "file"
-	Profiles       map[string]Profile `yaml:"profiles"`
+	Version        int                 `yaml:"version,omitempty"`        // Schema version (see migrate.go)
+	DefaultBackend string              `yaml:"defaultBackend,omitempty"` // Default credential backend: "auto", "1password", "keyring", or "file"
+	Profiles       map[string]Profile  `yaml:"profiles"`
+	Templates      map[string]Template `yaml:"templates,omitempty"`     // Parametrized profiles stamped out by 'veessh template apply'
+	Recording      *Recording          `yaml:"recording,omitempty"`     // Which sessions are recorded and where (see recording.go)
+	Audit          *Audit              `yaml:"audit,omitempty"`         // Audit log rotation and sinks (see audit.go)
+	HostKeyPolicy  string              `yaml:"hostKeyPolicy,omitempty"` // Checking of pinned host keys: "strict" (default), "warn" or "off"
+
+	// Config splitting (see layers.go)
+	Include []string              `yaml:"include,omitempty"` // Further config files or globs, relative to this file
+	Local   map[string]LocalState `yaml:"local,omitempty"`   // Deprecated: favorites and usage now live in the state store
+
+	layers *layers
+	usage  map[string]state.ProfileState // personal fields as read from the state store
 }
 
 func DefaultPath() (string, error) {
@@ -83,6 +110,8 @@ func DefaultPath() (string, error) {
 	return filepath.Join(cfgHome, "veessh", "config.yaml"), nil
 }
 
+// Load reads the main config at path together with its includes, conf.d
+// and the project overlay (see layers.go)
 func Load(path string) (Config, error) {
 	if path == "" {
 		var err error
@@ -91,23 +120,28 @@ func Load(path string) (Config, error) {
 			return Config{}, err
 		}
 	}
-	data, err := os.ReadFile(path)
+	cfg, _, rev, err := readMain(path)
 	if err != nil {
-		if errors.Is(err, fs.ErrNotExist) {
-			return Config{Profiles: map[string]Profile{}}, nil
-		}
 		return Config{}, err
 	}
-	var cfg Config
-	if err := yaml.Unmarshal(data, &cfg); err != nil {
+	base := comparable(cfg)
+	if err := loadLayers(path, &cfg); err != nil {
 		return Config{}, err
 	}
+	cfg.layers.base, cfg.layers.rev = base, rev
 	if cfg.Profiles == nil {
 		cfg.Profiles = map[string]Profile{}
 	}
+	if err := cfg.loadUsage(); err != nil {
+		return Config{}, err
+	}
 	return cfg, nil
 }
 
+// Save writes the main config. Profiles loaded from other files stay in
+// those files. Favorites and usage stats go to the state store, never to
+// the config. Changes saved by other processes since cfg was loaded are
+// kept; conflicting ones make Save fail with ErrConflict (see write.go).
 func Save(path string, cfg Config) error {
 	if path == "" {
 		var err error
@@ -116,19 +150,17 @@ func Save(path string, cfg Config) error {
 			return err
 		}
 	}
-	dir := filepath.Dir(path)
-	if err := os.MkdirAll(dir, 0o700); err != nil {
-		return err
-	}
-	data, err := yaml.Marshal(cfg)
-	if err != nil {
-		return err
+	out := cfg
+	if cfg.layers != nil {
+		var err error
+		if out, err = cfg.layers.mainFile(cfg); err != nil {
+			return err
+		}
 	}
-	tmp := path + ".tmp"
-	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+	if err := cfg.saveUsage(); err != nil {
 		return err
 	}
-	return os.Rename(tmp, path)
+	return writeMain(path, out, cfg.layers)
 }
 
 func (c *Config) UpsertProfile(p Profile) {
@@ -158,124 +190,12 @@ func (c *Config) GetProfile(name string) (Profile, bool) {
 		return Profile{}, false
 	}
 	// Resolve inheritance
-	if p.Extends != "" {
+	if len(p.Extends) > 0 {
 		p = c.resolveInheritance(p, make(map[string]bool))
 	}
 	return p, true
 }
 
-// resolveInheritance merges parent profile settings into child
-func (c *Config) resolveInheritance(p Profile, visited map[string]bool) Profile {
-	if p.Extends == "" || visited[p.Name] {
-		return p
-	}
-	visited[p.Name] = true
-
-	parent, ok := c.Profiles[p.Extends]
-	if !ok {
-		return p
-	}
-
-	// Recursively resolve parent's inheritance
-	if parent.Extends != "" {
-		parent = c.resolveInheritance(parent, visited)
-	}
-
-	// Merge: child values override parent values
-	merged := parent
-	merged.Name = p.Name // Always use child's name
-	merged.Extends = ""  // Clear extends after resolution
-
-	// Override with child's non-zero values
-	if p.Protocol != "" {
-		merged.Protocol = p.Protocol
-	}
-	if p.Host != "" {
-		merged.Host = p.Host
-	}
-	if p.Port != 0 {
-		merged.Port = p.Port
-	}
-	if p.Username != "" {
-		merged.Username = p.Username
-	}
-	if p.IdentityFile != "" {
-		merged.IdentityFile = p.IdentityFile
-	}
-	if p.Group != "" {
-		merged.Group = p.Group
-	}
-	if p.Description != "" {
-		merged.Description = p.Description
-	}
-	if p.ProxyJump != "" {
-		merged.ProxyJump = p.ProxyJump
-	}
-	if p.RemoteCommand != "" {
-		merged.RemoteCommand = p.RemoteCommand
-	}
-	if p.RemoteDir != "" {
-		merged.RemoteDir = p.RemoteDir
-	}
-	if p.InstanceID != "" {
-		merged.InstanceID = p.InstanceID
-	}
-	if p.AWSRegion != "" {
-		merged.AWSRegion = p.AWSRegion
-	}
-	if p.AWSProfile != "" {
-		merged.AWSProfile = p.AWSProfile
-	}
-	if p.GCPProject != "" {
-		merged.GCPProject = p.GCPProject
-	}
-	if p.GCPZone != "" {
-		merged.GCPZone = p.GCPZone
-	}
-	if p.MoshServer != "" {
-		merged.MoshServer = p.MoshServer
-	}
-
-	// Arrays: child replaces parent if non-empty
-	if len(p.Tags) > 0 {
-		merged.Tags = p.Tags
-	}
-	if len(p.ExtraArgs) > 0 {
-		merged.ExtraArgs = p.ExtraArgs
-	}
-	if len(p.LocalForwards) > 0 {
-		merged.LocalForwards = p.LocalForwards
-	}
-	if len(p.RemoteForwards) > 0 {
-		merged.RemoteForwards = p.RemoteForwards
-	}
-	if len(p.DynamicForwards) > 0 {
-		merged.DynamicForwards = p.DynamicForwards
-	}
-	if len(p.SetEnv) > 0 {
-		merged.SetEnv = p.SetEnv
-	}
-
-	// Booleans: only override if child explicitly sets to true
-	// (we can't distinguish "unset" from "false" in Go, so we preserve
-	// parent's true value unless child explicitly sets true)
-	if p.UseAgent {
-		merged.UseAgent = true
-	}
-	if p.Favorite {
-		merged.Favorite = true
-	}
-	if p.GCPUseTunnel {
-		merged.GCPUseTunnel = true
-	}
-
-	// Preserve child's usage stats
-	merged.LastUsed = p.LastUsed
-	merged.UseCount = p.UseCount
-
-	return merged
-}
-
 func (c *Config) ListProfiles() []Profile {
 	list := make([]Profile, 0, len(c.Profiles))
 	for _, p := range c.Profiles {
@@ -290,10 +210,44 @@ func (c *Config) ListProfiles() []Profile {
 	return list
 }
 
+// FindProfiles returns the resolved profiles that have all of tags and are
+// in one of groups. Empty filters match every profile.
+func (c *Config) FindProfiles(tags, groups []string) []Profile {
+	var out []Profile
+	for _, lp := range c.ListProfiles() {
+		p, _ := c.GetProfile(lp.Name)
+		if !p.HasTags(tags...) {
+			continue
+		}
+		if len(groups) > 0 && !slices.ContainsFunc(groups, func(g string) bool { return strings.EqualFold(g, p.Group) }) {
+			continue
+		}
+		out = append(out, p)
+	}
+	return out
+}
+
+// HasTags reports whether p has all of tags, ignoring case
+func (p Profile) HasTags(tags ...string) bool {
+	for _, t := range tags {
+		if !slices.ContainsFunc(p.Tags, func(have string) bool { return strings.EqualFold(have, t) }) {
+			return false
+		}
+	}
+	return true
+}
+
+// ControlPersist times, in ssh_config(5) time format
+var persistPattern = regexp.MustCompile(`^([0-9]+[sSmMhHdDwW]?)+$`)
+
 func (p *Profile) Validate() error {
 	if strings.TrimSpace(p.Name) == "" {
 		return errors.New("profile name is required")
 	}
+	// The encrypted file keys the secrets of a profile by its name and a NUL
+	if strings.ContainsFunc(p.Name, unicode.IsControl) {
+		return fmt.Errorf("profile name %q must not contain control characters", p.Name)
+	}
 	switch p.Protocol {
 	case ProtocolSSH, ProtocolSFTP, ProtocolTelnet, ProtocolMosh, ProtocolSSM, ProtocolGCloud:
 		// ok
@@ -303,6 +257,24 @@ func (p *Profile) Validate() error {
 	if strings.TrimSpace(p.Host) == "" {
 		return errors.New("host is required")
 	}
+	switch p.SSHClient {
+	case "", SSHClientOpenSSH, SSHClientNative:
+		// ok
+	default:
+		return fmt.Errorf("unsupported sshClient: %s (must be %s or %s)", p.SSHClient, SSHClientOpenSSH, SSHClientNative)
+	}
+	if err := validHostKeyPolicy(p.HostKeyPolicy); err != nil {
+		return err
+	}
+	if p.ControlPersist != "" && p.ControlPersist != "no" && p.ControlPersist != "yes" && !persistPattern.MatchString(p.ControlPersist) {
+		return fmt.Errorf("invalid controlPersist: %s (must be yes, no or a time like 10m or 1h30m)", p.ControlPersist)
+	}
+	if err := p.validateMerge(); err != nil {
+		return err
+	}
+	if err := p.validateForwards(); err != nil {
+		return err
+	}
 	if p.Port <= 0 {
 		switch p.Protocol {
 		case ProtocolSSH, ProtocolSFTP, ProtocolMosh:
@@ -22,6 +22,15 @@ func TestProfileValidate(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "control character in name",
+			profile: Profile{
+				Name:     "test\x00totp",
+				Protocol: ProtocolSSH,
+				Host:     "example.com",
+			},
+			wantErr: true,
+		},
 		{
 			name: "valid SFTP profile",
 			profile: Profile{
@@ -102,6 +111,36 @@ func TestProfileValidate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "controlPersist time",
+			profile: Profile{
+				Name:           "test",
+				Protocol:       ProtocolSSH,
+				Host:           "example.com",
+				ControlPersist: "1h30m",
+			},
+			wantErr: false,
+		},
+		{
+			name: "controlPersist no",
+			profile: Profile{
+				Name:           "test",
+				Protocol:       ProtocolSSH,
+				Host:           "example.com",
+				ControlPersist: "no",
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid controlPersist",
+			profile: Profile{
+				Name:           "test",
+				Protocol:       ProtocolSSH,
+				Host:           "example.com",
+				ControlPersist: "ten minutes",
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -224,13 +263,13 @@ func TestProfileInheritance(t *testing.T) {
 			IdentityFile: "/path/to/key",
 			Group:        "production",
 			Tags:         []string{"prod", "web"},
-			UseAgent:     true, // Boolean that should be inherited
+			UseAgent:     Bool(true), // Boolean that should be inherited
 		},
 		"child": {
 			Name:     "child",
 			Host:     "child.example.com",
 			Username: "childuser",
-			Extends:  "parent",
+			Extends:  Parents{"parent"},
 			// UseAgent not set - should inherit parent's true
 		},
 	}}
@@ -252,7 +291,7 @@ func TestProfileInheritance(t *testing.T) {
 	}
 
 	// Boolean inheritance: child should inherit parent's UseAgent=true
-	if !child.UseAgent {
+	if !IsTrue(child.UseAgent) {
 		t.Errorf("UseAgent = %v, want true (inherited from parent)", child.UseAgent)
 	}
 
@@ -270,8 +309,8 @@ func TestProfileInheritance(t *testing.T) {
 	}
 
 	// Extends should be cleared after resolution
-	if child.Extends != "" {
-		t.Errorf("Extends = %s, should be empty after resolution", child.Extends)
+	if len(child.Extends) != 0 {
+		t.Errorf("Extends = %v, should be empty after resolution", child.Extends)
 	}
 }
 
@@ -281,39 +320,39 @@ func TestProfileInheritanceBooleans(t *testing.T) {
 			Name:         "parent",
 			Protocol:     ProtocolSSH,
 			Host:         "parent.example.com",
-			UseAgent:     true,
+			UseAgent:     Bool(true),
 			Favorite:     true,
-			GCPUseTunnel: true,
+			GCPUseTunnel: Bool(true),
 		},
 		"child-no-override": {
 			Name:    "child-no-override",
 			Host:    "child.example.com",
-			Extends: "parent",
+			Extends: Parents{"parent"},
 			// All booleans unset (default false) - should inherit parent's true values
 		},
 		"child-with-true": {
 			Name:     "child-with-true",
 			Host:     "child2.example.com",
-			Extends:  "parent",
-			UseAgent: true, // Explicitly set to true
+			Extends:  Parents{"parent"},
+			UseAgent: Bool(true), // Explicitly set to true
 		},
 	}}
 
 	// Test that unset booleans inherit parent's true values
 	child1, _ := cfg.GetProfile("child-no-override")
-	if !child1.UseAgent {
+	if !IsTrue(child1.UseAgent) {
 		t.Errorf("child-no-override.UseAgent = false, want true (inherited)")
 	}
 	if !child1.Favorite {
 		t.Errorf("child-no-override.Favorite = false, want true (inherited)")
 	}
-	if !child1.GCPUseTunnel {
+	if !IsTrue(child1.GCPUseTunnel) {
 		t.Errorf("child-no-override.GCPUseTunnel = false, want true (inherited)")
 	}
 
 	// Test that explicitly set true values are preserved
 	child2, _ := cfg.GetProfile("child-with-true")
-	if !child2.UseAgent {
+	if !IsTrue(child2.UseAgent) {
 		t.Errorf("child-with-true.UseAgent = false, want true")
 	}
 }
@@ -330,12 +369,12 @@ func TestProfileInheritanceChain(t *testing.T) {
 			Name:     "parent",
 			Host:     "parent.example.com",
 			Username: "parentuser",
-			Extends:  "grandparent",
+			Extends:  Parents{"grandparent"},
 		},
 		"child": {
 			Name:    "child",
 			Host:    "child.example.com",
-			Extends: "parent",
+			Extends: Parents{"parent"},
 		},
 	}}
 
@@ -361,8 +400,8 @@ func TestProfileInheritanceChain(t *testing.T) {
 
 func TestProfileInheritanceCycleProtection(t *testing.T) {
 	cfg := Config{Profiles: map[string]Profile{
-		"a": {Name: "a", Protocol: ProtocolSSH, Host: "a.com", Extends: "b"},
-		"b": {Name: "b", Protocol: ProtocolSSH, Host: "b.com", Extends: "a"},
+		"a": {Name: "a", Protocol: ProtocolSSH, Host: "a.com", Extends: Parents{"b"}},
+		"b": {Name: "b", Protocol: ProtocolSSH, Host: "b.com", Extends: Parents{"a"}},
 	}}
 
 	// Should not infinite loop
@@ -384,7 +423,7 @@ func TestSaveAndLoad(t *testing.T) {
 			Port:            22,
 			Username:        "user",
 			IdentityFile:    "/path/to/key",
-			UseAgent:        true,
+			UseAgent:        Bool(true),
 			Group:           "prod",
 			Description:     "Test profile",
 			Favorite:        true,
@@ -487,7 +526,7 @@ func BenchmarkGetProfile(b *testing.B) {
 func BenchmarkGetProfileWithInheritance(b *testing.B) {
 	cfg := Config{Profiles: map[string]Profile{
 		"parent": {Name: "parent", Protocol: ProtocolSSH, Host: "parent.com", IdentityFile: "/key"},
-		"child":  {Name: "child", Host: "child.com", Extends: "parent"},
+		"child":  {Name: "child", Host: "child.com", Extends: Parents{"parent"}},
 	}}
 
 	b.ResetTimer()
@@ -0,0 +1,277 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Forward types
+const (
+	ForwardLocal   = "local"   // -L: localPort here reaches remoteHost:remotePort from the host
+	ForwardRemote  = "remote"  // -R: localPort on the host reaches remoteHost:remotePort from here
+	ForwardDynamic = "dynamic" // -D: a SOCKS proxy on localPort here
+)
+
+// AllForwards selects every forward of a profile, including those that are
+// not enabled by default
+const AllForwards = "all"
+
+// Forward is a port forward. Profiles list named forwards under forwards:,
+// which 'veessh connect --forward' can pick by name; the plain specs of
+// localForwards, remoteForwards and dynamicForwards parse into it too.
+type Forward struct {
+	Name        string `yaml:"name,omitempty"`
+	Type        string `yaml:"type,omitempty"`        // local (default), remote or dynamic
+	Bind        string `yaml:"bind,omitempty"`        // Address to listen on; localhost when empty
+	LocalPort   int    `yaml:"localPort"`             // Port to listen on (on the host for remote forwards)
+	RemoteHost  string `yaml:"remoteHost,omitempty"`  // Where connections go, as seen from the other end
+	RemotePort  int    `yaml:"remotePort,omitempty"`  // Port on remoteHost
+	Description string `yaml:"description,omitempty"` // Shown by 'veessh show' and the TUI
+	Enabled     *bool  `yaml:"enabled,omitempty"`     // Opened on connect unless others are picked; default true
+	Check       string `yaml:"check,omitempty"`       // Health check command for reconnecting tunnels
+}
+
+// Kind returns the forward type, local when unset
+func (f Forward) Kind() string {
+	if f.Type == "" {
+		return ForwardLocal
+	}
+	return f.Type
+}
+
+// IsEnabled reports whether the forward is opened by default
+func (f Forward) IsEnabled() bool {
+	return f.Enabled == nil || *f.Enabled
+}
+
+// Spec returns the forward as an ssh -L/-R/-D argument
+func (f Forward) Spec() string {
+	var parts []string
+	if f.Bind != "" {
+		parts = append(parts, bracket(f.Bind))
+	}
+	parts = append(parts, strconv.Itoa(f.LocalPort))
+	if f.Kind() != ForwardDynamic {
+		parts = append(parts, bracket(f.RemoteHost), strconv.Itoa(f.RemotePort))
+	}
+	return strings.Join(parts, ":")
+}
+
+// Label names the forward for messages: its name, or its spec
+func (f Forward) Label() string {
+	if f.Name != "" {
+		return f.Name
+	}
+	return f.Spec()
+}
+
+// BindAddr returns the address the forward listens on
+func (f Forward) BindAddr() string {
+	host := f.Bind
+	if host == "" {
+		host = "localhost"
+	}
+	return net.JoinHostPort(host, strconv.Itoa(f.LocalPort))
+}
+
+// DestAddr returns the address connections are forwarded to
+func (f Forward) DestAddr() string {
+	return net.JoinHostPort(f.RemoteHost, strconv.Itoa(f.RemotePort))
+}
+
+// Validate checks a forward from the forwards: list
+func (f Forward) Validate() error {
+	label := f.Label()
+	switch {
+	case f.Name == AllForwards || strings.ContainsAny(f.Name, ", \t"):
+		return fmt.Errorf("forward %q: name must not be %q or contain commas or spaces", f.Name, AllForwards)
+	case !slices.Contains([]string{ForwardLocal, ForwardRemote, ForwardDynamic}, f.Kind()):
+		return fmt.Errorf("forward %q: unknown type %q (must be local, remote or dynamic)", label, f.Type)
+	case f.LocalPort < 1 || f.LocalPort > 65535:
+		return fmt.Errorf("forward %q: localPort must be between 1 and 65535", label)
+	case f.Kind() == ForwardDynamic:
+		return nil
+	case f.RemoteHost == "":
+		return fmt.Errorf("forward %q: remoteHost is required", label)
+	case f.RemotePort < 1 || f.RemotePort > 65535:
+		return fmt.Errorf("forward %q: remotePort must be between 1 and 65535", label)
+	}
+	return nil
+}
+
+// ParseForward parses "[bind:]port:host:hostport", or "[bind:]port" when
+// dynamic is true. Bracketed IPv6 addresses are accepted anywhere. The
+// result is a local or dynamic forward.
+func ParseForward(spec string, dynamic bool) (Forward, error) {
+	parts := splitForward(strings.TrimSpace(spec))
+	f := Forward{Type: ForwardLocal}
+	if dynamic {
+		f.Type = ForwardDynamic
+	}
+	var err error
+	port := func(s string) int {
+		n, perr := strconv.Atoi(s)
+		if perr != nil || n < 0 || n > 65535 {
+			err = fmt.Errorf("invalid port %q in forward %q", s, spec)
+		}
+		return n
+	}
+
+	switch {
+	case dynamic && len(parts) == 1:
+		f.LocalPort = port(parts[0])
+	case dynamic && len(parts) == 2:
+		f.Bind, f.LocalPort = parts[0], port(parts[1])
+	case !dynamic && len(parts) == 3:
+		f.LocalPort, f.RemoteHost, f.RemotePort = port(parts[0]), parts[1], port(parts[2])
+	case !dynamic && len(parts) == 4:
+		f.Bind, f.LocalPort, f.RemoteHost, f.RemotePort = parts[0], port(parts[1]), parts[2], port(parts[3])
+	default:
+		return Forward{}, fmt.Errorf("invalid forward %q", spec)
+	}
+	if err != nil {
+		return Forward{}, err
+	}
+	return f, nil
+}
+
+// splitForward splits a forward spec on ':' while keeping bracketed IPv6
+// addresses intact.
+func splitForward(spec string) []string {
+	var parts []string
+	var cur strings.Builder
+	depth := 0
+	for _, r := range spec {
+		switch {
+		case r == '[':
+			depth++
+		case r == ']':
+			depth--
+		case r == ':' && depth == 0:
+			parts = append(parts, cur.String())
+			cur.Reset()
+			continue
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	return append(parts, cur.String())
+}
+
+func bracket(host string) string {
+	if strings.Contains(host, ":") {
+		return "[" + host + "]"
+	}
+	return host
+}
+
+// ActiveForwards returns the forwards opened on connect: the specs of
+// localForwards, remoteForwards and dynamicForwards (leaving out malformed
+// ones), then the enabled forwards of the forwards: list
+func (p Profile) ActiveForwards() []Forward {
+	var out []Forward
+	for _, list := range []struct {
+		specs []string
+		kind  string
+	}{
+		{p.LocalForwards, ForwardLocal},
+		{p.RemoteForwards, ForwardRemote},
+		{p.DynamicForwards, ForwardDynamic},
+	} {
+		for _, spec := range list.specs {
+			if strings.TrimSpace(spec) == "" {
+				continue
+			}
+			if f, err := ParseForward(spec, list.kind == ForwardDynamic); err == nil {
+				f.Type = list.kind
+				out = append(out, f)
+			}
+		}
+	}
+	for _, f := range p.Forwards {
+		if f.IsEnabled() {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// ForwardSpecs returns the -L, -R and -D arguments to open on connect: the
+// plain specs as written, then the enabled forwards of the forwards: list
+func (p Profile) ForwardSpecs() (local, remote, dynamic []string) {
+	local = slices.Clone(p.LocalForwards)
+	remote = slices.Clone(p.RemoteForwards)
+	dynamic = slices.Clone(p.DynamicForwards)
+	for _, f := range p.Forwards {
+		if !f.IsEnabled() {
+			continue
+		}
+		switch f.Kind() {
+		case ForwardRemote:
+			remote = append(remote, f.Spec())
+		case ForwardDynamic:
+			dynamic = append(dynamic, f.Spec())
+		default:
+			local = append(local, f.Spec())
+		}
+	}
+	return local, remote, dynamic
+}
+
+// SelectForwards picks the forwards to open by name instead of the
+// defaults. AllForwards opens every forward; no names opens none. Plain
+// specs have no name, so they are only kept for AllForwards.
+func (p *Profile) SelectForwards(names []string) error {
+	all := slices.Contains(names, AllForwards)
+	pick := map[string]bool{}
+	for _, name := range names {
+		pick[name] = true
+	}
+	forwards := slices.Clone(p.Forwards)
+	for i, f := range forwards {
+		forwards[i].Enabled = Bool(all || pick[f.Name])
+		delete(pick, f.Name)
+	}
+	delete(pick, AllForwards)
+	if len(pick) > 0 {
+		var known []string
+		for _, f := range p.Forwards {
+			if f.Name != "" {
+				known = append(known, f.Name)
+			}
+		}
+		missing := make([]string, 0, len(pick))
+		for name := range pick {
+			missing = append(missing, name)
+		}
+		sort.Strings(missing)
+		if len(known) == 0 {
+			return fmt.Errorf("profile %q has no named forwards (asked for %s)", p.Name, strings.Join(missing, ", "))
+		}
+		return fmt.Errorf("profile %q has no forward %s (has: %s)", p.Name, strings.Join(missing, ", "), strings.Join(known, ", "))
+	}
+	p.Forwards = forwards
+	if !all {
+		p.LocalForwards, p.RemoteForwards, p.DynamicForwards = nil, nil, nil
+	}
+	return nil
+}
+
+// validateForwards checks the forwards: list
+func (p *Profile) validateForwards() error {
+	seen := map[string]bool{}
+	for _, f := range p.Forwards {
+		if err := f.Validate(); err != nil {
+			return err
+		}
+		if f.Name != "" && seen[f.Name] {
+			return fmt.Errorf("forward %q is defined twice", f.Name)
+		}
+		seen[f.Name] = true
+	}
+	return nil
+}
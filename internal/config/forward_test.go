@@ -0,0 +1,141 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestForwardSpec(t *testing.T) {
+	tests := []struct {
+		f    Forward
+		want string
+	}{
+		{Forward{LocalPort: 5432, RemoteHost: "db", RemotePort: 5432}, "5432:db:5432"},
+		{Forward{Bind: "0.0.0.0", LocalPort: 8080, RemoteHost: "web", RemotePort: 80}, "0.0.0.0:8080:web:80"},
+		{Forward{Bind: "::1", LocalPort: 8080, RemoteHost: "fe80::1", RemotePort: 80}, "[::1]:8080:[fe80::1]:80"},
+		{Forward{Type: ForwardDynamic, LocalPort: 1080}, "1080"},
+	}
+	for _, tt := range tests {
+		if got := tt.f.Spec(); got != tt.want {
+			t.Errorf("Spec() = %q, want %q", got, tt.want)
+		}
+		if parsed, err := ParseForward(tt.want, tt.f.Kind() == ForwardDynamic); err != nil || parsed.Spec() != tt.want {
+			t.Errorf("ParseForward(%q) = %q, %v", tt.want, parsed.Spec(), err)
+		}
+	}
+}
+
+func TestForwardValidate(t *testing.T) {
+	tests := []struct {
+		f       Forward
+		wantErr string
+	}{
+		{Forward{Name: "pg", LocalPort: 5432, RemoteHost: "db", RemotePort: 5432}, ""},
+		{Forward{Name: "socks", Type: ForwardDynamic, LocalPort: 1080}, ""},
+		{Forward{Name: "all", LocalPort: 1, RemoteHost: "h", RemotePort: 1}, "must not be"},
+		{Forward{Name: "a,b", LocalPort: 1, RemoteHost: "h", RemotePort: 1}, "must not be"},
+		{Forward{Name: "x", Type: "sideways", LocalPort: 1}, "unknown type"},
+		{Forward{Name: "x", RemoteHost: "h", RemotePort: 1}, "localPort"},
+		{Forward{Name: "x", LocalPort: 1, RemotePort: 1}, "remoteHost"},
+		{Forward{Name: "x", LocalPort: 1, RemoteHost: "h", RemotePort: 70000}, "remotePort"},
+	}
+	for _, tt := range tests {
+		err := tt.f.Validate()
+		if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Errorf("Validate(%+v) = %v, want %q", tt.f, err, tt.wantErr)
+		}
+	}
+
+	p := Profile{Name: "db", Protocol: ProtocolSSH, Host: "h", Forwards: []Forward{
+		{Name: "pg", LocalPort: 5432, RemoteHost: "db", RemotePort: 5432},
+		{Name: "pg", LocalPort: 5433, RemoteHost: "db", RemotePort: 5432},
+	}}
+	if err := p.Validate(); err == nil || !strings.Contains(err.Error(), "defined twice") {
+		t.Errorf("Validate() = %v, want duplicate name error", err)
+	}
+}
+
+func TestSelectForwards(t *testing.T) {
+	base := Profile{
+		Name:          "db",
+		LocalForwards: []string{"9000:h:9000"},
+		Forwards: []Forward{
+			{Name: "pg", LocalPort: 5432, RemoteHost: "db", RemotePort: 5432},
+			{Name: "redis", LocalPort: 6379, RemoteHost: "cache", RemotePort: 6379, Enabled: Bool(false)},
+			{Name: "socks", Type: ForwardDynamic, LocalPort: 1080},
+		},
+	}
+
+	tests := []struct {
+		names       []string
+		wantLocal   []string
+		wantDynamic []string
+		wantErr     string
+	}{
+		{nil, nil, nil, ""},
+		{[]string{"redis"}, []string{"6379:cache:6379"}, nil, ""},
+		{[]string{"pg", "socks"}, []string{"5432:db:5432"}, []string{"1080"}, ""},
+		{[]string{AllForwards}, []string{"9000:h:9000", "5432:db:5432", "6379:cache:6379"}, []string{"1080"}, ""},
+		{[]string{"pg", "mysql"}, nil, nil, `no forward mysql (has: pg, redis, socks)`},
+	}
+	for _, tt := range tests {
+		p := base
+		err := p.SelectForwards(tt.names)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("SelectForwards(%v) error = %v, want %q", tt.names, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("SelectForwards(%v): %v", tt.names, err)
+		}
+		local, _, dynamic := p.ForwardSpecs()
+		if !reflect.DeepEqual(local, tt.wantLocal) || !reflect.DeepEqual(dynamic, tt.wantDynamic) {
+			t.Errorf("SelectForwards(%v): local %v dynamic %v, want %v %v", tt.names, local, dynamic, tt.wantLocal, tt.wantDynamic)
+		}
+	}
+
+	// The profile it was copied from is left alone
+	if base.Forwards[1].IsEnabled() || !base.Forwards[0].IsEnabled() {
+		t.Error("SelectForwards() changed the original forwards")
+	}
+	local, _, dynamic := base.ForwardSpecs()
+	if want := []string{"9000:h:9000", "5432:db:5432"}; !reflect.DeepEqual(local, want) || len(dynamic) != 1 {
+		t.Errorf("ForwardSpecs() = %v %v, want %v [1080]", local, dynamic, want)
+	}
+	if got := len(base.ActiveForwards()); got != 3 {
+		t.Errorf("ActiveForwards() has %d forwards, want 3", got)
+	}
+}
+
+func TestForwardInheritance(t *testing.T) {
+	cfg := Config{Profiles: map[string]Profile{
+		"base": {Name: "base", Protocol: ProtocolSSH, Host: "h.com", Forwards: []Forward{
+			{Name: "pg", LocalPort: 5432, RemoteHost: "db", RemotePort: 5432},
+			{Name: "redis", LocalPort: 6379, RemoteHost: "cache", RemotePort: 6379},
+		}},
+		"override": {Name: "override", Extends: Parents{"base"}, Merge: map[string]MergeMode{"forwards": MergeAppend}, Forwards: []Forward{
+			{Name: "pg", LocalPort: 15432, RemoteHost: "db", RemotePort: 5432},
+			{Name: "web", LocalPort: 8080, RemoteHost: "web", RemotePort: 80},
+		}},
+		"drop": {Name: "drop", Extends: Parents{"base"}, Merge: map[string]MergeMode{"forwards": MergeRemove}, Forwards: []Forward{
+			{Name: "redis"},
+		}},
+	}}
+
+	names := func(p Profile) string {
+		var out []string
+		for _, f := range p.Forwards {
+			out = append(out, f.Label())
+		}
+		return strings.Join(out, " ")
+	}
+	if p, _ := cfg.GetProfile("override"); names(p) != "pg redis web" || p.Forwards[0].LocalPort != 15432 {
+		t.Errorf("append: forwards %q, pg on %d", names(p), p.Forwards[0].LocalPort)
+	}
+	if p, _ := cfg.GetProfile("drop"); names(p) != "pg" {
+		t.Errorf("remove: forwards %q, want pg", names(p))
+	}
+}
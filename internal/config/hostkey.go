@@ -0,0 +1,34 @@
+package config
+
+import "fmt"
+
+// Host key policies: how veessh checks the key of a host with pinned keys
+// (see 'veessh hostkey pin')
+const (
+	HostKeyStrict = "strict" // Connect only if the host presents a pinned key
+	HostKeyWarn   = "warn"   // Warn of another key and connect anyway
+	HostKeyOff    = "off"    // Do not check
+)
+
+// HostKeyPolicyOf returns the host key policy for p: its own, else the
+// config's, else strict if p's host has keys pinned and off if not, as
+// there is nothing to check it against
+func (c *Config) HostKeyPolicyOf(p Profile, pinned bool) string {
+	switch {
+	case p.HostKeyPolicy != "":
+		return p.HostKeyPolicy
+	case c.HostKeyPolicy != "":
+		return c.HostKeyPolicy
+	case pinned:
+		return HostKeyStrict
+	}
+	return HostKeyOff
+}
+
+func validHostKeyPolicy(policy string) error {
+	switch policy {
+	case "", HostKeyStrict, HostKeyWarn, HostKeyOff:
+		return nil
+	}
+	return fmt.Errorf("invalid hostKeyPolicy: %s (must be %s, %s or %s)", policy, HostKeyStrict, HostKeyWarn, HostKeyOff)
+}
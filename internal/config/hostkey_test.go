@@ -0,0 +1,19 @@
+package config
+
+import "testing"
+
+func TestHostKeyPolicyOf(t *testing.T) {
+	cfg := Config{HostKeyPolicy: HostKeyWarn}
+	if got := cfg.HostKeyPolicyOf(Profile{}, true); got != HostKeyWarn {
+		t.Errorf("policy = %s, want the config's", got)
+	}
+	if got := cfg.HostKeyPolicyOf(Profile{HostKeyPolicy: HostKeyOff}, true); got != HostKeyOff {
+		t.Errorf("policy = %s, want the profile's", got)
+	}
+	if got := (&Config{}).HostKeyPolicyOf(Profile{}, true); got != HostKeyStrict {
+		t.Errorf("default policy of a pinned host = %s, want strict", got)
+	}
+	if got := (&Config{}).HostKeyPolicyOf(Profile{}, false); got != HostKeyOff {
+		t.Errorf("default policy of an unpinned host = %s, want off", got)
+	}
+}
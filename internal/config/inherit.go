@@ -0,0 +1,338 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// MergeMode controls how a child profile's list combines with the list it
+// inherits from its parent
+type MergeMode string
+
+const (
+	MergeReplace MergeMode = "replace" // child's list replaces the parent's, even when empty
+	MergeAppend  MergeMode = "append"  // child's items are added after the parent's
+	MergeRemove  MergeMode = "remove"  // child's items are taken out of the parent's
+)
+
+// Parents lists the profiles a profile extends. In YAML it is either a
+// single name (extends: base) or a list of mixins applied in order
+// (extends: [base-ssh, prod-bastion]), later parents overriding earlier ones.
+type Parents []string
+
+// UnmarshalYAML accepts a scalar or a sequence of names
+func (p *Parents) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*p = nil
+		if node.Value != "" {
+			*p = Parents{node.Value}
+		}
+		return nil
+	}
+	var names []string
+	if err := node.Decode(&names); err != nil {
+		return err
+	}
+	*p = names
+	return nil
+}
+
+// MarshalYAML writes a single parent as a plain name, as older versions did
+func (p Parents) MarshalYAML() (any, error) {
+	if len(p) == 1 {
+		return p[0], nil
+	}
+	return []string(p), nil
+}
+
+// String joins the names with commas
+func (p Parents) String() string {
+	return strings.Join(p, ", ")
+}
+
+// Bool returns a pointer to v, for the tri-state boolean profile fields
+// where nil means "inherit"
+func Bool(v bool) *bool {
+	return &v
+}
+
+// IsTrue reports whether a tri-state boolean is set and true
+func IsTrue(b *bool) bool {
+	return b != nil && *b
+}
+
+// Explain resolves a profile like GetProfile and also reports, for every
+// field that has a value, which profile of the Extends chain it came from.
+// Keys are YAML field names; merged lists name every contributor, e.g.
+// "base + web".
+func (c *Config) Explain(name string) (Profile, map[string]string, bool) {
+	p, ok := c.Profiles[name]
+	if !ok {
+		return Profile{}, nil, false
+	}
+	sources := map[string]string{}
+	return c.resolve(p, map[string]bool{}, sources), sources, true
+}
+
+// resolveInheritance merges parent profile settings into child
+func (c *Config) resolveInheritance(p Profile, visited map[string]bool) Profile {
+	return c.resolve(p, visited, nil)
+}
+
+// resolve flattens p onto its parents. Parents are resolved on their own and
+// layered in order, each mixin with its own merge directives, before p is
+// applied on top. visited holds the profiles on the current path, so shared
+// ancestors (a diamond) resolve normally while cycles are cut.
+func (c *Config) resolve(p Profile, visited map[string]bool, sources map[string]string) Profile {
+	var parents []Profile
+	if !visited[p.Name] {
+		for _, name := range p.Extends {
+			if parent, ok := c.Profiles[name]; ok {
+				parents = append(parents, parent)
+			}
+		}
+	}
+	if len(parents) == 0 {
+		recordSources(p, sources)
+		p.Extends = nil
+		p.Merge = nil
+		return p
+	}
+	visited[p.Name] = true
+	defer delete(visited, p.Name)
+
+	var base Profile
+	for i, parent := range parents {
+		var from map[string]string
+		if sources != nil {
+			from = map[string]string{}
+		}
+		resolved := c.resolve(parent, visited, from)
+		if i == 0 {
+			base = resolved
+			for key, src := range from {
+				sources[key] = src
+			}
+			continue
+		}
+		resolved.Merge = parent.Merge
+		base = mergeProfile(base, resolved, sources, from)
+	}
+	return mergeProfile(base, p, sources, nil)
+}
+
+// mergeProfile overlays child on its resolved parent. Scalars and
+// tri-state booleans override when set; lists follow the child's merge
+// directive and are otherwise replaced when the child has any items.
+// childSources, when the child is itself a resolved mixin, names where each
+// of its fields came from; otherwise the child's name is recorded.
+func mergeProfile(parent, child Profile, sources, childSources map[string]string) Profile {
+	merged := parent
+	mv := reflect.ValueOf(&merged).Elem()
+	cv := reflect.ValueOf(child)
+	t := cv.Type()
+
+	set := func(key string, from string) {
+		if sources != nil {
+			sources[key] = from
+		}
+	}
+	origin := func(key string) string {
+		if src := childSources[key]; src != "" {
+			return src
+		}
+		return child.Name
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		key := fieldKey(t.Field(i))
+		cf, mf := cv.Field(i), mv.Field(i)
+
+		switch key {
+		case "name":
+			mf.Set(cf)
+			set(key, origin(key))
+			continue
+		case "extends", "merge":
+			mf.Set(reflect.Zero(cf.Type()))
+			continue
+		case "lastUsed", "useCount":
+			// Usage stats always belong to the child
+			mf.Set(cf)
+			if sources != nil {
+				delete(sources, key)
+				if !cf.IsZero() {
+					set(key, origin(key))
+				}
+			}
+			continue
+		}
+
+		switch cf.Kind() {
+		case reflect.Slice:
+			switch child.Merge[key] {
+			case MergeAppend:
+				if cf.Len() > 0 {
+					mf.Set(appendMissing(mf, cf))
+					set(key, joinSource(sources, key, " + ", origin(key)))
+				}
+			case MergeRemove:
+				if cf.Len() > 0 && mf.Len() > 0 {
+					mf.Set(removeItems(mf, cf))
+					set(key, joinSource(sources, key, " - ", origin(key)))
+				}
+			case MergeReplace:
+				mf.Set(cf)
+				set(key, origin(key))
+			default:
+				if cf.Len() > 0 {
+					mf.Set(cf)
+					set(key, origin(key))
+				}
+			}
+		case reflect.Pointer:
+			if !cf.IsNil() {
+				mf.Set(cf)
+				set(key, origin(key))
+			}
+		case reflect.Bool:
+			// Plain booleans (Favorite) can only be switched on by a child
+			if cf.Bool() {
+				mf.SetBool(true)
+				set(key, origin(key))
+			}
+		default:
+			if !cf.IsZero() {
+				mf.Set(cf)
+				set(key, origin(key))
+			}
+		}
+		if sources != nil && mf.Kind() == reflect.Slice && mf.Len() == 0 {
+			delete(sources, key)
+		}
+	}
+	return merged
+}
+
+func recordSources(p Profile, sources map[string]string) {
+	if sources == nil {
+		return
+	}
+	v := reflect.ValueOf(p)
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		key := fieldKey(t.Field(i))
+		if key == "extends" || key == "merge" {
+			continue
+		}
+		if f := v.Field(i); !f.IsZero() && !(f.Kind() == reflect.Slice && f.Len() == 0) {
+			sources[key] = p.Name
+		}
+	}
+}
+
+func joinSource(sources map[string]string, key, op, child string) string {
+	if sources == nil || sources[key] == "" {
+		return child
+	}
+	return sources[key] + op + child
+}
+
+// appendMissing returns base followed by the items of extra it lacks
+func appendMissing(base, extra reflect.Value) reflect.Value {
+	out := reflect.MakeSlice(base.Type(), 0, base.Len()+extra.Len())
+	out = reflect.AppendSlice(out, base)
+	for i := 0; i < extra.Len(); i++ {
+		if j := indexItem(out, extra.Index(i)); j >= 0 {
+			out.Index(j).Set(extra.Index(i)) // a named forward the child redefines
+		} else {
+			out = reflect.Append(out, extra.Index(i))
+		}
+	}
+	return out
+}
+
+// removeItems returns base without the items listed in drop
+func removeItems(base, drop reflect.Value) reflect.Value {
+	out := reflect.MakeSlice(base.Type(), 0, base.Len())
+	for i := 0; i < base.Len(); i++ {
+		if !containsItem(drop, base.Index(i)) {
+			out = reflect.Append(out, base.Index(i))
+		}
+	}
+	return out
+}
+
+func containsItem(list, item reflect.Value) bool {
+	return indexItem(list, item) >= 0
+}
+
+// indexItem returns the position of item in list, or -1. Named forwards
+// match by name, so that a child can redefine or remove a parent's forward.
+func indexItem(list, item reflect.Value) int {
+	named, _ := item.Interface().(Forward)
+	for i := 0; i < list.Len(); i++ {
+		if f, ok := list.Index(i).Interface().(Forward); ok && named.Name != "" && f.Name == named.Name {
+			return i
+		}
+		if reflect.DeepEqual(list.Index(i).Interface(), item.Interface()) {
+			return i
+		}
+	}
+	return -1
+}
+
+// fieldKey returns the YAML name of a profile field
+func fieldKey(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+	if name == "" {
+		return f.Name
+	}
+	return name
+}
+
+// ListFields returns the YAML names of the profile fields that accept merge
+// directives
+func ListFields() []string {
+	var out []string
+	t := reflect.TypeOf(Profile{})
+	for i := 0; i < t.NumField(); i++ {
+		if key := fieldKey(t.Field(i)); key != "extends" && t.Field(i).Type.Kind() == reflect.Slice {
+			out = append(out, key)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// ProfileFields returns the YAML names of all profile fields in declaration
+// order
+func ProfileFields() []string {
+	var out []string
+	t := reflect.TypeOf(Profile{})
+	for i := 0; i < t.NumField(); i++ {
+		out = append(out, fieldKey(t.Field(i)))
+	}
+	return out
+}
+
+// validateMerge checks the merge directives of a profile
+func (p *Profile) validateMerge() error {
+	lists := ListFields()
+	for field, mode := range p.Merge {
+		idx := sort.SearchStrings(lists, field)
+		if idx >= len(lists) || lists[idx] != field {
+			return fmt.Errorf("merge: %q is not a list field (must be one of: %s)", field, strings.Join(lists, ", "))
+		}
+		switch mode {
+		case MergeReplace, MergeAppend, MergeRemove:
+		default:
+			return fmt.Errorf("merge: unknown mode %q for %s (must be: append, replace, or remove)", mode, field)
+		}
+	}
+	return nil
+}
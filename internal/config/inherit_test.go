@@ -0,0 +1,194 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestInheritanceExplicitFalse(t *testing.T) {
+	cfg := Config{Profiles: map[string]Profile{
+		"parent": {Name: "parent", Protocol: ProtocolSSH, Host: "p.com", UseAgent: Bool(true), GCPUseTunnel: Bool(true)},
+		"child":  {Name: "child", Host: "c.com", Extends: Parents{"parent"}, UseAgent: Bool(false)},
+	}}
+
+	child, _ := cfg.GetProfile("child")
+	if child.UseAgent == nil || *child.UseAgent {
+		t.Errorf("UseAgent = %v, want explicit false to override parent", child.UseAgent)
+	}
+	if !IsTrue(child.GCPUseTunnel) {
+		t.Errorf("GCPUseTunnel = %v, want true (inherited)", child.GCPUseTunnel)
+	}
+}
+
+func TestInheritanceMergeModes(t *testing.T) {
+	parent := Profile{
+		Name:          "parent",
+		Protocol:      ProtocolSSH,
+		Host:          "p.com",
+		Tags:          []string{"prod", "web"},
+		LocalForwards: []string{"5432:db:5432"},
+	}
+
+	tests := []struct {
+		name     string
+		child    Profile
+		wantTags []string
+		wantFwds []string
+	}{
+		{
+			name:     "default replaces non-empty list",
+			child:    Profile{Tags: []string{"canary"}},
+			wantTags: []string{"canary"},
+			wantFwds: []string{"5432:db:5432"},
+		},
+		{
+			name:     "append skips duplicates",
+			child:    Profile{Tags: []string{"web", "canary"}, Merge: map[string]MergeMode{"tags": MergeAppend}},
+			wantTags: []string{"prod", "web", "canary"},
+			wantFwds: []string{"5432:db:5432"},
+		},
+		{
+			name:     "remove",
+			child:    Profile{Tags: []string{"web"}, Merge: map[string]MergeMode{"tags": MergeRemove}},
+			wantTags: []string{"prod"},
+			wantFwds: []string{"5432:db:5432"},
+		},
+		{
+			name:     "replace with empty list clears",
+			child:    Profile{Merge: map[string]MergeMode{"localForwards": MergeReplace}},
+			wantTags: []string{"prod", "web"},
+			wantFwds: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			child := tt.child
+			child.Name, child.Host, child.Extends = "child", "c.com", Parents{"parent"}
+			cfg := Config{Profiles: map[string]Profile{"parent": parent, "child": child}}
+
+			got, _ := cfg.GetProfile("child")
+			if !reflect.DeepEqual(got.Tags, tt.wantTags) {
+				t.Errorf("Tags = %v, want %v", got.Tags, tt.wantTags)
+			}
+			if len(got.LocalForwards) != len(tt.wantFwds) || (len(tt.wantFwds) > 0 && !reflect.DeepEqual(got.LocalForwards, tt.wantFwds)) {
+				t.Errorf("LocalForwards = %v, want %v", got.LocalForwards, tt.wantFwds)
+			}
+			if got.Merge != nil {
+				t.Errorf("Merge = %v, should be cleared after resolution", got.Merge)
+			}
+		})
+	}
+
+	// The parent itself must not be modified by merging
+	if !reflect.DeepEqual(parent.Tags, []string{"prod", "web"}) {
+		t.Errorf("parent tags modified: %v", parent.Tags)
+	}
+}
+
+func TestExplainSources(t *testing.T) {
+	cfg := Config{Profiles: map[string]Profile{
+		"base": {Name: "base", Protocol: ProtocolSSH, Username: "deploy", Tags: []string{"prod"}, UseAgent: Bool(true)},
+		"web":  {Name: "web", Host: "web.com", Extends: Parents{"base"}, Tags: []string{"web"}, Merge: map[string]MergeMode{"tags": MergeAppend}},
+		"api":  {Name: "api", Host: "api.com", Extends: Parents{"web"}, UseAgent: Bool(false)},
+	}}
+
+	_, sources, ok := cfg.Explain("api")
+	if !ok {
+		t.Fatal("Explain(api) not found")
+	}
+	want := map[string]string{
+		"name":     "api",
+		"protocol": "base",
+		"host":     "api",
+		"username": "base",
+		"useAgent": "api",
+		"tags":     "base + web",
+	}
+	for key, src := range want {
+		if sources[key] != src {
+			t.Errorf("sources[%s] = %q, want %q", key, sources[key], src)
+		}
+	}
+	if _, ok := sources["extends"]; ok {
+		t.Error("extends should not have a source")
+	}
+
+	if _, _, ok := cfg.Explain("missing"); ok {
+		t.Error("Explain(missing) should report not found")
+	}
+}
+
+func TestValidateMerge(t *testing.T) {
+	tests := []struct {
+		name    string
+		merge   map[string]MergeMode
+		wantErr bool
+	}{
+		{"valid", map[string]MergeMode{"tags": MergeAppend, "localForwards": MergeRemove}, false},
+		{"not a list", map[string]MergeMode{"host": MergeAppend}, true},
+		{"unknown field", map[string]MergeMode{"nope": MergeReplace}, true},
+		{"unknown mode", map[string]MergeMode{"tags": "prepend"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := Profile{Name: "p", Protocol: ProtocolSSH, Host: "h", Merge: tt.merge}
+			err := p.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestInheritanceMixins(t *testing.T) {
+	cfg := Config{Profiles: map[string]Profile{
+		"base":    {Name: "base", Protocol: ProtocolSSH, Username: "deploy"},
+		"ssh":     {Name: "ssh", Extends: Parents{"base"}, Port: 2222, Tags: []string{"ssh"}},
+		"bastion": {Name: "bastion", Extends: Parents{"base"}, ProxyJump: "jump", Username: "ops", Tags: []string{"prod"}, Merge: map[string]MergeMode{"tags": MergeAppend}},
+		"db":      {Name: "db", Host: "db.com", Extends: Parents{"ssh", "bastion"}},
+	}}
+
+	db, _ := cfg.GetProfile("db")
+	if db.Port != 2222 || db.ProxyJump != "jump" || db.Protocol != ProtocolSSH {
+		t.Errorf("db = port %d, proxyJump %q, protocol %q; want values from both mixins", db.Port, db.ProxyJump, db.Protocol)
+	}
+	if db.Username != "ops" {
+		t.Errorf("Username = %q, want ops (later mixin wins)", db.Username)
+	}
+	if !reflect.DeepEqual(db.Tags, []string{"ssh", "prod"}) {
+		t.Errorf("Tags = %v, want [ssh prod]", db.Tags)
+	}
+
+	_, sources, _ := cfg.Explain("db")
+	if sources["port"] != "ssh" || sources["proxyJump"] != "bastion" || sources["protocol"] != "base" {
+		t.Errorf("sources = %v", sources)
+	}
+}
+
+func TestParentsYAML(t *testing.T) {
+	var p Profile
+	if err := yaml.Unmarshal([]byte("extends: base"), &p); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(p.Extends, Parents{"base"}) {
+		t.Errorf("scalar extends = %v", p.Extends)
+	}
+	if err := yaml.Unmarshal([]byte("extends: [a, b]"), &p); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(p.Extends, Parents{"a", "b"}) {
+		t.Errorf("list extends = %v", p.Extends)
+	}
+
+	out, err := yaml.Marshal(Profile{Extends: Parents{"base"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(out), "extends: base\n") {
+		t.Errorf("single parent should marshal as a name:\n%s", out)
+	}
+}
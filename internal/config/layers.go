@@ -0,0 +1,403 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/vee-sh/veessh/internal/util"
+)
+
+// Profiles and templates can be split over several files. Load reads them
+// in this order, later files overriding earlier ones profile by profile:
+//
+//  1. config.yaml, the main config
+//  2. conf.d/*.yaml next to it, in lexical order
+//  3. .veessh.yaml in the current directory or its nearest parent that has
+//     one, up to the home directory or the root of the repository (a
+//     project overlay, typically committed with the project)
+//
+// Files named in a file's include: list are read just before that file, so
+// a file overrides what it includes. Only the main config is ever written.
+//
+// The project overlay comes with whatever repository is checked out, so it
+// is trusted less: it is ignored unless the user owns it and only they can
+// write to it, and it (with what it includes) adds profiles and templates
+// but cannot redefine the user's own. Otherwise a cloned repository could
+// send a profile's connection, and the secrets stored for it, elsewhere.
+
+// Layer kinds
+const (
+	LayerMain    = "main"
+	LayerInclude = "include"
+	LayerConfD   = "conf.d"
+	LayerProject = "project"
+)
+
+// ProjectFile is the project overlay looked up from the working directory
+const ProjectFile = ".veessh.yaml"
+
+// NoProjectEnv disables the project overlay when set to a non-empty value
+const NoProjectEnv = "VEESSH_NO_PROJECT_CONFIG"
+
+// maxIncludeDepth bounds nested include: lists
+const maxIncludeDepth = 8
+
+// Layer is one file that contributed to a loaded config
+type Layer struct {
+	Path      string   `json:"path"`
+	Kind      string   `json:"kind"`
+	Profiles  []string `json:"profiles,omitempty"`
+	Templates []string `json:"templates,omitempty"`
+	Ignored   string   `json:"ignored,omitempty"` // Why the file was not read
+}
+
+// Conflict is a profile or template defined in more than one file. The
+// project overlay never wins over the user's own files.
+type Conflict struct {
+	Kind     string   `json:"kind"` // "profile" or "template"
+	Name     string   `json:"name"`
+	Winner   string   `json:"winner"`
+	Shadowed []string `json:"shadowed"` // overridden files, lowest precedence first
+}
+
+// LocalState holds the personal fields of a profile defined outside the
+// main config. Configs written before the state store kept them in the
+// local: section; they are moved to the state store on load.
+type LocalState struct {
+	Favorite bool      `yaml:"favorite,omitempty"`
+	LastUsed time.Time `yaml:"lastUsed,omitempty"`
+	UseCount int       `yaml:"useCount,omitempty"`
+}
+
+// layers records where the profiles of a loaded config came from
+type layers struct {
+	main      string
+	files     []Layer
+	conflicts []Conflict
+	origins   map[string]string  // profile name -> file
+	external  map[string]Profile // profiles from other files, as loaded
+	own       map[string]Profile // main config profiles shadowed by other files
+	ownTmpl   map[string]Template
+	tmplFrom  map[string]string // template name -> file
+
+	// The main config as last read or written, for Save (see write.go)
+	base Config
+	rev  string
+}
+
+// Layers returns the files the config was loaded from, lowest precedence
+// first
+func (c *Config) Layers() []Layer {
+	if c.layers == nil {
+		return nil
+	}
+	return c.layers.files
+}
+
+// Conflicts returns the profiles and templates defined in several files
+func (c *Config) Conflicts() []Conflict {
+	if c.layers == nil {
+		return nil
+	}
+	return c.layers.conflicts
+}
+
+// Origin returns the file a profile was loaded from, or "" for profiles
+// that only exist in memory
+func (c *Config) Origin(name string) string {
+	if c.layers == nil {
+		return ""
+	}
+	return c.layers.origins[name]
+}
+
+type layerFile struct {
+	path string
+	kind string
+	cfg  Config
+}
+
+// loadLayers reads the overlays of the main config at path and merges them
+// into cfg, which holds the parsed main config
+func loadLayers(path string, cfg *Config) error {
+	var files []layerFile
+	seen := map[string]bool{}
+	add := func(p, kind string, c *Config) error {
+		return collect(p, kind, c, 0, seen, &files)
+	}
+
+	main := cfg.clone()
+	if err := add(path, LayerMain, &main); err != nil {
+		return err
+	}
+	confd, err := filepath.Glob(filepath.Join(filepath.Dir(path), "conf.d", "*.yaml"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(confd)
+	for _, p := range confd {
+		if err := add(p, LayerConfD, nil); err != nil {
+			return err
+		}
+	}
+	// Files from here on come with the project
+	project := len(files)
+	var ignored []Layer
+	if os.Getenv(NoProjectEnv) == "" {
+		if wd, err := os.Getwd(); err == nil {
+			if p := findProjectFile(wd); p != "" && !seen[p] {
+				if err := util.CheckTrusted(p); err != nil {
+					ignored = append(ignored, Layer{Path: p, Kind: LayerProject, Ignored: err.Error()})
+				} else if err := add(p, LayerProject, nil); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	own := map[string]bool{}
+	for _, f := range files[:project] {
+		own[f.path] = true
+	}
+
+	l := &layers{
+		main:     path,
+		origins:  map[string]string{},
+		external: map[string]Profile{},
+		own:      map[string]Profile{},
+		ownTmpl:  map[string]Template{},
+		tmplFrom: map[string]string{},
+	}
+	profiles := map[string]Profile{}
+	templates := map[string]Template{}
+	shadowed := map[string][]string{}
+	for i, f := range files {
+		layer := Layer{Path: f.path, Kind: f.kind}
+		fromProject := i >= project
+		for _, name := range sortedKeys(f.cfg.Profiles) {
+			p := f.cfg.Profiles[name]
+			if p.Name == "" {
+				p.Name = name
+			}
+			if prev, ok := l.origins[name]; ok {
+				if fromProject && own[prev] {
+					shadowed["profile\x00"+name] = append(shadowed["profile\x00"+name], f.path)
+					continue
+				}
+				shadowed["profile\x00"+name] = append(shadowed["profile\x00"+name], prev)
+				if prev == path {
+					l.own[name] = profiles[name]
+				}
+			}
+			profiles[name] = p
+			l.origins[name] = f.path
+			layer.Profiles = append(layer.Profiles, name)
+		}
+		for _, name := range sortedKeys(f.cfg.Templates) {
+			if prev, ok := l.tmplFrom[name]; ok {
+				if fromProject && own[prev] {
+					shadowed["template\x00"+name] = append(shadowed["template\x00"+name], f.path)
+					continue
+				}
+				shadowed["template\x00"+name] = append(shadowed["template\x00"+name], prev)
+				if prev == path {
+					l.ownTmpl[name] = templates[name]
+				}
+			}
+			templates[name] = f.cfg.Templates[name]
+			l.tmplFrom[name] = f.path
+			layer.Templates = append(layer.Templates, name)
+		}
+		l.files = append(l.files, layer)
+	}
+	l.files = append(l.files, ignored...)
+
+	for key, prev := range shadowed {
+		kind, name, _ := strings.Cut(key, "\x00")
+		winner := l.origins[name]
+		if kind == "template" {
+			winner = l.tmplFrom[name]
+		}
+		l.conflicts = append(l.conflicts, Conflict{Kind: kind, Name: name, Winner: winner, Shadowed: prev})
+	}
+	sort.Slice(l.conflicts, func(i, j int) bool {
+		if l.conflicts[i].Kind != l.conflicts[j].Kind {
+			return l.conflicts[i].Kind < l.conflicts[j].Kind
+		}
+		return l.conflicts[i].Name < l.conflicts[j].Name
+	})
+
+	// Personal state of shared profiles lives in the main config
+	for name, p := range profiles {
+		if l.origins[name] == path {
+			continue
+		}
+		if st, ok := cfg.Local[name]; ok {
+			p.Favorite, p.LastUsed, p.UseCount = st.Favorite, st.LastUsed, st.UseCount
+			profiles[name] = p
+		}
+		l.external[name] = p
+	}
+
+	cfg.Profiles = profiles
+	if len(templates) > 0 {
+		cfg.Templates = templates
+	}
+	cfg.layers = l
+	return nil
+}
+
+// collect appends the files of path's include: list, then path itself.
+// c is the already parsed file, or nil to read it.
+func collect(path, kind string, c *Config, depth int, seen map[string]bool, files *[]layerFile) error {
+	if seen[path] {
+		return nil
+	}
+	seen[path] = true
+	if depth > maxIncludeDepth {
+		return fmt.Errorf("%s: too many nested includes", path)
+	}
+	if c == nil {
+		parsed, err := readFile(path)
+		if err != nil {
+			return err
+		}
+		c = &parsed
+	}
+	for _, pattern := range c.Include {
+		pattern = expandHome(pattern)
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(filepath.Dir(path), pattern)
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return fmt.Errorf("%s: bad include %q: %w", path, pattern, err)
+		}
+		if len(matches) == 0 && !strings.ContainsAny(pattern, "*?[") {
+			return fmt.Errorf("%s: included file %s does not exist", path, pattern)
+		}
+		sort.Strings(matches)
+		for _, m := range matches {
+			if err := collect(m, LayerInclude, nil, depth+1, seen, files); err != nil {
+				return err
+			}
+		}
+	}
+	*files = append(*files, layerFile{path: path, kind: kind, cfg: *c})
+	return nil
+}
+
+func readFile(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+	return decode(path, data)
+}
+
+// findProjectFile returns the nearest ProjectFile in dir or its parents,
+// looking no further up than the home directory or the root of the
+// repository dir is in
+func findProjectFile(dir string) string {
+	home, _ := os.UserHomeDir()
+	for {
+		p := filepath.Join(dir, ProjectFile)
+		if info, err := os.Stat(p); err == nil && !info.IsDir() {
+			return p
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir || dir == home || isRepoRoot(dir) {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// isRepoRoot reports whether dir is the root of a version control checkout
+func isRepoRoot(dir string) bool {
+	for _, vcs := range []string{".git", ".hg", ".svn", ".jj"} {
+		if _, err := os.Lstat(filepath.Join(dir, vcs)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func expandHome(p string) string {
+	if strings.HasPrefix(p, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, p[2:])
+		}
+	}
+	return p
+}
+
+// mainFile returns what Save writes to the main config: its own profiles
+// and templates. Changes to shared profiles other than their personal
+// fields (which belong to the state store) are errors, since they would
+// otherwise be silently dropped or copied into the main config.
+func (l *layers) mainFile(cfg Config) (Config, error) {
+	out := cfg.clone()
+	out.Profiles = map[string]Profile{}
+	out.Templates = map[string]Template{}
+
+	for name, p := range cfg.Profiles {
+		origin, ok := l.origins[name]
+		if !ok || origin == l.main {
+			out.Profiles[name] = p
+			continue
+		}
+		if !samePersonal(p, l.external[name]) {
+			return Config{}, fmt.Errorf("profile %q is defined in %s; change it there", name, origin)
+		}
+	}
+	for name, origin := range l.origins {
+		if _, ok := cfg.Profiles[name]; !ok && origin != l.main {
+			return Config{}, fmt.Errorf("profile %q is defined in %s; remove it there", name, origin)
+		}
+	}
+	for name, p := range l.own {
+		if _, ok := out.Profiles[name]; !ok {
+			out.Profiles[name] = p
+		}
+	}
+
+	for name, t := range cfg.Templates {
+		if origin, ok := l.tmplFrom[name]; !ok || origin == l.main {
+			out.Templates[name] = t
+		}
+	}
+	for name, t := range l.ownTmpl {
+		if _, ok := out.Templates[name]; !ok {
+			out.Templates[name] = t
+		}
+	}
+	return out, nil
+}
+
+// samePersonal reports whether a and b differ at most in favorite, last
+// use and use count
+func samePersonal(a, b Profile) bool {
+	a.Favorite, a.LastUsed, a.UseCount = false, time.Time{}, 0
+	b.Favorite, b.LastUsed, b.UseCount = false, time.Time{}, 0
+	return reflect.DeepEqual(a, b)
+}
+
+func (c Config) clone() Config {
+	out := c
+	out.layers = nil
+	return out
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
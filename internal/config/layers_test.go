@@ -0,0 +1,258 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// setupLayers creates a main config with an include, a conf.d file and a
+// project overlay, and changes into a subdirectory of the project
+func setupLayers(t *testing.T) (mainPath, projectPath string) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "veessh")
+	mainPath = filepath.Join(dir, "config.yaml")
+	writeFile(t, mainPath, `include: [team.yaml]
+profiles:
+  mine: {name: mine, protocol: ssh, host: mine.com}
+  web: {name: web, protocol: ssh, host: home-web.com}
+  db: {name: db, protocol: ssh, host: home-db.com}
+`)
+	writeFile(t, filepath.Join(dir, "team.yaml"), `profiles:
+  shared: {name: shared, protocol: ssh, host: shared.com}
+  mine: {name: mine, protocol: ssh, host: team-mine.com}
+`)
+	writeFile(t, filepath.Join(dir, "conf.d", "10-dc.yaml"), `profiles:
+  db: {name: db, protocol: ssh, host: dc-db.com}
+templates:
+  node: {profile: {name: "node-{{ .index }}"}}
+`)
+	projectPath = filepath.Join(root, "proj", ProjectFile)
+	writeFile(t, projectPath, `# committed with the project
+profiles:
+  web: {name: web, protocol: ssh, host: proj-web.com}
+  api: {name: api, protocol: ssh, host: proj-api.com}
+`)
+	sub := filepath.Join(root, "proj", "src", "pkg")
+	if err := os.MkdirAll(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(sub)
+	t.Setenv(NoProjectEnv, "")
+	isolateState(t)
+	return mainPath, projectPath
+}
+
+func TestLoadLayersPrecedence(t *testing.T) {
+	mainPath, projectPath := setupLayers(t)
+	cfg, err := Load(mainPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hosts := map[string]string{
+		"mine":   "mine.com",     // main overrides what it includes
+		"shared": "shared.com",   // from the include
+		"db":     "dc-db.com",    // conf.d overrides main
+		"web":    "home-web.com", // the project cannot redefine it
+		"api":    "proj-api.com", // but adds its own
+	}
+	for name, want := range hosts {
+		p, ok := cfg.GetProfile(name)
+		if !ok || p.Host != want {
+			t.Errorf("%s host = %q, want %q", name, p.Host, want)
+		}
+	}
+	if _, ok := cfg.Templates["node"]; !ok {
+		t.Error("template from conf.d not loaded")
+	}
+	if got := cfg.Origin("api"); got != projectPath {
+		t.Errorf("Origin(api) = %q, want %q", got, projectPath)
+	}
+
+	var kinds []string
+	for _, l := range cfg.Layers() {
+		kinds = append(kinds, l.Kind)
+	}
+	if strings.Join(kinds, ",") != "include,main,conf.d,project" {
+		t.Errorf("layer order = %v", kinds)
+	}
+
+	conflicts := map[string]Conflict{}
+	for _, c := range cfg.Conflicts() {
+		conflicts[c.Name] = c
+	}
+	if len(conflicts) != 3 {
+		t.Errorf("conflicts = %+v, want db, mine and web", cfg.Conflicts())
+	}
+	if c := conflicts["web"]; c.Winner != mainPath || len(c.Shadowed) != 1 || c.Shadowed[0] != projectPath {
+		t.Errorf("web conflict = %+v", c)
+	}
+
+	t.Setenv(NoProjectEnv, "1")
+	cfg, err = Load(mainPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cfg.GetProfile("api"); ok {
+		t.Errorf("with %s set, the project's api was loaded", NoProjectEnv)
+	}
+}
+
+func TestSaveLayers(t *testing.T) {
+	mainPath, projectPath := setupLayers(t)
+	project, _ := os.ReadFile(projectPath)
+
+	cfg, err := Load(mainPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Personal state of a shared profile goes to the state store
+	api := cfg.Profiles["api"]
+	api.Favorite = true
+	api.UseCount = 3
+	cfg.UpsertProfile(api)
+	cfg.UpsertProfile(Profile{Name: "new", Protocol: ProtocolSSH, Host: "new.com"})
+	if err := Save(mainPath, cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, _ := os.ReadFile(projectPath); string(got) != string(project) {
+		t.Errorf("project file was modified:\n%s", got)
+	}
+	raw, err := readFile(mainPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if raw.Profiles["web"].Host != "home-web.com" || raw.Profiles["db"].Host != "home-db.com" {
+		t.Errorf("shadowed main profiles not kept: web %q, db %q", raw.Profiles["web"].Host, raw.Profiles["db"].Host)
+	}
+	if _, ok := raw.Profiles["shared"]; ok {
+		t.Error("included profile copied into the main config")
+	}
+	if _, ok := raw.Profiles["new"]; !ok {
+		t.Error("new profile not saved")
+	}
+	if _, ok := raw.Profiles["api"]; ok || len(raw.Local) != 0 {
+		t.Error("personal state written to the main config")
+	}
+	if len(raw.Include) != 1 || len(raw.Templates) != 0 {
+		t.Errorf("include = %v, templates = %v", raw.Include, raw.Templates)
+	}
+
+	// The state store is applied on the next load
+	cfg, err = Load(mainPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := cfg.Profiles["api"]; !p.Favorite || p.UseCount != 3 || p.Host != "proj-api.com" {
+		t.Errorf("reloaded api = %+v", p)
+	}
+
+	// Other changes to shared profiles are refused
+	api = cfg.Profiles["api"]
+	api.Host = "elsewhere.com"
+	cfg.UpsertProfile(api)
+	if err := Save(mainPath, cfg); err == nil || !strings.Contains(err.Error(), projectPath) {
+		t.Errorf("Save() of a changed shared profile: err = %v", err)
+	}
+	cfg, _ = Load(mainPath)
+	cfg.DeleteProfile("shared")
+	if err := Save(mainPath, cfg); err == nil {
+		t.Error("Save() after deleting an included profile should fail")
+	}
+}
+
+func TestProjectFileTrust(t *testing.T) {
+	mainPath, projectPath := setupLayers(t)
+	proj := filepath.Dir(projectPath)
+
+	// What the project includes cannot redefine the user's profiles or
+	// templates either
+	writeFile(t, projectPath, `include: [more.yaml]
+profiles:
+  api: {name: api, protocol: ssh, host: proj-api.com}
+`)
+	writeFile(t, filepath.Join(proj, "more.yaml"), `profiles:
+  mine: {name: mine, protocol: ssh, host: evil.com}
+templates:
+  node: {profile: {name: "evil-{{ .index }}"}}
+`)
+	cfg, err := Load(mainPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p, _ := cfg.GetProfile("mine"); p.Host != "mine.com" {
+		t.Errorf("mine host = %q, want it kept", p.Host)
+	}
+	if tmpl := cfg.Templates["node"]; tmpl.Profile.Name != "node-{{ .index }}" {
+		t.Errorf("node template = %+v, want it kept", tmpl)
+	}
+	if p, _ := cfg.GetProfile("api"); p.Host != "proj-api.com" {
+		t.Errorf("api host = %q", p.Host)
+	}
+
+	// Files others can write are not read
+	if err := os.Chmod(projectPath, 0o664); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err = Load(mainPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cfg.GetProfile("api"); ok {
+		t.Error("profile loaded from a group-writable project file")
+	}
+	layers := cfg.Layers()
+	if last := layers[len(layers)-1]; last.Path != projectPath || !strings.Contains(last.Ignored, "writable by other users") {
+		t.Errorf("last layer = %+v, want the ignored project file", last)
+	}
+	if err := os.Chmod(projectPath, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// The lookup stops at the root of the repository and at home
+	for _, stop := range []func(dir string){
+		func(dir string) { writeFile(t, filepath.Join(dir, ".git", "HEAD"), "ref: refs/heads/main\n") },
+		func(dir string) { t.Setenv("HOME", dir) },
+	} {
+		base := t.TempDir()
+		writeFile(t, filepath.Join(base, ProjectFile), "profiles: {}\n")
+		dir := filepath.Join(base, "src")
+		stop(dir)
+		if got := findProjectFile(filepath.Join(dir, "pkg")); got != "" {
+			t.Errorf("found %s", got)
+		}
+	}
+	if got := findProjectFile(filepath.Join(proj, "src", "pkg")); got != projectPath {
+		t.Errorf("findProjectFile() = %q, want %q", got, projectPath)
+	}
+}
+
+func TestLoadMissingInclude(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	writeFile(t, path, "include: [missing.yaml, 'optional/*.yaml']\n")
+	t.Setenv(NoProjectEnv, "1")
+
+	_, err := Load(path)
+	if err == nil || !strings.Contains(err.Error(), "missing.yaml") {
+		t.Errorf("Load() error = %v, want missing include", err)
+	}
+
+	writeFile(t, path, "include: ['optional/*.yaml']\n")
+	if _, err := Load(path); err != nil {
+		t.Errorf("Load() with an unmatched glob: %v", err)
+	}
+}
@@ -0,0 +1,127 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config files carry the schema version they were written for in their
+// version: key; files without one are version 0. Every file is upgraded to
+// CurrentVersion when it is read, one migration at a time, so the rest of
+// the package only ever sees the current schema. The main config is
+// written back in the new version on the next Save; other files are only
+// upgraded in memory.
+
+// CurrentVersion is the config schema version written by this veessh
+const CurrentVersion = 1
+
+// migration upgrades a file from version from to from+1, working on the YAML
+// nodes so that comments survive
+type migration struct {
+	from  int
+	apply func(root *yaml.Node) error
+}
+
+var migrations = []migration{
+	// Version 1 is the first versioned schema. Favorites and usage stats
+	// left in version 0 files are moved to the state store by Load. Booleans
+	// left false in version 0 files meant unset, which they now say by
+	// being left out.
+	{from: 0, apply: dropFalseOverrides},
+}
+
+// dropFalseOverrides removes useAgent and gcpUseTunnel set to false from
+// profiles extending others. Version 0 could not leave them unset (it
+// wrote useAgent: false on every profile), and false now overrides a
+// parent's true.
+func dropFalseOverrides(root *yaml.Node) error {
+	i := findKey(root, "profiles")
+	if i < 0 || root.Content[i+1].Kind != yaml.MappingNode {
+		return nil
+	}
+	profiles := root.Content[i+1]
+	for j := 1; j < len(profiles.Content); j += 2 {
+		p := profiles.Content[j]
+		if p.Kind != yaml.MappingNode || findKey(p, "extends") < 0 {
+			continue
+		}
+		for _, key := range []string{"useAgent", "gcpUseTunnel"} {
+			k := findKey(p, key)
+			if k < 0 {
+				continue
+			}
+			var b bool
+			if err := p.Content[k+1].Decode(&b); err == nil && !b {
+				p.Content = slices.Delete(p.Content, k, k+2)
+			}
+		}
+	}
+	return nil
+}
+
+// decode parses a config file, upgrading it to CurrentVersion
+func decode(path string, data []byte) (Config, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return Config{}, fmt.Errorf("%s: %w", path, err)
+	}
+	if doc.Kind == 0 {
+		return Config{}, nil
+	}
+	if err := migrate(&doc); err != nil {
+		return Config{}, fmt.Errorf("%s: %w", path, err)
+	}
+	var cfg Config
+	if err := doc.Decode(&cfg); err != nil {
+		return Config{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// migrate upgrades the parsed document doc to CurrentVersion
+func migrate(doc *yaml.Node) error {
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil
+	}
+	root := doc.Content[0]
+	version := 0
+	i := findKey(root, "version")
+	if i >= 0 {
+		v, err := strconv.Atoi(root.Content[i+1].Value)
+		if err != nil || v < 0 {
+			return fmt.Errorf("line %d: version must be a number, got %q", root.Content[i+1].Line, root.Content[i+1].Value)
+		}
+		version = v
+	}
+	if version > CurrentVersion {
+		return fmt.Errorf("written by a newer veessh (config version %d, this veessh reads up to %d)", version, CurrentVersion)
+	}
+	if version == CurrentVersion {
+		return nil
+	}
+
+	for _, m := range migrations {
+		if m.from < version {
+			continue
+		}
+		if err := m.apply(root); err != nil {
+			return fmt.Errorf("migrating from version %d: %w", m.from, err)
+		}
+	}
+
+	value := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: strconv.Itoa(CurrentVersion)}
+	if i >= 0 {
+		replaceNode(root.Content[i+1], value)
+		return nil
+	}
+	// Put the version first, below a comment heading the file
+	key := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "version"}
+	if len(root.Content) > 0 {
+		key.HeadComment, root.Content[0].HeadComment = root.Content[0].HeadComment, ""
+	}
+	root.Content = append([]*yaml.Node{key, value}, root.Content...)
+	return nil
+}
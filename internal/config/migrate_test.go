@@ -0,0 +1,151 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMigrate(t *testing.T) {
+	isolateState(t)
+	t.Setenv(NoProjectEnv, "1")
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeFile(t, path, "# mine\nprofiles:\n  a: {protocol: ssh, host: a.com}\n")
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Version != CurrentVersion {
+		t.Errorf("Version = %d, want %d", cfg.Version, CurrentVersion)
+	}
+	if data, _ := os.ReadFile(path); strings.Contains(string(data), "version") {
+		t.Error("Load() wrote the file")
+	}
+
+	// The upgrade is written with the next change
+	cfg.UpsertProfile(Profile{Name: "b", Protocol: ProtocolSSH, Host: "b.com"})
+	if err := Save(path, cfg); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	if !strings.HasPrefix(string(data), "# mine\nversion: 1\nprofiles:\n") {
+		t.Errorf("saved config:\n%s", data)
+	}
+}
+
+// baselineConfig was saved by veessh before config versions, which wrote
+// every field of a profile
+const baselineConfig = `profiles:
+    base:
+        name: base
+        protocol: ssh
+        host: bastion.example.com
+        port: 0
+        username: ops
+        identityFile: ""
+        useAgent: true
+        extraArgs: []
+        group: ""
+        description: ""
+        favorite: false
+        lastUsed: 0001-01-01T00:00:00Z
+        useCount: 0
+        proxyJump: ""
+        tags: []
+        localForwards: []
+        remoteForwards: []
+        dynamicForwards: []
+        gcpUseTunnel: true
+    solo:
+        name: solo
+        protocol: ssh
+        host: solo.example.com
+        port: 0
+        username: ""
+        identityFile: ""
+        useAgent: false
+        extraArgs: []
+        group: ""
+        description: ""
+        favorite: false
+        lastUsed: 0001-01-01T00:00:00Z
+        useCount: 0
+        proxyJump: ""
+        tags: []
+        localForwards: []
+        remoteForwards: []
+        dynamicForwards: []
+    web:
+        name: web
+        protocol: ssh
+        host: web.example.com
+        port: 0
+        username: ""
+        identityFile: ""
+        useAgent: false
+        extraArgs: []
+        group: ""
+        description: ""
+        favorite: false
+        lastUsed: 0001-01-01T00:00:00Z
+        useCount: 0
+        proxyJump: ""
+        tags: []
+        localForwards: []
+        remoteForwards: []
+        dynamicForwards: []
+        gcpUseTunnel: false # Set by hand; omitted when false
+        extends: base
+`
+
+func TestMigrateBaselineFalse(t *testing.T) {
+	isolateState(t)
+	t.Setenv(NoProjectEnv, "1")
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeFile(t, path, baselineConfig)
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The false written for every profile no longer overrides the parent
+	web, _ := cfg.GetProfile("web")
+	if !IsTrue(web.UseAgent) || !IsTrue(web.GCPUseTunnel) {
+		t.Errorf("web: useAgent = %v, gcpUseTunnel = %v, want both inherited", web.UseAgent, web.GCPUseTunnel)
+	}
+	// Profiles extending none keep it, though false means the same as unset
+	if solo, _ := cfg.GetProfile("solo"); solo.UseAgent == nil || *solo.UseAgent {
+		t.Errorf("solo: useAgent = %v, want false", solo.UseAgent)
+	}
+
+	if err := Save(path, cfg); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err = Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := cfg.Profiles["web"]; p.UseAgent != nil || p.GCPUseTunnel != nil {
+		t.Errorf("saved web: useAgent = %v, gcpUseTunnel = %v, want them left out", p.UseAgent, p.GCPUseTunnel)
+	}
+}
+
+func TestMigrateRejects(t *testing.T) {
+	t.Setenv(NoProjectEnv, "1")
+	tests := map[string]string{
+		"version: 99\n":     "newer veessh",
+		"version: one\n":    "must be a number",
+		"version: -1\n":     "must be a number",
+		"profiles: [a]\n":   "cannot unmarshal",
+		"version: [1, 2]\n": "must be a number",
+	}
+	for content, want := range tests {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		writeFile(t, path, content)
+		if _, err := Load(path); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Load(%q) error = %v, want %q", content, err, want)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Recording selects the sessions 'veessh connect' records, in addition to
+// profiles with record: true. It is read from the main config only.
+type Recording struct {
+	Tags []string `yaml:"tags,omitempty"` // Record profiles with any of these tags
+	Dir  string   `yaml:"dir,omitempty"`  // Where recordings are kept; defaults to $XDG_STATE_HOME/veessh/recordings
+}
+
+// ShouldRecord reports whether connecting to p is recorded: p has
+// record: true or a tag listed under recording.tags. record: false does not
+// exempt a profile from the tag rule.
+func (c *Config) ShouldRecord(p Profile) bool {
+	if IsTrue(p.Record) {
+		return true
+	}
+	if c.Recording == nil {
+		return false
+	}
+	for _, tag := range c.Recording.Tags {
+		for _, t := range p.Tags {
+			if strings.EqualFold(t, tag) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// RecordingDir returns where recordings are kept
+func (c *Config) RecordingDir() (string, error) {
+	if c.Recording != nil && c.Recording.Dir != "" {
+		return expandHome(c.Recording.Dir), nil
+	}
+	stateHome := os.Getenv("XDG_STATE_HOME")
+	if strings.TrimSpace(stateHome) == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		stateHome = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(stateHome, "veessh", "recordings"), nil
+}
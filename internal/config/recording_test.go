@@ -0,0 +1,25 @@
+package config
+
+import "testing"
+
+func TestShouldRecord(t *testing.T) {
+	cfg := Config{Recording: &Recording{Tags: []string{"prod"}}}
+	tests := []struct {
+		name string
+		p    Profile
+		want bool
+	}{
+		{"untagged", Profile{Tags: []string{"dev"}}, false},
+		{"record true", Profile{Record: Bool(true)}, true},
+		{"tag", Profile{Tags: []string{"web", "Prod"}}, true},
+		{"tag wins over record false", Profile{Tags: []string{"prod"}, Record: Bool(false)}, true},
+	}
+	for _, tt := range tests {
+		if got := cfg.ShouldRecord(tt.p); got != tt.want {
+			t.Errorf("%s: ShouldRecord() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	if (&Config{}).ShouldRecord(Profile{Tags: []string{"prod"}}) {
+		t.Error("recorded without a recording section")
+	}
+}
@@ -0,0 +1,208 @@
+package config
+
+import (
+	"reflect"
+	"time"
+)
+
+// Schema returns a JSON Schema of config.yaml for editors. It is derived
+// from the Config type, so it always lists the keys this veessh knows.
+func Schema() map[string]any {
+	config := object(reflect.TypeOf(Config{}), configDocs)
+	config["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	config["title"] = "veessh config"
+	// Top-level x- keys are free for anchors
+	config["patternProperties"] = map[string]any{"^x-": map[string]any{}}
+	profile := object(reflect.TypeOf(Profile{}), profileDocs)
+	profile["properties"].(map[string]any)["<<"] = map[string]any{"description": "YAML merge key"}
+	config["$defs"] = map[string]any{
+		"profile":  profile,
+		"template": object(reflect.TypeOf(Template{}), templateDocs),
+	}
+	return config
+}
+
+var configDocs = map[string]string{
+	"version":        "Schema version of this file; set by veessh",
+	"defaultBackend": "Default credential backend",
+	"profiles":       "Connection profiles by name",
+	"templates":      "Parametrized profiles stamped out by 'veessh template apply'",
+	"include":        "Further config files or globs, relative to this file",
+	"recording":      "Sessions 'veessh connect' records besides profiles with record: true",
+	"audit":          "Rotation of the audit log and where its entries are shipped",
+	"hostKeyPolicy":  "Checking of pinned host keys on connect, run, scp and rsync: strict (default for pinned hosts) refuses another key, warn warns, off skips the check",
+	"local":          "Deprecated: favorites and usage now live in the state store",
+}
+
+var recordingDocs = map[string]string{
+	"tags": "Record profiles with any of these tags",
+	"dir":  "Where recordings are kept (default $XDG_STATE_HOME/veessh/recordings)",
+}
+
+var auditDocs = map[string]string{
+	"maxSizeMB":  "Rotate the audit log at this size in MiB (default 10)",
+	"maxAgeDays": "Rotate the audit log when its first entry is this many days old (default 30)",
+	"keep":       "Compressed archives to keep; 0 keeps all",
+	"sinks":      "Where entries are shipped besides the local log",
+}
+
+var auditSinkDocs = map[string]string{
+	"name":      "Shown by 'veessh audit ship'; defaults to the type",
+	"type":      "syslog (RFC 5424), journald (native protocol) or webhook (JSON POST)",
+	"network":   "syslog: udp (default), tcp or unix",
+	"address":   "syslog: host:port (default localhost:514) or socket path; journald: socket path (default /run/systemd/journal/socket)",
+	"url":       "webhook: endpoint; https unless on this host",
+	"headers":   "webhook: extra request headers, e.g. Authorization; $VAR is expanded from the environment",
+	"batchSize": "webhook: entries per request (default 50)",
+}
+
+var templateDocs = map[string]string{
+	"description": "What the template is for",
+	"vars":        "Declared variables and their defaults; an empty default makes the variable required",
+	"profile":     "The profile to render; string fields may use {{ .var }}",
+}
+
+var profileDocs = map[string]string{
+	"name":            "Profile name; defaults to the key under profiles",
+	"protocol":        "How to connect",
+	"host":            "Host name or address (instance for ssm and gcloud)",
+	"port":            "Port; defaults to 22 for ssh, sftp and mosh and 23 for telnet",
+	"username":        "Remote user",
+	"identityFile":    "Private key file",
+	"useAgent":        "Use the local SSH agent for authentication",
+	"forwardAgent":    "Forward the local SSH agent to the host",
+	"extraArgs":       "Extra arguments passed to the client",
+	"group":           "Group shown in list and the TUI",
+	"description":     "Free-form description",
+	"favorite":        "Kept in the state store",
+	"lastUsed":        "Kept in the state store",
+	"useCount":        "Kept in the state store",
+	"proxyJump":       "Jump host(s), as for ssh -J",
+	"tags":            "Tags for filtering",
+	"localForwards":   "Local forwards, [bind:]port:host:hostport",
+	"remoteForwards":  "Remote forwards, [bind:]port:host:hostport",
+	"dynamicForwards": "SOCKS proxies, [bind:]port",
+	"sshClient":       "SSH client; empty picks openssh if installed",
+	"controlPersist":  "How long the shared connection veessh keeps for run, scp and rsync stays open after its last use (default 10m); no turns sharing off",
+	"record":          "Record interactive sessions to asciicast files; see 'veessh recordings'",
+	"hostKeyPolicy":   "What to do when the host's key does not match its pin: strict refuses to connect, warn warns, off skips the check; overrides the config's",
+	"remoteCommand":   "Command to run on connect (e.g. tmux attach || tmux new)",
+	"remoteDir":       "Directory to cd to on connect",
+	"setEnv":          "Environment variables to set, KEY=VALUE",
+	"awsRegion":       "AWS region (ssm)",
+	"awsProfile":      "AWS CLI profile (ssm)",
+	"instanceId":      "EC2 instance ID (ssm)",
+	"moshServer":      "Path to mosh-server on the remote host",
+	"gcpProject":      "GCP project (gcloud)",
+	"gcpZone":         "GCP zone (gcloud)",
+	"gcpUseTunnel":    "Connect through an IAP tunnel (gcloud)",
+	"extends":         "Parent profile(s) to inherit from, applied in order",
+	"merge":           "How inherited lists combine, keyed by field",
+	"forwards":        "Named forwards; 'veessh connect --forward name,...' picks which to open",
+}
+
+var forwardDocs = map[string]string{
+	"name":        "Name for 'veessh connect --forward'",
+	"type":        "local (-L, default), remote (-R) or dynamic (-D)",
+	"bind":        "Address to listen on; localhost when empty",
+	"localPort":   "Port to listen on (on the host for remote forwards)",
+	"remoteHost":  "Where connections go, as seen from the other end",
+	"remotePort":  "Port on remoteHost",
+	"description": "What the forward is for",
+	"enabled":     "Opened on connect unless others are picked (default true)",
+	"check":       "Command that succeeds while the forward works, for 'veessh tunnel --reconnect'; a TCP connect to localPort by default",
+}
+
+// Values the schema restricts string fields to, by key
+var schemaEnums = map[string][]any{
+	"protocol":       {string(ProtocolSSH), string(ProtocolSFTP), string(ProtocolTelnet), string(ProtocolMosh), string(ProtocolSSM), string(ProtocolGCloud)},
+	"sshClient":      {SSHClientOpenSSH, SSHClientNative},
+	"hostKeyPolicy":  {HostKeyStrict, HostKeyWarn, HostKeyOff},
+	"defaultBackend": {"auto", "1password", "keyring", "file"},
+	"type":           {ForwardLocal, ForwardRemote, ForwardDynamic},
+}
+
+// object describes struct type t, with docs by key
+func object(t reflect.Type, docs map[string]string) map[string]any {
+	props := map[string]any{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		key := fieldKey(f)
+		if !f.IsExported() || key == "-" {
+			continue
+		}
+		s := schemaOf(key, f.Type)
+		if doc := docs[key]; doc != "" {
+			s["description"] = doc
+		}
+		if key == "local" {
+			s["deprecated"] = true
+		}
+		props[key] = s
+	}
+	return map[string]any{"type": "object", "properties": props, "additionalProperties": false}
+}
+
+// schemaOf describes the value of key, of type t
+func schemaOf(key string, t reflect.Type) map[string]any {
+	switch t {
+	case reflect.TypeOf(Parents{}):
+		return map[string]any{"oneOf": []any{
+			map[string]any{"type": "string"},
+			map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+		}}
+	case reflect.TypeOf(map[string]MergeMode{}):
+		var fields []any
+		for _, f := range ListFields() {
+			fields = append(fields, f)
+		}
+		return map[string]any{
+			"type":                 "object",
+			"propertyNames":        map[string]any{"enum": fields},
+			"additionalProperties": map[string]any{"enum": []any{string(MergeAppend), string(MergeReplace), string(MergeRemove)}},
+		}
+	case reflect.TypeOf(map[string]Profile{}):
+		return map[string]any{"type": "object", "additionalProperties": map[string]any{"$ref": "#/$defs/profile"}}
+	case reflect.TypeOf(map[string]Template{}):
+		return map[string]any{"type": "object", "additionalProperties": map[string]any{"$ref": "#/$defs/template"}}
+	case reflect.TypeOf(Profile{}):
+		return map[string]any{"$ref": "#/$defs/profile"}
+	case reflect.TypeOf(Forward{}):
+		return object(t, forwardDocs)
+	case reflect.TypeOf(Recording{}):
+		return object(t, recordingDocs)
+	case reflect.TypeOf(Audit{}):
+		return object(t, auditDocs)
+	case reflect.TypeOf(AuditSink{}):
+		s := object(t, auditSinkDocs)
+		s["properties"].(map[string]any)["type"].(map[string]any)["enum"] = []any{SinkSyslog, SinkJournald, SinkWebhook}
+		s["required"] = []any{"type"}
+		return s
+	case reflect.TypeOf(time.Time{}):
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return schemaOf(key, t.Elem())
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int:
+		if key == "port" || key == "localPort" || key == "remotePort" {
+			return map[string]any{"type": "integer", "minimum": 0, "maximum": 65535}
+		}
+		return map[string]any{"type": "integer", "minimum": 0}
+	case reflect.String:
+		if enum, ok := schemaEnums[key]; ok {
+			return map[string]any{"type": "string", "enum": enum}
+		}
+		return map[string]any{"type": "string"}
+	case reflect.Slice:
+		return map[string]any{"type": "array", "items": schemaOf("", t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": schemaOf("", t.Elem())}
+	case reflect.Struct:
+		return object(t, nil)
+	}
+	return map[string]any{}
+}
@@ -0,0 +1,208 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// Template is a parametrized profile for fleets of nearly identical hosts.
+// Every string of Profile, list items included, may use text/template
+// syntax with the template's variables, e.g.
+//
+//	templates:
+//	  web:
+//	    vars: {env: "", index: "1"}
+//	    profile:
+//	      name: "web-{{ .env }}-{{ .index }}"
+//	      host: "web{{ .index }}.{{ .env }}.example.com"
+//	      extends: [base-ssh, "{{ .env }}-bastion"]
+//	      tags: [web, "{{ .env }}"]
+type Template struct {
+	Description string            `yaml:"description,omitempty"`
+	Vars        map[string]string `yaml:"vars,omitempty"` // Declared variables and their defaults; an empty default makes the variable required
+	Profile     Profile           `yaml:"profile"`
+}
+
+// Range is a variable stepped through a span of integers. Values keep the
+// width of a zero-padded start, so 01..12 yields 01, 02, ... 12.
+type Range struct {
+	Var   string
+	From  int
+	To    int
+	Width int
+}
+
+// ParseRange parses "name=from..to", e.g. "index=1..12" or "index=01..12"
+func ParseRange(spec string) (Range, error) {
+	name, span, ok := strings.Cut(spec, "=")
+	from, to, ok2 := strings.Cut(span, "..")
+	if !ok || !ok2 || strings.TrimSpace(name) == "" {
+		return Range{}, fmt.Errorf("invalid range %q (want name=from..to)", spec)
+	}
+	r := Range{Var: strings.TrimSpace(name)}
+	var err error
+	if r.From, err = strconv.Atoi(from); err != nil {
+		return Range{}, fmt.Errorf("invalid range %q: %w", spec, err)
+	}
+	if r.To, err = strconv.Atoi(to); err != nil {
+		return Range{}, fmt.Errorf("invalid range %q: %w", spec, err)
+	}
+	if r.From > r.To {
+		return Range{}, fmt.Errorf("invalid range %q: %d is greater than %d", spec, r.From, r.To)
+	}
+	if len(from) > 1 && strings.HasPrefix(from, "0") {
+		r.Width = len(from)
+	}
+	return r, nil
+}
+
+// Values returns the formatted values of the range
+func (r Range) Values() []string {
+	out := make([]string, 0, r.To-r.From+1)
+	for i := r.From; i <= r.To; i++ {
+		out = append(out, fmt.Sprintf("%0*d", r.Width, i))
+	}
+	return out
+}
+
+// Expand returns one variable set per combination of the ranges, each
+// starting from vars. Without ranges it returns vars alone.
+func Expand(vars map[string]string, ranges []Range) []map[string]string {
+	sets := []map[string]string{copyVars(vars)}
+	for _, r := range ranges {
+		var next []map[string]string
+		for _, set := range sets {
+			for _, v := range r.Values() {
+				s := copyVars(set)
+				s[r.Var] = v
+				next = append(next, s)
+			}
+		}
+		sets = next
+	}
+	return sets
+}
+
+func copyVars(vars map[string]string) map[string]string {
+	out := make(map[string]string, len(vars))
+	for k, v := range vars {
+		out[k] = v
+	}
+	return out
+}
+
+// Render stamps out one profile. vars override the template's defaults;
+// variables the template does not declare, and declared variables left
+// without a value, are errors.
+func (t Template) Render(vars map[string]string) (Profile, error) {
+	values := copyVars(t.Vars)
+	for k, v := range vars {
+		if _, ok := t.Vars[k]; !ok {
+			return Profile{}, fmt.Errorf("unknown variable %q (declared: %s)", k, strings.Join(t.VarNames(), ", "))
+		}
+		values[k] = v
+	}
+	for _, k := range t.VarNames() {
+		if values[k] == "" {
+			return Profile{}, fmt.Errorf("variable %q needs a value (--var %s=...)", k, k)
+		}
+	}
+	if strings.TrimSpace(t.Profile.Name) == "" {
+		return Profile{}, fmt.Errorf("template has no profile name")
+	}
+
+	p := t.Profile
+	v := reflect.ValueOf(&p).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		key := fieldKey(v.Type().Field(i))
+		if err := renderValue(v.Field(i), values); err != nil {
+			return Profile{}, fmt.Errorf("%s: %w", key, err)
+		}
+	}
+	// renderValue copied the lists; the merge map must not be shared either
+	if t.Profile.Merge != nil {
+		p.Merge = make(map[string]MergeMode, len(t.Profile.Merge))
+		for k, m := range t.Profile.Merge {
+			p.Merge[k] = m
+		}
+	}
+	return p, nil
+}
+
+// renderValue executes the templates in a string or string list field,
+// writing the results to a fresh slice, and in the fields of structs and
+// struct lists such as forwards
+func renderValue(f reflect.Value, values map[string]string) error {
+	switch {
+	case f.Kind() == reflect.String:
+		out, err := execute(f.String(), values)
+		if err != nil {
+			return err
+		}
+		f.SetString(out)
+	case f.Kind() == reflect.Slice && f.Type().Elem().Kind() == reflect.String:
+		if f.IsNil() {
+			return nil
+		}
+		out := reflect.MakeSlice(f.Type(), f.Len(), f.Len())
+		for i := 0; i < f.Len(); i++ {
+			s, err := execute(f.Index(i).String(), values)
+			if err != nil {
+				return err
+			}
+			out.Index(i).SetString(s)
+		}
+		f.Set(out)
+	case f.Kind() == reflect.Struct:
+		for i := 0; i < f.NumField(); i++ {
+			if !f.Field(i).CanSet() {
+				continue // Unexported, as in time.Time
+			}
+			if err := renderValue(f.Field(i), values); err != nil {
+				return fmt.Errorf("%s: %w", fieldKey(f.Type().Field(i)), err)
+			}
+		}
+	case f.Kind() == reflect.Slice && f.Type().Elem().Kind() == reflect.Struct:
+		if f.IsNil() {
+			return nil
+		}
+		out := reflect.MakeSlice(f.Type(), f.Len(), f.Len())
+		reflect.Copy(out, f)
+		for i := 0; i < out.Len(); i++ {
+			if err := renderValue(out.Index(i), values); err != nil {
+				return err
+			}
+		}
+		f.Set(out)
+	}
+	return nil
+}
+
+func execute(text string, values map[string]string) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+	tmpl, err := template.New("").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, values); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// VarNames returns the declared variables, sorted
+func (t Template) VarNames() []string {
+	names := make([]string, 0, len(t.Vars))
+	for k := range t.Vars {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
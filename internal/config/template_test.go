@@ -0,0 +1,105 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    []string
+		wantErr bool
+	}{
+		{"index=1..3", []string{"1", "2", "3"}, false},
+		{"index=08..10", []string{"08", "09", "10"}, false},
+		{"index=5..5", []string{"5"}, false},
+		{"index=3..1", nil, true},
+		{"index=1-3", nil, true},
+		{"=1..3", nil, true},
+		{"index=a..c", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			r, err := ParseRange(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseRange() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(r.Values(), tt.want) {
+				t.Errorf("Values() = %v, want %v", r.Values(), tt.want)
+			}
+		})
+	}
+}
+
+func TestExpand(t *testing.T) {
+	sets := Expand(map[string]string{"env": "prod"}, []Range{
+		{Var: "dc", From: 1, To: 2},
+		{Var: "index", From: 1, To: 3},
+	})
+	if len(sets) != 6 {
+		t.Fatalf("got %d sets, want 6", len(sets))
+	}
+	if sets[0]["env"] != "prod" || sets[0]["dc"] != "1" || sets[5]["dc"] != "2" || sets[5]["index"] != "3" {
+		t.Errorf("sets = %v", sets)
+	}
+	if got := Expand(map[string]string{"env": "prod"}, nil); len(got) != 1 || got[0]["env"] != "prod" {
+		t.Errorf("Expand without ranges = %v", got)
+	}
+}
+
+func TestTemplateRender(t *testing.T) {
+	tmpl := Template{
+		Vars: map[string]string{"env": "", "index": "1"},
+		Profile: Profile{
+			Name:          "web-{{ .env }}-{{ .index }}",
+			Host:          "web{{ .index }}.{{ .env }}.example.com",
+			Extends:       Parents{"base", "{{ .env }}-bastion"},
+			Tags:          []string{"web", "{{ .env }}"},
+			LocalForwards: []string{"80{{ .index }}:localhost:80"},
+			Forwards: []Forward{
+				{Name: "pg", LocalPort: 5432, RemoteHost: "db{{ .index }}.{{ .env }}", RemotePort: 5432, Description: "{{ .env }} database"},
+			},
+			Merge: map[string]MergeMode{"tags": MergeAppend},
+		},
+	}
+
+	p, err := tmpl.Render(map[string]string{"env": "prod", "index": "7"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Name != "web-prod-7" || p.Host != "web7.prod.example.com" {
+		t.Errorf("Name = %q, Host = %q", p.Name, p.Host)
+	}
+	if !reflect.DeepEqual(p.Extends, Parents{"base", "prod-bastion"}) {
+		t.Errorf("Extends = %v", p.Extends)
+	}
+	if !reflect.DeepEqual(p.Tags, []string{"web", "prod"}) || p.LocalForwards[0] != "807:localhost:80" {
+		t.Errorf("Tags = %v, LocalForwards = %v", p.Tags, p.LocalForwards)
+	}
+	if f := p.Forwards[0]; f.RemoteHost != "db7.prod" || f.Description != "prod database" || f.LocalPort != 5432 {
+		t.Errorf("Forwards = %+v", p.Forwards)
+	}
+	// The template itself must stay untouched
+	if tmpl.Profile.Tags[1] != "{{ .env }}" || tmpl.Profile.Forwards[0].RemoteHost != "db{{ .index }}.{{ .env }}" {
+		t.Errorf("template modified: %v, %+v", tmpl.Profile.Tags, tmpl.Profile.Forwards)
+	}
+	p.Merge["tags"] = MergeRemove
+	if tmpl.Profile.Merge["tags"] != MergeAppend {
+		t.Error("rendered profile shares the template's merge map")
+	}
+
+	// Defaults fill in undeclared values
+	if p, err := tmpl.Render(map[string]string{"env": "dev"}); err != nil || p.Name != "web-dev-1" {
+		t.Errorf("Render with default = %q, %v", p.Name, err)
+	}
+
+	for name, vars := range map[string]map[string]string{
+		"required variable missing": {"index": "1"},
+		"undeclared variable":       {"env": "prod", "color": "blue"},
+	} {
+		if _, err := tmpl.Render(vars); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package config
+
+import (
+	"time"
+
+	"github.com/vee-sh/veessh/internal/state"
+)
+
+// Favorites, last use and use counts are personal and change all the time,
+// so they live in the state store (internal/state) rather than in the
+// hand-edited config. Load copies them into the profiles so callers can
+// keep reading Profile.Favorite and friends; Save writes changed values
+// back to the state store and leaves them out of config.yaml.
+
+// loadUsage fills the personal fields of every profile from the state
+// store. The first time, values still stored in config files (profile
+// fields or the old local: section) are moved to the state store.
+func (c *Config) loadUsage() error {
+	path, err := state.DefaultPath()
+	if err != nil {
+		return err
+	}
+	st, err := state.Load(path)
+	if err != nil {
+		return err
+	}
+
+	if !st.ConfigImported {
+		legacy := map[string]state.ProfileState{}
+		for name, p := range c.Profiles {
+			if ps := personal(p); !ps.IsZero() {
+				legacy[name] = ps
+			}
+		}
+		err := state.Update(path, func(s *state.State) error {
+			if s.ConfigImported {
+				return nil
+			}
+			for name, ps := range legacy {
+				if _, ok := s.Profiles[name]; !ok {
+					s.Profiles[name] = ps
+				}
+			}
+			s.ConfigImported = true
+			st = *s
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	c.usage = make(map[string]state.ProfileState, len(c.Profiles))
+	for name, p := range c.Profiles {
+		ps := st.Profiles[name]
+		p.Favorite, p.LastUsed, p.UseCount = ps.Favorite, ps.LastUsed, ps.UseCount
+		c.Profiles[name] = p
+		c.usage[name] = ps
+	}
+	return nil
+}
+
+// saveUsage writes personal fields that changed since Load to the state
+// store, and forgets the state of deleted profiles. Only the fields that
+// changed are written, so that a favorite toggled in a long-running
+// session keeps the uses that other processes recorded meanwhile.
+func (c *Config) saveUsage() error {
+	changed := map[string]func(*state.ProfileState){}
+	for name, p := range c.Profiles {
+		was, now := c.usage[name], personal(p)
+		if now == was {
+			continue
+		}
+		changed[name] = func(ps *state.ProfileState) {
+			if now.Favorite != was.Favorite {
+				ps.Favorite = now.Favorite
+			}
+			if !now.LastUsed.Equal(was.LastUsed) {
+				ps.LastUsed = now.LastUsed
+			}
+			if now.UseCount != was.UseCount {
+				ps.UseCount = now.UseCount
+			}
+		}
+	}
+	var deleted []string
+	for name := range c.usage {
+		if _, ok := c.Profiles[name]; !ok {
+			deleted = append(deleted, name)
+		}
+	}
+	if len(changed) == 0 && len(deleted) == 0 {
+		return nil
+	}
+
+	path, err := state.DefaultPath()
+	if err != nil {
+		return err
+	}
+	return state.Update(path, func(s *state.State) error {
+		for name, update := range changed {
+			s.Set(name, update)
+		}
+		for _, name := range deleted {
+			delete(s.Profiles, name)
+		}
+		return nil
+	})
+}
+
+// withoutUsage returns a copy of c with the personal fields cleared, as
+// written to config.yaml
+func (c Config) withoutUsage() Config {
+	out := c
+	out.Local = nil
+	out.Profiles = make(map[string]Profile, len(c.Profiles))
+	for name, p := range c.Profiles {
+		p.Favorite, p.LastUsed, p.UseCount = false, time.Time{}, 0
+		out.Profiles[name] = p
+	}
+	return out
+}
+
+func personal(p Profile) state.ProfileState {
+	return state.ProfileState{Favorite: p.Favorite, LastUsed: p.LastUsed, UseCount: p.UseCount}
+}
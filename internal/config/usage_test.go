@@ -0,0 +1,163 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vee-sh/veessh/internal/state"
+)
+
+func TestMain(m *testing.M) {
+	// Never touch the state store of the user running the tests
+	dir, err := os.MkdirTemp("", "veessh-state-")
+	if err != nil {
+		panic(err)
+	}
+	os.Setenv(state.PathEnv, filepath.Join(dir, "state.json"))
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// isolateState gives a test its own state file
+func isolateState(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "state", "state.json")
+	t.Setenv(state.PathEnv, path)
+	return path
+}
+
+func TestUsageLivesInStateStore(t *testing.T) {
+	statePath := isolateState(t)
+	t.Setenv(NoProjectEnv, "1")
+	cfgPath := filepath.Join(t.TempDir(), "config.yaml")
+
+	cfg := Config{Profiles: map[string]Profile{
+		"a": {Name: "a", Protocol: ProtocolSSH, Host: "a.com", Favorite: true, UseCount: 2},
+		"b": {Name: "b", Protocol: ProtocolSSH, Host: "b.com"},
+	}}
+	if err := Save(cfgPath, cfg); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(cfgPath)
+	for _, field := range []string{"favorite", "useCount", "lastUsed"} {
+		if strings.Contains(string(data), field) {
+			t.Errorf("config.yaml contains %s:\n%s", field, data)
+		}
+	}
+
+	st, err := state.Load(statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ps := st.Profiles["a"]; !ps.Favorite || ps.UseCount != 2 {
+		t.Errorf("state for a = %+v", ps)
+	}
+
+	// Changes made elsewhere show up on load
+	err = state.Update(statePath, func(s *state.State) error {
+		s.RecordConnection(state.Connection{Profile: "b", Time: time.Now()})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err = Load(cfgPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := cfg.Profiles["b"]; p.UseCount != 1 || p.LastUsed.IsZero() {
+		t.Errorf("b = %+v, want usage from the state store", p)
+	}
+
+	// Deleting a profile forgets its state
+	cfg.DeleteProfile("a")
+	if err := Save(cfgPath, cfg); err != nil {
+		t.Fatal(err)
+	}
+	st, _ = state.Load(statePath)
+	if _, ok := st.Profiles["a"]; ok {
+		t.Error("state of deleted profile kept")
+	}
+}
+
+func TestSaveKeepsConcurrentUse(t *testing.T) {
+	statePath := isolateState(t)
+	t.Setenv(NoProjectEnv, "1")
+	cfgPath := filepath.Join(t.TempDir(), "config.yaml")
+	if err := Save(cfgPath, Config{Profiles: map[string]Profile{
+		"a": {Name: "a", Protocol: ProtocolSSH, Host: "a.com"},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load(cfgPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A connect records a use while the config is loaded elsewhere
+	used := time.Now().Truncate(time.Second)
+	if err := state.Update(statePath, func(s *state.State) error {
+		s.RecordConnection(state.Connection{Profile: "a", Time: used})
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	a := cfg.Profiles["a"]
+	a.Favorite = true
+	cfg.UpsertProfile(a)
+	if err := Save(cfgPath, cfg); err != nil {
+		t.Fatal(err)
+	}
+	st, _ := state.Load(statePath)
+	if ps := st.Profiles["a"]; !ps.Favorite || ps.UseCount != 1 || !ps.LastUsed.Equal(used) {
+		t.Errorf("state = %+v, want the favorite and the concurrent use", ps)
+	}
+}
+
+func TestUsageMigratedFromConfig(t *testing.T) {
+	statePath := isolateState(t)
+	t.Setenv(NoProjectEnv, "1")
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "config.yaml")
+	writeFile(t, cfgPath, `profiles:
+  a: {name: a, protocol: ssh, host: a.com, favorite: true, useCount: 7}
+  b: {name: b, protocol: ssh, host: b.com}
+local:
+  shared: {useCount: 4}
+`)
+	writeFile(t, filepath.Join(dir, "conf.d", "team.yaml"), `profiles:
+  shared: {name: shared, protocol: ssh, host: s.com}
+`)
+
+	cfg, err := Load(cfgPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := cfg.Profiles["a"]; !p.Favorite || p.UseCount != 7 {
+		t.Errorf("a = %+v", p)
+	}
+	if p := cfg.Profiles["shared"]; p.UseCount != 4 {
+		t.Errorf("shared useCount = %d, want 4 from local:", p.UseCount)
+	}
+	st, _ := state.Load(statePath)
+	if !st.ConfigImported || st.Profiles["a"].UseCount != 7 {
+		t.Errorf("state after migration = %+v", st)
+	}
+
+	// Unfavoriting must stick even though config.yaml still says favorite
+	if err := state.Update(statePath, func(s *state.State) error {
+		s.SetFavorite("a", false)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	cfg, _ = Load(cfgPath)
+	if cfg.Profiles["a"].Favorite {
+		t.Error("legacy favorite imported twice")
+	}
+}
@@ -0,0 +1,190 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/vee-sh/veessh/internal/util"
+)
+
+// Commands load the config, change it and save it, and several veessh
+// processes may do that at once (e.g. the panes of 'veessh session'). Save
+// holds an exclusive lock on config.yaml.lock while it writes, and compares
+// the file with the revision Load saw. If another process saved in between,
+// both sets of changes are merged profile by profile; if both changed the
+// same profile, template or setting, Save fails with ErrConflict instead
+// of overwriting the other change.
+
+// ErrConflict is returned by Save when the main config was changed by
+// another process in a way that conflicts with the changes being saved
+var ErrConflict = errors.New("config was changed by another process")
+
+// revision identifies the content of the main config
+func revision(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// readMain reads the main config, its content and its revision. A missing
+// file is an empty config with revision "".
+func readMain(path string) (Config, []byte, string, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return Config{}, nil, "", nil
+	}
+	if err != nil {
+		return Config{}, nil, "", err
+	}
+	cfg, err := decode(path, data)
+	if err != nil {
+		return Config{}, nil, "", err
+	}
+	return cfg, data, revision(data), nil
+}
+
+// writeMain writes out to the main config at path under the lock. When l
+// is the record of a Load from path, changes made to the file since then
+// are merged into out first.
+func writeMain(path string, out Config, l *layers) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	lock, err := util.Lock(path, true)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	current, data, rev, err := readMain(path)
+	if err != nil {
+		return err
+	}
+	out = comparable(out)
+	if l != nil && l.main == path && rev != l.rev {
+		if out, err = merge3(l.base, out, comparable(current)); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	out.Version = CurrentVersion
+
+	// Edit the file in place to keep comments and order (see yamledit.go)
+	if data, err = editFile(data, withNames(current), out); err != nil {
+		return err
+	}
+	if err := util.WriteFileAtomic(path, data, 0o600); err != nil {
+		return err
+	}
+	if l != nil && l.main == path {
+		l.base, l.rev = out, revision(data)
+	}
+	return nil
+}
+
+// comparable normalizes a main config for merging: personal fields are
+// dropped and profile names filled in, as Load does
+func comparable(c Config) Config {
+	return withNames(c.withoutUsage())
+}
+
+func withNames(c Config) Config {
+	profiles := make(map[string]Profile, len(c.Profiles))
+	for name, p := range c.Profiles {
+		if p.Name == "" {
+			p.Name = name
+		}
+		profiles[name] = p
+	}
+	c.Profiles = profiles
+	return c
+}
+
+// merge3 applies the changes from base to ours on top of theirs
+func merge3(base, ours, theirs Config) (Config, error) {
+	var conflicts []string
+	out := theirs
+
+	if ours.DefaultBackend != base.DefaultBackend {
+		if theirs.DefaultBackend != base.DefaultBackend && theirs.DefaultBackend != ours.DefaultBackend {
+			conflicts = append(conflicts, "defaultBackend")
+		}
+		out.DefaultBackend = ours.DefaultBackend
+	}
+	if !same(ours.Include, base.Include) {
+		if !same(theirs.Include, base.Include) && !same(theirs.Include, ours.Include) {
+			conflicts = append(conflicts, "include")
+		}
+		out.Include = ours.Include
+	}
+	if !same(ours.Recording, base.Recording) {
+		if !same(theirs.Recording, base.Recording) && !same(theirs.Recording, ours.Recording) {
+			conflicts = append(conflicts, "recording")
+		}
+		out.Recording = ours.Recording
+	}
+	if !same(ours.Audit, base.Audit) {
+		if !same(theirs.Audit, base.Audit) && !same(theirs.Audit, ours.Audit) {
+			conflicts = append(conflicts, "audit")
+		}
+		out.Audit = ours.Audit
+	}
+	if ours.HostKeyPolicy != base.HostKeyPolicy {
+		if theirs.HostKeyPolicy != base.HostKeyPolicy && theirs.HostKeyPolicy != ours.HostKeyPolicy {
+			conflicts = append(conflicts, "hostKeyPolicy")
+		}
+		out.HostKeyPolicy = ours.HostKeyPolicy
+	}
+	out.Profiles = mergeMap("profile", base.Profiles, ours.Profiles, theirs.Profiles, &conflicts)
+	out.Templates = mergeMap("template", base.Templates, ours.Templates, theirs.Templates, &conflicts)
+
+	if len(conflicts) > 0 {
+		return Config{}, fmt.Errorf("%w: %s changed since it was loaded; run the command again", ErrConflict, strings.Join(conflicts, ", "))
+	}
+	return out, nil
+}
+
+// mergeMap merges the entries of a map; a missing entry is a deleted one
+func mergeMap[V any](kind string, base, ours, theirs map[string]V, conflicts *[]string) map[string]V {
+	names := map[string]bool{}
+	for _, m := range []map[string]V{base, ours, theirs} {
+		for name := range m {
+			names[name] = true
+		}
+	}
+	out := map[string]V{}
+	for _, name := range sortedKeys(names) {
+		b, inBase := base[name]
+		o, inOurs := ours[name]
+		t, inTheirs := theirs[name]
+		switch {
+		case inOurs == inBase && same(o, b):
+			// unchanged here: keep theirs
+			if inTheirs {
+				out[name] = t
+			}
+		case inTheirs == inBase && same(t, b),
+			inTheirs == inOurs && same(t, o):
+			if inOurs {
+				out[name] = o
+			}
+		default:
+			*conflicts = append(*conflicts, fmt.Sprintf("%s %q", kind, name))
+		}
+	}
+	return out
+}
+
+// same compares two values as they are written to the config, so that a
+// list read back as [] equals one that was never set
+func same(a, b any) bool {
+	x, errA := yaml.Marshal(a)
+	y, errB := yaml.Marshal(b)
+	return errA == nil && errB == nil && string(x) == string(y)
+}
@@ -0,0 +1,122 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSaveConcurrentEdits(t *testing.T) {
+	isolateState(t)
+	t.Setenv(NoProjectEnv, "1")
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	writeFile(t, path, `profiles:
+  a: {protocol: ssh, host: a.com}
+  b: {name: b, protocol: ssh, host: b.com}
+  c: {name: c, protocol: ssh, host: c.com}
+`)
+
+	first, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Changes to different profiles are merged
+	a := first.Profiles["a"]
+	a.Host = "new-a.com"
+	first.UpsertProfile(a)
+	first.UpsertProfile(Profile{Name: "d", Protocol: ProtocolSSH, Host: "d.com"})
+	if err := Save(path, first); err != nil {
+		t.Fatal(err)
+	}
+	second.DeleteProfile("b")
+	second.DefaultBackend = "keyring"
+	if err := Save(path, second); err != nil {
+		t.Fatalf("Save() of disjoint changes: %v", err)
+	}
+
+	raw, err := readFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if raw.Profiles["a"].Host != "new-a.com" || raw.Profiles["c"].Host != "c.com" {
+		t.Errorf("profiles = %+v", raw.Profiles)
+	}
+	if _, ok := raw.Profiles["b"]; ok {
+		t.Error("deleted profile b came back")
+	}
+	if _, ok := raw.Profiles["d"]; !ok {
+		t.Error("profile d added by the first save was lost")
+	}
+	if raw.DefaultBackend != "keyring" {
+		t.Errorf("defaultBackend = %q", raw.DefaultBackend)
+	}
+
+	// Saving again from the same config does not see its own write as a
+	// concurrent change
+	second.DefaultBackend = "file"
+	if err := Save(path, second); err != nil {
+		t.Fatalf("second Save(): %v", err)
+	}
+
+	// Changes to the same profile conflict and leave the file alone
+	third, _ := Load(path)
+	fourth, _ := Load(path)
+	c := third.Profiles["c"]
+	c.Port = 2222
+	third.UpsertProfile(c)
+	if err := Save(path, third); err != nil {
+		t.Fatal(err)
+	}
+	before, _ := os.ReadFile(path)
+	c = fourth.Profiles["c"]
+	c.Username = "root"
+	fourth.UpsertProfile(c)
+	if err := Save(path, fourth); !errors.Is(err, ErrConflict) {
+		t.Errorf("Save() of a conflicting change: err = %v, want ErrConflict", err)
+	}
+	if after, _ := os.ReadFile(path); string(after) != string(before) {
+		t.Error("conflicting Save() modified the file")
+	}
+
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		if e.Name() != "config.yaml" && e.Name() != "config.yaml.lock" {
+			t.Errorf("leftover file %s", e.Name())
+		}
+	}
+}
+
+func TestMergeMap(t *testing.T) {
+	base := map[string]string{"same": "x", "ours": "x", "theirs": "x", "both": "x", "gone": "x"}
+	ours := map[string]string{"same": "x", "ours": "y", "theirs": "x", "both": "y", "added": "y"}
+	theirs := map[string]string{"same": "x", "ours": "x", "theirs": "z", "both": "y", "gone": "x"}
+
+	var conflicts []string
+	got := mergeMap("profile", base, ours, theirs, &conflicts)
+	want := map[string]string{"same": "x", "ours": "y", "theirs": "z", "both": "y", "added": "y"}
+	if len(conflicts) != 0 {
+		t.Errorf("conflicts = %v", conflicts)
+	}
+	if len(got) != len(want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %q, want %q", k, got[k], v)
+		}
+	}
+
+	// Deleted here, changed there
+	theirs["gone"] = "z"
+	mergeMap("profile", base, ours, theirs, &conflicts)
+	if len(conflicts) != 1 || conflicts[0] != `profile "gone"` {
+		t.Errorf("conflicts = %v", conflicts)
+	}
+}
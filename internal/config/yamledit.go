@@ -0,0 +1,283 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// config.yaml is also edited by hand, so Save does not marshal the whole
+// Config. It applies the difference between the file as read and the config
+// being saved to the file's YAML node tree instead: only keys whose values
+// changed are rewritten, new profiles and templates are appended, and
+// comments, key order, anchors and keys veessh does not know stay as they
+// are.
+
+// editFile returns data, whose parsed content is old, changed to hold cfg
+func editFile(data []byte, old, cfg Config) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if doc.Kind != yaml.DocumentNode {
+		doc = yaml.Node{Kind: yaml.DocumentNode}
+	}
+	if err := migrate(&doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		doc.Content = []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}
+	}
+	aliased := map[*yaml.Node]bool{}
+	findAliased(&doc, aliased)
+	if err := patchStruct(doc.Content[0], reflect.ValueOf(old), reflect.ValueOf(cfg), aliased); err != nil {
+		return nil, err
+	}
+
+	plainMergeKeys(&doc)
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(detectIndent(data))
+	if err := enc.Encode(&doc); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// patchStruct rewrites the keys of mapping node m whose fields differ
+// between old and cfg. aliased holds the anchored nodes that aliases refer
+// to, which must keep their values.
+func patchStruct(m *yaml.Node, old, cfg reflect.Value, aliased map[*yaml.Node]bool) error {
+	t := cfg.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		key := fieldKey(f)
+		if !f.IsExported() || key == "-" {
+			continue
+		}
+		o, n := old.Field(i), cfg.Field(i)
+		if same(o.Interface(), n.Interface()) {
+			continue
+		}
+		if err := setKey(m, key, o, n, true, aliased); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// patchMap rewrites, removes and appends the entries of mapping node m to
+// turn old into cfg
+func patchMap(m *yaml.Node, old, cfg reflect.Value, aliased map[*yaml.Node]bool) error {
+	for i := 0; i < len(m.Content)-1; {
+		key := reflect.ValueOf(m.Content[i].Value).Convert(cfg.Type().Key())
+		if m.Content[i].Value != "<<" && !cfg.MapIndex(key).IsValid() {
+			m.Content = append(m.Content[:i], m.Content[i+2:]...)
+			continue
+		}
+		i += 2
+	}
+
+	keys := make([]string, 0, cfg.Len())
+	for _, k := range cfg.MapKeys() {
+		keys = append(keys, k.String())
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		key := reflect.ValueOf(k).Convert(cfg.Type().Key())
+		o, n := old.MapIndex(key), cfg.MapIndex(key)
+		if o.IsValid() && same(o.Interface(), n.Interface()) {
+			continue
+		}
+		if !o.IsValid() {
+			o = reflect.Zero(n.Type())
+		}
+		if err := setKey(m, k, o, n, false, aliased); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setKey changes the value of key in mapping node m from old to v. Struct
+// fields that become empty are removed, as they read back the same.
+func setKey(m *yaml.Node, key string, old, v reflect.Value, omitEmpty bool, aliased map[*yaml.Node]bool) error {
+	i := findKey(m, key)
+	if omitEmpty && isEmpty(v) {
+		switch {
+		case hasMergeKey(m):
+			// An empty value must hide the one merged in from the anchor
+		case i >= 0:
+			m.Content = append(m.Content[:i], m.Content[i+2:]...)
+			return nil
+		default:
+			return nil
+		}
+	}
+	if i < 0 {
+		val, err := newNode(v)
+		if err != nil {
+			return err
+		}
+		m.Content = append(m.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, val)
+		return nil
+	}
+
+	val := m.Content[i+1]
+	if aliased[val] {
+		// Other keys merge or alias this value (web2: {<<: *web}), so it
+		// stays as it is; a record overrides the changed fields next to it
+		if val.Kind != yaml.MappingNode || !isRecord(v.Type()) {
+			return fmt.Errorf("cannot change %s: its value is anchored as &%s and used elsewhere in the file", key, val.Anchor)
+		}
+		m.Content[i+1] = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", HeadComment: val.HeadComment, LineComment: val.LineComment, FootComment: val.FootComment}
+		val.HeadComment, val.LineComment, val.FootComment = "", "", ""
+		m.Content[i+1].Content = []*yaml.Node{{Kind: yaml.ScalarNode, Value: "<<"}, val}
+		val = m.Content[i+1]
+	}
+	if val.Kind == yaml.AliasNode && isRecord(v.Type()) {
+		// Keep using the anchor and override the changed fields
+		alias := *val
+		*val = yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", HeadComment: alias.HeadComment, LineComment: alias.LineComment, FootComment: alias.FootComment}
+		alias.HeadComment, alias.LineComment, alias.FootComment = "", "", ""
+		val.Content = []*yaml.Node{{Kind: yaml.ScalarNode, Value: "<<"}, &alias}
+	}
+	switch {
+	case val.Kind == yaml.MappingNode && isRecord(v.Type()):
+		return patchStruct(val, old, v, aliased)
+	case val.Kind == yaml.MappingNode && v.Kind() == reflect.Map:
+		return patchMap(val, old, v, aliased)
+	}
+	n, err := newNode(v)
+	if err != nil {
+		return err
+	}
+	replaceNode(val, n)
+	return nil
+}
+
+// newNode encodes v, leaving out empty struct fields
+func newNode(v reflect.Value) (*yaml.Node, error) {
+	if v.Kind() == reflect.Map && isRecord(v.Type().Elem()) {
+		m := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+		for _, k := range keys {
+			val, err := newNode(v.MapIndex(k))
+			if err != nil {
+				return nil, err
+			}
+			m.Content = append(m.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: k.String()}, val)
+		}
+		return m, nil
+	}
+	if !isRecord(v.Type()) {
+		var n yaml.Node
+		if err := n.Encode(v.Interface()); err != nil {
+			return nil, err
+		}
+		return &n, nil
+	}
+	m := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		key := fieldKey(f)
+		if !f.IsExported() || key == "-" || isEmpty(v.Field(i)) {
+			continue
+		}
+		val, err := newNode(v.Field(i))
+		if err != nil {
+			return nil, err
+		}
+		m.Content = append(m.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, val)
+	}
+	return m, nil
+}
+
+// replaceNode puts n in the place of old, keeping old's comments and, where
+// it still fits, its style
+func replaceNode(old, n *yaml.Node) {
+	n.HeadComment, n.LineComment, n.FootComment = old.HeadComment, old.LineComment, old.FootComment
+	switch {
+	case old.Kind != n.Kind:
+	case n.Kind == yaml.ScalarNode:
+		if n.Tag == "!!str" && n.Style == 0 && old.Style&(yaml.DoubleQuotedStyle|yaml.SingleQuotedStyle) != 0 {
+			n.Style = old.Style
+		}
+	default:
+		n.Style |= old.Style & yaml.FlowStyle
+	}
+	*old = *n
+}
+
+func findKey(m *yaml.Node, key string) int {
+	for i := 0; i < len(m.Content)-1; i += 2 {
+		if m.Content[i].Value == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// plainMergeKeys clears the tag of merge keys, which the encoder would
+// otherwise write as "!!merge <<"
+func plainMergeKeys(n *yaml.Node) {
+	if n.Kind == yaml.ScalarNode && n.Tag == "!!merge" {
+		n.Tag = ""
+	}
+	for _, c := range n.Content {
+		plainMergeKeys(c)
+	}
+}
+
+// findAliased adds the nodes aliases in n refer to to aliased
+func findAliased(n *yaml.Node, aliased map[*yaml.Node]bool) {
+	if n.Kind == yaml.AliasNode {
+		aliased[n.Alias] = true
+	}
+	for _, c := range n.Content {
+		findAliased(c, aliased)
+	}
+}
+
+func hasMergeKey(m *yaml.Node) bool {
+	return findKey(m, "<<") >= 0
+}
+
+// isRecord reports whether t is encoded field by field
+func isRecord(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && t != reflect.TypeOf(time.Time{})
+}
+
+func isEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	}
+	return v.IsZero()
+}
+
+// detectIndent returns the indentation of data, or that of yaml.Marshal
+// for a file without nesting
+func detectIndent(data []byte) int {
+	for _, line := range strings.Split(string(data), "\n") {
+		trimmed := strings.TrimLeft(line, " ")
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, "- ") {
+			continue
+		}
+		if n := len(line) - len(trimmed); n > 0 {
+			return max(n, 2)
+		}
+	}
+	return 4
+}
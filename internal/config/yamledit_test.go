@@ -0,0 +1,162 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const handEdited = `# veessh profiles
+defaultBackend: keyring
+base: &base
+  protocol: ssh
+  username: deploy
+profiles:
+  # production web
+  web:
+    host: web.example.com # primary
+    port: 22
+    description: "front end"
+    tags: [prod, web]
+    futureKey: keep me
+    favorite: true
+  db: {name: db, protocol: ssh, host: db.example.com}
+  jump: *base
+  old: {protocol: ssh, host: old.example.com}
+`
+
+func TestSaveKeepsLayout(t *testing.T) {
+	isolateState(t)
+	t.Setenv(NoProjectEnv, "1")
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeFile(t, path, handEdited)
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	web := cfg.Profiles["web"]
+	web.Port = 2222
+	web.Description = "edge"
+	web.Tags = nil
+	cfg.UpsertProfile(web)
+	jump := cfg.Profiles["jump"]
+	jump.Host = "jump.example.com"
+	cfg.UpsertProfile(jump)
+	cfg.DeleteProfile("old")
+	cfg.UpsertProfile(Profile{Name: "new", Protocol: ProtocolSSH, Host: "new.example.com"})
+	if err := Save(path, cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(data)
+	want := `# veessh profiles
+version: 1
+defaultBackend: keyring
+base: &base
+  protocol: ssh
+  username: deploy
+profiles:
+  # production web
+  web:
+    host: web.example.com # primary
+    port: 2222
+    description: "edge"
+    futureKey: keep me
+  db: {name: db, protocol: ssh, host: db.example.com}
+  jump:
+    <<: *base
+    host: jump.example.com
+  new:
+    name: new
+    protocol: ssh
+    host: new.example.com
+`
+	if got != want {
+		t.Errorf("saved config:\n%s\nwant:\n%s", got, want)
+	}
+
+	cfg, err = Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := cfg.Profiles["jump"]; p.Username != "deploy" || p.Host != "jump.example.com" {
+		t.Errorf("jump = %+v", p)
+	}
+	if !cfg.Profiles["web"].Favorite {
+		t.Error("legacy favorite lost")
+	}
+}
+
+func TestSaveLeavesProfilesMergingAnAnchor(t *testing.T) {
+	isolateState(t)
+	t.Setenv(NoProjectEnv, "1")
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeFile(t, path, `profiles:
+  web1: &web # shared
+    protocol: ssh
+    host: web1.example.com
+    port: 22
+  web2:
+    <<: *web
+    host: web2.example.com
+`)
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	web1 := cfg.Profiles["web1"]
+	web1.Port = 2222
+	cfg.UpsertProfile(web1)
+	if err := Save(path, cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg, err = Load(path); err != nil {
+		t.Fatal(err)
+	}
+	if p := cfg.Profiles["web1"]; p.Port != 2222 || p.Host != "web1.example.com" {
+		t.Errorf("web1 = %+v", p)
+	}
+	if p := cfg.Profiles["web2"]; p.Port != 22 || p.Host != "web2.example.com" {
+		t.Errorf("web2 = %+v, want the anchor's port 22", p)
+	}
+	data, _ := os.ReadFile(path)
+	if !strings.Contains(string(data), "&web") || !strings.Contains(string(data), "# shared") {
+		t.Errorf("anchor or comment lost:\n%s", data)
+	}
+}
+
+func TestDetectIndent(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int
+	}{
+		{"profiles:\n  a:\n    host: x\n", 2},
+		{"# c\n\nprofiles:\n    a: {}\n", 4},
+		{"include:\n- a.yaml\n", 4},
+		{"", 4},
+	}
+	for _, tt := range tests {
+		if got := detectIndent([]byte(tt.in)); got != tt.want {
+			t.Errorf("detectIndent(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestEditFileNewFile(t *testing.T) {
+	cfg := Config{Profiles: map[string]Profile{"a": {Name: "a", Protocol: ProtocolSSH, Host: "a.com"}}}
+	data, err := editFile(nil, Config{}, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), `""`) || !strings.Contains(string(data), "host: a.com") {
+		t.Errorf("new file:\n%s", data)
+	}
+}
@@ -0,0 +1,89 @@
+package connectors
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// AskPassProfileEnv names the profile whose stored secrets the askpass
+// helper (`veessh askpass`) answers OpenSSH prompts from.
+const AskPassProfileEnv = "VEESSH_ASKPASS_PROFILE"
+
+// AskPassTargetEnv holds the user@host of the connection the profile is
+// for, so that the helper answers only that host's prompts and not those of
+// ProxyJump hosts, which get the same environment.
+const AskPassTargetEnv = "VEESSH_ASKPASS_TARGET"
+
+// AskPassEnv returns environment variables that make OpenSSH ask veessh for
+// key passphrases, passwords and one-time codes of the profile, on the
+// connection to target. The returned function removes the helper script.
+func AskPassEnv(profileName, target string) ([]string, func(), error) {
+	if runtime.GOOS == "windows" {
+		return nil, nil, fmt.Errorf("askpass helper is not supported on Windows")
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to locate veessh executable: %w", err)
+	}
+
+	// SSH_ASKPASS must be a single program, so wrap `veessh askpass`
+	tmpFile, err := os.CreateTemp("", "veessh-askpass-*")
+	if err != nil {
+		return nil, nil, err
+	}
+	scriptPath := tmpFile.Name()
+	script := fmt.Sprintf("#!/bin/sh\nexec %s askpass \"$@\"\n", shellQuote(exe))
+	if _, err := tmpFile.WriteString(script); err != nil {
+		tmpFile.Close()
+		os.Remove(scriptPath)
+		return nil, nil, err
+	}
+	if err := tmpFile.Close(); err != nil {
+		os.Remove(scriptPath)
+		return nil, nil, err
+	}
+	if err := os.Chmod(scriptPath, 0o700); err != nil {
+		os.Remove(scriptPath)
+		return nil, nil, err
+	}
+
+	env := []string{
+		"SSH_ASKPASS=" + scriptPath,
+		"SSH_ASKPASS_REQUIRE=force", // OpenSSH 8.4+: use askpass even with a TTY
+		AskPassProfileEnv + "=" + profileName,
+		AskPassTargetEnv + "=" + target,
+	}
+	if os.Getenv("DISPLAY") == "" {
+		// Older OpenSSH only consults SSH_ASKPASS when DISPLAY is set
+		env = append(env, "DISPLAY=:0")
+	}
+	return env, func() { os.Remove(scriptPath) }, nil
+}
+
+// AskPassTarget returns the user@host OpenSSH names in the prompts of the
+// connection sshArgs make, as ssh -G resolves it with ssh_config applied:
+// the HostKeyAlias if one is set (pins set one), else the HostName.
+func AskPassTarget(ctx context.Context, sshArgs []string) (string, error) {
+	out, err := exec.CommandContext(ctx, "ssh", append([]string{"-G"}, sshArgs...)...).Output()
+	if err != nil {
+		return "", fmt.Errorf("ssh -G failed: %w", err)
+	}
+	settings := map[string]string{}
+	for _, line := range strings.Split(string(out), "\n") {
+		if key, value, ok := strings.Cut(strings.TrimSpace(line), " "); ok {
+			settings[key] = value
+		}
+	}
+	host := settings["hostkeyalias"]
+	if host == "" {
+		host = settings["hostname"]
+	}
+	if settings["user"] == "" || host == "" {
+		return "", fmt.Errorf("ssh -G names no user or host")
+	}
+	return settings["user"] + "@" + host, nil
+}
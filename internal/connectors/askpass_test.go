@@ -0,0 +1,28 @@
+package connectors
+
+import (
+	"context"
+	"os/exec"
+	"testing"
+)
+
+func TestAskPassTarget(t *testing.T) {
+	if _, err := exec.LookPath("ssh"); err != nil {
+		t.Skip("ssh not installed")
+	}
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-F", "none", "-l", "alice", "example.com"}, "alice@example.com"},
+		{[]string{"-F", "none", "-o", "HostName=10.0.0.1", "alice@web", "uptime"}, "alice@10.0.0.1"},
+		// ssh names the alias pins set in its prompts
+		{[]string{"-F", "none", "-o", "HostKeyAlias=veessh-pin-1", "alice@example.com"}, "alice@veessh-pin-1"},
+	}
+	for _, tt := range tests {
+		got, err := AskPassTarget(context.Background(), tt.args)
+		if err != nil || got != tt.want {
+			t.Errorf("AskPassTarget(%q) = %q, %v; want %q", tt.args, got, err, tt.want)
+		}
+	}
+}
@@ -2,15 +2,38 @@ package connectors
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os"
+	"os/exec"
+	"strings"
 	"sync"
 
+	"golang.org/x/crypto/ssh"
+
 	"github.com/vee-sh/veessh/internal/config"
+	"github.com/vee-sh/veessh/internal/credentials"
+	"github.com/vee-sh/veessh/internal/recording"
+	"github.com/vee-sh/veessh/internal/util"
 )
 
 type Connector interface {
 	Name() string
-	Exec(ctx context.Context, profile config.Profile, password string) error
+	// Exec connects using the profile's stored secrets (empty when absent)
+	Exec(ctx context.Context, profile config.Profile, secrets credentials.Secrets) error
+}
+
+// Tunneler is implemented by connectors that can open a profile's forwards
+// without a remote shell, until ctx is cancelled or the connection drops
+type Tunneler interface {
+	Tunnel(ctx context.Context, profile config.Profile, secrets credentials.Secrets) error
+}
+
+// Commander is implemented by connectors that connect by running a single
+// local command, so that the command can also be started elsewhere, like in
+// a tmux pane
+type Commander interface {
+	Command(ctx context.Context, profile config.Profile) *exec.Cmd
 }
 
 var (
@@ -33,3 +56,85 @@ func Get(p config.Protocol) (Connector, error) {
 	}
 	return c, nil
 }
+
+// ForProfile returns the connector to use for a profile. SSH profiles use the
+// native in-process client when they ask for it with `sshClient: native`, or
+// when no OpenSSH client is installed and they did not pin `openssh`.
+func ForProfile(p config.Profile) (Connector, error) {
+	if p.Protocol == config.ProtocolSSH {
+		switch p.SSHClient {
+		case config.SSHClientNative:
+			return Get(ProtocolNative)
+		case "":
+			if findExecutable("ssh") == "" {
+				return Get(ProtocolNative)
+			}
+		}
+	}
+	return Get(p.Protocol)
+}
+
+// TunnelerFor returns the connector that runs tunnels for a profile. SFTP
+// profiles tunnel over SSH like SSH profiles do.
+func TunnelerFor(p config.Profile) (Tunneler, error) {
+	if p.Protocol == config.ProtocolSFTP {
+		p.Protocol = config.ProtocolSSH
+	}
+	c, err := ForProfile(p)
+	if err != nil {
+		return nil, err
+	}
+	t, ok := c.(Tunneler)
+	if !ok {
+		return nil, fmt.Errorf("%s profiles cannot run tunnels", p.Protocol)
+	}
+	return t, nil
+}
+
+// CommandLine renders cmd as a shell command line, with the environment
+// variables it sets in front
+func CommandLine(cmd *exec.Cmd) string {
+	var parts []string
+	if cmd.Env != nil {
+		inherited := map[string]bool{}
+		for _, kv := range os.Environ() {
+			inherited[kv] = true
+		}
+		for _, kv := range cmd.Env {
+			if !inherited[kv] {
+				k, v, _ := strings.Cut(kv, "=")
+				parts = append(parts, k+"="+shellQuote(v))
+			}
+		}
+	}
+	for _, arg := range cmd.Args {
+		if arg == "" || strings.ContainsAny(arg, " \t\n'\"\\$`!;|&()<>*?[]#~{}") {
+			arg = shellQuote(arg)
+		}
+		parts = append(parts, arg)
+	}
+	return strings.Join(parts, " ")
+}
+
+// runAttached runs cmd attached to the terminal, through the session
+// recorder when ctx carries one (see recording.NewContext)
+func runAttached(ctx context.Context, cmd *exec.Cmd) error {
+	if rec := recording.FromContext(ctx); rec != nil {
+		return rec.Run(cmd)
+	}
+	return util.RunAttached(cmd)
+}
+
+// ExitCode extracts the remote exit status from a connector error, or 0 if
+// the error carries none.
+func ExitCode(err error) int {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	var sshErr *ssh.ExitError
+	if errors.As(err, &sshErr) {
+		return sshErr.ExitStatus()
+	}
+	return 0
+}
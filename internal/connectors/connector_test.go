@@ -1,6 +1,8 @@
 package connectors
 
 import (
+	"context"
+	"os/exec"
 	"testing"
 
 	"github.com/vee-sh/veessh/internal/config"
@@ -63,3 +65,74 @@ func TestConnectorNames(t *testing.T) {
 	}
 }
 
+
+func TestForProfileNativeClient(t *testing.T) {
+	p := config.Profile{Protocol: config.ProtocolSSH, SSHClient: config.SSHClientNative}
+	conn, err := ForProfile(p)
+	if err != nil {
+		t.Fatalf("ForProfile() error = %v", err)
+	}
+	if conn.Name() != "native" {
+		t.Errorf("ForProfile() = %s, want native", conn.Name())
+	}
+
+	// sshClient only applies to SSH profiles
+	p = config.Profile{Protocol: config.ProtocolMosh, SSHClient: config.SSHClientNative}
+	conn, err = ForProfile(p)
+	if err != nil {
+		t.Fatalf("ForProfile() error = %v", err)
+	}
+	if conn.Name() != "mosh" {
+		t.Errorf("ForProfile() = %s, want mosh", conn.Name())
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	if got := ExitCode(nil); got != 0 {
+		t.Errorf("ExitCode(nil) = %d, want 0", got)
+	}
+	err := exec.Command("sh", "-c", "exit 3").Run()
+	if got := ExitCode(err); got != 3 {
+		t.Errorf("ExitCode(exit 3) = %d, want 3", got)
+	}
+}
+
+func TestCommandLine(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile config.Profile
+		want    string
+	}{
+		{
+			name:    "mosh",
+			profile: config.Profile{Protocol: config.ProtocolMosh, Host: "web", Username: "alice", Port: 2222},
+			want:    "mosh '--ssh=ssh -p 2222' alice@web",
+		},
+		{
+			name:    "ssm with aws profile",
+			profile: config.Profile{Protocol: config.ProtocolSSM, InstanceID: "i-0abc", AWSRegion: "eu-west-1", AWSProfile: "prod"},
+			want:    "AWS_PROFILE='prod' aws ssm start-session --target i-0abc --region eu-west-1",
+		},
+		{
+			name:    "gcloud",
+			profile: config.Profile{Protocol: config.ProtocolGCloud, Host: "vm-1", GCPZone: "us-east1-b", RemoteCommand: "tmux attach || tmux new"},
+			want:    "gcloud compute ssh vm-1 --zone us-east1-b --command 'tmux attach || tmux new'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, err := ForProfile(tt.profile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c, ok := conn.(Commander)
+			if !ok {
+				t.Fatalf("%s connector is not a Commander", tt.profile.Protocol)
+			}
+			if got := CommandLine(c.Command(context.Background(), tt.profile)); got != tt.want {
+				t.Errorf("CommandLine() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
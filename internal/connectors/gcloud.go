@@ -5,14 +5,18 @@ import (
 	"os/exec"
 
 	"github.com/vee-sh/veessh/internal/config"
-	"github.com/vee-sh/veessh/internal/util"
+	"github.com/vee-sh/veessh/internal/credentials"
 )
 
 type gcloudConnector struct{}
 
 func (g *gcloudConnector) Name() string { return "gcloud" }
 
-func (g *gcloudConnector) Exec(ctx context.Context, p config.Profile, _ string) error {
+func (g *gcloudConnector) Exec(ctx context.Context, p config.Profile, _ credentials.Secrets) error {
+	return runAttached(ctx, g.Command(ctx, p))
+}
+
+func (g *gcloudConnector) Command(ctx context.Context, p config.Profile) *exec.Cmd {
 	args := []string{"compute", "ssh"}
 
 	// Instance name is stored in Host field for gcloud
@@ -30,7 +34,7 @@ func (g *gcloudConnector) Exec(ctx context.Context, p config.Profile, _ string)
 	}
 
 	// Add tunnel through IAP if configured
-	if p.GCPUseTunnel {
+	if config.IsTrue(p.GCPUseTunnel) {
 		args = append(args, "--tunnel-through-iap")
 	}
 
@@ -43,8 +47,7 @@ func (g *gcloudConnector) Exec(ctx context.Context, p config.Profile, _ string)
 		args = append(args, "--command", p.RemoteCommand)
 	}
 
-	cmd := exec.CommandContext(ctx, "gcloud", args...)
-	return util.RunAttached(cmd)
+	return exec.CommandContext(ctx, "gcloud", args...)
 }
 
 func init() {
@@ -6,14 +6,19 @@ import (
 	"strconv"
 
 	"github.com/vee-sh/veessh/internal/config"
-	"github.com/vee-sh/veessh/internal/util"
+	"github.com/vee-sh/veessh/internal/credentials"
+	"github.com/vee-sh/veessh/internal/hostkeys"
 )
 
 type moshConnector struct{}
 
 func (m *moshConnector) Name() string { return "mosh" }
 
-func (m *moshConnector) Exec(ctx context.Context, p config.Profile, _ string) error {
+func (m *moshConnector) Exec(ctx context.Context, p config.Profile, _ credentials.Secrets) error {
+	return runAttached(ctx, m.Command(ctx, p))
+}
+
+func (m *moshConnector) Command(ctx context.Context, p config.Profile) *exec.Cmd {
 	args := []string{}
 
 	// Mosh uses --ssh for ssh options
@@ -27,6 +32,9 @@ func (m *moshConnector) Exec(ctx context.Context, p config.Profile, _ string) er
 	if p.ProxyJump != "" {
 		sshArgs += " -J " + p.ProxyJump
 	}
+	for _, arg := range hostkeys.SSHArgs(ctx) {
+		sshArgs += " " + arg
+	}
 	if sshArgs != "" {
 		args = append(args, "--ssh=ssh"+sshArgs)
 	}
@@ -50,8 +58,7 @@ func (m *moshConnector) Exec(ctx context.Context, p config.Profile, _ string) er
 		args = append(args, "--", p.RemoteCommand)
 	}
 
-	cmd := exec.CommandContext(ctx, "mosh", args...)
-	return util.RunAttached(cmd)
+	return exec.CommandContext(ctx, "mosh", args...)
 }
 
 func init() {
@@ -0,0 +1,208 @@
+package connectors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/term"
+
+	"github.com/vee-sh/veessh/internal/config"
+	"github.com/vee-sh/veessh/internal/credentials"
+	"github.com/vee-sh/veessh/internal/hostkeys"
+	"github.com/vee-sh/veessh/internal/recording"
+	"github.com/vee-sh/veessh/internal/sshclient"
+)
+
+// ProtocolNative is the registry key of the in-process SSH connector. It is
+// not a profile protocol; SSH profiles opt into it with `sshClient: native`.
+const ProtocolNative config.Protocol = "native"
+
+type nativeConnector struct{}
+
+func (n *nativeConnector) Name() string { return "native" }
+
+func (n *nativeConnector) Exec(ctx context.Context, p config.Profile, secrets credentials.Secrets) error {
+	if len(p.ExtraArgs) > 0 {
+		fmt.Fprintf(os.Stderr, "Warning: extraArgs are OpenSSH options and are ignored by the native client\n")
+	}
+
+	client, err := sshclient.Dial(ctx, p, sshclient.Config{
+		Password:      secrets.Password,
+		KeyPassphrase: secrets.KeyPassphrase,
+		TOTPSeed:      secrets.TOTPSeed,
+		Prompt:        sshclient.TerminalPrompt,
+		Pin:           hostkeys.FromContext(ctx),
+	})
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	stopForwards, err := sshclient.StartForwards(ctx, client.Client, p)
+	if err != nil {
+		return err
+	}
+	defer stopForwards()
+
+	session, err := client.NewSession()
+	if err != nil {
+		return fmt.Errorf("failed to open session: %w", err)
+	}
+	defer session.Close()
+
+	if config.IsTrue(p.ForwardAgent) {
+		if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+			if err := agent.ForwardToRemote(client.Client, sock); err == nil {
+				agent.RequestAgentForwarding(session)
+			}
+		}
+	}
+
+	// Servers commonly reject variables not listed in AcceptEnv; that is not fatal
+	for _, env := range p.SetEnv {
+		if k, v, ok := strings.Cut(env, "="); ok && k != "" {
+			session.Setenv(k, v)
+		}
+	}
+
+	fd := int(os.Stdin.Fd())
+	if term.IsTerminal(fd) {
+		state, err := term.MakeRaw(fd)
+		if err != nil {
+			return fmt.Errorf("failed to set terminal raw mode: %w", err)
+		}
+		defer term.Restore(fd, state)
+
+		width, height, err := term.GetSize(fd)
+		if err != nil {
+			width, height = 80, 24
+		}
+		termType := os.Getenv("TERM")
+		if termType == "" {
+			termType = "xterm-256color"
+		}
+		modes := ssh.TerminalModes{
+			ssh.ECHO:          1,
+			ssh.TTY_OP_ISPEED: 14400,
+			ssh.TTY_OP_OSPEED: 14400,
+		}
+		if err := session.RequestPty(termType, height, width, modes); err != nil {
+			return fmt.Errorf("failed to request pty: %w", err)
+		}
+		stopResize := watchWindowSize(ctx, fd, session)
+		defer stopResize()
+	}
+
+	session.Stdin = os.Stdin
+	session.Stdout = os.Stdout
+	session.Stderr = os.Stderr
+	if rec := recording.FromContext(ctx); rec != nil {
+		// The remote end runs the pty, so record what it sends
+		session.Stdout = io.MultiWriter(os.Stdout, rec)
+		session.Stderr = io.MultiWriter(os.Stderr, rec)
+	}
+
+	if remoteCmd := BuildRemoteCommand(p); remoteCmd != "" {
+		err = session.Start(remoteCmd)
+	} else {
+		err = session.Shell()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to start remote shell: %w", err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- session.Wait() }()
+	select {
+	case err := <-done:
+		var missing *ssh.ExitMissingError
+		if errors.As(err, &missing) {
+			// Connection dropped without an exit status (e.g. `exit` racing the close)
+			return nil
+		}
+		return err
+	case <-ctx.Done():
+		return context.Canceled
+	}
+}
+
+// Tunnel opens the profile's forwards and keeps them open until ctx is
+// cancelled or the connection drops
+func (n *nativeConnector) Tunnel(ctx context.Context, p config.Profile, secrets credentials.Secrets) error {
+	cfg := sshclient.Config{
+		Password:      secrets.Password,
+		KeyPassphrase: secrets.KeyPassphrase,
+		TOTPSeed:      secrets.TOTPSeed,
+		Pin:           hostkeys.FromContext(ctx),
+	}
+	// Background tunnels have no terminal to ask on
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		cfg.Prompt = sshclient.TerminalPrompt
+	}
+	client, err := sshclient.Dial(ctx, p, cfg)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	stopForwards, err := sshclient.StartForwards(ctx, client.Client, p)
+	if err != nil {
+		return err
+	}
+	defer stopForwards()
+	go keepAlive(ctx, client, 15*time.Second)
+
+	done := make(chan error, 1)
+	go func() { done <- client.Wait() }()
+	select {
+	case err := <-done:
+		if err == nil {
+			err = errors.New("closed by the remote host")
+		}
+		return fmt.Errorf("connection to %s lost: %w", p.Host, err)
+	case <-ctx.Done():
+		return context.Canceled
+	}
+}
+
+// keepAlive closes client when the server stops answering keepalive
+// requests, like ssh's ServerAliveInterval, so that a dead connection ends
+// the tunnel
+func keepAlive(ctx context.Context, client *sshclient.Client, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		answered := make(chan error, 1)
+		go func() {
+			_, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
+			answered <- err
+		}()
+		select {
+		case err := <-answered:
+			if err == nil {
+				continue
+			}
+		case <-time.After(3 * interval):
+		case <-ctx.Done():
+			return
+		}
+		client.Close()
+		return
+	}
+}
+
+func init() {
+	Register(ProtocolNative, &nativeConnector{})
+}
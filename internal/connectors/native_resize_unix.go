@@ -0,0 +1,38 @@
+//go:build !windows
+
+package connectors
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/term"
+)
+
+// watchWindowSize propagates local terminal resizes to the remote pty.
+func watchWindowSize(ctx context.Context, fd int, session *ssh.Session) func() {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGWINCH)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-sigs:
+				if w, h, err := term.GetSize(fd); err == nil {
+					session.WindowChange(h, w)
+				}
+			case <-ctx.Done():
+				return
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(sigs)
+		close(done)
+	}
+}
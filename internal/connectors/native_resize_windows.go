@@ -0,0 +1,35 @@
+//go:build windows
+
+package connectors
+
+import (
+	"context"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/term"
+)
+
+// watchWindowSize polls the console size, since Windows has no SIGWINCH.
+func watchWindowSize(ctx context.Context, fd int, session *ssh.Session) func() {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(500 * time.Millisecond)
+		defer ticker.Stop()
+		lastW, lastH, _ := term.GetSize(fd)
+		for {
+			select {
+			case <-ticker.C:
+				if w, h, err := term.GetSize(fd); err == nil && (w != lastW || h != lastH) {
+					lastW, lastH = w, h
+					session.WindowChange(h, w)
+				}
+			case <-ctx.Done():
+				return
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() { close(done) }
+}
@@ -6,14 +6,15 @@ import (
 	"strconv"
 
 	"github.com/vee-sh/veessh/internal/config"
-	"github.com/vee-sh/veessh/internal/util"
+	"github.com/vee-sh/veessh/internal/credentials"
+	"github.com/vee-sh/veessh/internal/hostkeys"
 )
 
 type sftpConnector struct{}
 
 func (s *sftpConnector) Name() string { return "sftp" }
 
-func (s *sftpConnector) Exec(ctx context.Context, p config.Profile, _ string) error {
+func (s *sftpConnector) Exec(ctx context.Context, p config.Profile, _ credentials.Secrets) error {
 	args := []string{}
 	if p.Port > 0 {
 		args = append(args, "-P", strconv.Itoa(p.Port))
@@ -24,21 +25,23 @@ func (s *sftpConnector) Exec(ctx context.Context, p config.Profile, _ string) er
 	if p.ProxyJump != "" {
 		args = append(args, "-o", "ProxyJump="+p.ProxyJump)
 	}
-	for _, lf := range p.LocalForwards {
+	local, remote, dynamic := p.ForwardSpecs()
+	for _, lf := range local {
 		if lf != "" {
 			args = append(args, "-o", "LocalForward="+lf)
 		}
 	}
-	for _, rf := range p.RemoteForwards {
+	for _, rf := range remote {
 		if rf != "" {
 			args = append(args, "-o", "RemoteForward="+rf)
 		}
 	}
-	for _, df := range p.DynamicForwards {
+	for _, df := range dynamic {
 		if df != "" {
 			args = append(args, "-o", "DynamicForward="+df)
 		}
 	}
+	args = append(args, hostkeys.SSHArgs(ctx)...)
 	if len(p.ExtraArgs) > 0 {
 		args = append(args, p.ExtraArgs...)
 	}
@@ -48,7 +51,7 @@ func (s *sftpConnector) Exec(ctx context.Context, p config.Profile, _ string) er
 	}
 	args = append(args, target)
 	cmd := exec.CommandContext(ctx, "sftp", args...)
-	return util.RunAttached(cmd)
+	return runAttached(ctx, cmd)
 }
 
 func init() {
@@ -3,20 +3,39 @@ package connectors
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"strconv"
 	"strings"
 
 	"github.com/vee-sh/veessh/internal/config"
-	"github.com/vee-sh/veessh/internal/util"
+	"github.com/vee-sh/veessh/internal/credentials"
+	"github.com/vee-sh/veessh/internal/hostkeys"
+	"github.com/vee-sh/veessh/internal/mux"
+	"github.com/vee-sh/veessh/internal/recording"
 )
 
 type sshConnector struct{}
 
 func (s *sshConnector) Name() string { return "ssh" }
 
-func (s *sshConnector) Exec(ctx context.Context, p config.Profile, password string) error {
+func (s *sshConnector) Exec(ctx context.Context, p config.Profile, secrets credentials.Secrets) error {
+	return s.run(ctx, p, secrets, false)
+}
+
+// Tunnel runs ssh -N: the profile's forwards without a remote command
+func (s *sshConnector) Tunnel(ctx context.Context, p config.Profile, secrets credentials.Secrets) error {
+	return s.run(ctx, p, secrets, true)
+}
+
+func (s *sshConnector) run(ctx context.Context, p config.Profile, secrets credentials.Secrets, tunnel bool) error {
+	password := secrets.Password
+	// Stored key passphrases and TOTP seeds are answered by the askpass
+	// helper, which handles the password too, so sshpass is not needed then
+	useAskPass := secrets.KeyPassphrase != "" || secrets.TOTPSeed != ""
+	usePassword := password != "" && p.IdentityFile == "" && !useAskPass
+
 	args := []string{}
 	if p.Port > 0 {
 		args = append(args, "-p", strconv.Itoa(p.Port))
@@ -30,17 +49,21 @@ func (s *sshConnector) Exec(ctx context.Context, p config.Profile, password stri
 	if p.ProxyJump != "" {
 		args = append(args, "-J", p.ProxyJump)
 	}
-	for _, lf := range p.LocalForwards {
+	if config.IsTrue(p.ForwardAgent) {
+		args = append(args, "-A")
+	}
+	local, remote, dynamic := p.ForwardSpecs()
+	for _, lf := range local {
 		if lf != "" {
 			args = append(args, "-L", lf)
 		}
 	}
-	for _, rf := range p.RemoteForwards {
+	for _, rf := range remote {
 		if rf != "" {
 			args = append(args, "-R", rf)
 		}
 	}
-	for _, df := range p.DynamicForwards {
+	for _, df := range dynamic {
 		if df != "" {
 			args = append(args, "-D", df)
 		}
@@ -56,7 +79,7 @@ func (s *sshConnector) Exec(ctx context.Context, p config.Profile, password stri
 	// If password is provided and no identity file, configure SSH for password auth
 	// Note: We inject password even if UseAgent is true, as SSH may fall back
 	// to password auth if the agent doesn't have the right key
-	if password != "" && p.IdentityFile == "" {
+	if usePassword {
 		// Check if sshpass is available and warn if not
 		if findExecutable("sshpass") == "" {
 			fmt.Fprintf(os.Stderr, "⚠️  Password is stored but 'sshpass' is not installed.\n")
@@ -76,30 +99,69 @@ func (s *sshConnector) Exec(ctx context.Context, p config.Profile, password stri
 		args = append(args, "-o", "ConnectTimeout=10")
 	}
 
+	// Sessions become the master that run, scp and rsync reuse. Not with
+	// forwards, which would stay open with the master, nor with sshpass,
+	// which would wait for the backgrounded master.
+	if !tunnel && !usePassword && len(local)+len(remote)+len(dynamic) == 0 {
+		args = append(args, mux.Args(p)...)
+	}
+	args = append(args, hostkeys.SSHArgs(ctx)...)
+
 	if len(p.ExtraArgs) > 0 {
 		args = append(args, p.ExtraArgs...)
 	}
 
-	// Request TTY if we have a remote command
-	if p.RemoteCommand != "" || p.RemoteDir != "" {
-		args = append(args, "-t")
-	}
+	if tunnel {
+		// Fail instead of running without a forward that could not be opened,
+		// and notice a dead connection within a minute
+		args = append(args, "-N", "-o", "ExitOnForwardFailure=yes")
+		args = append(args, "-o", "ServerAliveInterval=15", "-o", "ServerAliveCountMax=3")
+		args = append(args, p.Host)
+	} else {
+		// Request TTY if we have a remote command
+		if p.RemoteCommand != "" || p.RemoteDir != "" {
+			args = append(args, "-t")
+		}
 
-	args = append(args, p.Host)
+		args = append(args, p.Host)
 
-	// Build remote command if specified
-	remoteCmd := buildRemoteCommand(p)
-	if remoteCmd != "" {
-		args = append(args, remoteCmd)
+		// Build remote command if specified
+		remoteCmd := BuildRemoteCommand(p)
+		if remoteCmd != "" {
+			args = append(args, remoteCmd)
+		}
+	}
+
+	if useAskPass {
+		return s.execWithAskPass(ctx, args, p.Name)
 	}
 
 	// If password is provided, use execWithPassword
-	if password != "" && p.IdentityFile == "" {
+	if usePassword {
 		return s.execWithPassword(ctx, args, password, p.Name)
 	}
 
 	cmd := exec.CommandContext(ctx, "ssh", args...)
-	return util.RunAttached(cmd)
+	return runAttached(ctx, cmd)
+}
+
+// execWithAskPass executes SSH with veessh as its askpass helper, so key
+// passphrases, passwords and one-time codes come from the stored secrets
+func (s *sshConnector) execWithAskPass(ctx context.Context, sshArgs []string, profileName string) error {
+	cmd := exec.CommandContext(ctx, "ssh", sshArgs...)
+	// Without the target, password prompts are asked on the terminal
+	target, err := AskPassTarget(ctx, sshArgs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Could not tell the host ssh connects to (%v). You will be prompted for passwords.\n", err)
+	}
+	env, cleanup, err := AskPassEnv(profileName, target)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Could not set up askpass helper (%v). You will be prompted.\n", err)
+		return runAttached(ctx, cmd)
+	}
+	defer cleanup()
+	cmd.Env = append(os.Environ(), env...)
+	return runAttached(ctx, cmd)
 }
 
 // execWithPassword executes SSH with password authentication
@@ -108,7 +170,7 @@ func (s *sshConnector) execWithPassword(ctx context.Context, sshArgs []string, p
 	if password == "" {
 		fmt.Fprintf(os.Stderr, "Warning: Password is empty. You will be prompted.\n")
 		cmd := exec.CommandContext(ctx, "ssh", sshArgs...)
-		return util.RunAttached(cmd)
+		return runAttached(ctx, cmd)
 	}
 
 	// Try sshpass first (if available) - this is the most reliable method
@@ -121,7 +183,7 @@ func (s *sshConnector) execWithPassword(ctx context.Context, sshArgs []string, p
 		args = append(args, sshArgs...)
 		cmd := exec.CommandContext(ctx, sshpassPath, args...)
 		cmd.Env = append(os.Environ(), "SSHPASS="+cleanPassword)
-		err := util.RunAttached(cmd)
+		err := runAttached(ctx, cmd)
 		if err != nil {
 			// Check if it's an authentication failure
 			if exitErr, ok := err.(*exec.ExitError); ok {
@@ -144,7 +206,7 @@ func (s *sshConnector) execWithPassword(ctx context.Context, sshArgs []string, p
 		// If we can't create the script, fall back to interactive prompt
 		fmt.Fprintf(os.Stderr, "Warning: Could not create SSH_ASKPASS script. Password will be prompted.\n")
 		cmd := exec.CommandContext(ctx, "ssh", sshArgs...)
-		return util.RunAttached(cmd)
+		return runAttached(ctx, cmd)
 	}
 	defer os.Remove(tmpScript)
 
@@ -177,6 +239,10 @@ func (s *sshConnector) execWithPassword(ctx context.Context, sshArgs []string, p
 	cmd.Stdin = nil
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
+	if rec := recording.FromContext(ctx); rec != nil {
+		cmd.Stdout = io.MultiWriter(os.Stdout, rec)
+		cmd.Stderr = io.MultiWriter(os.Stderr, rec)
+	}
 
 	if err := cmd.Run(); err != nil {
 		// If SSH_ASKPASS failed, fall back to interactive prompt
@@ -186,7 +252,7 @@ func (s *sshConnector) execWithPassword(ctx context.Context, sshArgs []string, p
 		fmt.Fprintf(os.Stderr, "  brew install hudochenkov/sshpass/sshpass\n\n")
 		
 		cmd := exec.CommandContext(ctx, "ssh", sshArgs...)
-		return util.RunAttached(cmd)
+		return runAttached(ctx, cmd)
 	}
 
 	return nil
@@ -228,8 +294,9 @@ func createSSHAskPassScript(password string) (string, error) {
 	return scriptPath, nil
 }
 
-// buildRemoteCommand constructs the command to run on the remote host
-func buildRemoteCommand(p config.Profile) string {
+// BuildRemoteCommand constructs the command to run on the remote host from
+// the profile's remote directory and command
+func BuildRemoteCommand(p config.Profile) string {
 	var parts []string
 
 	// Change to remote directory (properly quoted for spaces/special chars)
@@ -74,9 +74,9 @@ func TestBuildRemoteCommand(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := buildRemoteCommand(tt.profile)
+			got := BuildRemoteCommand(tt.profile)
 			if got != tt.want {
-				t.Errorf("buildRemoteCommand() = %q, want %q", got, tt.want)
+				t.Errorf("BuildRemoteCommand() = %q, want %q", got, tt.want)
 			}
 		})
 	}
@@ -6,14 +6,18 @@ import (
 	"os/exec"
 
 	"github.com/vee-sh/veessh/internal/config"
-	"github.com/vee-sh/veessh/internal/util"
+	"github.com/vee-sh/veessh/internal/credentials"
 )
 
 type ssmConnector struct{}
 
 func (s *ssmConnector) Name() string { return "ssm" }
 
-func (s *ssmConnector) Exec(ctx context.Context, p config.Profile, _ string) error {
+func (s *ssmConnector) Exec(ctx context.Context, p config.Profile, _ credentials.Secrets) error {
+	return runAttached(ctx, s.Command(ctx, p))
+}
+
+func (s *ssmConnector) Command(ctx context.Context, p config.Profile) *exec.Cmd {
 	args := []string{"ssm", "start-session", "--target", p.InstanceID}
 
 	if p.AWSRegion != "" {
@@ -30,8 +34,7 @@ func (s *ssmConnector) Exec(ctx context.Context, p config.Profile, _ string) err
 	if p.AWSProfile != "" {
 		cmd.Env = append(os.Environ(), "AWS_PROFILE="+p.AWSProfile)
 	}
-
-	return util.RunAttached(cmd)
+	return cmd
 }
 
 func init() {
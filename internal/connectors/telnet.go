@@ -6,20 +6,20 @@ import (
 	"strconv"
 
 	"github.com/vee-sh/veessh/internal/config"
-	"github.com/vee-sh/veessh/internal/util"
+	"github.com/vee-sh/veessh/internal/credentials"
 )
 
 type telnetConnector struct{}
 
 func (t *telnetConnector) Name() string { return "telnet" }
 
-func (t *telnetConnector) Exec(ctx context.Context, p config.Profile, _ string) error {
+func (t *telnetConnector) Exec(ctx context.Context, p config.Profile, _ credentials.Secrets) error {
 	args := []string{p.Host}
 	if p.Port > 0 {
 		args = append(args, strconv.Itoa(p.Port))
 	}
 	cmd := exec.CommandContext(ctx, "telnet", args...)
-	return util.RunAttached(cmd)
+	return runAttached(ctx, cmd)
 }
 
 func init() {
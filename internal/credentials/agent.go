@@ -0,0 +1,242 @@
+package credentials
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/vee-sh/veessh/internal/util"
+)
+
+// The unlock agent caches derived file backend keys in memory so the master
+// passphrase is asked once per session instead of once per command. It
+// listens on a unix socket in a directory only the current user can enter,
+// answers one JSON request per connection, and forgets every key when its
+// timeout expires or 'veessh lock' is run.
+
+// AgentSocketEnv overrides the unlock agent socket path
+const AgentSocketEnv = "VEESSH_AGENT_SOCK"
+
+type agentRequest struct {
+	Op  string `json:"op"` // get, put, status, lock
+	ID  string `json:"id,omitempty"`
+	Key []byte `json:"key,omitempty"`
+}
+
+type agentResponse struct {
+	Key     []byte    `json:"key,omitempty"`
+	Keys    int       `json:"keys"`
+	Expires time.Time `json:"expires,omitempty"`
+	Error   string    `json:"error,omitempty"`
+}
+
+// AgentSocketPath returns the unlock agent socket path, in the veessh
+// runtime directory
+func AgentSocketPath() string {
+	if p := os.Getenv(AgentSocketEnv); p != "" {
+		return p
+	}
+	return filepath.Join(util.RuntimeDir(), "agent.sock")
+}
+
+// checkAgentSocket refuses a socket that another user could have put in
+// place to collect the keys sent to it
+func checkAgentSocket(path string) error {
+	if err := util.CheckPrivate(filepath.Dir(path)); err != nil {
+		return fmt.Errorf("not trusting the unlock agent socket: %w", err)
+	}
+	if err := util.CheckPrivate(path); err != nil {
+		return fmt.Errorf("not trusting the unlock agent socket: %w", err)
+	}
+	return nil
+}
+
+func agentCall(req agentRequest) (agentResponse, error) {
+	path := AgentSocketPath()
+	if err := checkAgentSocket(path); err != nil {
+		return agentResponse{}, err
+	}
+	conn, err := net.DialTimeout("unix", path, time.Second)
+	if err != nil {
+		return agentResponse{}, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(2 * time.Second))
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return agentResponse{}, err
+	}
+	var resp agentResponse
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return agentResponse{}, err
+	}
+	if resp.Error != "" {
+		return resp, errors.New(resp.Error)
+	}
+	return resp, nil
+}
+
+func agentRunning() bool {
+	_, err := agentCall(agentRequest{Op: "status"})
+	return err == nil
+}
+
+func agentGetKey(id string) ([]byte, error) {
+	resp, err := agentCall(agentRequest{Op: "get", ID: id})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Key, nil
+}
+
+func agentPutKey(id string, key []byte) error {
+	_, err := agentCall(agentRequest{Op: "put", ID: id, Key: key})
+	return err
+}
+
+// AgentStatus reports whether the unlock agent is running, how many keys it
+// holds and when it expires (zero time if it never does)
+func AgentStatus() (running bool, keys int, expires time.Time) {
+	resp, err := agentCall(agentRequest{Op: "status"})
+	if err != nil {
+		return false, 0, time.Time{}
+	}
+	return true, resp.Keys, resp.Expires
+}
+
+// LockAgent makes the unlock agent forget its keys and exit
+func LockAgent() error {
+	if _, err := agentCall(agentRequest{Op: "lock"}); err != nil {
+		return fmt.Errorf("unlock agent is not running: %w", err)
+	}
+	return nil
+}
+
+// CacheFileBackendKey unlocks the file backend (prompting if needed) and
+// stores its key in the running unlock agent
+func CacheFileBackendKey() error {
+	f, err := NewFileBackend()
+	if err != nil {
+		return err
+	}
+	id, key, err := f.Unlock()
+	if err != nil {
+		return err
+	}
+	return agentPutKey(id, key)
+}
+
+// RunAgent serves the unlock agent until timeout elapses (0 means never) or
+// LockAgent is called. ready is closed once the socket is listening.
+func RunAgent(timeout time.Duration, ready chan<- struct{}) error {
+	path := AgentSocketPath()
+	if err := util.PrivateDir(filepath.Dir(path)); err != nil {
+		return err
+	}
+	if agentRunning() {
+		return fmt.Errorf("unlock agent already running on %s", path)
+	}
+	os.Remove(path) // stale socket of an agent that died
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", path, err)
+	}
+	defer os.Remove(path)
+	if err := os.Chmod(path, 0o600); err != nil {
+		l.Close()
+		return err
+	}
+
+	a := &keyAgent{keys: map[string][]byte{}, done: make(chan struct{})}
+	if timeout > 0 {
+		a.expires = time.Now().Add(timeout)
+		timer := time.AfterFunc(timeout, a.stop)
+		defer timer.Stop()
+	}
+	go func() {
+		<-a.done
+		l.Close()
+	}()
+	if ready != nil {
+		close(ready)
+	}
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			select {
+			case <-a.done:
+				return nil
+			default:
+			}
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				continue
+			}
+			a.stop()
+			return err
+		}
+		go a.serve(conn)
+	}
+}
+
+type keyAgent struct {
+	mu      sync.Mutex
+	keys    map[string][]byte
+	expires time.Time
+	done    chan struct{}
+	once    sync.Once
+}
+
+// stop wipes all keys and shuts the agent down
+func (a *keyAgent) stop() {
+	a.once.Do(func() {
+		a.mu.Lock()
+		for id, key := range a.keys {
+			clear(key)
+			delete(a.keys, id)
+		}
+		a.mu.Unlock()
+		close(a.done)
+	})
+}
+
+func (a *keyAgent) serve(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	var req agentRequest
+	if err := json.NewDecoder(conn).Decode(&req); err != nil {
+		return
+	}
+
+	var resp agentResponse
+	a.mu.Lock()
+	switch req.Op {
+	case "get":
+		if key, ok := a.keys[req.ID]; ok {
+			resp.Key = key
+		}
+	case "put":
+		if req.ID == "" || len(req.Key) != 32 {
+			resp.Error = "invalid key"
+		} else {
+			a.keys[req.ID] = req.Key
+		}
+	case "status":
+	case "lock":
+		defer a.stop()
+	default:
+		resp.Error = fmt.Sprintf("unknown operation %q", req.Op)
+	}
+	resp.Keys = len(a.keys)
+	resp.Expires = a.expires
+	json.NewEncoder(conn).Encode(resp)
+	a.mu.Unlock()
+}
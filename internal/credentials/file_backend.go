@@ -1,25 +1,105 @@
 package credentials
 
 import (
+	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
 	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"sync"
 
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/term"
+
 	"github.com/vee-sh/veessh/internal/config"
 )
 
-// FileBackend provides password storage using an encrypted file
+// MasterPassphraseEnv supplies the file backend master passphrase
+// non-interactively (CI, scripts)
+const MasterPassphraseEnv = "VEESSH_MASTER_PASSPHRASE"
+
+// File format versions of passwords.enc
+const (
+	fileFormatV1 = 1 // nonce+ciphertext, key derived from the home directory
+	fileFormatV2 = 2 // JSON envelope, key derived from a master passphrase
+)
+
+// Argon2id parameters for new files (RFC 9106, second recommended option)
+const (
+	argonTime    = 3
+	argonMemory  = 64 * 1024 // KiB
+	argonThreads = 4
+	argonSaltLen = 16
+)
+
+// ErrWrongPassphrase is returned when the master passphrase does not decrypt
+// the password file
+var ErrWrongPassphrase = errors.New("incorrect master passphrase")
+
+// PassphrasePrompt asks for the master passphrase of the file backend.
+// Set it to nil while something else owns the terminal (e.g. the TUI);
+// the passphrase then has to come from the unlock agent or
+// VEESSH_MASTER_PASSPHRASE.
+var PassphrasePrompt = promptTerminal
+
+// fileEnvelope is the on-disk v2 format. The KDF parameters are stored so
+// they can be raised later without breaking existing files, and they are
+// authenticated as additional data so they cannot be tampered with.
+type fileEnvelope struct {
+	Version int    `json:"version"`
+	KDF     string `json:"kdf"`
+	Salt    []byte `json:"salt"`
+	Time    uint32 `json:"time"`
+	Memory  uint32 `json:"memory"`
+	Threads uint8  `json:"threads"`
+	Data    []byte `json:"data"` // nonce + AES-256-GCM ciphertext
+}
+
+func newEnvelope() (*fileEnvelope, error) {
+	salt := make([]byte, argonSaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+	return &fileEnvelope{
+		Version: fileFormatV2,
+		KDF:     "argon2id",
+		Salt:    salt,
+		Time:    argonTime,
+		Memory:  argonMemory,
+		Threads: argonThreads,
+	}, nil
+}
+
+// deriveKey turns the master passphrase into the 32-byte file key
+func (e *fileEnvelope) deriveKey(passphrase string) []byte {
+	return argon2.IDKey([]byte(passphrase), e.Salt, e.Time, e.Memory, e.Threads, 32)
+}
+
+// id identifies the file's key in the unlock agent
+func (e *fileEnvelope) id() string {
+	return hex.EncodeToString(e.Salt)
+}
+
+func (e *fileEnvelope) aad() []byte {
+	header := fmt.Sprintf("veessh-passwords:v%d:%s:%d:%d:%d:", e.Version, e.KDF, e.Time, e.Memory, e.Threads)
+	return append([]byte(header), e.Salt...)
+}
+
+// FileBackend provides secret storage using an encrypted file
 // This backend works on all platforms (Linux, Windows, macOS)
 type FileBackend struct {
 	filePath string
+	mu       sync.Mutex // Protects concurrent access to the password file
+
+	// Unlocked state, kept for the life of the process
+	envelope *fileEnvelope
 	key      []byte
-	mu       sync.RWMutex // Protects concurrent access to the password file
 }
 
 // NewFileBackend creates a new file-based backend
@@ -31,31 +111,21 @@ func NewFileBackend() (*FileBackend, error) {
 	cfgDir := filepath.Dir(cfgPath)
 	filePath := filepath.Join(cfgDir, "passwords.enc")
 
-	// Derive encryption key from user's home directory
-	// This ensures the key is unique per user but doesn't require a master password
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get home directory: %w", err)
-	}
-	key := deriveKey(home)
-
-	return &FileBackend{
-		filePath: filePath,
-		key:      key,
-	}, nil
+	return &FileBackend{filePath: filePath}, nil
 }
 
-// deriveKey creates a 32-byte key from the user's home directory
-func deriveKey(home string) []byte {
-	// Use a constant salt combined with home directory
-	// This ensures the key is deterministic per user
+// legacyKey is the v1 key: derived from the user's home directory with a
+// constant salt, so anyone who can read the file can decrypt it
+func legacyKey(home string) []byte {
 	salt := []byte("veessh-password-encryption-salt-v1")
 	data := append([]byte(home), salt...)
 	hash := sha256.Sum256(data)
 	return hash[:]
 }
 
-// loadPasswords loads and decrypts the password file
+// loadPasswords loads and decrypts the password file. A v1 file is upgraded
+// to v2 when a master passphrase is available; otherwise it stays readable
+// and the upgrade happens on the next write.
 func (f *FileBackend) loadPasswords() (map[string]string, error) {
 	data, err := os.ReadFile(f.filePath)
 	if err != nil {
@@ -65,10 +135,32 @@ func (f *FileBackend) loadPasswords() (map[string]string, error) {
 		return nil, fmt.Errorf("failed to read password file: %w", err)
 	}
 
-	// Decrypt
-	plaintext, err := f.decrypt(data)
-	if err != nil {
-		return nil, fmt.Errorf("failed to decrypt password file: %w", err)
+	var plaintext []byte
+	version := fileFormatV1
+	var env fileEnvelope
+	if json.Unmarshal(data, &env) == nil && env.Version != 0 {
+		version = env.Version
+	}
+	switch version {
+	case fileFormatV1:
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get home directory: %w", err)
+		}
+		plaintext, err = decrypt(legacyKey(home), data, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt password file: %w", err)
+		}
+	case fileFormatV2:
+		if env.KDF != "argon2id" {
+			return nil, fmt.Errorf("unsupported key derivation %q in password file", env.KDF)
+		}
+		plaintext, err = f.unlock(&env)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported password file version %d (written by a newer veessh?)", version)
 	}
 
 	// Parse JSON
@@ -81,11 +173,117 @@ func (f *FileBackend) loadPasswords() (map[string]string, error) {
 		passwords = make(map[string]string)
 	}
 
+	if version == fileFormatV1 && f.canSetMasterPassphrase() {
+		fmt.Fprintf(os.Stderr, "Upgrading %s to a master passphrase protected format\n", f.filePath)
+		if err := f.savePasswords(passwords); err != nil {
+			return nil, fmt.Errorf("failed to upgrade password file: %w", err)
+		}
+	}
+
 	return passwords, nil
 }
 
-// savePasswords encrypts and saves the password file
+// unlock decrypts a v2 envelope, getting the key from the process cache, the
+// unlock agent, VEESSH_MASTER_PASSPHRASE or a prompt, in that order
+func (f *FileBackend) unlock(env *fileEnvelope) ([]byte, error) {
+	if f.key != nil && f.envelope != nil && bytes.Equal(f.envelope.Salt, env.Salt) {
+		if plaintext, err := decrypt(f.key, env.Data, env.aad()); err == nil {
+			f.envelope = env
+			return plaintext, nil
+		}
+	}
+	if key, err := agentGetKey(env.id()); err == nil && key != nil {
+		if plaintext, err := decrypt(key, env.Data, env.aad()); err == nil {
+			f.envelope, f.key = env, key
+			return plaintext, nil
+		}
+	}
+
+	try := func(passphrase string) ([]byte, error) {
+		key := env.deriveKey(passphrase)
+		plaintext, err := decrypt(key, env.Data, env.aad())
+		if err != nil {
+			return nil, ErrWrongPassphrase
+		}
+		f.envelope, f.key = env, key
+		return plaintext, nil
+	}
+
+	if passphrase := os.Getenv(MasterPassphraseEnv); passphrase != "" {
+		plaintext, err := try(passphrase)
+		if err != nil {
+			return nil, fmt.Errorf("%w (from %s)", err, MasterPassphraseEnv)
+		}
+		return plaintext, nil
+	}
+	if PassphrasePrompt == nil {
+		return nil, fmt.Errorf("password file is locked: run 'veessh unlock' or set %s", MasterPassphraseEnv)
+	}
+	for attempt := 0; attempt < 3; attempt++ {
+		passphrase, err := PassphrasePrompt("Master passphrase: ")
+		if err != nil {
+			return nil, err
+		}
+		plaintext, err := try(passphrase)
+		if err == nil {
+			if agentRunning() {
+				_ = agentPutKey(env.id(), f.key)
+			}
+			return plaintext, nil
+		}
+		fmt.Fprintln(os.Stderr, "Incorrect master passphrase, try again.")
+	}
+	return nil, ErrWrongPassphrase
+}
+
+// canSetMasterPassphrase reports whether a new master passphrase can be
+// obtained without failing
+func (f *FileBackend) canSetMasterPassphrase() bool {
+	return f.key != nil || os.Getenv(MasterPassphraseEnv) != "" || (PassphrasePrompt != nil && term.IsTerminal(int(os.Stdin.Fd())))
+}
+
+// newMasterKey sets up a fresh envelope for a new or upgraded file
+func (f *FileBackend) newMasterKey() error {
+	env, err := newEnvelope()
+	if err != nil {
+		return err
+	}
+	passphrase := os.Getenv(MasterPassphraseEnv)
+	if passphrase == "" {
+		if PassphrasePrompt == nil {
+			return fmt.Errorf("the file backend needs a master passphrase: run 'veessh unlock' in a terminal or set %s", MasterPassphraseEnv)
+		}
+		fmt.Fprintf(os.Stderr, "Choose a master passphrase for %s\n", f.filePath)
+		passphrase, err = PassphrasePrompt("New master passphrase: ")
+		if err != nil {
+			return err
+		}
+		if passphrase == "" {
+			return fmt.Errorf("master passphrase cannot be empty")
+		}
+		confirm, err := PassphrasePrompt("Repeat master passphrase: ")
+		if err != nil {
+			return err
+		}
+		if confirm != passphrase {
+			return fmt.Errorf("master passphrases do not match")
+		}
+	}
+	f.envelope, f.key = env, env.deriveKey(passphrase)
+	if agentRunning() {
+		_ = agentPutKey(env.id(), f.key)
+	}
+	return nil
+}
+
+// savePasswords encrypts and saves the password file in the v2 format
 func (f *FileBackend) savePasswords(passwords map[string]string) error {
+	if f.key == nil {
+		if err := f.newMasterKey(); err != nil {
+			return err
+		}
+	}
+
 	// Serialize to JSON
 	data, err := json.Marshal(passwords)
 	if err != nil {
@@ -93,10 +291,15 @@ func (f *FileBackend) savePasswords(passwords map[string]string) error {
 	}
 
 	// Encrypt
-	encrypted, err := f.encrypt(data)
+	env := *f.envelope
+	env.Data, err = encrypt(f.key, data, env.aad())
 	if err != nil {
 		return fmt.Errorf("failed to encrypt passwords: %w", err)
 	}
+	out, err := json.MarshalIndent(env, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal password file: %w", err)
+	}
 
 	// Ensure directory exists
 	dir := filepath.Dir(f.filePath)
@@ -104,9 +307,22 @@ func (f *FileBackend) savePasswords(passwords map[string]string) error {
 		return fmt.Errorf("failed to create config directory: %w", err)
 	}
 
-	// Write to temp file first
-	tmpPath := f.filePath + ".tmp"
-	if err := os.WriteFile(tmpPath, encrypted, 0o600); err != nil {
+	// Write to a private temp file first
+	tmp, err := os.CreateTemp(dir, ".passwords-*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write password file: %w", err)
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+	if _, err := tmp.Write(out); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write password file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write password file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
 		return fmt.Errorf("failed to write password file: %w", err)
 	}
 
@@ -115,12 +331,32 @@ func (f *FileBackend) savePasswords(passwords map[string]string) error {
 		return fmt.Errorf("failed to rename password file: %w", err)
 	}
 
+	f.envelope = &env
 	return nil
 }
 
+// Unlock makes sure the file exists in the v2 format and is unlocked, and
+// returns the agent id and key so they can be cached
+func (f *FileBackend) Unlock() (id string, key []byte, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	passwords, err := f.loadPasswords()
+	if err != nil {
+		return "", nil, err
+	}
+	if f.key == nil {
+		// New file, or a v1 file that could not be upgraded while loading
+		if err := f.savePasswords(passwords); err != nil {
+			return "", nil, err
+		}
+	}
+	return f.envelope.id(), f.key, nil
+}
+
 // encrypt encrypts data using AES-256-GCM
-func (f *FileBackend) encrypt(plaintext []byte) ([]byte, error) {
-	block, err := aes.NewCipher(f.key)
+func encrypt(key, plaintext, aad []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
 	if err != nil {
 		return nil, err
 	}
@@ -135,13 +371,13 @@ func (f *FileBackend) encrypt(plaintext []byte) ([]byte, error) {
 		return nil, err
 	}
 
-	ciphertext := gcm.Seal(nonce, nonce, plaintext, nil)
+	ciphertext := gcm.Seal(nonce, nonce, plaintext, aad)
 	return ciphertext, nil
 }
 
 // decrypt decrypts data using AES-256-GCM
-func (f *FileBackend) decrypt(ciphertext []byte) ([]byte, error) {
-	block, err := aes.NewCipher(f.key)
+func decrypt(key, ciphertext, aad []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
 	if err != nil {
 		return nil, err
 	}
@@ -157,7 +393,7 @@ func (f *FileBackend) decrypt(ciphertext []byte) ([]byte, error) {
 	}
 
 	nonce, ciphertext := ciphertext[:nonceSize], ciphertext[nonceSize:]
-	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, aad)
 	if err != nil {
 		return nil, fmt.Errorf("decryption failed: %w", err)
 	}
@@ -165,8 +401,34 @@ func (f *FileBackend) decrypt(ciphertext []byte) ([]byte, error) {
 	return plaintext, nil
 }
 
-// SetPassword stores a password in the encrypted file
-func (f *FileBackend) SetPassword(profileName string, password string) error {
+// promptTerminal reads a passphrase from the controlling terminal without echo
+func promptTerminal(prompt string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", fmt.Errorf("password file is locked and there is no terminal to ask for the master passphrase (run 'veessh unlock' or set %s)", MasterPassphraseEnv)
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	b, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// fileKey is the map key of a profile secret. Passwords use the bare profile
+// name, as they always have, so existing files keep working. Other kinds
+// are set apart by a NUL, which profile names cannot contain: with ":" the
+// TOTP seed of "prod" would be the password of a profile "prod:totp".
+func fileKey(profileName string, kind SecretKind) string {
+	if kind == SecretPassword {
+		return profileName
+	}
+	return profileName + "\x00" + string(kind)
+}
+
+// SetSecret stores a secret in the encrypted file
+func (f *FileBackend) SetSecret(profileName string, kind SecretKind, value string) error {
 	if profileName == "" {
 		return fmt.Errorf("profile name required")
 	}
@@ -179,35 +441,61 @@ func (f *FileBackend) SetPassword(profileName string, password string) error {
 		return err
 	}
 
-	passwords[profileName] = password
+	passwords[fileKey(profileName, kind)] = value
 
 	return f.savePasswords(passwords)
 }
 
-// GetPassword retrieves a password from the encrypted file
-func (f *FileBackend) GetPassword(profileName string) (string, error) {
+// GetSecret retrieves a secret from the encrypted file
+func (f *FileBackend) GetSecret(profileName string, kind SecretKind) (string, error) {
 	if profileName == "" {
 		return "", fmt.Errorf("profile name required")
 	}
 
-	f.mu.RLock()
-	defer f.mu.RUnlock()
+	f.mu.Lock()
+	defer f.mu.Unlock()
 
 	passwords, err := f.loadPasswords()
 	if err != nil {
 		return "", err
 	}
 
-	password, ok := passwords[profileName]
-	if !ok {
-		return "", nil // Not found, return empty (consistent with other backends)
+	// Not found returns empty (consistent with other backends)
+	return passwords[fileKey(profileName, kind)], nil
+}
+
+// GetSecrets retrieves all secrets of a profile with a single decryption
+func (f *FileBackend) GetSecrets(profileName string) (Secrets, error) {
+	if profileName == "" {
+		return Secrets{}, fmt.Errorf("profile name required")
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	passwords, err := f.loadPasswords()
+	if err != nil {
+		return Secrets{}, err
+	}
+
+	var secrets Secrets
+	for _, kind := range SecretKinds {
+		secrets.Set(kind, passwords[fileKey(profileName, kind)])
 	}
+	return secrets, nil
+}
 
-	return password, nil
+// DeleteSecret removes a secret from the encrypted file
+func (f *FileBackend) DeleteSecret(profileName string, kind SecretKind) error {
+	return f.deleteKeys(profileName, kind)
 }
 
-// DeletePassword removes a password from the encrypted file
-func (f *FileBackend) DeletePassword(profileName string) error {
+// DeleteSecrets removes all secrets of a profile from the encrypted file
+func (f *FileBackend) DeleteSecrets(profileName string) error {
+	return f.deleteKeys(profileName, SecretKinds...)
+}
+
+func (f *FileBackend) deleteKeys(profileName string, kinds ...SecretKind) error {
 	if profileName == "" {
 		return fmt.Errorf("profile name required")
 	}
@@ -220,8 +508,9 @@ func (f *FileBackend) DeletePassword(profileName string) error {
 		return err
 	}
 
-	delete(passwords, profileName)
+	for _, kind := range kinds {
+		delete(passwords, fileKey(profileName, kind))
+	}
 
 	return f.savePasswords(passwords)
 }
-
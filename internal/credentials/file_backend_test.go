@@ -0,0 +1,209 @@
+package credentials
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// isolate points the file backend at a temp dir with no unlock agent and
+// no interactive prompt
+func isolate(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Setenv(AgentSocketEnv, filepath.Join(dir, "agent", "agent.sock"))
+	prompt := PassphrasePrompt
+	PassphrasePrompt = nil
+	t.Cleanup(func() { PassphrasePrompt = prompt })
+	return filepath.Join(dir, "passwords.enc")
+}
+
+func readEnvelope(t *testing.T, path string) fileEnvelope {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var env fileEnvelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		t.Fatalf("password file is not a v2 envelope: %v", err)
+	}
+	return env
+}
+
+func TestFileBackendMigratesV1(t *testing.T) {
+	path := isolate(t)
+	home, _ := os.UserHomeDir()
+
+	plaintext, _ := json.Marshal(map[string]string{"prod": "legacy-pw"})
+	v1, err := encrypt(legacyKey(home), plaintext, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, v1, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without a master passphrase the v1 file stays readable but not writable
+	f := &FileBackend{filePath: path}
+	if got, err := f.GetSecret("prod", SecretPassword); err != nil || got != "legacy-pw" {
+		t.Fatalf("GetSecret() on v1 file = %q, %v", got, err)
+	}
+	if err := f.SetSecret("dev", SecretPassword, "x"); err == nil {
+		t.Error("SetSecret() on v1 file without a master passphrase should fail")
+	}
+
+	t.Setenv(MasterPassphraseEnv, "correct horse")
+	f = &FileBackend{filePath: path}
+	if got, err := f.GetSecret("prod", SecretPassword); err != nil || got != "legacy-pw" {
+		t.Fatalf("GetSecret() during upgrade = %q, %v", got, err)
+	}
+	env := readEnvelope(t, path)
+	if env.Version != fileFormatV2 || env.KDF != "argon2id" || len(env.Salt) != argonSaltLen {
+		t.Errorf("upgraded envelope = %+v", env)
+	}
+
+	// A fresh process needs the passphrase again
+	f = &FileBackend{filePath: path}
+	if got, err := f.GetSecret("prod", SecretPassword); err != nil || got != "legacy-pw" {
+		t.Errorf("GetSecret() after upgrade = %q, %v", got, err)
+	}
+}
+
+func TestFileBackendWrongPassphrase(t *testing.T) {
+	path := isolate(t)
+	t.Setenv(MasterPassphraseEnv, "correct horse")
+	if err := (&FileBackend{filePath: path}).SetSecret("prod", SecretPassword, "pw"); err != nil {
+		t.Fatal(err)
+	}
+	first := readEnvelope(t, path)
+
+	t.Setenv(MasterPassphraseEnv, "battery staple")
+	if _, err := (&FileBackend{filePath: path}).GetSecret("prod", SecretPassword); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("GetSecret() with wrong passphrase error = %v, want ErrWrongPassphrase", err)
+	}
+
+	t.Setenv(MasterPassphraseEnv, "")
+	if _, err := (&FileBackend{filePath: path}).GetSecret("prod", SecretPassword); err == nil {
+		t.Error("GetSecret() without any passphrase source should fail")
+	}
+
+	// Every file gets its own salt
+	other := filepath.Join(filepath.Dir(path), "other.enc")
+	t.Setenv(MasterPassphraseEnv, "correct horse")
+	if err := (&FileBackend{filePath: other}).SetSecret("prod", SecretPassword, "pw"); err != nil {
+		t.Fatal(err)
+	}
+	if string(readEnvelope(t, other).Salt) == string(first.Salt) {
+		t.Error("two password files share a salt")
+	}
+}
+
+func TestUnlockAgent(t *testing.T) {
+	path := isolate(t)
+	t.Setenv(MasterPassphraseEnv, "correct horse")
+	if err := (&FileBackend{filePath: path}).SetSecret("prod", SecretPassword, "pw"); err != nil {
+		t.Fatal(err)
+	}
+
+	ready := make(chan struct{})
+	done := make(chan error, 1)
+	go func() { done <- RunAgent(time.Minute, ready) }()
+	select {
+	case <-ready:
+	case err := <-done:
+		t.Fatalf("RunAgent() error = %v", err)
+	}
+
+	id, key, err := (&FileBackend{filePath: path}).Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := agentPutKey(id, key); err != nil {
+		t.Fatal(err)
+	}
+	if running, keys, expires := AgentStatus(); !running || keys != 1 || expires.IsZero() {
+		t.Errorf("AgentStatus() = %v, %d, %v", running, keys, expires)
+	}
+
+	// With the key cached, no passphrase source is needed
+	t.Setenv(MasterPassphraseEnv, "")
+	if got, err := (&FileBackend{filePath: path}).GetSecret("prod", SecretPassword); err != nil || got != "pw" {
+		t.Errorf("GetSecret() via agent = %q, %v", got, err)
+	}
+
+	if err := LockAgent(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("RunAgent() after lock = %v", err)
+	}
+	if running, _, _ := AgentStatus(); running {
+		t.Error("agent still running after LockAgent()")
+	}
+	if _, err := (&FileBackend{filePath: path}).GetSecret("prod", SecretPassword); err == nil {
+		t.Error("GetSecret() after lock should need the passphrase again")
+	}
+}
+
+func TestAgentRefusesForeignSocket(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("socket directory modes are not enforced on Windows")
+	}
+	isolate(t)
+	sock := AgentSocketPath()
+	dir := filepath.Dir(sock)
+
+	setups := map[string]func() error{
+		// Pre-created by someone else in a shared /tmp
+		"foreign owner": func() error {
+			if os.Getuid() != 0 {
+				t.Skip("chown needs root")
+			}
+			return os.Chown(dir, 65534, 65534)
+		},
+		"shared dir": func() error { return os.Chmod(dir, 0o777) },
+	}
+	for name, setup := range setups {
+		t.Run(name, func(t *testing.T) {
+			os.RemoveAll(dir)
+			if err := os.Mkdir(dir, 0o700); err != nil {
+				t.Fatal(err)
+			}
+			l, err := net.Listen("unix", sock)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer l.Close()
+			os.Chmod(sock, 0o600)
+			if err := setup(); err != nil {
+				t.Fatal(err)
+			}
+			dialed := make(chan struct{}, 1)
+			go func() {
+				if conn, err := l.Accept(); err == nil {
+					dialed <- struct{}{}
+					conn.Close()
+				}
+			}()
+
+			if err := agentPutKey("id", make([]byte, 32)); err == nil {
+				t.Error("agentPutKey() sent the key to a socket of another user")
+			}
+			if running, _, _ := AgentStatus(); running {
+				t.Error("AgentStatus() trusts a socket of another user")
+			}
+			select {
+			case <-dialed:
+				t.Error("the socket was dialed")
+			case <-time.After(100 * time.Millisecond):
+			}
+		})
+	}
+}
@@ -26,11 +26,14 @@ var (
 	backendType    = BackendAuto
 )
 
-// Backend interface for credential storage
+// Backend interface for credential storage. Each profile can hold one
+// secret of every SecretKind; missing secrets read as empty strings.
 type Backend interface {
-	SetPassword(profileName string, password string) error
-	GetPassword(profileName string) (string, error)
-	DeletePassword(profileName string) error
+	SetSecret(profileName string, kind SecretKind, value string) error
+	GetSecret(profileName string, kind SecretKind) (string, error)
+	DeleteSecret(profileName string, kind SecretKind) error
+	// DeleteSecrets removes every secret stored for the profile
+	DeleteSecrets(profileName string) error
 }
 
 // SetBackendType configures which backend to use
@@ -119,7 +122,7 @@ func getBackend() (Backend, error) {
 	}
 }
 
-// KeyringBackend provides password storage using system keyring
+// KeyringBackend provides secret storage using system keyring
 type KeyringBackend struct{}
 
 // NewKeyringBackend creates a new keyring backend
@@ -131,7 +134,12 @@ func (k *KeyringBackend) openRing() (keyring.Keyring, error) {
 	return keyring.Open(keyring.Config{ServiceName: serviceName})
 }
 
-func (k *KeyringBackend) SetPassword(profileName string, password string) error {
+// keyringKey is the item key of a profile secret, e.g. "prod:password"
+func keyringKey(profileName string, kind SecretKind) string {
+	return profileName + ":" + string(kind)
+}
+
+func (k *KeyringBackend) SetSecret(profileName string, kind SecretKind, value string) error {
 	if profileName == "" {
 		return fmt.Errorf("profile name required")
 	}
@@ -139,15 +147,15 @@ func (k *KeyringBackend) SetPassword(profileName string, password string) error
 	if err != nil {
 		return err
 	}
-	return r.Set(keyring.Item{Key: profileName + ":password", Data: []byte(password)})
+	return r.Set(keyring.Item{Key: keyringKey(profileName, kind), Data: []byte(value)})
 }
 
-func (k *KeyringBackend) GetPassword(profileName string) (string, error) {
+func (k *KeyringBackend) GetSecret(profileName string, kind SecretKind) (string, error) {
 	r, err := k.openRing()
 	if err != nil {
 		return "", err
 	}
-	it, err := r.Get(profileName + ":password")
+	it, err := r.Get(keyringKey(profileName, kind))
 	if err != nil {
 		// keyring returns ErrKeyNotFound when key doesn't exist
 		if err == keyring.ErrKeyNotFound {
@@ -158,39 +166,84 @@ func (k *KeyringBackend) GetPassword(profileName string) (string, error) {
 	return string(it.Data), nil
 }
 
-func (k *KeyringBackend) DeletePassword(profileName string) error {
+func (k *KeyringBackend) DeleteSecret(profileName string, kind SecretKind) error {
 	r, err := k.openRing()
 	if err != nil {
 		return err
 	}
-	return r.Remove(profileName + ":password")
+	if err := r.Remove(keyringKey(profileName, kind)); err != nil && err != keyring.ErrKeyNotFound {
+		return err
+	}
+	return nil
+}
+
+func (k *KeyringBackend) DeleteSecrets(profileName string) error {
+	for _, kind := range SecretKinds {
+		if err := k.DeleteSecret(profileName, kind); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // SetPassword stores a password for a profile name.
 func SetPassword(profileName string, password string) error {
+	return SetSecret(profileName, SecretPassword, password)
+}
+
+// GetPassword retrieves a password for a profile name, empty string if missing.
+func GetPassword(profileName string) (string, error) {
+	return GetSecret(profileName, SecretPassword)
+}
+
+// DeletePassword removes the stored password for a profile.
+func DeletePassword(profileName string) error {
+	return DeleteSecret(profileName, SecretPassword)
+}
+
+// SetSecret stores a secret of the given kind for a profile name.
+func SetSecret(profileName string, kind SecretKind, value string) error {
 	backend, err := getBackend()
 	if err != nil {
 		return err
 	}
-	return backend.SetPassword(profileName, password)
+	return backend.SetSecret(profileName, kind, value)
 }
 
-// GetPassword retrieves a password for a profile name, empty string if missing.
-func GetPassword(profileName string) (string, error) {
+// GetSecret retrieves a secret of the given kind, empty string if missing.
+func GetSecret(profileName string, kind SecretKind) (string, error) {
 	backend, err := getBackend()
 	if err != nil {
 		return "", err
 	}
-	return backend.GetPassword(profileName)
+	return backend.GetSecret(profileName, kind)
 }
 
-// DeletePassword removes the stored password for a profile.
-func DeletePassword(profileName string) error {
+// DeleteSecret removes a single stored secret for a profile.
+func DeleteSecret(profileName string, kind SecretKind) error {
+	backend, err := getBackend()
+	if err != nil {
+		return err
+	}
+	return backend.DeleteSecret(profileName, kind)
+}
+
+// DeleteSecrets removes every stored secret for a profile.
+func DeleteSecrets(profileName string) error {
 	backend, err := getBackend()
 	if err != nil {
 		return err
 	}
-	return backend.DeletePassword(profileName)
+	return backend.DeleteSecrets(profileName)
+}
+
+// GetSecrets retrieves all stored secrets for a profile.
+func GetSecrets(profileName string) (Secrets, error) {
+	backend, err := getBackend()
+	if err != nil {
+		return Secrets{}, err
+	}
+	return LoadSecrets(backend, profileName)
 }
 
 // GetBackend returns the current backend instance (for migration/testing)
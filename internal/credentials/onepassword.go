@@ -2,12 +2,13 @@ package credentials
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os/exec"
 	"strings"
 )
 
-// OnePasswordBackend provides password storage using 1Password CLI
+// OnePasswordBackend provides secret storage using 1Password CLI
 type OnePasswordBackend struct {
 	vault string // Optional vault name
 }
@@ -33,9 +34,47 @@ func (op *OnePasswordBackend) IsAvailable() bool {
 	return len(strings.TrimSpace(string(output))) > 0
 }
 
-// SetPassword stores a password in 1Password
-// Uses item title: "veessh - profile-name"
-func (op *OnePasswordBackend) SetPassword(profileName string, password string) error {
+// opField returns the item field label and field type used for a secret
+// kind. Passwords use the item's built-in password field; TOTP seeds use a
+// one-time password field so 1Password can show codes too.
+func opField(kind SecretKind) (label, fieldType string) {
+	switch kind {
+	case SecretPassword:
+		return "password", ""
+	case SecretTOTPSeed:
+		return "totp", "otp"
+	default:
+		return string(kind), "password"
+	}
+}
+
+// opAssignment builds an `op item` field assignment for a secret
+func opAssignment(kind SecretKind, value string) string {
+	label, fieldType := opField(kind)
+	if fieldType == "" {
+		return fmt.Sprintf("%s=%s", label, value)
+	}
+	return fmt.Sprintf("%s[%s]=%s", label, fieldType, value)
+}
+
+// opNotFound reports whether an op error means the item or field is missing
+func opNotFound(err error, output []byte) bool {
+	msg := string(output)
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		msg += string(exitErr.Stderr)
+	}
+	for _, s := range []string{"isn't in", "isn't an item", "not found", "No item found", "no fields"} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetSecret stores a secret in 1Password
+// Uses item title: "veessh - profile-name", with one field per secret kind
+func (op *OnePasswordBackend) SetSecret(profileName string, kind SecretKind, value string) error {
 	if profileName == "" {
 		return fmt.Errorf("profile name required")
 	}
@@ -50,7 +89,7 @@ func (op *OnePasswordBackend) SetPassword(profileName string, password string) e
 		if op.vault != "" {
 			args = append(args, "--vault", op.vault)
 		}
-		args = append(args, fmt.Sprintf("password=%s", password))
+		args = append(args, opAssignment(kind, value))
 
 		cmd := exec.Command("op", args...)
 		output, err := cmd.CombinedOutput()
@@ -68,7 +107,7 @@ func (op *OnePasswordBackend) SetPassword(profileName string, password string) e
 	args = append(args,
 		"--category", "password",
 		"--title", itemTitle,
-		fmt.Sprintf("password=%s", password),
+		opAssignment(kind, value),
 		fmt.Sprintf("notesPlain=SSH connection profile: %s\n\nManaged by veessh", profileName),
 	)
 
@@ -81,14 +120,15 @@ func (op *OnePasswordBackend) SetPassword(profileName string, password string) e
 	return nil
 }
 
-// GetPassword retrieves a password from 1Password
-func (op *OnePasswordBackend) GetPassword(profileName string) (string, error) {
+// GetSecret retrieves a secret from 1Password
+func (op *OnePasswordBackend) GetSecret(profileName string, kind SecretKind) (string, error) {
 	if profileName == "" {
 		return "", fmt.Errorf("profile name required")
 	}
 
+	label, _ := opField(kind)
 	itemRef := op.getItemRef(profileName)
-	args := []string{"item", "get", itemRef, "--fields", "label=password", "--reveal"}
+	args := []string{"item", "get", itemRef, "--fields", "label=" + label, "--reveal"}
 	if op.vault != "" {
 		args = append(args, "--vault", op.vault)
 	}
@@ -96,7 +136,7 @@ func (op *OnePasswordBackend) GetPassword(profileName string) (string, error) {
 	cmd := exec.Command("op", args...)
 	output, err := cmd.Output()
 	if err != nil {
-		if strings.Contains(string(output), "isn't in") || strings.Contains(string(output), "not found") || strings.Contains(string(output), "No item found") {
+		if opNotFound(err, output) {
 			return "", nil // Not found, return empty (similar to keyring behavior)
 		}
 		return "", fmt.Errorf("1password CLI error: %w (output: %s)", err, string(output))
@@ -114,8 +154,80 @@ func (op *OnePasswordBackend) GetPassword(profileName string) (string, error) {
 	return strings.TrimSpace(result.Value), nil
 }
 
-// DeletePassword removes a password from 1Password
-func (op *OnePasswordBackend) DeletePassword(profileName string) error {
+// GetSecrets retrieves all secrets of a profile with a single op call
+func (op *OnePasswordBackend) GetSecrets(profileName string) (Secrets, error) {
+	if profileName == "" {
+		return Secrets{}, fmt.Errorf("profile name required")
+	}
+
+	itemRef := op.getItemRef(profileName)
+	args := []string{"item", "get", itemRef, "--reveal", "--format", "json"}
+	if op.vault != "" {
+		args = append(args, "--vault", op.vault)
+	}
+
+	cmd := exec.Command("op", args...)
+	output, err := cmd.Output()
+	if err != nil {
+		if opNotFound(err, output) {
+			return Secrets{}, nil
+		}
+		return Secrets{}, fmt.Errorf("1password CLI error: %w (output: %s)", err, string(output))
+	}
+
+	var item struct {
+		Fields []struct {
+			Label string `json:"label"`
+			Value string `json:"value"`
+		} `json:"fields"`
+	}
+	if err := json.Unmarshal(output, &item); err != nil {
+		return Secrets{}, fmt.Errorf("failed to parse 1password item: %w", err)
+	}
+
+	var secrets Secrets
+	for _, kind := range SecretKinds {
+		label, _ := opField(kind)
+		for _, f := range item.Fields {
+			if f.Label == label {
+				secrets.Set(kind, strings.TrimSpace(f.Value))
+				break
+			}
+		}
+	}
+	return secrets, nil
+}
+
+// DeleteSecret removes a single secret from the 1Password item
+func (op *OnePasswordBackend) DeleteSecret(profileName string, kind SecretKind) error {
+	if profileName == "" {
+		return fmt.Errorf("profile name required")
+	}
+	if !op.itemExists(profileName) {
+		return nil
+	}
+
+	// The built-in password field can't be removed, only cleared
+	assignment := opAssignment(kind, "")
+	if label, fieldType := opField(kind); fieldType != "" {
+		assignment = label + "[delete]"
+	}
+
+	args := []string{"item", "edit", op.getItemRef(profileName)}
+	if op.vault != "" {
+		args = append(args, "--vault", op.vault)
+	}
+	args = append(args, assignment)
+
+	cmd := exec.Command("op", args...)
+	if output, err := cmd.CombinedOutput(); err != nil && !opNotFound(err, output) {
+		return fmt.Errorf("1password CLI error: %w (output: %s)", err, string(output))
+	}
+	return nil
+}
+
+// DeleteSecrets removes the profile's item from 1Password
+func (op *OnePasswordBackend) DeleteSecrets(profileName string) error {
 	if profileName == "" {
 		return fmt.Errorf("profile name required")
 	}
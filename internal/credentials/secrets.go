@@ -0,0 +1,157 @@
+package credentials
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SecretKind identifies one of the secrets a profile can hold
+type SecretKind string
+
+const (
+	SecretPassword      SecretKind = "password"   // Login password
+	SecretKeyPassphrase SecretKind = "passphrase" // Private key passphrase
+	SecretTOTPSeed      SecretKind = "totp"       // TOTP seed (base32 secret or otpauth:// URI)
+	SecretSudoPassword  SecretKind = "sudo"       // sudo password on the remote host
+)
+
+// SecretKinds lists every secret kind in display order
+var SecretKinds = []SecretKind{SecretPassword, SecretKeyPassphrase, SecretTOTPSeed, SecretSudoPassword}
+
+// ParseSecretKind converts user input into a SecretKind
+func ParseSecretKind(s string) (SecretKind, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "password", "pass":
+		return SecretPassword, nil
+	case "passphrase", "key-passphrase":
+		return SecretKeyPassphrase, nil
+	case "totp", "otp", "totp-seed":
+		return SecretTOTPSeed, nil
+	case "sudo", "sudo-password":
+		return SecretSudoPassword, nil
+	}
+	return "", fmt.Errorf("unknown secret type %q (must be: password, passphrase, totp, or sudo)", s)
+}
+
+// Description returns a human readable name for the kind
+func (k SecretKind) Description() string {
+	switch k {
+	case SecretPassword:
+		return "login password"
+	case SecretKeyPassphrase:
+		return "private key passphrase"
+	case SecretTOTPSeed:
+		return "TOTP seed"
+	case SecretSudoPassword:
+		return "sudo password"
+	}
+	return string(k)
+}
+
+// Secrets holds all stored secrets of a profile; missing ones are empty
+type Secrets struct {
+	Password      string
+	KeyPassphrase string
+	TOTPSeed      string
+	SudoPassword  string
+}
+
+// Get returns the secret of the given kind
+func (s Secrets) Get(kind SecretKind) string {
+	switch kind {
+	case SecretPassword:
+		return s.Password
+	case SecretKeyPassphrase:
+		return s.KeyPassphrase
+	case SecretTOTPSeed:
+		return s.TOTPSeed
+	case SecretSudoPassword:
+		return s.SudoPassword
+	}
+	return ""
+}
+
+// Set stores the secret of the given kind
+func (s *Secrets) Set(kind SecretKind, value string) {
+	switch kind {
+	case SecretPassword:
+		s.Password = value
+	case SecretKeyPassphrase:
+		s.KeyPassphrase = value
+	case SecretTOTPSeed:
+		s.TOTPSeed = value
+	case SecretSudoPassword:
+		s.SudoPassword = value
+	}
+}
+
+// AnswerPrompt picks the stored secret that answers an SSH authentication
+// prompt of the connection to target (user@host as OpenSSH names it): a
+// fresh TOTP code for one-time password questions, the key passphrase for
+// passphrase prompts and the password for password prompts. Prompts naming
+// another host, such as those of ProxyJump hosts, are refused, and so are
+// password prompts naming no host at all.
+func (s Secrets) AnswerPrompt(prompt, target string) (string, error) {
+	named := promptTarget(prompt)
+	if named != "" && named != target {
+		return "", fmt.Errorf("prompt is for %s, not %s", named, target)
+	}
+	lower := strings.ToLower(prompt)
+	switch {
+	case IsOTPPrompt(prompt):
+		if s.TOTPSeed == "" {
+			return "", errors.New("no TOTP seed stored")
+		}
+		return TOTPCode(s.TOTPSeed, time.Now())
+	case strings.Contains(lower, "passphrase"):
+		if s.KeyPassphrase == "" {
+			return "", errors.New("no key passphrase stored")
+		}
+		return s.KeyPassphrase, nil
+	case strings.Contains(lower, "password"):
+		if named == "" {
+			return "", errors.New("password prompt does not name its host")
+		}
+		if s.Password == "" {
+			return "", errors.New("no password stored")
+		}
+		return s.Password, nil
+	}
+	return "", fmt.Errorf("no stored secret answers %q", strings.TrimSpace(prompt))
+}
+
+// promptTarget returns the user@host an OpenSSH prompt is for, or "".
+// Password prompts read "user@host's password: " and keyboard-interactive
+// ones start with "(user@host) " (OpenSSH 8.5+).
+func promptTarget(prompt string) string {
+	if rest, ok := strings.CutPrefix(prompt, "("); ok {
+		if target, _, ok := strings.Cut(rest, ") "); ok {
+			return target
+		}
+	}
+	if target, _, ok := strings.Cut(prompt, "'s password"); ok {
+		return target
+	}
+	return ""
+}
+
+// LoadSecrets reads every secret kind of a profile from a specific backend
+// (used by GetSecrets and migration)
+func LoadSecrets(b Backend, profileName string) (Secrets, error) {
+	if bulk, ok := b.(interface {
+		GetSecrets(profileName string) (Secrets, error)
+	}); ok {
+		return bulk.GetSecrets(profileName)
+	}
+	var s Secrets
+	for _, kind := range SecretKinds {
+		v, err := b.GetSecret(profileName, kind)
+		if err != nil {
+			return Secrets{}, err
+		}
+		s.Set(kind, v)
+	}
+	return s, nil
+}
@@ -0,0 +1,125 @@
+package credentials
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestParseSecretKind(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    SecretKind
+		wantErr bool
+	}{
+		{"password", SecretPassword, false},
+		{"Passphrase", SecretKeyPassphrase, false},
+		{"otp", SecretTOTPSeed, false},
+		{"sudo-password", SecretSudoPassword, false},
+		{"pin", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseSecretKind(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseSecretKind(%q) = %q, %v; want %q, wantErr %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestSecretsAnswerPrompt(t *testing.T) {
+	s := Secrets{Password: "pw", KeyPassphrase: "pp", TOTPSeed: "JBSWY3DPEHPK3PXP"}
+	const target = "alice@host"
+
+	if got, err := s.AnswerPrompt("alice@host's password: ", target); err != nil || got != "pw" {
+		t.Errorf("password prompt answered %q, %v", got, err)
+	}
+	if got, err := s.AnswerPrompt("(alice@host) Password: ", target); err != nil || got != "pw" {
+		t.Errorf("keyboard-interactive password prompt answered %q, %v", got, err)
+	}
+	if got, err := s.AnswerPrompt("Enter passphrase for key '/k': ", target); err != nil || got != "pp" {
+		t.Errorf("passphrase prompt answered %q, %v", got, err)
+	}
+	if got, err := s.AnswerPrompt("Verification code: ", target); err != nil || len(got) != 6 {
+		t.Errorf("OTP prompt answered %q, %v", got, err)
+	}
+	if _, err := s.AnswerPrompt("Are you sure you want to continue connecting (yes/no)? ", target); err == nil {
+		t.Error("host key confirmation should not be answered")
+	}
+	if _, err := (Secrets{}).AnswerPrompt("Verification code: ", target); err == nil {
+		t.Error("OTP prompt should not be answered without a seed")
+	}
+
+	// ProxyJump hosts ask through the same helper
+	for _, prompt := range []string{"bob@jump's password: ", "alice@jump's password: ", "(alice@jump) Verification code: "} {
+		if got, err := s.AnswerPrompt(prompt, target); err == nil {
+			t.Errorf("%q answered %q for %s", prompt, got, target)
+		}
+	}
+	if got, err := s.AnswerPrompt("Password: ", target); err == nil {
+		t.Errorf("password prompt naming no host answered %q", got)
+	}
+	if got, err := s.AnswerPrompt("alice@host's password: ", ""); err == nil {
+		t.Errorf("password prompt answered %q without a target", got)
+	}
+}
+
+func TestFileBackendSecrets(t *testing.T) {
+	t.Setenv(MasterPassphraseEnv, "correct horse")
+	t.Setenv(AgentSocketEnv, filepath.Join(t.TempDir(), "none.sock"))
+	f := &FileBackend{filePath: filepath.Join(t.TempDir(), "passwords.enc")}
+
+	for _, kind := range SecretKinds {
+		if err := f.SetSecret("prod", kind, "v-"+string(kind)); err != nil {
+			t.Fatalf("SetSecret(%s) error = %v", kind, err)
+		}
+	}
+	if err := f.SetSecret("dev", SecretPassword, "devpw"); err != nil {
+		t.Fatal(err)
+	}
+
+	secrets, err := f.GetSecrets("prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, kind := range SecretKinds {
+		if got := secrets.Get(kind); got != "v-"+string(kind) {
+			t.Errorf("GetSecrets().Get(%s) = %q", kind, got)
+		}
+	}
+
+	// Passwords keep the bare profile key used by older versions
+	passwords, err := f.loadPasswords()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if passwords["prod"] != "v-password" {
+		t.Errorf("password stored under %v, want bare profile key", passwords)
+	}
+
+	if err := f.DeleteSecret("prod", SecretTOTPSeed); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := f.GetSecret("prod", SecretTOTPSeed); got != "" {
+		t.Errorf("TOTP seed after DeleteSecret = %q", got)
+	}
+	if got, _ := f.GetSecret("prod", SecretPassword); got != "v-password" {
+		t.Errorf("password after deleting TOTP seed = %q", got)
+	}
+
+	if err := f.DeleteSecrets("prod"); err != nil {
+		t.Fatal(err)
+	}
+	if secrets, _ := f.GetSecrets("prod"); secrets != (Secrets{}) {
+		t.Errorf("secrets after DeleteSecrets = %+v", secrets)
+	}
+	if got, _ := f.GetSecret("dev", SecretPassword); got != "devpw" {
+		t.Errorf("other profile's password = %q", got)
+	}
+
+	// The password of "dev:totp" is not the TOTP seed of "dev"
+	if err := f.SetSecret("dev:totp", SecretPassword, "otherpw"); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := f.GetSecret("dev", SecretTOTPSeed); got != "" {
+		t.Errorf("TOTP seed of dev = %q, want none", got)
+	}
+}
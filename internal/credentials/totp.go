@@ -0,0 +1,107 @@
+package credentials
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// totpParams are the RFC 6238 parameters of a TOTP seed
+type totpParams struct {
+	key    []byte
+	digits int
+	period int64
+	hash   func() hash.Hash
+}
+
+// TOTPCode returns the RFC 6238 one-time password for seed at time t.
+// The seed is either a base32 secret or an otpauth://totp/ URI, whose
+// digits, period and algorithm parameters are honoured.
+func TOTPCode(seed string, t time.Time) (string, error) {
+	p, err := parseTOTPSeed(seed)
+	if err != nil {
+		return "", err
+	}
+
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(t.Unix()/p.period))
+	mac := hmac.New(p.hash, p.key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	// Dynamic truncation (RFC 4226 section 5.3)
+	offset := sum[len(sum)-1] & 0x0f
+	value := uint64(binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff)
+	mod := uint64(1)
+	for i := 0; i < p.digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", p.digits, value%mod), nil
+}
+
+func parseTOTPSeed(seed string) (totpParams, error) {
+	p := totpParams{digits: 6, period: 30, hash: sha1.New}
+	secret := strings.TrimSpace(seed)
+
+	if strings.HasPrefix(strings.ToLower(secret), "otpauth://") {
+		u, err := url.Parse(secret)
+		if err != nil {
+			return p, fmt.Errorf("invalid TOTP URI: %w", err)
+		}
+		q := u.Query()
+		secret = q.Get("secret")
+		if d := q.Get("digits"); d != "" {
+			n, err := strconv.Atoi(d)
+			if err != nil || n < 6 || n > 10 {
+				return p, fmt.Errorf("invalid TOTP digits %q", d)
+			}
+			p.digits = n
+		}
+		if s := q.Get("period"); s != "" {
+			n, err := strconv.ParseInt(s, 10, 64)
+			if err != nil || n <= 0 {
+				return p, fmt.Errorf("invalid TOTP period %q", s)
+			}
+			p.period = n
+		}
+		switch alg := strings.ToUpper(q.Get("algorithm")); alg {
+		case "", "SHA1":
+		case "SHA256":
+			p.hash = sha256.New
+		case "SHA512":
+			p.hash = sha512.New
+		default:
+			return p, fmt.Errorf("unsupported TOTP algorithm %q", alg)
+		}
+	}
+
+	// Authenticator apps show seeds grouped, lowercased and unpadded
+	secret = strings.ToUpper(strings.NewReplacer(" ", "", "-", "", "=", "").Replace(secret))
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil || len(key) == 0 {
+		return p, fmt.Errorf("invalid TOTP seed: expected a base32 secret or otpauth:// URI")
+	}
+	p.key = key
+	return p, nil
+}
+
+// IsOTPPrompt reports whether an authentication prompt asks for a one-time
+// code rather than a password
+func IsOTPPrompt(prompt string) bool {
+	p := strings.ToLower(prompt)
+	for _, hint := range []string{"verification code", "one-time", "one time", "otp", "token code", "authenticator", "2fa", "two-factor", "mfa"} {
+		if strings.Contains(p, hint) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,83 @@
+package credentials
+
+import (
+	"encoding/base32"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestTOTPCodeRFC6238(t *testing.T) {
+	enc := base32.StdEncoding.WithPadding(base32.NoPadding)
+	sha1Seed := enc.EncodeToString([]byte("12345678901234567890"))
+	sha256Seed := enc.EncodeToString([]byte("12345678901234567890123456789012"))
+	sha512Seed := enc.EncodeToString([]byte("1234567890123456789012345678901234567890123456789012345678901234"))
+	uri := func(secret, alg string) string {
+		return "otpauth://totp/test?digits=8&algorithm=" + alg + "&secret=" + url.QueryEscape(secret)
+	}
+
+	// Test vectors from RFC 6238 appendix B
+	tests := []struct {
+		seed string
+		unix int64
+		want string
+	}{
+		{uri(sha1Seed, "SHA1"), 59, "94287082"},
+		{uri(sha256Seed, "SHA256"), 59, "46119246"},
+		{uri(sha512Seed, "SHA512"), 59, "90693936"},
+		{uri(sha1Seed, "SHA1"), 1111111109, "07081804"},
+		{uri(sha256Seed, "SHA256"), 1111111111, "67062674"},
+		{uri(sha512Seed, "SHA512"), 1234567890, "93441116"},
+		{uri(sha1Seed, "SHA1"), 2000000000, "69279037"},
+		// Plain base32 seeds default to 6 digits
+		{sha1Seed, 59, "287082"},
+	}
+
+	for _, tt := range tests {
+		got, err := TOTPCode(tt.seed, time.Unix(tt.unix, 0))
+		if err != nil {
+			t.Fatalf("TOTPCode(%q, %d) error = %v", tt.seed, tt.unix, err)
+		}
+		if got != tt.want {
+			t.Errorf("TOTPCode(%q, %d) = %q, want %q", tt.seed, tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestTOTPCodeSeedFormats(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	want, err := TOTPCode("JBSWY3DPEHPK3PXP", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, seed := range []string{"jbsw y3dp ehpk 3pxp", "JBSWY3DPEHPK3PXP====", "otpauth://totp/x?secret=JBSWY3DPEHPK3PXP"} {
+		got, err := TOTPCode(seed, now)
+		if err != nil || got != want {
+			t.Errorf("TOTPCode(%q) = %q, %v; want %q", seed, got, err, want)
+		}
+	}
+
+	for _, seed := range []string{"", "not base32!", "otpauth://totp/x?secret=JBSWY3DP&algorithm=MD5", "otpauth://totp/x?secret=JBSWY3DP&digits=4"} {
+		if _, err := TOTPCode(seed, now); err == nil {
+			t.Errorf("TOTPCode(%q) should fail", seed)
+		}
+	}
+}
+
+func TestIsOTPPrompt(t *testing.T) {
+	tests := []struct {
+		prompt string
+		want   bool
+	}{
+		{"Verification code: ", true},
+		{"One-time password (OATH) for `alice': ", true},
+		{"Enter your OTP: ", true},
+		{"alice@host's password: ", false},
+		{"Enter passphrase for key '/home/alice/.ssh/id_ed25519': ", false},
+	}
+	for _, tt := range tests {
+		if got := IsOTPPrompt(tt.prompt); got != tt.want {
+			t.Errorf("IsOTPPrompt(%q) = %v, want %v", tt.prompt, got, tt.want)
+		}
+	}
+}
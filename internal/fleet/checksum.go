@@ -0,0 +1,123 @@
+package fleet
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Manifest maps the files under a copied path to their SHA-256. Keys are
+// slash-separated and start with "./"; a single file is ".".
+type Manifest map[string]string
+
+// LocalManifest hashes the file or directory tree at root
+func LocalManifest(root string) (Manifest, error) {
+	m := Manifest{}
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		sum, err := hashFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if rel != "." {
+			rel = "./" + filepath.ToSlash(rel)
+		}
+		m[rel] = sum
+		return nil
+	})
+	return m, err
+}
+
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// ManifestScript returns a shell script that prints the manifest of path on
+// a remote host, in the format of sha256sum, for ParseManifest. quotedPath
+// must already be quoted for the shell.
+func ManifestScript(quotedPath string) string {
+	return `p=` + quotedPath + `; ` +
+		`if command -v sha256sum >/dev/null 2>&1; then h=sha256sum; else h="shasum -a 256"; fi; ` +
+		`if [ -d "$p" ]; then cd "$p" && find . -type f -exec $h {} +; ` +
+		`else $h < "$p" | sed 's/ .*$/  ./'; fi`
+}
+
+// ParseManifest reads sha256sum output
+func ParseManifest(r io.Reader) (Manifest, error) {
+	m := Manifest{}
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := sc.Text()
+		if line == "" {
+			continue
+		}
+		sum, name, ok := strings.Cut(line, "  ")
+		if !ok {
+			// Binary mode marker
+			sum, name, ok = strings.Cut(line, " *")
+		}
+		if !ok || len(sum) != sha256.Size*2 {
+			return nil, fmt.Errorf("unexpected checksum line: %q", line)
+		}
+		m[name] = sum
+	}
+	return m, sc.Err()
+}
+
+// Verify checks that every file of m has the same checksum in other. Files
+// only in other, such as ones already in a destination directory, are fine.
+func (m Manifest) Verify(other Manifest) error {
+	var missing, differ []string
+	for name, sum := range m {
+		got, ok := other[name]
+		switch {
+		case !ok:
+			missing = append(missing, name)
+		case got != sum:
+			differ = append(differ, name)
+		}
+	}
+	if len(missing) == 0 && len(differ) == 0 {
+		return nil
+	}
+	sort.Strings(missing)
+	sort.Strings(differ)
+	var parts []string
+	if len(differ) > 0 {
+		parts = append(parts, fmt.Sprintf("%d differ (%s)", len(differ), listSome(differ)))
+	}
+	if len(missing) > 0 {
+		parts = append(parts, fmt.Sprintf("%d missing (%s)", len(missing), listSome(missing)))
+	}
+	return fmt.Errorf("checksum mismatch: %d of %d files: %s", len(missing)+len(differ), len(m), strings.Join(parts, ", "))
+}
+
+func listSome(names []string) string {
+	const limit = 3
+	if len(names) <= limit {
+		return strings.Join(names, ", ")
+	}
+	return strings.Join(names[:limit], ", ") + ", ..."
+}
@@ -0,0 +1,67 @@
+package fleet
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestLocalManifest(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "conf.d"), 0o755)
+	os.WriteFile(filepath.Join(dir, "a.conf"), []byte("a\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "conf.d", "b.conf"), []byte("b\n"), 0o644)
+
+	m, err := LocalManifest(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// sha256 of "a\n"
+	if len(m) != 2 || m["./a.conf"] != "87428fc522803d31065e7bce3cf03fe475096631e5e07bbd7a0fde60c4cf25c7" || m["./conf.d/b.conf"] == "" {
+		t.Errorf("LocalManifest(dir) = %v", m)
+	}
+
+	single, err := LocalManifest(filepath.Join(dir, "a.conf"))
+	if err != nil || len(single) != 1 || single["."] != m["./a.conf"] {
+		t.Errorf("LocalManifest(file) = %v, %v", single, err)
+	}
+}
+
+func TestManifestScript(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "sub"), 0o755)
+	os.WriteFile(filepath.Join(dir, "a"), []byte("a\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "sub", "b"), []byte("b\n"), 0o644)
+
+	for _, path := range []string{dir, filepath.Join(dir, "a")} {
+		out, err := exec.Command("sh", "-c", ManifestScript("'"+path+"'")).Output()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := ParseManifest(strings.NewReader(string(out)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		local, _ := LocalManifest(path)
+		if err := local.Verify(got); err != nil || len(got) != len(local) {
+			t.Errorf("%s: remote manifest %v, want %v (%v)", path, got, local, err)
+		}
+	}
+}
+
+func TestManifestVerify(t *testing.T) {
+	src := Manifest{"./a": "1", "./b": "2", "./c": "3"}
+	if err := src.Verify(Manifest{"./a": "1", "./b": "2", "./c": "3", "./extra": "4"}); err != nil {
+		t.Errorf("Verify(same plus extra) = %v", err)
+	}
+	err := src.Verify(Manifest{"./a": "1", "./b": "x"})
+	if err == nil || !strings.Contains(err.Error(), "2 of 3 files") || !strings.Contains(err.Error(), "1 differ (./b)") || !strings.Contains(err.Error(), "1 missing (./c)") {
+		t.Errorf("Verify(changed) = %v", err)
+	}
+}
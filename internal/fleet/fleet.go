@@ -0,0 +1,148 @@
+// Package fleet runs a command on many profiles at once with a bounded
+// number of workers, for 'veessh run' across tags and groups.
+package fleet
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/vee-sh/veessh/internal/config"
+)
+
+// Result statuses
+const (
+	StatusOK      = "ok"
+	StatusFailed  = "failed"  // The command exited non-zero
+	StatusTimeout = "timeout" // The host did not finish within the timeout
+	StatusError   = "error"   // The command could not be run
+	StatusSkipped = "skipped" // Not started after a failure with FailFast
+)
+
+// Result is the outcome of the command on one profile
+type Result struct {
+	Profile  string        `json:"profile"`
+	Host     string        `json:"host"`
+	Status   string        `json:"status"`
+	ExitCode int           `json:"exitCode"` // -1 when the command did not exit by itself
+	Duration time.Duration `json:"-"`
+	Stdout   string        `json:"stdout"`
+	Stderr   string        `json:"stderr"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// MarshalJSON writes the duration like the audit log does, as "1.5s"
+func (r Result) MarshalJSON() ([]byte, error) {
+	type result Result
+	return json.Marshal(struct {
+		result
+		Duration string `json:"duration"`
+	}{result(r), r.Duration.Round(time.Millisecond).String()})
+}
+
+// OK reports whether the command succeeded
+func (r Result) OK() bool { return r.Status == StatusOK }
+
+// Func runs the command on p, writing its output to stdout and stderr
+type Func func(ctx context.Context, p config.Profile, stdout, stderr io.Writer) error
+
+// Options controls a fleet run
+type Options struct {
+	Parallel int           // Profiles at a time; 1 when zero
+	Timeout  time.Duration // Per profile; none when zero
+	FailFast bool          // Start no more profiles after the first failure
+
+	// Output, when set, also receives the output of p as it is produced
+	Output func(p config.Profile) (stdout, stderr io.Writer)
+	// Done, when set, is called as each profile finishes, one at a time
+	Done func(r Result)
+}
+
+// Run runs fn on every profile and returns the results in the order of
+// profiles
+func Run(ctx context.Context, profiles []config.Profile, opts Options, fn Func) []Result {
+	parallel := max(opts.Parallel, 1)
+	results := make([]Result, len(profiles))
+	jobs := make(chan int)
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex // Serializes Done
+		failed bool
+	)
+	for range min(parallel, len(profiles)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				mu.Lock()
+				stop := opts.FailFast && failed
+				mu.Unlock()
+				if stop || ctx.Err() != nil {
+					results[i] = Result{Profile: profiles[i].Name, Host: profiles[i].Host, Status: StatusSkipped, ExitCode: -1}
+					continue
+				}
+				r := runOne(ctx, profiles[i], opts, fn)
+				results[i] = r
+				mu.Lock()
+				if !r.OK() {
+					failed = true
+				}
+				if opts.Done != nil {
+					opts.Done(r)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for i := range profiles {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
+func runOne(ctx context.Context, p config.Profile, opts Options, fn Func) Result {
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	var stdout, stderr bytes.Buffer
+	var outW, errW io.Writer = &stdout, &stderr
+	if opts.Output != nil {
+		o, e := opts.Output(p)
+		outW, errW = io.MultiWriter(&stdout, o), io.MultiWriter(&stderr, e)
+	}
+
+	started := time.Now()
+	err := fn(ctx, p, outW, errW)
+	r := Result{
+		Profile:  p.Name,
+		Host:     p.Host,
+		Status:   StatusOK,
+		Duration: time.Since(started),
+		Stdout:   stdout.String(),
+		Stderr:   stderr.String(),
+	}
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		r.Status, r.ExitCode = StatusTimeout, -1
+		r.Error = fmt.Sprintf("timed out after %s", opts.Timeout)
+	case errors.As(err, &exitErr) && exitErr.ExitCode() >= 0:
+		r.Status, r.ExitCode = StatusFailed, exitErr.ExitCode()
+	default:
+		r.Status, r.ExitCode = StatusError, -1
+		r.Error = err.Error()
+	}
+	return r
+}
@@ -0,0 +1,114 @@
+package fleet
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/vee-sh/veessh/internal/config"
+)
+
+func profiles(n int) []config.Profile {
+	out := make([]config.Profile, n)
+	for i := range out {
+		out[i] = config.Profile{Name: fmt.Sprintf("web%d", i+1), Host: fmt.Sprintf("web%d.example.com", i+1)}
+	}
+	return out
+}
+
+func TestRunBoundsParallelism(t *testing.T) {
+	var running, peak atomic.Int32
+	results := Run(context.Background(), profiles(10), Options{Parallel: 3}, func(ctx context.Context, p config.Profile, stdout, stderr io.Writer) error {
+		n := running.Add(1)
+		for {
+			old := peak.Load()
+			if n <= old || peak.CompareAndSwap(old, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		running.Add(-1)
+		fmt.Fprintf(stdout, "hello from %s\n", p.Name)
+		return nil
+	})
+
+	if peak.Load() > 3 {
+		t.Errorf("%d profiles ran at once, want at most 3", peak.Load())
+	}
+	for i, r := range results {
+		want := fmt.Sprintf("web%d", i+1)
+		if r.Profile != want || !r.OK() || r.Stdout != "hello from "+want+"\n" {
+			t.Errorf("results[%d] = %+v", i, r)
+		}
+	}
+}
+
+func TestRunStatuses(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	fn := func(ctx context.Context, p config.Profile, stdout, stderr io.Writer) error {
+		switch p.Name {
+		case "web1":
+			return exec.CommandContext(ctx, "sh", "-c", "exit 3").Run()
+		case "web2":
+			<-ctx.Done()
+			return ctx.Err()
+		case "web3":
+			return errors.New("no route to host")
+		}
+		return nil
+	}
+	results := Run(context.Background(), profiles(4), Options{Parallel: 4, Timeout: 50 * time.Millisecond}, fn)
+
+	want := []struct {
+		status string
+		code   int
+	}{{StatusFailed, 3}, {StatusTimeout, -1}, {StatusError, -1}, {StatusOK, 0}}
+	for i, w := range want {
+		if results[i].Status != w.status || results[i].ExitCode != w.code {
+			t.Errorf("%s: status %s, exit code %d; want %s, %d", results[i].Profile, results[i].Status, results[i].ExitCode, w.status, w.code)
+		}
+	}
+}
+
+func TestRunFailFast(t *testing.T) {
+	var started atomic.Int32
+	results := Run(context.Background(), profiles(5), Options{Parallel: 1, FailFast: true}, func(ctx context.Context, p config.Profile, stdout, stderr io.Writer) error {
+		started.Add(1)
+		if p.Name == "web2" {
+			return errors.New("boom")
+		}
+		return nil
+	})
+
+	if started.Load() != 2 {
+		t.Errorf("%d profiles started, want 2: none after the failure", started.Load())
+	}
+	if results[4].Status != StatusSkipped {
+		t.Errorf("last profile: status %s, want %s", results[4].Status, StatusSkipped)
+	}
+}
+
+func TestPrefixWriter(t *testing.T) {
+	var out bytes.Buffer
+	var mu sync.Mutex
+	w := NewPrefixWriter(&out, &mu, "web1 | ")
+	fmt.Fprint(w, "one\ntw")
+	fmt.Fprint(w, "o\nthree")
+	if got := out.String(); got != "web1 | one\nweb1 | two\n" {
+		t.Errorf("before Flush: %q", got)
+	}
+	w.Flush()
+	if got := out.String(); got != "web1 | one\nweb1 | two\nweb1 | three\n" {
+		t.Errorf("after Flush: %q", got)
+	}
+}
@@ -0,0 +1,57 @@
+package fleet
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// PrefixWriter writes whole lines to w with a prefix, so that the output
+// of profiles running at the same time does not interleave mid-line.
+// Writers sharing w must share mu.
+type PrefixWriter struct {
+	w      io.Writer
+	mu     *sync.Mutex
+	prefix []byte
+	buf    []byte // Partial last line
+}
+
+// NewPrefixWriter returns a PrefixWriter writing to w under mu
+func NewPrefixWriter(w io.Writer, mu *sync.Mutex, prefix string) *PrefixWriter {
+	return &PrefixWriter{w: w, mu: mu, prefix: []byte(prefix)}
+}
+
+func (pw *PrefixWriter) Write(p []byte) (int, error) {
+	pw.buf = append(pw.buf, p...)
+	end := bytes.LastIndexByte(pw.buf, '\n')
+	if end < 0 {
+		return len(p), nil
+	}
+	if err := pw.writeLines(pw.buf[:end+1]); err != nil {
+		return 0, err
+	}
+	pw.buf = append(pw.buf[:0], pw.buf[end+1:]...)
+	return len(p), nil
+}
+
+// Flush writes a last line that did not end in a newline
+func (pw *PrefixWriter) Flush() error {
+	if len(pw.buf) == 0 {
+		return nil
+	}
+	err := pw.writeLines(append(pw.buf, '\n'))
+	pw.buf = pw.buf[:0]
+	return err
+}
+
+func (pw *PrefixWriter) writeLines(lines []byte) error {
+	var out bytes.Buffer
+	for line := range bytes.Lines(lines) {
+		out.Write(pw.prefix)
+		out.Write(line)
+	}
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+	_, err := pw.w.Write(out.Bytes())
+	return err
+}
@@ -0,0 +1,144 @@
+package hostkeys
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/vee-sh/veessh/internal/util"
+)
+
+// A pin is enforced by the connection it guards rather than checked on a
+// connection of its own first, which a host could pass before presenting
+// another key to the real one. The pin rides in the context the connection
+// is made with: OpenSSH is given a known_hosts file holding only the keys
+// pinned, and the native client checks them in its host key callback.
+
+type contextKey struct{}
+
+// enforced is a pin to enforce and the ssh options that do so
+type enforced struct {
+	pin  Pin
+	args []string
+}
+
+// NewContext returns ctx carrying pin, for the connections made with it to
+// accept only the keys of pin valid at now. It writes the known_hosts file
+// for OpenSSH, which leaves out keys pinned without their public key.
+func NewContext(ctx context.Context, pin Pin, now time.Time) (context.Context, error) {
+	dir := filepath.Join(util.RuntimeDir(), "known_hosts")
+	if err := util.PrivateDir(util.RuntimeDir()); err != nil {
+		return nil, err
+	}
+	if err := util.PrivateDir(dir); err != nil {
+		return nil, err
+	}
+
+	alias := pin.alias()
+	var lines strings.Builder
+	for _, k := range pin.Keys {
+		if !k.Valid(now) {
+			continue
+		}
+		key, err := k.Public()
+		if err != nil {
+			return nil, err
+		}
+		if key != nil {
+			lines.WriteString(alias + " " + string(ssh.MarshalAuthorizedKey(key)))
+		}
+	}
+	path := filepath.Join(dir, alias)
+	if err := util.WriteFileAtomic(path, []byte(lines.String()), 0o600); err != nil {
+		return nil, fmt.Errorf("failed to write the pinned keys for ssh: %w", err)
+	}
+
+	args := []string{
+		"-o", "UserKnownHostsFile=" + quoteOption(path),
+		"-o", "GlobalKnownHostsFile=none",
+		"-o", "StrictHostKeyChecking=yes",
+		"-o", "HostKeyAlias=" + alias,
+		"-o", "CheckHostIP=no",
+		"-o", "UpdateHostKeys=no",
+	}
+	return context.WithValue(ctx, contextKey{}, &enforced{pin: pin, args: args}), nil
+}
+
+// FromContext returns the pin ctx carries, or nil
+func FromContext(ctx context.Context) *Pin {
+	if e, ok := ctx.Value(contextKey{}).(*enforced); ok {
+		return &e.pin
+	}
+	return nil
+}
+
+// SSHArgs returns the ssh options enforcing the pin ctx carries, or nil.
+// ssh takes the first value given for an option, so they go before any
+// the user adds.
+func SSHArgs(ctx context.Context) []string {
+	if e, ok := ctx.Value(contextKey{}).(*enforced); ok {
+		return e.args
+	}
+	return nil
+}
+
+// alias is the name p's keys are listed under in its known_hosts file,
+// the same for every connection p applies to
+func (p Pin) alias() string {
+	sum := sha256.Sum256([]byte(p.Profile + "\x00" + trimBrackets(p.Host) + "\x00" + strconv.Itoa(p.Port)))
+	return "veessh-pin-" + hex.EncodeToString(sum[:8])
+}
+
+// quoteOption quotes an ssh option value holding spaces
+func quoteOption(s string) string {
+	if !strings.ContainsAny(s, " \t\"") {
+		return s
+	}
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
+
+// MismatchError is a host presenting a key its pin does not accept
+type MismatchError struct {
+	Addr        string
+	Type        string
+	Fingerprint string
+	Pinned      []string // Fingerprints pinned for Type
+}
+
+func (e *MismatchError) Error() string {
+	if len(e.Pinned) == 0 {
+		return fmt.Sprintf("the %s key of %s is %s, and no %s key is pinned", e.Type, e.Addr, e.Fingerprint, e.Type)
+	}
+	return fmt.Sprintf("the %s key of %s is %s, not the pinned %s", e.Type, e.Addr, e.Fingerprint, strings.Join(e.Pinned, " or "))
+}
+
+// Mismatch describes key failing to match p at now
+func (p Pin) Mismatch(key ssh.PublicKey, now time.Time) *MismatchError {
+	e := &MismatchError{Addr: p.Address(), Type: key.Type(), Fingerprint: Fingerprint(key)}
+	for _, k := range p.Keys {
+		if k.Type == key.Type() && k.Valid(now) {
+			e.Pinned = append(e.Pinned, k.Fingerprint)
+		}
+	}
+	return e
+}
+
+// Callback returns a host key callback accepting only the keys of p valid
+// at now, and the host key algorithms to ask for them
+func (p Pin) Callback(now time.Time) (ssh.HostKeyCallback, []string) {
+	callback := func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		if p.Accepts(key.Type(), Fingerprint(key), now) {
+			return nil
+		}
+		return p.Mismatch(key, now)
+	}
+	return callback, algorithmsFor(p.Types(now)...)
+}
@@ -0,0 +1,110 @@
+package hostkeys
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func TestPinCallback(t *testing.T) {
+	_, keys := startKeyServer(t)
+	now := time.Now()
+	ed, rsaKey := keys[ssh.KeyAlgoED25519], keys[ssh.KeyAlgoRSA]
+	pin := Pin{Host: "127.0.0.1", Port: 22, Keys: []Key{KeyOf(ed, now)}}
+
+	callback, algorithms := pin.Callback(now)
+	if len(algorithms) != 1 || algorithms[0] != ssh.KeyAlgoED25519 {
+		t.Errorf("algorithms = %v, want the pinned type only", algorithms)
+	}
+	if err := callback("127.0.0.1:22", nil, ed); err != nil {
+		t.Errorf("pinned key refused: %v", err)
+	}
+	var mismatch *MismatchError
+	if err := callback("127.0.0.1:22", nil, rsaKey); !errors.As(err, &mismatch) || mismatch.Fingerprint != Fingerprint(rsaKey) {
+		t.Errorf("other key: error = %v, want a mismatch", err)
+	}
+
+	pin.Keys[0].ValidUntil = now
+	callback, _ = pin.Callback(now)
+	if err := callback("127.0.0.1:22", nil, ed); err == nil {
+		t.Error("expired key accepted")
+	}
+}
+
+func TestKeyPublic(t *testing.T) {
+	_, keys := startKeyServer(t)
+	k := KeyOf(keys[ssh.KeyAlgoED25519], time.Now())
+	if key, err := k.Public(); err != nil || Fingerprint(key) != k.Fingerprint {
+		t.Errorf("Public() = %v, %v", key, err)
+	}
+	if key, err := (Key{Type: k.Type, Fingerprint: k.Fingerprint}).Public(); key != nil || err != nil {
+		t.Errorf("Public() of a fingerprint = %v, %v, want nothing", key, err)
+	}
+	// A public key edited in that the fingerprint does not vouch for
+	k.PublicKey = KeyOf(keys[ssh.KeyAlgoRSA], time.Now()).PublicKey
+	if _, err := k.Public(); err == nil {
+		t.Error("Public() accepted a key not matching its fingerprint")
+	}
+}
+
+func TestNewContext(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	if SSHArgs(context.Background()) != nil || FromContext(context.Background()) != nil {
+		t.Fatal("a plain context carries a pin")
+	}
+	port, keys := startKeyServer(t)
+	now := time.Now()
+	pinned := Pin{Host: "127.0.0.1", Port: port, Keys: []Key{KeyOf(keys[ssh.KeyAlgoED25519], now)}}
+	ctx, err := NewContext(context.Background(), pinned, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pin := FromContext(ctx); pin == nil || pin.Port != port {
+		t.Fatalf("FromContext() = %v", pin)
+	}
+
+	if _, err := exec.LookPath("ssh"); err != nil {
+		t.Skip("ssh not installed")
+	}
+	// ssh gets past the host key to logging in, which the server denies
+	if out := runSSH(t, ctx, port); !strings.Contains(out, "Permission denied") {
+		t.Errorf("ssh with the pinned key: %s", out)
+	}
+
+	_, other := startKeyServer(t)
+	stale := Pin{Host: "127.0.0.1", Port: port, Keys: []Key{KeyOf(other[ssh.KeyAlgoED25519], now)}}
+	if ctx, err = NewContext(context.Background(), stale, now); err != nil {
+		t.Fatal(err)
+	}
+	if out := runSSH(t, ctx, port); !strings.Contains(out, "Host key verification failed") {
+		t.Errorf("ssh with another key pinned: %s", out)
+	}
+
+	// Keys pinned by fingerprint alone are not enough for ssh
+	fingerprintOnly := Pin{Host: "127.0.0.1", Port: port, Keys: []Key{{Type: ssh.KeyAlgoED25519, Fingerprint: Fingerprint(keys[ssh.KeyAlgoED25519])}}}
+	if ctx, err = NewContext(context.Background(), fingerprintOnly, now); err != nil {
+		t.Fatal(err)
+	}
+	if out := runSSH(t, ctx, port); !strings.Contains(out, "Host key verification failed") {
+		t.Errorf("ssh with a fingerprint pinned: %s", out)
+	}
+}
+
+// runSSH runs ssh to port on localhost with the options of ctx and returns
+// what it says
+func runSSH(t *testing.T, ctx context.Context, port int) string {
+	t.Helper()
+	args := append([]string{"-F", "none", "-o", "BatchMode=yes", "-p", strconv.Itoa(port)}, SSHArgs(ctx)...)
+	args = append(args, "probe@127.0.0.1", "true")
+	out, err := exec.Command("ssh", args...).CombinedOutput()
+	if err == nil {
+		t.Fatal("ssh logged in")
+	}
+	return string(out)
+}
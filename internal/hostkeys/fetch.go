@@ -0,0 +1,174 @@
+package hostkeys
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// fetchTimeout bounds fetching a host key
+const fetchTimeout = 10 * time.Second
+
+// errGotKey ends the handshake once the host key is in
+var errGotKey = errors.New("got host key")
+
+// Fetch returns the type and fingerprint of the key a host presents, as
+// FetchKey fetches it
+func Fetch(ctx context.Context, host string, port int, proxyJump string, keyTypes ...string) (string, string, error) {
+	key, err := FetchKey(ctx, host, port, proxyJump, keyTypes...)
+	if err != nil {
+		return "", "", err
+	}
+	return key.Type(), Fingerprint(key), nil
+}
+
+// FetchKey returns the key a host presents. keyTypes ask the host for a key of one of those types, for hosts with
+// several; none takes the host's choice. With a ProxyJump spec
+// ([user@]host[:port],...) the host is reached through the jump hosts by
+// ssh -W, which runs in batch mode and so cannot prompt for passwords.
+func FetchKey(ctx context.Context, host string, port int, proxyJump string, keyTypes ...string) (ssh.PublicKey, error) {
+	ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
+
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
+	var conn net.Conn
+	var err error
+	if proxyJump != "" {
+		conn, err = jumpConn(ctx, host, port, proxyJump)
+	} else {
+		var d net.Dialer
+		conn, err = d.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get host key: %w", err)
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	var hostKey ssh.PublicKey
+	config := &ssh.ClientConfig{
+		User:              "probe",
+		HostKeyAlgorithms: algorithmsFor(keyTypes...),
+		HostKeyCallback: func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			hostKey = key
+			return errGotKey
+		},
+	}
+	// The callback fails the handshake either way
+	if _, _, _, err := ssh.NewClientConn(conn, addr, config); hostKey == nil {
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		// ssh says best why the jump failed
+		if c, ok := conn.(*cmdConn); ok {
+			c.Close()
+			if msg := strings.TrimSpace(c.stderr.String()); msg != "" {
+				err = errors.New(msg)
+			}
+		}
+		return nil, fmt.Errorf("failed to get host key: %w", err)
+	}
+	return hostKey, nil
+}
+
+// Fingerprint returns the SHA-256 fingerprint of key as pins record it
+func Fingerprint(key ssh.PublicKey) string {
+	hash := sha256.Sum256(key.Marshal())
+	return "SHA256:" + base64.StdEncoding.EncodeToString(hash[:])
+}
+
+// SameFingerprint reports whether two SHA-256 fingerprints are the same,
+// with or without base64 padding (ssh-keygen leaves it out)
+func SameFingerprint(a, b string) bool {
+	return strings.TrimRight(a, "=") == strings.TrimRight(b, "=")
+}
+
+// algorithmsFor returns the host key algorithms that yield a key of one
+// of keyTypes, or nil for any
+func algorithmsFor(keyTypes ...string) []string {
+	var algos []string
+	for _, keyType := range keyTypes {
+		switch keyType {
+		case "":
+		case ssh.KeyAlgoRSA:
+			algos = append(algos, ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA)
+		case ssh.CertAlgoRSAv01:
+			algos = append(algos, ssh.CertAlgoRSASHA512v01, ssh.CertAlgoRSASHA256v01, ssh.CertAlgoRSAv01)
+		default:
+			algos = append(algos, keyType)
+		}
+	}
+	return algos
+}
+
+// jumpConn connects to host:port through the jump hosts of a ProxyJump
+// spec, with ssh -W on the last one
+func jumpConn(ctx context.Context, host string, port int, proxyJump string) (net.Conn, error) {
+	hops := strings.Split(proxyJump, ",")
+	args := []string{"-o", "BatchMode=yes", "-W", net.JoinHostPort(host, strconv.Itoa(port))}
+	if len(hops) > 1 {
+		args = append(args, "-J", strings.Join(hops[:len(hops)-1], ","))
+	}
+	args = append(args, "ssh://"+strings.TrimSpace(hops[len(hops)-1]))
+
+	cmd := exec.CommandContext(ctx, "ssh", args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &cmdConn{cmd: cmd, r: stdout, w: stdin, stderr: &stderr}, nil
+}
+
+// cmdConn is a connection over the stdin and stdout of a command
+type cmdConn struct {
+	cmd    *exec.Cmd
+	r      io.Reader
+	w      io.WriteCloser
+	stderr *bytes.Buffer // Read once closed
+	once   sync.Once
+}
+
+func (c *cmdConn) Read(b []byte) (int, error)  { return c.r.Read(b) }
+func (c *cmdConn) Write(b []byte) (int, error) { return c.w.Write(b) }
+
+func (c *cmdConn) Close() error {
+	c.once.Do(func() {
+		c.w.Close()
+		c.cmd.Process.Kill()
+		c.cmd.Wait()
+	})
+	return nil
+}
+
+func (c *cmdConn) LocalAddr() net.Addr                { return cmdAddr{} }
+func (c *cmdConn) RemoteAddr() net.Addr               { return cmdAddr{} }
+func (c *cmdConn) SetDeadline(t time.Time) error      { return nil }
+func (c *cmdConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *cmdConn) SetWriteDeadline(t time.Time) error { return nil }
+
+type cmdAddr struct{}
+
+func (cmdAddr) Network() string { return "ssh" }
+func (cmdAddr) String() string  { return "ssh -W" }
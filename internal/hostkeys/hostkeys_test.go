@@ -1,9 +1,17 @@
 package hostkeys
 
 import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"net"
 	"os"
 	"path/filepath"
 	"testing"
+
+	"golang.org/x/crypto/ssh"
 )
 
 func TestPinnedKeysPath(t *testing.T) {
@@ -32,14 +40,15 @@ func TestKnownHostsPath(t *testing.T) {
 	}
 }
 
-func TestLoadPinnedKeysEmpty(t *testing.T) {
-	// Should not error when file doesn't exist
-	// We can't easily test this without mocking the path
-	keys, err := LoadPinnedKeys()
-	if err != nil && !os.IsNotExist(err) {
-		// Only fail if it's not a "file not found" type error
-		// and there's actually an error
-		t.Logf("LoadPinnedKeys() returned: keys=%v, err=%v", keys, err)
+func TestLoadStoreEmpty(t *testing.T) {
+	// Should not error when the file doesn't exist
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	s, err := LoadStore()
+	if err != nil {
+		t.Fatalf("LoadStore() error = %v", err)
+	}
+	if len(s.Pins) != 0 {
+		t.Errorf("LoadStore() = %d pins, want none", len(s.Pins))
 	}
 }
 
@@ -93,3 +102,71 @@ func TestIsHostInKnownHostsNonExistent(t *testing.T) {
 	_ = path
 }
 
+
+// startKeyServer starts an SSH server that presents an Ed25519 and an RSA
+// host key and lets no one in
+func startKeyServer(t *testing.T) (port int, keys map[string]ssh.PublicKey) {
+	t.Helper()
+	cfg := &ssh.ServerConfig{
+		PasswordCallback: func(ssh.ConnMetadata, []byte) (*ssh.Permissions, error) {
+			return nil, errors.New("denied")
+		},
+	}
+	keys = map[string]ssh.PublicKey{}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range []any{edKey, rsaKey} {
+		signer, err := ssh.NewSignerFromKey(k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg.AddHostKey(signer)
+		keys[signer.PublicKey().Type()] = signer.PublicKey()
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			nc, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				ssh.NewServerConn(nc, cfg)
+				nc.Close()
+			}()
+		}
+	}()
+	return l.Addr().(*net.TCPAddr).Port, keys
+}
+
+func TestFetch(t *testing.T) {
+	port, keys := startKeyServer(t)
+	for _, keyType := range []string{ssh.KeyAlgoED25519, ssh.KeyAlgoRSA} {
+		gotType, fingerprint, err := Fetch(context.Background(), "127.0.0.1", port, "", keyType)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if gotType != keyType || fingerprint != Fingerprint(keys[keyType]) {
+			t.Errorf("Fetch(%s) = %s %s, want %s", keyType, gotType, fingerprint, Fingerprint(keys[keyType]))
+		}
+		// ssh-keygen -l prints fingerprints without padding
+		if !SameFingerprint(fingerprint, ssh.FingerprintSHA256(keys[keyType])) {
+			t.Errorf("%s and %s differ", fingerprint, ssh.FingerprintSHA256(keys[keyType]))
+		}
+	}
+
+	if _, _, err := Fetch(context.Background(), "127.0.0.1", port, "", ssh.KeyAlgoECDSA256); err == nil {
+		t.Error("Fetch of a key type the server does not have succeeded")
+	}
+}
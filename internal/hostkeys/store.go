@@ -0,0 +1,419 @@
+package hostkeys
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"gopkg.in/yaml.v3"
+
+	"github.com/vee-sh/veessh/internal/util"
+)
+
+// Pins are kept in pinned_keys.yaml next to the config. A pin holds the
+// keys a host may present, one or more per key type. It belongs to a
+// profile when pinned by profile name, and then applies to that profile
+// only; pins by host:port apply to every profile connecting there that
+// has none of its own.
+//
+// Planned rotation: the replacement key is pinned ahead of time as the
+// next key, accepted alongside the current one, which can be given a
+// validUntil time after which it is no longer accepted. Once the host
+// presents the new key, finishing the rotation drops the old one.
+//
+// OpenSSH checks hosts against whole public keys, not fingerprints, so
+// keys are kept with their public key. Keys pinned by fingerprint alone
+// get it the first time the host presents a key with that fingerprint.
+//
+// Pins used to be lines of "host:port keyType fingerprint [comment]" in
+// pinned_keys.txt. That file is read while pinned_keys.yaml does not
+// exist, and removed once it is written.
+
+// storeVersion is the format of pinned_keys.yaml
+const storeVersion = 1
+
+// Key is a pinned host key
+type Key struct {
+	Type        string    `yaml:"type" json:"type"`
+	Fingerprint string    `yaml:"fingerprint" json:"fingerprint"` // SHA256:base64
+	PinnedAt    time.Time `yaml:"pinnedAt" json:"pinnedAt"`
+	ValidUntil  time.Time `yaml:"validUntil,omitempty" json:"validUntil,omitzero"` // Not accepted from then on
+	Next        bool      `yaml:"next,omitempty" json:"next,omitempty"`            // Announced replacement of the current key of its type
+	Comment     string    `yaml:"comment,omitempty" json:"comment,omitempty"`
+	PublicKey   string    `yaml:"publicKey,omitempty" json:"publicKey,omitempty"` // authorized_keys format, for OpenSSH to check the host against
+}
+
+// Valid reports whether k is accepted at now
+func (k Key) Valid(now time.Time) bool {
+	return k.ValidUntil.IsZero() || now.Before(k.ValidUntil)
+}
+
+// Public returns the public key of k, or nil if only its fingerprint is
+// known. A public key that does not match the fingerprint is an error.
+func (k Key) Public() (ssh.PublicKey, error) {
+	if k.PublicKey == "" {
+		return nil, nil
+	}
+	key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(k.PublicKey))
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key pinned as %s: %w", k.Fingerprint, err)
+	}
+	if key.Type() != k.Type || !SameFingerprint(Fingerprint(key), k.Fingerprint) {
+		return nil, fmt.Errorf("the public key pinned as %s is %s %s", k.Fingerprint, key.Type(), Fingerprint(key))
+	}
+	return key, nil
+}
+
+// KeyOf returns key as a pinned key, pinned at now
+func KeyOf(key ssh.PublicKey, now time.Time) Key {
+	return Key{
+		Type:        key.Type(),
+		Fingerprint: Fingerprint(key),
+		PinnedAt:    now,
+		PublicKey:   strings.TrimSpace(string(ssh.MarshalAuthorizedKey(key))),
+	}
+}
+
+// Pin is the keys pinned for a host, for one profile or for all
+type Pin struct {
+	Profile string `yaml:"profile,omitempty" json:"profile,omitempty"` // Empty for every profile connecting to host:port
+	Host    string `yaml:"host" json:"host"`
+	Port    int    `yaml:"port" json:"port"`
+	Keys    []Key  `yaml:"keys" json:"keys"`
+}
+
+// Address returns host:port, with IPv6 hosts in brackets
+func (p Pin) Address() string {
+	return net.JoinHostPort(p.Host, strconv.Itoa(p.Port))
+}
+
+// Accepts reports whether a presented key of keyType with fingerprint
+// matches a key valid at now
+func (p Pin) Accepts(keyType, fingerprint string, now time.Time) bool {
+	return slices.ContainsFunc(p.Keys, func(k Key) bool {
+		return k.Type == keyType && k.Valid(now) && SameFingerprint(k.Fingerprint, fingerprint)
+	})
+}
+
+// Types returns the key types with keys valid at now, to ask the host for
+func (p Pin) Types(now time.Time) []string {
+	var types []string
+	for _, k := range p.Keys {
+		if k.Valid(now) && !slices.Contains(types, k.Type) {
+			types = append(types, k.Type)
+		}
+	}
+	return types
+}
+
+// Verify fetches the key the host presents, of one of the types pinned,
+// and reports whether p accepts it
+func (p Pin) Verify(ctx context.Context, proxyJump string, now time.Time) (keyType, fingerprint string, ok bool, err error) {
+	types := p.Types(now)
+	if len(types) == 0 {
+		return "", "", false, fmt.Errorf("every key pinned for %s has expired", p.Address())
+	}
+	keyType, fingerprint, err = Fetch(ctx, p.Host, p.Port, proxyJump, types...)
+	if err != nil {
+		return "", "", false, err
+	}
+	return keyType, fingerprint, p.Accepts(keyType, fingerprint, now), nil
+}
+
+// Store holds the pins
+type Store struct {
+	Version int   `yaml:"version"`
+	Pins    []Pin `yaml:"pins"`
+}
+
+// PinnedKeysPath returns the path to veessh's pinned keys file
+func PinnedKeysPath() (string, error) {
+	cfgHome := os.Getenv("XDG_CONFIG_HOME")
+	if cfgHome == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		cfgHome = filepath.Join(home, ".config")
+	}
+	return filepath.Join(cfgHome, "veessh", "pinned_keys.yaml"), nil
+}
+
+// legacyPath returns the pinned_keys.txt next to path
+func legacyPath(path string) string {
+	return filepath.Join(filepath.Dir(path), "pinned_keys.txt")
+}
+
+// LoadStore reads the pins
+func LoadStore() (*Store, error) {
+	path, err := PinnedKeysPath()
+	if err != nil {
+		return nil, err
+	}
+	return loadStore(path)
+}
+
+func loadStore(path string) (*Store, error) {
+	s := &Store{Version: storeVersion}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, readLegacy(legacyPath(path), s)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if s.Version > storeVersion {
+		return nil, fmt.Errorf("%s was written by a newer veessh (version %d)", path, s.Version)
+	}
+	return s, nil
+}
+
+// UpdateStore changes the pins with fn and saves them, locked against
+// other veessh processes meanwhile
+func UpdateStore(fn func(s *Store) error) error {
+	path, err := PinnedKeysPath()
+	if err != nil {
+		return err
+	}
+	lock, err := util.Lock(path, true)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	s, err := loadStore(path)
+	if err != nil {
+		return err
+	}
+	if err := fn(s); err != nil {
+		return err
+	}
+	s.Version = storeVersion
+	data, err := yaml.Marshal(s)
+	if err != nil {
+		return err
+	}
+	if err := util.WriteFileAtomic(path, data, 0o600); err != nil {
+		return err
+	}
+	// Its pins are in the new file now
+	if err := os.Remove(legacyPath(path)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// Find returns the pin that applies to profile connecting to host:port:
+// the profile's own, else the host's. profile may be empty.
+func (s *Store) Find(profile, host string, port int) *Pin {
+	var shared *Pin
+	for i := range s.Pins {
+		p := &s.Pins[i]
+		if p.Port != port || !sameHost(p.Host, host) {
+			continue
+		}
+		switch p.Profile {
+		case profile:
+			return p
+		case "":
+			shared = p
+		}
+	}
+	return shared
+}
+
+// exact returns the pin of exactly profile and host:port, or nil
+func (s *Store) exact(profile, host string, port int) *Pin {
+	for i := range s.Pins {
+		if p := &s.Pins[i]; p.Profile == profile && p.Port == port && sameHost(p.Host, host) {
+			return p
+		}
+	}
+	return nil
+}
+
+// pin returns the pin of exactly profile and host:port, adding it if needed
+func (s *Store) pin(profile, host string, port int) *Pin {
+	if p := s.exact(profile, host, port); p != nil {
+		return p
+	}
+	s.Pins = append(s.Pins, Pin{Profile: profile, Host: trimBrackets(host), Port: port})
+	return &s.Pins[len(s.Pins)-1]
+}
+
+// Pin pins key for profile (empty for all) at host:port, replacing the
+// keys of its type
+func (s *Store) Pin(profile, host string, port int, key Key) {
+	p := s.pin(profile, host, port)
+	p.Keys = slices.DeleteFunc(p.Keys, func(k Key) bool { return k.Type == key.Type })
+	p.Keys = append(p.Keys, key)
+}
+
+// Unpin removes the keys of keyType, or all keys when keyType is empty,
+// from the pin of exactly profile and host:port, and the pin once it has
+// none. It returns how many keys it removed.
+func (s *Store) Unpin(profile, host string, port int, keyType string) int {
+	p := s.exact(profile, host, port)
+	if p == nil {
+		return 0
+	}
+	n := len(p.Keys)
+	p.Keys = slices.DeleteFunc(p.Keys, func(k Key) bool { return keyType == "" || k.Type == keyType })
+	removed := n - len(p.Keys)
+	if len(p.Keys) == 0 {
+		s.Pins = slices.DeleteFunc(s.Pins, func(q Pin) bool { return len(q.Keys) == 0 })
+	}
+	return removed
+}
+
+// PlanRotation pins next as the coming key of its type at the pin of
+// exactly profile and host:port. The current keys of the type stay valid
+// until validUntil, or until the rotation is finished when it is zero.
+func (s *Store) PlanRotation(profile, host string, port int, next Key, validUntil time.Time) error {
+	p := s.exact(profile, host, port)
+	if p == nil {
+		return fmt.Errorf("no key is pinned for %s; pin one first", net.JoinHostPort(trimBrackets(host), strconv.Itoa(port)))
+	}
+	if !slices.ContainsFunc(p.Keys, func(k Key) bool { return k.Type == next.Type && !k.Next }) {
+		return fmt.Errorf("no %s key is pinned for %s to rotate from", next.Type, p.Address())
+	}
+	if slices.ContainsFunc(p.Keys, func(k Key) bool { return !k.Next && SameFingerprint(k.Fingerprint, next.Fingerprint) }) {
+		return fmt.Errorf("%s is already the pinned %s key of %s", next.Fingerprint, next.Type, p.Address())
+	}
+	for i := range p.Keys {
+		if p.Keys[i].Type == next.Type && !p.Keys[i].Next {
+			p.Keys[i].ValidUntil = validUntil
+		}
+	}
+	p.Keys = slices.DeleteFunc(p.Keys, func(k Key) bool { return k.Type == next.Type && k.Next })
+	next.Next = true
+	p.Keys = append(p.Keys, next)
+	return nil
+}
+
+// FinishRotation makes the next key of keyType the current one at the pin
+// of exactly profile and host:port, dropping the keys it replaces, and
+// returns it
+func (s *Store) FinishRotation(profile, host string, port int, keyType string) (Key, error) {
+	p := s.exact(profile, host, port)
+	if p == nil {
+		return Key{}, fmt.Errorf("no key is pinned for %s", net.JoinHostPort(trimBrackets(host), strconv.Itoa(port)))
+	}
+	i := slices.IndexFunc(p.Keys, func(k Key) bool { return k.Type == keyType && k.Next })
+	if i < 0 {
+		return Key{}, fmt.Errorf("no rotation of the %s key of %s is planned", keyType, p.Address())
+	}
+	next := p.Keys[i]
+	next.Next = false
+	s.Pin(profile, p.Host, port, next)
+	return next, nil
+}
+
+// AddPublicKey records key as the public key of the keys with its
+// fingerprint at the pin of exactly profile and host:port, and reports
+// whether there were any
+func (s *Store) AddPublicKey(profile, host string, port int, key ssh.PublicKey) bool {
+	p := s.exact(profile, host, port)
+	if p == nil {
+		return false
+	}
+	found := false
+	for i := range p.Keys {
+		if k := &p.Keys[i]; k.Type == key.Type() && SameFingerprint(k.Fingerprint, Fingerprint(key)) {
+			k.PublicKey = KeyOf(key, k.PinnedAt).PublicKey
+			found = true
+		}
+	}
+	return found
+}
+
+// sameHost compares host names case-insensitively and IP addresses by
+// value, with or without brackets
+func sameHost(a, b string) bool {
+	a, b = trimBrackets(a), trimBrackets(b)
+	if ipA, ipB := net.ParseIP(a), net.ParseIP(b); ipA != nil && ipB != nil {
+		return ipA.Equal(ipB)
+	}
+	return strings.EqualFold(a, b)
+}
+
+func trimBrackets(host string) string {
+	if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
+		return host[1 : len(host)-1]
+	}
+	return host
+}
+
+// SplitAddress splits host[:port], [v6host]:port or a bare IPv6 address,
+// with port 22 when there is none
+func SplitAddress(addr string) (string, int, error) {
+	if host, port, err := net.SplitHostPort(addr); err == nil {
+		n, err := strconv.Atoi(port)
+		if err != nil || n <= 0 || n > 65535 {
+			return "", 0, fmt.Errorf("invalid port in %q", addr)
+		}
+		return host, n, nil
+	}
+	// No port: a host name, or an IPv6 address with or without brackets
+	return trimBrackets(addr), 22, nil
+}
+
+// readLegacy adds the pins of a pinned_keys.txt to s. Its hosts and ports
+// were joined with a colon, IPv6 addresses without brackets, so the port
+// is what follows the last colon.
+func readLegacy(path string, s *Store) error {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var modTime time.Time
+	if info, err := file.Stat(); err == nil {
+		modTime = info.ModTime()
+	}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		// Format: host:port keyType fingerprint [comment]
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			continue
+		}
+		host, port := fields[0], 22
+		if strings.HasPrefix(host, "[") {
+			if h, p, err := SplitAddress(host); err == nil {
+				host, port = h, p
+			}
+		} else if i := strings.LastIndex(host, ":"); i > 0 {
+			if p, err := strconv.Atoi(host[i+1:]); err == nil {
+				host, port = host[:i], p
+			}
+		}
+		key := Key{Type: fields[1], Fingerprint: fields[2], PinnedAt: modTime}
+		if len(fields) > 3 {
+			key.Comment = strings.Join(fields[3:], " ")
+		}
+		// Pinning again appended a line; the last one counts
+		s.Pin("", host, port, key)
+	}
+	return scanner.Err()
+}
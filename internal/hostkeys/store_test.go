@@ -0,0 +1,185 @@
+package hostkeys
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func TestLegacyPinsAreImported(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	legacy := filepath.Join(dir, "veessh", "pinned_keys.txt")
+	os.MkdirAll(filepath.Dir(legacy), 0o700)
+	os.WriteFile(legacy, []byte(`# pins
+example.com:22 ssh-ed25519 SHA256:old
+example.com:2222 ssh-ed25519 SHA256:other
+example.com:22 ssh-ed25519 SHA256:new test comment
+::1:2200 ssh-rsa SHA256:v6
+[fe80::1]:22 ssh-ed25519 SHA256:bracketed
+`), 0o600)
+
+	s, err := LoadStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		host string
+		port int
+		want string
+	}{
+		{"example.com", 22, "SHA256:new"}, // Pinning again appended; the last pin counts
+		{"EXAMPLE.com", 2222, "SHA256:other"},
+		{"::1", 2200, "SHA256:v6"},
+		{"[0:0::1]", 2200, "SHA256:v6"},
+		{"fe80::1", 22, "SHA256:bracketed"},
+	}
+	for _, tt := range tests {
+		pin := s.Find("", tt.host, tt.port)
+		if pin == nil || len(pin.Keys) != 1 || pin.Keys[0].Fingerprint != tt.want {
+			t.Errorf("Find(%s, %d) = %+v, want %s", tt.host, tt.port, pin, tt.want)
+		}
+	}
+	if pin := s.Find("", "example.com", 22); pin.Keys[0].Comment != "test comment" {
+		t.Errorf("comment = %q", pin.Keys[0].Comment)
+	}
+
+	// Saving moves them to the new file
+	if err := UpdateStore(func(*Store) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(legacy); !os.IsNotExist(err) {
+		t.Errorf("pinned_keys.txt left behind: %v", err)
+	}
+	if s, err = LoadStore(); err != nil || len(s.Pins) != 4 {
+		t.Errorf("LoadStore() = %d pins (%v), want 4", len(s.Pins), err)
+	}
+}
+
+func TestStoreScopesAndTypes(t *testing.T) {
+	now := time.Now()
+	var s Store
+	s.Pin("", "db.example.com", 22, Key{Type: ssh.KeyAlgoED25519, Fingerprint: "SHA256:shared"})
+	s.Pin("", "db.example.com", 22, Key{Type: ssh.KeyAlgoRSA, Fingerprint: "SHA256:rsa"})
+	s.Pin("db", "db.example.com", 22, Key{Type: ssh.KeyAlgoED25519, Fingerprint: "SHA256:own"})
+
+	if pin := s.Find("db", "db.example.com", 22); pin == nil || !pin.Accepts(ssh.KeyAlgoED25519, "SHA256:own", now) || pin.Accepts(ssh.KeyAlgoED25519, "SHA256:shared", now) {
+		t.Errorf("profile pin = %+v, want its own key only", pin)
+	}
+	shared := s.Find("other", "db.example.com", 22)
+	if shared == nil || !shared.Accepts(ssh.KeyAlgoRSA, "SHA256:rsa", now) || !shared.Accepts(ssh.KeyAlgoED25519, "SHA256:shared", now) {
+		t.Errorf("shared pin = %+v, want both keys", shared)
+	}
+	if got := shared.Types(now); len(got) != 2 {
+		t.Errorf("Types() = %v, want both", got)
+	}
+
+	// Pinning a type again replaces its key
+	s.Pin("", "db.example.com", 22, Key{Type: ssh.KeyAlgoRSA, Fingerprint: "SHA256:rsa2"})
+	if shared := s.Find("", "db.example.com", 22); len(shared.Keys) != 2 || shared.Accepts(ssh.KeyAlgoRSA, "SHA256:rsa", now) {
+		t.Errorf("after pinning again: %+v", shared.Keys)
+	}
+
+	if n := s.Unpin("", "db.example.com", 22, ssh.KeyAlgoRSA); n != 1 {
+		t.Errorf("Unpin(rsa) removed %d keys, want 1", n)
+	}
+	if n := s.Unpin("db", "db.example.com", 22, ""); n != 1 {
+		t.Errorf("Unpin(db) removed %d keys, want 1", n)
+	}
+	if pin := s.Find("db", "db.example.com", 22); pin == nil || pin.Profile != "" {
+		t.Errorf("after unpinning the profile, Find = %+v, want the shared pin", pin)
+	}
+	if n := s.Unpin("", "db.example.com", 22, ""); n != 1 || len(s.Pins) != 0 {
+		t.Errorf("Unpin(all) removed %d keys, left %d pins", n, len(s.Pins))
+	}
+}
+
+func TestRotation(t *testing.T) {
+	now := time.Now()
+	var s Store
+	if err := s.PlanRotation("", "web", 22, Key{Type: ssh.KeyAlgoED25519, Fingerprint: "SHA256:next"}, time.Time{}); err == nil {
+		t.Error("PlanRotation without a pin succeeded")
+	}
+	s.Pin("", "web", 22, Key{Type: ssh.KeyAlgoED25519, Fingerprint: "SHA256:current"})
+	if err := s.PlanRotation("", "web", 22, Key{Type: ssh.KeyAlgoRSA, Fingerprint: "SHA256:next"}, time.Time{}); err == nil {
+		t.Error("PlanRotation of a type not pinned succeeded")
+	}
+	until := now.Add(time.Hour)
+	if err := s.PlanRotation("", "web", 22, Key{Type: ssh.KeyAlgoED25519, Fingerprint: "SHA256:next"}, until); err != nil {
+		t.Fatal(err)
+	}
+
+	pin := s.Find("", "web", 22)
+	for _, fp := range []string{"SHA256:current", "SHA256:next"} {
+		if !pin.Accepts(ssh.KeyAlgoED25519, fp, now) {
+			t.Errorf("%s not accepted during the rotation", fp)
+		}
+	}
+	if pin.Accepts(ssh.KeyAlgoED25519, "SHA256:current", until) {
+		t.Error("current key accepted past its validUntil")
+	}
+	if !pin.Accepts(ssh.KeyAlgoED25519, "SHA256:next", until.Add(time.Hour)) {
+		t.Error("next key not accepted after the current one expired")
+	}
+
+	key, err := s.FinishRotation("", "web", 22, ssh.KeyAlgoED25519)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pin = s.Find("", "web", 22)
+	if key.Fingerprint != "SHA256:next" || len(pin.Keys) != 1 || pin.Keys[0].Next || !pin.Keys[0].ValidUntil.IsZero() {
+		t.Errorf("after FinishRotation: %+v", pin.Keys)
+	}
+	if _, err := s.FinishRotation("", "web", 22, ssh.KeyAlgoED25519); err == nil {
+		t.Error("FinishRotation without a planned rotation succeeded")
+	}
+}
+
+func TestSplitAddress(t *testing.T) {
+	tests := []struct {
+		addr string
+		host string
+		port int
+		err  bool
+	}{
+		{"example.com", "example.com", 22, false},
+		{"example.com:2222", "example.com", 2222, false},
+		{"[::1]:2222", "::1", 2222, false},
+		{"[::1]", "::1", 22, false},
+		{"fe80::1", "fe80::1", 22, false},
+		{"example.com:ssh", "", 0, true},
+	}
+	for _, tt := range tests {
+		host, port, err := SplitAddress(tt.addr)
+		if (err != nil) != tt.err || host != tt.host || port != tt.port {
+			t.Errorf("SplitAddress(%q) = %q, %d, %v", tt.addr, host, port, err)
+		}
+	}
+}
+
+func TestPinVerify(t *testing.T) {
+	port, keys := startKeyServer(t)
+	now := time.Now()
+	rsaFP := Fingerprint(keys[ssh.KeyAlgoRSA])
+
+	// Only the RSA key is pinned; the host is asked for that one
+	pin := Pin{Host: "127.0.0.1", Port: port, Keys: []Key{{Type: ssh.KeyAlgoRSA, Fingerprint: rsaFP}}}
+	keyType, fp, ok, err := pin.Verify(context.Background(), "", now)
+	if err != nil || !ok || keyType != ssh.KeyAlgoRSA || fp != rsaFP {
+		t.Errorf("Verify() = %s %s %v %v", keyType, fp, ok, err)
+	}
+
+	pin.Keys[0].Fingerprint = "SHA256:c3RhbGUga2V5"
+	if _, _, ok, err := pin.Verify(context.Background(), "", now); err != nil || ok {
+		t.Errorf("Verify(stale pin) = %v %v, want a mismatch", ok, err)
+	}
+
+	pin.Keys[0].ValidUntil = now
+	if _, _, _, err := pin.Verify(context.Background(), "", now); err == nil {
+		t.Error("Verify with every key expired succeeded")
+	}
+}
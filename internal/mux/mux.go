@@ -0,0 +1,109 @@
+// Package mux shares one SSH connection per profile between the ssh, scp
+// and rsync processes veessh starts, using OpenSSH's ControlMaster. The
+// first process becomes the master; later ones reuse its connection and
+// skip the handshake, which matters most through ProxyJump bastions. The
+// control sockets live in the private veessh runtime directory.
+package mux
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/vee-sh/veessh/internal/config"
+	"github.com/vee-sh/veessh/internal/util"
+)
+
+// DefaultPersist is how long a master stays open after its last use when
+// the profile does not set controlPersist
+const DefaultPersist = "10m"
+
+// DisableEnv turns connection sharing off for every profile when set
+const DisableEnv = "VEESSH_NO_MUX"
+
+// Enabled reports whether connections to p are shared
+func Enabled(p config.Profile) bool {
+	// OpenSSH for Windows has no ControlMaster
+	if runtime.GOOS == "windows" || os.Getenv(DisableEnv) != "" || p.ControlPersist == "no" {
+		return false
+	}
+	// Profiles that set up sharing in extraArgs keep their own
+	for _, arg := range p.ExtraArgs {
+		if a := strings.ToLower(arg); strings.Contains(a, "controlmaster") || strings.Contains(a, "controlpath") {
+			return false
+		}
+	}
+	return true
+}
+
+// SocketPath returns the control socket of p's master. It is named after
+// where p connects, so a profile that changes gets a new master instead of
+// reusing a connection to the old host.
+func SocketPath(p config.Profile) string {
+	key := strings.Join([]string{p.Name, p.Username, p.Host, strconv.Itoa(p.Port), p.ProxyJump}, "\x00")
+	sum := sha256.Sum256([]byte(key))
+	// Short, because unix socket paths are limited to about 100 bytes
+	return filepath.Join(util.RuntimeDir(), "mux", hex.EncodeToString(sum[:8]))
+}
+
+// Args returns the ssh options that share connections to p, or nil when
+// they are not shared
+func Args(p config.Profile) []string {
+	if !Enabled(p) {
+		return nil
+	}
+	sock := SocketPath(p)
+	if util.PrivateDir(util.RuntimeDir()) != nil || util.PrivateDir(filepath.Dir(sock)) != nil {
+		return nil
+	}
+	persist := p.ControlPersist
+	if persist == "" {
+		persist = DefaultPersist
+	}
+	return []string{"-o", "ControlMaster=auto", "-o", "ControlPath=" + sock, "-o", "ControlPersist=" + persist}
+}
+
+var masterPID = regexp.MustCompile(`pid=(\d+)`)
+
+// Check returns the pid of p's master, or 0 when none is running
+func Check(ctx context.Context, p config.Profile) (int, error) {
+	if _, err := os.Stat(SocketPath(p)); errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	out, err := control(ctx, p, "check")
+	if err != nil {
+		// A socket left behind by a master that died
+		return 0, nil
+	}
+	m := masterPID.FindStringSubmatch(out)
+	if m == nil {
+		return 0, fmt.Errorf("unexpected answer from master of %s: %s", p.Name, out)
+	}
+	return strconv.Atoi(m[1])
+}
+
+// Stop asks p's master to close its connection. Sessions still using it are
+// closed too.
+func Stop(ctx context.Context, p config.Profile) error {
+	if out, err := control(ctx, p, "exit"); err != nil {
+		return fmt.Errorf("failed to stop master of %s: %s", p.Name, out)
+	}
+	return nil
+}
+
+// control sends a ssh -O command to p's master
+func control(ctx context.Context, p config.Profile, op string) (string, error) {
+	cmd := exec.CommandContext(ctx, "ssh", "-o", "ControlPath="+SocketPath(p), "-O", op, p.Host)
+	out, err := cmd.CombinedOutput()
+	return strings.TrimSpace(string(out)), err
+}
@@ -0,0 +1,59 @@
+package mux
+
+import (
+	"os"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/vee-sh/veessh/internal/config"
+)
+
+func TestSocketPath(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	p := config.Profile{Name: "web", Host: "web.example.com", Port: 22, Username: "deploy"}
+	moved := p
+	moved.Host = "web2.example.com"
+
+	if SocketPath(p) != SocketPath(p) {
+		t.Error("SocketPath() is not stable")
+	}
+	if SocketPath(p) == SocketPath(moved) {
+		t.Error("SocketPath() does not change with the host")
+	}
+	if !strings.HasPrefix(SocketPath(p), os.Getenv("XDG_RUNTIME_DIR")) {
+		t.Errorf("SocketPath() = %s, want it in the runtime dir", SocketPath(p))
+	}
+}
+
+func TestArgs(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no ControlMaster on Windows")
+	}
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	p := config.Profile{Name: "web", Host: "web.example.com"}
+
+	args := Args(p)
+	if !slices.Contains(args, "ControlMaster=auto") || !slices.Contains(args, "ControlPersist="+DefaultPersist) || !slices.Contains(args, "ControlPath="+SocketPath(p)) {
+		t.Errorf("Args() = %q", args)
+	}
+	p.ControlPersist = "1h"
+	if args := Args(p); !slices.Contains(args, "ControlPersist=1h") {
+		t.Errorf("Args() = %q, want ControlPersist=1h", args)
+	}
+
+	tests := map[string]config.Profile{
+		"controlPersist no": {Name: "a", Host: "h", ControlPersist: "no"},
+		"own ControlPath":   {Name: "a", Host: "h", ExtraArgs: []string{"-o", "ControlPath=~/.ssh/cm-%C"}},
+	}
+	for name, p := range tests {
+		if args := Args(p); args != nil {
+			t.Errorf("%s: Args() = %q, want none", name, args)
+		}
+	}
+	t.Setenv(DisableEnv, "1")
+	if args := Args(p); args != nil {
+		t.Errorf("with %s: Args() = %q, want none", DisableEnv, args)
+	}
+}
@@ -0,0 +1,135 @@
+package recording
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Event is an event of a recording after its header
+type Event struct {
+	Time time.Duration // Since the start of the recording
+	Kind string        // "o" for output, "r" for a resize, "i" for input
+	Data string
+}
+
+// UnmarshalJSON reads an event line, [seconds, kind, data]
+func (e *Event) UnmarshalJSON(data []byte) error {
+	var fields []any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	if len(fields) != 3 {
+		return fmt.Errorf("event has %d fields, want 3", len(fields))
+	}
+	seconds, ok1 := fields[0].(float64)
+	kind, ok2 := fields[1].(string)
+	text, ok3 := fields[2].(string)
+	if !ok1 || !ok2 || !ok3 {
+		return fmt.Errorf("malformed event %s", data)
+	}
+	*e = Event{Time: time.Duration(seconds * float64(time.Second)), Kind: kind, Data: text}
+	return nil
+}
+
+// Reader reads a recording
+type Reader struct {
+	Header Header
+	sc     *bufio.Scanner
+	line   int
+}
+
+// NewReader reads the header of the recording in r
+func NewReader(r io.Reader) (*Reader, error) {
+	sc := bufio.NewScanner(r)
+	// Output events carry up to one read of the terminal
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	rd := &Reader{sc: sc, line: 1}
+	if !sc.Scan() {
+		if err := sc.Err(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("empty recording")
+	}
+	if err := json.Unmarshal(sc.Bytes(), &rd.Header); err != nil {
+		return nil, fmt.Errorf("bad recording header: %w", err)
+	}
+	if rd.Header.Version != 2 {
+		return nil, fmt.Errorf("unsupported asciicast version %d (want 2)", rd.Header.Version)
+	}
+	return rd, nil
+}
+
+// Next returns the next event, or io.EOF after the last one
+func (rd *Reader) Next() (Event, error) {
+	for rd.sc.Scan() {
+		rd.line++
+		if len(rd.sc.Bytes()) == 0 {
+			continue
+		}
+		var e Event
+		if err := json.Unmarshal(rd.sc.Bytes(), &e); err != nil {
+			return Event{}, fmt.Errorf("line %d: %w", rd.line, err)
+		}
+		return e, nil
+	}
+	if err := rd.sc.Err(); err != nil {
+		return Event{}, err
+	}
+	return Event{}, io.EOF
+}
+
+// PlayOptions control playback
+type PlayOptions struct {
+	Speed   float64       // Playback speed; 2 plays twice as fast. 0 means 1.
+	MaxIdle time.Duration // Pauses longer than this are shortened to it; 0 keeps them
+}
+
+// Play writes the output of the recording in r to w with its timing
+func Play(ctx context.Context, w io.Writer, r io.Reader, opts PlayOptions) error {
+	rd, err := NewReader(r)
+	if err != nil {
+		return err
+	}
+	speed := opts.Speed
+	if speed <= 0 {
+		speed = 1
+	}
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	<-timer.C
+	var last time.Duration
+	for {
+		e, err := rd.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if e.Kind != "o" {
+			continue
+		}
+
+		wait := e.Time - last
+		last = e.Time
+		if opts.MaxIdle > 0 && wait > opts.MaxIdle {
+			wait = opts.MaxIdle
+		}
+		if wait = time.Duration(float64(wait) / speed); wait > 0 {
+			timer.Reset(wait)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-timer.C:
+			}
+		}
+		if _, err := io.WriteString(w, e.Data); err != nil {
+			return err
+		}
+	}
+}
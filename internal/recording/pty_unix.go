@@ -0,0 +1,74 @@
+//go:build !windows
+
+package recording
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/creack/pty"
+	"golang.org/x/term"
+
+	"github.com/vee-sh/veessh/internal/util"
+)
+
+// Run runs cmd attached to the terminal through a pseudo-terminal, so that
+// everything it shows is recorded. Like util.RunAttached it returns
+// context.Canceled when the command is killed by Ctrl-C.
+func (r *Recorder) Run(cmd *exec.Cmd) error {
+	var ptmx *os.File
+	var err error
+	if width, height, sizeErr := term.GetSize(int(os.Stdin.Fd())); sizeErr == nil {
+		ptmx, err = pty.StartWithSize(cmd, &pty.Winsize{Cols: uint16(width), Rows: uint16(height)})
+	} else {
+		ptmx, err = pty.Start(cmd)
+	}
+	if err != nil {
+		return err
+	}
+	defer ptmx.Close()
+
+	// The command's pty does the line editing and echo now
+	if fd := int(os.Stdin.Fd()); term.IsTerminal(fd) {
+		if state, err := term.MakeRaw(fd); err == nil {
+			defer term.Restore(fd, state)
+		}
+	}
+
+	winch := make(chan os.Signal, 1)
+	signal.Notify(winch, syscall.SIGWINCH)
+	defer func() {
+		signal.Stop(winch)
+		close(winch)
+	}()
+	go func() {
+		for range winch {
+			if width, height, err := term.GetSize(int(os.Stdin.Fd())); err == nil {
+				pty.Setsize(ptmx, &pty.Winsize{Cols: uint16(width), Rows: uint16(height)})
+				r.Resize(width, height)
+			}
+		}
+	}()
+
+	// Reading stdin blocks until the next key press, so this goroutine
+	// outlives the command by one key; veessh exits soon after anyway
+	go io.Copy(ptmx, os.Stdin)
+	copied := make(chan struct{})
+	go func() {
+		io.Copy(io.MultiWriter(os.Stdout, r), ptmx)
+		close(copied)
+	}()
+
+	err = cmd.Wait()
+	// Drain what the command printed last. Processes it left behind may
+	// hold the pty open, so don't wait for them.
+	select {
+	case <-copied:
+	case <-time.After(time.Second):
+	}
+	return util.Interrupted(err)
+}
@@ -0,0 +1,15 @@
+//go:build windows
+
+package recording
+
+import (
+	"errors"
+	"os/exec"
+)
+
+// Run would run cmd through a pseudo-terminal, which veessh does not
+// support on Windows. Recorded profiles refuse to connect rather than
+// connect unrecorded.
+func (r *Recorder) Run(cmd *exec.Cmd) error {
+	return errors.New("session recording is not supported on Windows")
+}
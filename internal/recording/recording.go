@@ -0,0 +1,167 @@
+// Package recording records interactive sessions as asciicast v2 files
+// (https://docs.asciinema.org/manual/asciicast/v2/) and plays them back.
+// A recording is a JSON header line followed by one JSON line per event:
+// [seconds since start, "o", output] or [seconds, "r", "COLSxROWS"].
+package recording
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Ext is the file extension of recordings
+const Ext = ".cast"
+
+// timeFormat is the start time in recording file names, in UTC
+const timeFormat = "20060102T150405.000Z"
+
+// Header is the first line of an asciicast v2 file
+type Header struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp"`
+	Title     string            `json:"title,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+// Recorder writes a session to a recording. Its Write records output and
+// never fails, as it shares the session's output with the terminal: once
+// the recording cannot be written (a full disk, say) it stops, and Err
+// says why.
+type Recorder struct {
+	mu      sync.Mutex
+	file    *os.File
+	path    string
+	start   time.Time
+	pending []byte // Incomplete UTF-8 sequence held back from the last Write
+	err     error  // Why the recording stopped
+}
+
+// Create starts a recording of profile in dir, for a terminal of width
+// columns and height rows
+func Create(dir, profile, title string, width, height int) (*Recorder, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	name := strings.NewReplacer("/", "_", string(filepath.Separator), "_").Replace(profile)
+	path := filepath.Join(dir, name+"-"+start.UTC().Format(timeFormat)+Ext)
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	header, err := json.Marshal(Header{
+		Version:   2,
+		Width:     width,
+		Height:    height,
+		Timestamp: start.Unix(),
+		Title:     title,
+		Env:       map[string]string{"TERM": os.Getenv("TERM"), "SHELL": os.Getenv("SHELL")},
+	})
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if _, err := file.Write(append(header, '\n')); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &Recorder{file: file, path: path, start: start}, nil
+}
+
+// Path returns the file the recording is written to
+func (r *Recorder) Path() string {
+	return r.path
+}
+
+// Write records p as output. A UTF-8 sequence split across writes is held
+// back until it is complete, since events must be valid strings.
+func (r *Recorder) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return len(p), nil
+	}
+
+	data := append(r.pending, p...)
+	cut := len(data)
+	for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(data[i]) {
+			if !utf8.FullRune(data[i:]) {
+				cut = i
+			}
+			break
+		}
+	}
+	r.pending = append([]byte(nil), data[cut:]...)
+	if cut > 0 {
+		r.event("o", string(data[:cut]))
+	}
+	return len(p), nil
+}
+
+// Resize records the terminal changing size
+func (r *Recorder) Resize(width, height int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.event("r", fmt.Sprintf("%dx%d", width, height))
+}
+
+// Err returns why the recording stopped before the session ended, or nil
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// Close writes any held back output and closes the file
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.pending) > 0 {
+		r.event("o", string(r.pending))
+		r.pending = nil
+	}
+	return r.file.Close()
+}
+
+// event appends an event; each is written at once, so a recording survives
+// veessh being killed. The first failure stops the recording.
+func (r *Recorder) event(kind, data string) error {
+	if r.err != nil {
+		return r.err
+	}
+	elapsed := math.Round(time.Since(r.start).Seconds()*1e6) / 1e6
+	line, err := json.Marshal([]any{elapsed, kind, data})
+	if err == nil {
+		_, err = r.file.Write(append(line, '\n'))
+	}
+	if err != nil {
+		r.err = fmt.Errorf("failed to write %s: %w", r.path, err)
+	}
+	return r.err
+}
+
+type contextKey struct{}
+
+// NewContext returns ctx carrying r, for connectors to record through
+func NewContext(ctx context.Context, r *Recorder) context.Context {
+	return context.WithValue(ctx, contextKey{}, r)
+}
+
+// FromContext returns the recorder of ctx, or nil when the session is not
+// recorded
+func FromContext(ctx context.Context) *Recorder {
+	r, _ := ctx.Value(contextKey{}).(*Recorder)
+	return r
+}
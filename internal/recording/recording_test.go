@@ -0,0 +1,141 @@
+package recording
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecordAndList(t *testing.T) {
+	dir := t.TempDir()
+	r, err := Create(dir, "web", "veessh connect web", 100, 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// "é" split across writes must come out whole
+	r.Write([]byte("caf\xc3"))
+	r.Write([]byte("\xa9 ok\r\n"))
+	r.Resize(120, 40)
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if st, _ := os.Stat(r.Path()); st.Mode().Perm() != 0o600 && runtime.GOOS != "windows" {
+		t.Errorf("recording mode %v, want 0600", st.Mode().Perm())
+	}
+
+	f, err := os.Open(r.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rd, err := NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rd.Header.Version != 2 || rd.Header.Width != 100 || rd.Header.Height != 30 {
+		t.Errorf("header = %+v", rd.Header)
+	}
+	var out strings.Builder
+	var kinds []string
+	for {
+		e, err := rd.Next()
+		if err != nil {
+			break
+		}
+		kinds = append(kinds, e.Kind)
+		if e.Kind == "o" {
+			out.WriteString(e.Data)
+		}
+	}
+	if out.String() != "café ok\r\n" || strings.Join(kinds, ",") != "o,o,r" {
+		t.Errorf("events %v with output %q", kinds, out.String())
+	}
+
+	infos, err := List(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 1 || infos[0].Profile != "web" || infos[0].Path != r.Path() {
+		t.Errorf("List() = %+v", infos)
+	}
+	if infos, err := List(filepath.Join(dir, "missing")); err != nil || len(infos) != 0 {
+		t.Errorf("List(missing) = %v, %v", infos, err)
+	}
+}
+
+func TestRecordingFailureKeepsOutput(t *testing.T) {
+	r, err := Create(t.TempDir(), "web", "", 80, 24)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// As if the disk filled up
+	r.file.Close()
+
+	var terminal bytes.Buffer
+	w := io.MultiWriter(&terminal, r)
+	for _, line := range []string{"one\r\n", "two\r\n"} {
+		if _, err := w.Write([]byte(line)); err != nil {
+			t.Fatalf("Write() = %v, want the terminal to keep going", err)
+		}
+	}
+	if terminal.String() != "one\r\ntwo\r\n" {
+		t.Errorf("terminal got %q", terminal.String())
+	}
+	if r.Err() == nil || !strings.Contains(r.Err().Error(), r.Path()) {
+		t.Errorf("Err() = %v, want why the recording stopped", r.Err())
+	}
+}
+
+func TestPlay(t *testing.T) {
+	cast := `{"version": 2, "width": 80, "height": 24, "timestamp": 1700000000}
+[0.5, "o", "one "]
+[1.0, "r", "100x40"]
+[60.0, "o", "two"]
+`
+	var out bytes.Buffer
+	start := time.Now()
+	err := Play(context.Background(), &out, strings.NewReader(cast), PlayOptions{Speed: 100, MaxIdle: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "one two" {
+		t.Errorf("output %q", out.String())
+	}
+	// 0.5s, then the minute shortened to 1s, at 100x
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("playback took %s", elapsed)
+	}
+
+	if err := Play(context.Background(), &out, strings.NewReader(`{"version": 1}`), PlayOptions{}); err == nil {
+		t.Error("Play() accepted asciicast v1")
+	}
+}
+
+func TestRunRecordsOutput(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no pty on Windows")
+	}
+	r, err := Create(t.TempDir(), "web", "", 80, 24)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Run(exec.Command("sh", "-c", "echo recorded; exit 3")); err == nil {
+		t.Error("Run() lost the exit status")
+	}
+	r.Close()
+
+	data, err := os.ReadFile(r.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"o","recorded\r\n"`) {
+		t.Errorf("recording has no output event:\n%s", data)
+	}
+}
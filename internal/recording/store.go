@@ -0,0 +1,87 @@
+package recording
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Info describes a recording in a directory
+type Info struct {
+	Path     string        `json:"path"`
+	Profile  string        `json:"profile"`
+	Start    time.Time     `json:"start"`
+	Duration time.Duration `json:"-"` // Time of the last event
+	Size     int64         `json:"size"`
+	Title    string        `json:"title,omitempty"`
+}
+
+// MarshalJSON writes Duration as a string, e.g. "12m3s"
+func (i Info) MarshalJSON() ([]byte, error) {
+	type info Info
+	return json.Marshal(struct {
+		info
+		Duration string `json:"duration"`
+	}{info(i), i.Duration.Round(time.Second).String()})
+}
+
+// List returns the recordings in dir, newest first. A missing dir has none.
+func List(dir string) ([]Info, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, "*"+Ext))
+	if err != nil {
+		return nil, err
+	}
+	var out []Info
+	for _, path := range matches {
+		info, err := Stat(path)
+		if err != nil {
+			continue // Not a recording of ours
+		}
+		out = append(out, info)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Start.After(out[j].Start) })
+	return out, nil
+}
+
+// Stat reads the header and the time of the last event of the recording at
+// path
+func Stat(path string) (Info, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Info{}, err
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		return Info{}, err
+	}
+	rd, err := NewReader(f)
+	if err != nil {
+		return Info{}, err
+	}
+
+	info := Info{
+		Path:  path,
+		Size:  st.Size(),
+		Start: time.Unix(rd.Header.Timestamp, 0),
+		Title: rd.Header.Title,
+	}
+	name := strings.TrimSuffix(filepath.Base(path), Ext)
+	if i := strings.LastIndex(name, "-"); i > 0 {
+		info.Profile = name[:i]
+		if start, err := time.Parse(timeFormat, name[i+1:]); err == nil {
+			info.Start = start
+		}
+	}
+	for {
+		e, err := rd.Next()
+		if err != nil {
+			break // A recording cut short by a crash lists up to where it ends
+		}
+		info.Duration = e.Time
+	}
+	return info, nil
+}
@@ -0,0 +1,231 @@
+package sshclient
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/vee-sh/veessh/internal/config"
+)
+
+// forwardSpec is a parsed -L/-R/-D style forward.
+type forwardSpec struct {
+	bindHost string
+	bindPort int
+	destHost string // empty for dynamic forwards
+	destPort int
+}
+
+func (f forwardSpec) bindAddr() string {
+	host := f.bindHost
+	if host == "" {
+		host = "localhost"
+	}
+	return net.JoinHostPort(host, strconv.Itoa(f.bindPort))
+}
+
+func (f forwardSpec) destAddr() string {
+	return net.JoinHostPort(f.destHost, strconv.Itoa(f.destPort))
+}
+
+// parseForward parses "[bind:]port:host:hostport", or "[bind:]port" when
+// dynamic is true.
+func parseForward(spec string, dynamic bool) (forwardSpec, error) {
+	f, err := config.ParseForward(spec, dynamic)
+	if err != nil {
+		return forwardSpec{}, err
+	}
+	return forwardSpec{bindHost: f.Bind, bindPort: f.LocalPort, destHost: f.RemoteHost, destPort: f.RemotePort}, nil
+}
+
+// StartForwards opens the profile's local, remote and dynamic forwards over
+// client. Forwarding stops when ctx is cancelled or the returned function is
+// called.
+func StartForwards(ctx context.Context, client *ssh.Client, p config.Profile) (func(), error) {
+	ctx, cancel := context.WithCancel(ctx)
+	var listeners []net.Listener
+	stop := func() {
+		cancel()
+		for _, l := range listeners {
+			l.Close()
+		}
+	}
+
+	type forward struct {
+		spec    string
+		kind    string
+		listen  func(forwardSpec) (net.Listener, error)
+		connect func(forwardSpec, net.Conn)
+		dynamic bool
+	}
+	var forwards []forward
+	local, remote, dynamic := p.ForwardSpecs()
+	for _, s := range local {
+		forwards = append(forwards, forward{
+			spec: s, kind: "local",
+			listen: func(f forwardSpec) (net.Listener, error) { return net.Listen("tcp", f.bindAddr()) },
+			connect: func(f forwardSpec, c net.Conn) {
+				pipe(c, func() (net.Conn, error) { return client.DialContext(ctx, "tcp", f.destAddr()) })
+			},
+		})
+	}
+	for _, s := range remote {
+		forwards = append(forwards, forward{
+			spec: s, kind: "remote",
+			listen: func(f forwardSpec) (net.Listener, error) { return client.Listen("tcp", f.bindAddr()) },
+			connect: func(f forwardSpec, c net.Conn) {
+				pipe(c, func() (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, "tcp", f.destAddr())
+				})
+			},
+		})
+	}
+	for _, s := range dynamic {
+		forwards = append(forwards, forward{
+			spec: s, kind: "dynamic", dynamic: true,
+			listen: func(f forwardSpec) (net.Listener, error) { return net.Listen("tcp", f.bindAddr()) },
+			connect: func(f forwardSpec, c net.Conn) {
+				serveSOCKS(c, func(addr string) (net.Conn, error) { return client.DialContext(ctx, "tcp", addr) })
+			},
+		})
+	}
+
+	for _, fw := range forwards {
+		if strings.TrimSpace(fw.spec) == "" {
+			continue
+		}
+		f, err := parseForward(fw.spec, fw.dynamic)
+		if err != nil {
+			stop()
+			return nil, err
+		}
+		l, err := fw.listen(f)
+		if err != nil {
+			stop()
+			return nil, fmt.Errorf("%s forward %s: %w", fw.kind, fw.spec, err)
+		}
+		listeners = append(listeners, l)
+		go acceptLoop(ctx, l, func(c net.Conn) { fw.connect(f, c) })
+	}
+
+	return stop, nil
+}
+
+func acceptLoop(ctx context.Context, l net.Listener, handle func(net.Conn)) {
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			if ctx.Err() == nil && !errors.Is(err, net.ErrClosed) && !errors.Is(err, io.EOF) {
+				fmt.Fprintf(os.Stderr, "Warning: forward on %s stopped: %v\n", l.Addr(), err)
+			}
+			return
+		}
+		go handle(c)
+	}
+}
+
+// pipe connects c to a new connection from dial and copies in both directions.
+func pipe(c net.Conn, dial func() (net.Conn, error)) {
+	defer c.Close()
+	remote, err := dial()
+	if err != nil {
+		return
+	}
+	defer remote.Close()
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() { defer wg.Done(); io.Copy(remote, c); closeWrite(remote) }()
+	go func() { defer wg.Done(); io.Copy(c, remote); closeWrite(c) }()
+	wg.Wait()
+}
+
+func closeWrite(c net.Conn) {
+	if cw, ok := c.(interface{ CloseWrite() error }); ok {
+		cw.CloseWrite()
+	}
+}
+
+// serveSOCKS handles a single SOCKS5 CONNECT request (no authentication),
+// dialing the requested destination through dial.
+func serveSOCKS(c net.Conn, dial func(addr string) (net.Conn, error)) {
+	buf := make([]byte, 262)
+
+	// Greeting: VER NMETHODS METHODS...
+	if _, err := io.ReadFull(c, buf[:2]); err != nil || buf[0] != 5 {
+		c.Close()
+		return
+	}
+	if _, err := io.ReadFull(c, buf[:buf[1]]); err != nil {
+		c.Close()
+		return
+	}
+	if _, err := c.Write([]byte{5, 0}); err != nil {
+		c.Close()
+		return
+	}
+
+	// Request: VER CMD RSV ATYP DST.ADDR DST.PORT
+	if _, err := io.ReadFull(c, buf[:4]); err != nil || buf[1] != 1 {
+		c.Write([]byte{5, 7, 0, 1, 0, 0, 0, 0, 0, 0}) // command not supported
+		c.Close()
+		return
+	}
+	var host string
+	switch buf[3] {
+	case 1: // IPv4
+		if _, err := io.ReadFull(c, buf[:4]); err != nil {
+			c.Close()
+			return
+		}
+		host = net.IP(buf[:4]).String()
+	case 3: // domain name
+		if _, err := io.ReadFull(c, buf[:1]); err != nil {
+			c.Close()
+			return
+		}
+		n := int(buf[0])
+		if _, err := io.ReadFull(c, buf[:n]); err != nil {
+			c.Close()
+			return
+		}
+		host = string(buf[:n])
+	case 4: // IPv6
+		if _, err := io.ReadFull(c, buf[:16]); err != nil {
+			c.Close()
+			return
+		}
+		host = net.IP(buf[:16]).String()
+	default:
+		c.Write([]byte{5, 8, 0, 1, 0, 0, 0, 0, 0, 0}) // address type not supported
+		c.Close()
+		return
+	}
+	if _, err := io.ReadFull(c, buf[:2]); err != nil {
+		c.Close()
+		return
+	}
+	addr := net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(buf[:2]))))
+
+	pipe(c, func() (net.Conn, error) {
+		remote, err := dial(addr)
+		if err != nil {
+			c.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0}) // connection refused
+			return nil, err
+		}
+		if _, err := c.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0}); err != nil {
+			remote.Close()
+			return nil, err
+		}
+		return remote, nil
+	})
+}
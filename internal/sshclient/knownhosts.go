@@ -0,0 +1,129 @@
+package sshclient
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"github.com/vee-sh/veessh/internal/hostkeys"
+)
+
+// KnownHostsCallback returns a host key callback that verifies servers
+// against ~/.ssh/known_hosts, and the host key algorithms already recorded
+// for addr so the server is asked for a key type we can verify.
+//
+// Unknown hosts are offered to the user for trust-on-first-use via prompt and
+// appended to known_hosts; without a prompt they are rejected. Changed keys
+// are always rejected. Target hosts with a pin enforced (Config.Pin) are
+// checked against it instead.
+func KnownHostsCallback(addr string, prompt PromptFunc) (ssh.HostKeyCallback, []string, error) {
+	path, err := hostkeys.KnownHostsPath()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := ensureFile(path); err != nil {
+		return nil, nil, fmt.Errorf("failed to prepare %s: %w", path, err)
+	}
+	cb, err := knownhosts.New(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	callback := func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		err := cb(hostname, remote, key)
+		var keyErr *knownhosts.KeyError
+		if !errors.As(err, &keyErr) {
+			return err
+		}
+		if len(keyErr.Want) > 0 {
+			want := keyErr.Want[0]
+			return fmt.Errorf("host key verification failed: the %s key for %s does not match %s:%d (possible man-in-the-middle attack)",
+				key.Type(), hostname, want.Filename, want.Line)
+		}
+
+		if prompt == nil {
+			return fmt.Errorf("host key verification failed: %s is not in %s", hostname, path)
+		}
+		fmt.Fprintf(os.Stderr, "The authenticity of host '%s' can't be established.\n", hostname)
+		fmt.Fprintf(os.Stderr, "%s key fingerprint is %s.\n", key.Type(), ssh.FingerprintSHA256(key))
+		answer, err := prompt("Are you sure you want to continue connecting (yes/no)? ", true)
+		if err != nil {
+			return err
+		}
+		if strings.ToLower(strings.TrimSpace(answer)) != "yes" {
+			return errors.New("host key verification failed: host not trusted")
+		}
+		if err := appendKnownHost(path, hostname, key); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to update %s: %v\n", path, err)
+		} else {
+			fmt.Fprintf(os.Stderr, "Permanently added '%s' (%s) to the list of known hosts.\n", hostname, key.Type())
+		}
+		return nil
+	}
+
+	return callback, knownAlgorithms(cb, addr), nil
+}
+
+// knownAlgorithms asks the known_hosts callback which key types are recorded
+// for addr by presenting a key that cannot match.
+func knownAlgorithms(cb ssh.HostKeyCallback, addr string) []string {
+	var keyErr *knownhosts.KeyError
+	if err := cb(addr, &net.TCPAddr{IP: net.IPv4zero}, probeKey{}); !errors.As(err, &keyErr) {
+		return nil
+	}
+	var algorithms []string
+	seen := map[string]bool{}
+	for _, k := range keyErr.Want {
+		for _, alg := range algorithmsForKeyType(k.Key.Type()) {
+			if !seen[alg] {
+				seen[alg] = true
+				algorithms = append(algorithms, alg)
+			}
+		}
+	}
+	return algorithms
+}
+
+func algorithmsForKeyType(keyType string) []string {
+	if keyType == ssh.KeyAlgoRSA {
+		return []string{ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA}
+	}
+	return []string{keyType}
+}
+
+func appendKnownHost(path, hostname string, key ssh.PublicKey) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = fmt.Fprintln(f, knownhosts.Line([]string{knownhosts.Normalize(hostname)}, key))
+	return err
+}
+
+func ensureFile(path string) error {
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// probeKey is a public key that never matches a known_hosts entry.
+type probeKey struct{}
+
+func (probeKey) Type() string                        { return "veessh-probe" }
+func (probeKey) Marshal() []byte                     { return []byte("veessh-probe") }
+func (probeKey) Verify([]byte, *ssh.Signature) error { return errors.New("probe key") }
@@ -0,0 +1,421 @@
+// Package sshclient establishes in-process SSH connections for profiles using
+// golang.org/x/crypto/ssh, without relying on the OpenSSH client binaries.
+package sshclient
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/term"
+
+	"github.com/vee-sh/veessh/internal/config"
+	"github.com/vee-sh/veessh/internal/credentials"
+	"github.com/vee-sh/veessh/internal/hostkeys"
+)
+
+// DefaultTimeout bounds the TCP connect and SSH handshake of each hop.
+const DefaultTimeout = 15 * time.Second
+
+// PromptFunc asks the user for a value. When echo is false the input is secret
+// and must not be displayed.
+type PromptFunc func(prompt string, echo bool) (string, error)
+
+// Config controls how Dial authenticates and verifies hosts.
+type Config struct {
+	// Password is the stored password for the profile. It is only offered
+	// to the target host, never to jump hosts.
+	Password string
+
+	// KeyPassphrase is the stored passphrase for the profile's private keys.
+	// It is tried on encrypted keys before falling back to Prompt.
+	KeyPassphrase string
+
+	// TOTPSeed answers one-time password questions of the target host with
+	// generated codes during keyboard-interactive authentication.
+	TOTPSeed string
+
+	// Prompt is used for passphrases, passwords and keyboard-interactive
+	// questions that cannot be answered from stored secrets. A nil Prompt
+	// disables interactive prompting.
+	Prompt PromptFunc
+
+	// HostKeyCallback overrides known_hosts verification (used by tests and
+	// callers with their own policy). When nil, KnownHostsCallback is used.
+	HostKeyCallback ssh.HostKeyCallback
+
+	// Pin, when set, is enforced on the target host in place of
+	// known_hosts: only the keys it pins are accepted.
+	Pin *hostkeys.Pin
+
+	// Timeout for connecting to each hop; DefaultTimeout when zero.
+	Timeout time.Duration
+}
+
+// Client is an SSH connection to a profile's host, together with the jump
+// host connections it was established through.
+type Client struct {
+	*ssh.Client
+	jumps []*ssh.Client
+}
+
+// Close closes the target connection and every jump host connection.
+func (c *Client) Close() error {
+	err := c.Client.Close()
+	for i := len(c.jumps) - 1; i >= 0; i-- {
+		c.jumps[i].Close()
+	}
+	return err
+}
+
+// hop is a single host in a connection chain.
+type hop struct {
+	user string
+	host string
+	port int
+}
+
+func (h hop) addr() string {
+	return net.JoinHostPort(h.host, strconv.Itoa(h.port))
+}
+
+// Dial connects to the profile's host, going through ProxyJump hosts if
+// configured, and authenticates with the agent, identity files, the stored
+// password and keyboard-interactive prompts.
+func Dial(ctx context.Context, p config.Profile, cfg Config) (*Client, error) {
+	if cfg.Timeout == 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+
+	defaultUser := p.Username
+	if defaultUser == "" {
+		defaultUser = localUsername()
+	}
+
+	jumps, err := parseProxyJump(p.ProxyJump, defaultUser)
+	if err != nil {
+		return nil, err
+	}
+	port := p.Port
+	if port <= 0 {
+		port = 22
+	}
+	target := hop{user: defaultUser, host: p.Host, port: port}
+
+	signers, closeAgent := loadSigners(p, cfg.KeyPassphrase, cfg.Prompt)
+	defer closeAgent()
+
+	var (
+		clients []*ssh.Client
+		prev    *ssh.Client
+	)
+	closeAll := func() {
+		for i := len(clients) - 1; i >= 0; i-- {
+			clients[i].Close()
+		}
+	}
+
+	hops := append(jumps, target)
+	for i, h := range hops {
+		clientCfg, err := clientConfig(h, signers, cfg, i == len(hops)-1)
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		c, err := dialHop(ctx, prev, h, clientCfg)
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		clients = append(clients, c)
+		prev = c
+	}
+
+	return &Client{Client: prev, jumps: clients[:len(clients)-1]}, nil
+}
+
+// dialHop opens a connection to h, either directly or through prev.
+func dialHop(ctx context.Context, prev *ssh.Client, h hop, cfg *ssh.ClientConfig) (*ssh.Client, error) {
+	addr := h.addr()
+
+	var (
+		conn net.Conn
+		err  error
+	)
+	if prev == nil {
+		d := net.Dialer{Timeout: cfg.Timeout}
+		conn, err = d.DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = prev.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", addr, err)
+	}
+
+	// The handshake does not observe ctx; bound it with a deadline and
+	// abort it if the context is cancelled first.
+	conn.SetDeadline(time.Now().Add(cfg.Timeout))
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	c, chans, reqs, err := ssh.NewClientConn(conn, addr, cfg)
+	stop()
+	if err != nil {
+		conn.Close()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("ssh handshake with %s failed: %w", addr, err)
+	}
+	conn.SetDeadline(time.Time{})
+	return ssh.NewClient(c, chans, reqs), nil
+}
+
+func clientConfig(h hop, signers []ssh.Signer, cfg Config, isTarget bool) (*ssh.ClientConfig, error) {
+	hostKeyCallback := cfg.HostKeyCallback
+	var algorithms []string
+	switch {
+	case hostKeyCallback != nil:
+	case isTarget && cfg.Pin != nil:
+		hostKeyCallback, algorithms = cfg.Pin.Callback(time.Now())
+	default:
+		var err error
+		hostKeyCallback, algorithms, err = KnownHostsCallback(h.addr(), cfg.Prompt)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var auth []ssh.AuthMethod
+	if len(signers) > 0 {
+		auth = append(auth, ssh.PublicKeys(signers...))
+	}
+
+	password, totpSeed := "", ""
+	if isTarget {
+		password, totpSeed = cfg.Password, cfg.TOTPSeed
+	}
+	if password != "" || cfg.Prompt != nil {
+		auth = append(auth, ssh.PasswordCallback(func() (string, error) {
+			if password != "" {
+				return password, nil
+			}
+			return cfg.Prompt(fmt.Sprintf("%s@%s's password: ", h.user, h.host), false)
+		}))
+	}
+	if password != "" || totpSeed != "" || cfg.Prompt != nil {
+		auth = append(auth, ssh.KeyboardInteractive(keyboardInteractive(password, totpSeed, cfg.Prompt)))
+	}
+
+	return &ssh.ClientConfig{
+		User:              h.user,
+		Auth:              auth,
+		HostKeyCallback:   hostKeyCallback,
+		HostKeyAlgorithms: algorithms,
+		Timeout:           cfg.Timeout,
+	}, nil
+}
+
+// keyboardInteractive answers one-time password questions with codes from
+// the TOTP seed, password-like questions with the stored password (once)
+// and forwards everything else to the prompt.
+func keyboardInteractive(password, totpSeed string, prompt PromptFunc) ssh.KeyboardInteractiveChallenge {
+	usedPassword := false
+	return func(name, instruction string, questions []string, echos []bool) ([]string, error) {
+		if instruction != "" {
+			fmt.Fprintln(os.Stderr, instruction)
+		}
+		answers := make([]string, len(questions))
+		for i, q := range questions {
+			if totpSeed != "" && credentials.IsOTPPrompt(q) {
+				code, err := credentials.TOTPCode(totpSeed, time.Now())
+				if err != nil {
+					return nil, err
+				}
+				answers[i] = code
+				continue
+			}
+			if password != "" && !usedPassword && !echos[i] && strings.Contains(strings.ToLower(q), "password") {
+				answers[i] = password
+				usedPassword = true
+				continue
+			}
+			if prompt == nil {
+				return nil, fmt.Errorf("cannot answer %q without an interactive prompt", strings.TrimSpace(q))
+			}
+			a, err := prompt(q, echos[i])
+			if err != nil {
+				return nil, err
+			}
+			answers[i] = a
+		}
+		return answers, nil
+	}
+}
+
+// loadSigners collects public key signers from the SSH agent (if enabled)
+// and from the profile's identity file or the default identity files.
+// The returned function releases the agent connection.
+func loadSigners(p config.Profile, passphrase string, prompt PromptFunc) ([]ssh.Signer, func()) {
+	var signers []ssh.Signer
+	closeAgent := func() {}
+
+	if config.IsTrue(p.UseAgent) || p.IdentityFile == "" {
+		if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+			if conn, err := net.Dial("unix", sock); err == nil {
+				closeAgent = func() { conn.Close() }
+				if s, err := agent.NewClient(conn).Signers(); err == nil {
+					signers = append(signers, s...)
+				}
+			}
+		}
+	}
+
+	paths := []string{p.IdentityFile}
+	keyPrompt := prompt
+	if p.IdentityFile == "" {
+		paths = defaultIdentityFiles()
+		// Don't ask for passphrases of default keys the agent may already hold
+		if len(signers) > 0 {
+			keyPrompt = nil
+		}
+	}
+	for _, path := range paths {
+		s, err := loadIdentity(expandHome(path), passphrase, keyPrompt, p.IdentityFile != "")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			continue
+		}
+		if s != nil {
+			signers = append(signers, s)
+		}
+	}
+	return signers, closeAgent
+}
+
+// loadIdentity parses a private key, trying the stored passphrase and then
+// prompting when it is encrypted. Missing default keys are silently skipped.
+func loadIdentity(path, passphrase string, prompt PromptFunc, explicit bool) (ssh.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !explicit && errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read identity file %s: %w", path, err)
+	}
+	signer, err := ssh.ParsePrivateKey(data)
+	var missing *ssh.PassphraseMissingError
+	if errors.As(err, &missing) && passphrase != "" {
+		if s, perr := ssh.ParsePrivateKeyWithPassphrase(data, []byte(passphrase)); perr == nil {
+			return s, nil
+		}
+	}
+	if errors.As(err, &missing) {
+		if prompt == nil {
+			if !explicit {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("identity file %s is encrypted and no passphrase is available", path)
+		}
+		passphrase, perr := prompt(fmt.Sprintf("Enter passphrase for key '%s': ", path), false)
+		if perr != nil {
+			return nil, perr
+		}
+		signer, err = ssh.ParsePrivateKeyWithPassphrase(data, []byte(passphrase))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse identity file %s: %w", path, err)
+	}
+	return signer, nil
+}
+
+func defaultIdentityFiles() []string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil
+	}
+	return []string{
+		filepath.Join(home, ".ssh", "id_ed25519"),
+		filepath.Join(home, ".ssh", "id_ecdsa"),
+		filepath.Join(home, ".ssh", "id_rsa"),
+	}
+}
+
+// parseProxyJump parses an OpenSSH ProxyJump value ("[user@]host[:port],...")
+// into the list of hops to traverse, in order.
+func parseProxyJump(spec, defaultUser string) ([]hop, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" || strings.EqualFold(spec, "none") {
+		return nil, nil
+	}
+	var hops []hop
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		part = strings.TrimPrefix(part, "ssh://")
+		if part == "" {
+			continue
+		}
+		h := hop{user: defaultUser, port: 22}
+		if at := strings.LastIndex(part, "@"); at >= 0 {
+			h.user = part[:at]
+			part = part[at+1:]
+		}
+		if host, port, err := net.SplitHostPort(part); err == nil {
+			n, err := strconv.Atoi(port)
+			if err != nil || n <= 0 || n > 65535 {
+				return nil, fmt.Errorf("invalid port in ProxyJump %q", spec)
+			}
+			h.host, h.port = host, n
+		} else {
+			h.host = strings.Trim(part, "[]")
+		}
+		if h.host == "" {
+			return nil, fmt.Errorf("invalid ProxyJump %q", spec)
+		}
+		hops = append(hops, h)
+	}
+	return hops, nil
+}
+
+// TerminalPrompt prompts on stderr and reads the answer from stdin, hiding
+// the input when echo is false.
+func TerminalPrompt(prompt string, echo bool) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	if !echo {
+		b, err := term.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+	}
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func localUsername() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
+
+func expandHome(path string) string {
+	if strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, path[2:])
+		}
+	}
+	return path
+}
@@ -0,0 +1,259 @@
+package sshclient
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/vee-sh/veessh/internal/config"
+	"github.com/vee-sh/veessh/internal/credentials"
+)
+
+func TestParseProxyJump(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    []hop
+		wantErr bool
+	}{
+		{"", nil, false},
+		{"none", nil, false},
+		{"bastion", []hop{{user: "me", host: "bastion", port: 22}}, false},
+		{"ops@bastion:2222", []hop{{user: "ops", host: "bastion", port: 2222}}, false},
+		{"a, b@c:23", []hop{{user: "me", host: "a", port: 22}, {user: "b", host: "c", port: 23}}, false},
+		{"[2001:db8::1]:2200", []hop{{user: "me", host: "2001:db8::1", port: 2200}}, false},
+		{"ssh://u@h", []hop{{user: "u", host: "h", port: 22}}, false},
+		{"host:notaport", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := parseProxyJump(tt.spec, "me")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseProxyJump(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("parseProxyJump(%q) = %v, want %v", tt.spec, got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("parseProxyJump(%q)[%d] = %v, want %v", tt.spec, i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParseForward(t *testing.T) {
+	tests := []struct {
+		spec    string
+		dynamic bool
+		want    forwardSpec
+		wantErr bool
+	}{
+		{"8080:internal:80", false, forwardSpec{bindPort: 8080, destHost: "internal", destPort: 80}, false},
+		{"0.0.0.0:8080:internal:80", false, forwardSpec{bindHost: "0.0.0.0", bindPort: 8080, destHost: "internal", destPort: 80}, false},
+		{"[::1]:8080:[2001:db8::2]:80", false, forwardSpec{bindHost: "::1", bindPort: 8080, destHost: "2001:db8::2", destPort: 80}, false},
+		{"1080", true, forwardSpec{bindPort: 1080}, false},
+		{"127.0.0.1:1080", true, forwardSpec{bindHost: "127.0.0.1", bindPort: 1080}, false},
+		{"8080", false, forwardSpec{}, true},
+		{"x:internal:80", false, forwardSpec{}, true},
+		{"99999", true, forwardSpec{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := parseForward(tt.spec, tt.dynamic)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseForward(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseForward(%q) = %+v, want %+v", tt.spec, got, tt.want)
+			}
+		})
+	}
+}
+
+// startTestServer runs an in-process SSH server that accepts the given
+// password and answers exec requests with exit status 0.
+func startTestServer(t *testing.T, password string) (host string, port int) {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &ssh.ServerConfig{
+		PasswordCallback: func(c ssh.ConnMetadata, pass []byte) (*ssh.Permissions, error) {
+			if string(pass) == password {
+				return nil, nil
+			}
+			return nil, errors.New("denied")
+		},
+	}
+	cfg.AddHostKey(signer)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		for {
+			nc, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				_, chans, reqs, err := ssh.NewServerConn(nc, cfg)
+				if err != nil {
+					nc.Close()
+					return
+				}
+				go ssh.DiscardRequests(reqs)
+				for nch := range chans {
+					ch, chReqs, err := nch.Accept()
+					if err != nil {
+						continue
+					}
+					go func() {
+						for req := range chReqs {
+							req.Reply(req.Type == "exec", nil)
+							if req.Type == "exec" {
+								ch.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{0}))
+								ch.Close()
+							}
+						}
+					}()
+				}
+			}()
+		}
+	}()
+
+	addr := l.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port
+}
+
+func TestDialWithStoredPassword(t *testing.T) {
+	t.Setenv("SSH_AUTH_SOCK", "")
+	t.Setenv("HOME", t.TempDir())
+	host, port := startTestServer(t, "s3cret")
+
+	p := config.Profile{Name: "test", Protocol: config.ProtocolSSH, Host: host, Port: port, Username: "alice"}
+	client, err := Dial(context.Background(), p, Config{
+		Password:        "s3cret",
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	})
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer client.Close()
+
+	session, err := client.NewSession()
+	if err != nil {
+		t.Fatalf("NewSession() error = %v", err)
+	}
+	defer session.Close()
+	if err := session.Run("true"); err != nil {
+		t.Errorf("Run() error = %v", err)
+	}
+}
+
+func TestDialWrongPasswordWithoutPrompt(t *testing.T) {
+	t.Setenv("SSH_AUTH_SOCK", "")
+	t.Setenv("HOME", t.TempDir())
+	host, port := startTestServer(t, "s3cret")
+
+	p := config.Profile{Name: "test", Protocol: config.ProtocolSSH, Host: host, Port: port, Username: "alice"}
+	_, err := Dial(context.Background(), p, Config{
+		Password:        "wrong",
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	})
+	if err == nil {
+		t.Fatal("Dial() should fail with a wrong password")
+	}
+}
+
+func TestKnownHostsCallbackRejectsUnknownWithoutPrompt(t *testing.T) {
+	t.Setenv("SSH_AUTH_SOCK", "")
+	t.Setenv("HOME", t.TempDir())
+	host, port := startTestServer(t, "s3cret")
+
+	p := config.Profile{Name: "test", Protocol: config.ProtocolSSH, Host: host, Port: port, Username: "alice"}
+	_, err := Dial(context.Background(), p, Config{Password: "s3cret"})
+	if err == nil {
+		t.Fatal("Dial() should reject a host that is not in known_hosts")
+	}
+
+	// Accepting on first use records the key, so the next dial needs no prompt
+	accept := func(string, bool) (string, error) { return "yes", nil }
+	client, err := Dial(context.Background(), p, Config{Password: "s3cret", Prompt: accept})
+	if err != nil {
+		t.Fatalf("Dial() with trust prompt error = %v", err)
+	}
+	client.Close()
+
+	client, err = Dial(context.Background(), p, Config{Password: "s3cret"})
+	if err != nil {
+		t.Fatalf("Dial() after trusting host error = %v", err)
+	}
+	client.Close()
+}
+
+func TestLoadIdentityWithStoredPassphrase(t *testing.T) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := ssh.MarshalPrivateKeyWithPassphrase(priv, "", []byte("s3cret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "id_ed25519")
+	if err := os.WriteFile(path, pem.EncodeToMemory(block), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if s, err := loadIdentity(path, "s3cret", nil, true); err != nil || s == nil {
+		t.Fatalf("loadIdentity() with stored passphrase = %v, %v", s, err)
+	}
+
+	// A wrong stored passphrase falls back to the prompt
+	prompted := false
+	prompt := func(string, bool) (string, error) { prompted = true; return "s3cret", nil }
+	if s, err := loadIdentity(path, "wrong", prompt, true); err != nil || s == nil || !prompted {
+		t.Fatalf("loadIdentity() fallback = %v, %v (prompted %v)", s, err, prompted)
+	}
+
+	if _, err := loadIdentity(path, "wrong", nil, true); err == nil {
+		t.Error("loadIdentity() should fail without a usable passphrase")
+	}
+}
+
+func TestKeyboardInteractiveAnswersOTP(t *testing.T) {
+	challenge := keyboardInteractive("pw", "JBSWY3DPEHPK3PXP", nil)
+	answers, err := challenge("", "", []string{"Password: ", "Verification code: "}, []bool{false, false})
+	if err != nil {
+		t.Fatalf("challenge error = %v", err)
+	}
+	want, _ := credentials.TOTPCode("JBSWY3DPEHPK3PXP", time.Now())
+	if answers[0] != "pw" || answers[1] != want {
+		t.Errorf("answers = %q, want [pw %s]", answers, want)
+	}
+
+	if _, err := keyboardInteractive("pw", "", nil)("", "", []string{"Verification code: "}, []bool{false}); err == nil {
+		t.Error("OTP question without a seed or prompt should fail")
+	}
+}
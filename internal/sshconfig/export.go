@@ -0,0 +1,312 @@
+package sshconfig
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/vee-sh/veessh/internal/config"
+	"github.com/vee-sh/veessh/internal/connectors"
+)
+
+// Markers around the section of ~/.ssh/config that veessh owns
+const (
+	BeginMarker = "# BEGIN veessh managed block"
+	EndMarker   = "# END veessh managed block"
+)
+
+// IncludeFile is the file sync-ssh-config writes hosts to in include mode,
+// relative to ~/.ssh
+const IncludeFile = "veessh.conf"
+
+// Render writes every profile that ssh can reach as a Host entry, with
+// Extends chains flattened. Profiles of other protocols and parents without
+// a host are skipped and reported.
+func Render(cfg config.Config) ([]byte, []ReportEntry) {
+	var buf bytes.Buffer
+	var report []ReportEntry
+	skip := func(name, format string, args ...any) {
+		report = append(report, ReportEntry{Action: ActionDropped, Profile: name, Detail: fmt.Sprintf(format, args...)})
+	}
+
+	for _, listed := range cfg.ListProfiles() {
+		p, _ := cfg.GetProfile(listed.Name)
+		switch p.Protocol {
+		case config.ProtocolSSH, config.ProtocolSFTP, config.ProtocolMosh, "":
+		default:
+			skip(p.Name, "%s profiles are not reachable with ssh", p.Protocol)
+			continue
+		}
+		if strings.TrimSpace(p.Host) == "" {
+			skip(p.Name, "no host (parent profile)")
+			continue
+		}
+		if strings.ContainsAny(p.Name, " \t*?!,\"") || strings.ContainsFunc(p.Name, isControl) {
+			skip(p.Name, "name is not a valid ssh Host alias")
+			continue
+		}
+		lines := hostLines(p, func(format string, args ...any) { skip(p.Name, format, args...) })
+		// A line break in a value would start a directive of its own, which
+		// everything reading the ssh config (git, editors) would obey
+		if i := slices.IndexFunc(lines, func(line string) bool { return strings.ContainsFunc(line, isControl) }); i >= 0 {
+			skip(p.Name, "%q contains control characters", lines[i])
+			continue
+		}
+
+		if buf.Len() > 0 {
+			buf.WriteString("\n")
+		}
+		if p.Description != "" {
+			fmt.Fprintf(&buf, "# %s\n", strings.Map(func(r rune) rune {
+				if unicode.IsControl(r) {
+					return ' '
+				}
+				return r
+			}, p.Description))
+		}
+		fmt.Fprintf(&buf, "Host %s\n", p.Name)
+		for _, line := range lines {
+			fmt.Fprintf(&buf, "  %s\n", line)
+		}
+		report = append(report, ReportEntry{Action: ActionExported, Profile: p.Name, Detail: "Host " + p.Name})
+	}
+	return buf.Bytes(), report
+}
+
+// hostLines maps a resolved profile onto ssh_config keywords
+func hostLines(p config.Profile, warn func(string, ...any)) []string {
+	var lines []string
+	add := func(key string, args ...string) {
+		quoted := make([]string, len(args))
+		for i, a := range args {
+			quoted[i] = quoteArg(a)
+		}
+		lines = append(lines, key+" "+strings.Join(quoted, " "))
+	}
+
+	add("HostName", p.Host)
+	if p.Port > 0 {
+		add("Port", strconv.Itoa(p.Port))
+	}
+	if p.Username != "" {
+		add("User", p.Username)
+	}
+	if p.IdentityFile != "" {
+		add("IdentityFile", p.IdentityFile)
+	}
+	if p.ProxyJump != "" {
+		add("ProxyJump", p.ProxyJump)
+	}
+	if config.IsTrue(p.ForwardAgent) {
+		add("ForwardAgent", "yes")
+	}
+	local, remote, dynamic := p.ForwardSpecs()
+	for _, spec := range local {
+		if args, ok := forwardArgs(spec); ok && len(args) == 2 {
+			add("LocalForward", args...)
+		} else {
+			warn("LocalForward %q is not a valid forward", spec)
+		}
+	}
+	for _, spec := range remote {
+		if args, ok := forwardArgs(spec); ok {
+			add("RemoteForward", args...)
+		} else {
+			warn("RemoteForward %q is not a valid forward", spec)
+		}
+	}
+	for _, spec := range dynamic {
+		add("DynamicForward", spec)
+	}
+	if len(p.SetEnv) > 0 {
+		add("SetEnv", p.SetEnv...)
+	}
+	if cmd := connectors.BuildRemoteCommand(p); cmd != "" {
+		add("RemoteCommand", cmd)
+		add("RequestTTY", "yes")
+	}
+
+	// extraArgs are ssh command line arguments; keep what has a config form
+	args := p.ExtraArgs
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "-o" && i+1 < len(args):
+			i++
+			if key, value, ok := splitOption(args[i]); ok {
+				add(key, value)
+			} else {
+				warn("extra argument -o %q is not Key=Value", args[i])
+			}
+		case strings.HasPrefix(arg, "-o") && len(arg) > 2:
+			if key, value, ok := splitOption(arg[2:]); ok {
+				add(key, value)
+			} else {
+				warn("extra argument %q is not Key=Value", arg)
+			}
+		case arg == "-i" && i+1 < len(args):
+			i++
+			add("IdentityFile", args[i])
+		case arg == "-A":
+			add("ForwardAgent", "yes")
+		case arg == "-C":
+			add("Compression", "yes")
+		default:
+			warn("extra argument %q has no ssh config equivalent", arg)
+		}
+	}
+	return lines
+}
+
+func splitOption(s string) (string, string, bool) {
+	key, value, ok := strings.Cut(s, "=")
+	if !ok {
+		key, value, ok = strings.Cut(s, " ")
+	}
+	key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+	return key, value, ok && key != "" && value != ""
+}
+
+// forwardArgs turns "[bind:]port:host:hostport" into the two arguments of
+// LocalForward/RemoteForward. A bare "[bind:]port" (dynamic remote forward)
+// is returned as one argument.
+func forwardArgs(spec string) ([]string, bool) {
+	var fields []string
+	rest := spec
+	for rest != "" {
+		if strings.HasPrefix(rest, "[") {
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				return nil, false
+			}
+			fields = append(fields, rest[:end+1])
+			rest = strings.TrimPrefix(rest[end+1:], ":")
+			continue
+		}
+		field, after, found := strings.Cut(rest, ":")
+		fields = append(fields, field)
+		rest = after
+		if !found {
+			break
+		}
+	}
+	switch len(fields) {
+	case 1, 2:
+		return []string{spec}, true
+	case 3:
+		return []string{fields[0], fields[1] + ":" + fields[2]}, true
+	case 4:
+		return []string{fields[0] + ":" + fields[1], fields[2] + ":" + fields[3]}, true
+	}
+	return nil, false
+}
+
+// isControl reports control characters other than tabs, which quoteArg
+// quotes
+func isControl(r rune) bool {
+	return r != '\t' && unicode.IsControl(r)
+}
+
+func quoteArg(s string) string {
+	if s == "" || strings.ContainsAny(s, " \t#") {
+		return `"` + s + `"`
+	}
+	return s
+}
+
+// ManagedBlock wraps content in the begin/end markers
+func ManagedBlock(content []byte) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(BeginMarker + " (generated by 'veessh sync-ssh-config', do not edit)\n")
+	buf.Write(content)
+	if len(content) > 0 && !bytes.HasSuffix(content, []byte("\n")) {
+		buf.WriteString("\n")
+	}
+	buf.WriteString(EndMarker + "\n")
+	return buf.Bytes()
+}
+
+// ReplaceManagedBlock swaps the managed block in an ssh config for block,
+// or inserts it at the top so veessh hosts take precedence over later
+// wildcard blocks. An empty block removes the managed section.
+func ReplaceManagedBlock(existing, block []byte) ([]byte, error) {
+	lines := strings.SplitAfter(string(existing), "\n")
+	begin, end := -1, -1
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, BeginMarker) {
+			if begin >= 0 {
+				return nil, fmt.Errorf("ssh config has more than one veessh managed block")
+			}
+			begin = i
+		} else if trimmed == EndMarker && begin >= 0 && end < 0 {
+			end = i
+		}
+	}
+	if begin >= 0 && end < 0 {
+		return nil, fmt.Errorf("ssh config has a veessh begin marker without an end marker")
+	}
+
+	var out strings.Builder
+	if begin < 0 {
+		if len(block) == 0 {
+			return existing, nil
+		}
+		out.Write(block)
+		if len(existing) > 0 {
+			out.WriteString("\n")
+		}
+		out.Write(existing)
+		return []byte(out.String()), nil
+	}
+
+	before := strings.Join(lines[:begin], "")
+	after := strings.Join(lines[end+1:], "")
+	out.WriteString(before)
+	out.Write(block)
+	if len(block) == 0 {
+		// Drop the blank line that separated the block from the rest
+		after = strings.TrimPrefix(after, "\n")
+	}
+	out.WriteString(after)
+	return []byte(out.String()), nil
+}
+
+// WriteFile atomically replaces path with data, creating ~/.ssh style
+// private directories and files
+func WriteFile(path string, data []byte) error {
+	// Keep symlinked configs (dotfile managers) as symlinks
+	if real, err := filepath.EvalSymlinks(path); err == nil {
+		path = real
+	}
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	mode := os.FileMode(0o600)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+"-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,148 @@
+package sshconfig
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/vee-sh/veessh/internal/config"
+)
+
+func TestForwardArgs(t *testing.T) {
+	tests := []struct {
+		spec string
+		want []string
+	}{
+		{"8080:internal:80", []string{"8080", "internal:80"}},
+		{"127.0.0.1:8080:internal:80", []string{"127.0.0.1:8080", "internal:80"}},
+		{"[::1]:8080:[2001:db8::2]:80", []string{"[::1]:8080", "[2001:db8::2]:80"}},
+		{"1080", []string{"1080"}},
+		{"a:b:c:d:e", nil},
+	}
+	for _, tt := range tests {
+		got, _ := forwardArgs(tt.spec)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("forwardArgs(%q) = %q, want %q", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestRenderRoundTrip(t *testing.T) {
+	cfg := config.Config{Profiles: map[string]config.Profile{
+		"base": {Name: "base", Protocol: config.ProtocolSSH, Username: "deploy", ProxyJump: "bastion", ForwardAgent: config.Bool(true)},
+		"web": {
+			Name: "web", Protocol: config.ProtocolSSH, Host: "web.example.com", Port: 2222, Extends: config.Parents{"base"},
+			Description:   "frontend",
+			LocalForwards: []string{"5432:db:5432"},
+			SetEnv:        []string{"APP=web", "GREETING=hello world"},
+			RemoteDir:     "/srv/app",
+			ExtraArgs:     []string{"-o", "ServerAliveInterval=30", "-v"},
+		},
+		"cloud": {Name: "cloud", Protocol: config.ProtocolSSM, InstanceID: "i-123"},
+	}}
+
+	data, report := Render(cfg)
+	text := string(data)
+	if strings.Contains(text, "Host base") || strings.Contains(text, "Host cloud") {
+		t.Errorf("parent and SSM profiles should be skipped:\n%s", text)
+	}
+
+	var dropped []string
+	for _, e := range report {
+		if e.Action == ActionDropped {
+			dropped = append(dropped, e.Profile+": "+e.Detail)
+		}
+	}
+	if len(dropped) != 3 || !strings.Contains(strings.Join(dropped, "\n"), `"-v" has no ssh config equivalent`) {
+		t.Errorf("dropped = %q", dropped)
+	}
+
+	// Reading the output back gives ssh the flattened profile
+	path := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := profileFromOptions(parsed.Lookup("web"), "web")
+	if got.Host != "web.example.com" || got.Port != 2222 || got.Username != "deploy" || got.ProxyJump != "bastion" || !config.IsTrue(got.ForwardAgent) {
+		t.Errorf("round trip = %+v", got)
+	}
+	if !reflect.DeepEqual(got.LocalForwards, []string{"5432:db:5432"}) || !reflect.DeepEqual(got.SetEnv, []string{"APP=web", "GREETING=hello world"}) {
+		t.Errorf("round trip forwards/env = %q %q", got.LocalForwards, got.SetEnv)
+	}
+	if got.RemoteCommand != "cd '/srv/app' && exec $SHELL -l" {
+		t.Errorf("round trip RemoteCommand = %q", got.RemoteCommand)
+	}
+}
+
+func TestRenderControlCharacters(t *testing.T) {
+	evil := "\n  ProxyCommand sh -c 'touch /tmp/pwned'"
+	cfg := config.Config{Profiles: map[string]config.Profile{
+		"host":    {Name: "host", Protocol: config.ProtocolSSH, Host: "x" + evil},
+		"user":    {Name: "user", Protocol: config.ProtocolSSH, Host: "x", Username: "u\r" + evil},
+		"env":     {Name: "env", Protocol: config.ProtocolSSH, Host: "x", SetEnv: []string{"A=1" + evil}},
+		"command": {Name: "command", Protocol: config.ProtocolSSH, Host: "x", RemoteCommand: "uptime" + evil},
+		"extra":   {Name: "extra", Protocol: config.ProtocolSSH, Host: "x", ExtraArgs: []string{"-o", "User=u" + evil}},
+		"ok": {Name: "ok", Protocol: config.ProtocolSSH, Host: "ok.example.com", Description: "line one\nProxyCommand sh",
+			SetEnv: []string{"GREETING=hello\tworld"}},
+	}}
+
+	data, report := Render(cfg)
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "ProxyCommand") {
+			t.Errorf("control characters reached the ssh config:\n%s", data)
+		}
+	}
+	if !strings.Contains(string(data), "Host ok\n") {
+		t.Errorf("clean profile not exported:\n%s", data)
+	}
+	dropped := map[string]bool{}
+	for _, e := range report {
+		if e.Action == ActionDropped && strings.Contains(e.Detail, "control characters") {
+			dropped[e.Profile] = true
+		}
+	}
+	for _, name := range []string{"host", "user", "env", "command", "extra"} {
+		if !dropped[name] {
+			t.Errorf("%s not reported as dropped: %+v", name, report)
+		}
+	}
+}
+
+func TestReplaceManagedBlock(t *testing.T) {
+	user := "Host old\n  User x\n"
+	block := ManagedBlock([]byte("Host a\n  HostName a.example.com\n"))
+
+	inserted, err := ReplaceManagedBlock([]byte(user), block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(inserted), BeginMarker) || !strings.HasSuffix(string(inserted), user) {
+		t.Errorf("insert = %q", inserted)
+	}
+
+	replaced, err := ReplaceManagedBlock(inserted, ManagedBlock([]byte("Host b\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(replaced), "Host a") || !strings.Contains(string(replaced), "Host b") || strings.Count(string(replaced), BeginMarker) != 1 {
+		t.Errorf("replace = %q", replaced)
+	}
+
+	removed, err := ReplaceManagedBlock(replaced, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(removed) != user {
+		t.Errorf("remove = %q, want %q", removed, user)
+	}
+
+	if _, err := ReplaceManagedBlock([]byte(BeginMarker+"\nHost a\n"), block); err == nil {
+		t.Error("a begin marker without an end marker should fail")
+	}
+}
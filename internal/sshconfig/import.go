@@ -0,0 +1,428 @@
+package sshconfig
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/vee-sh/veessh/internal/config"
+)
+
+// Report actions
+const (
+	ActionImported = "imported"
+	ActionMerged   = "merged"
+	ActionDropped  = "dropped"
+	ActionExported = "exported"
+)
+
+// ReportEntry explains what happened to one profile, block or option
+type ReportEntry struct {
+	Action  string `json:"action"`
+	Profile string `json:"profile,omitempty"`
+	Source  string `json:"source,omitempty"` // file:line in the ssh config
+	Detail  string `json:"detail"`
+}
+
+// ImportOptions control how profiles are named and grouped
+type ImportOptions struct {
+	Prefix string
+	Group  string
+}
+
+// ImportResult holds the profiles built from an ssh config, parents before
+// the profiles that extend them
+type ImportResult struct {
+	Profiles []config.Profile
+	Report   []ReportEntry
+}
+
+// parent is a profile built from one or more wildcard blocks with the same
+// patterns
+type parent struct {
+	name     string
+	patterns []string
+	blocks   []*Block
+	global   bool // patterns include a bare "*"
+}
+
+func (p *parent) matches(host string) bool {
+	return matchList(p.patterns, host)
+}
+
+// Import turns a parsed ssh config into veessh profiles. Every concrete
+// Host alias becomes a profile; wildcard blocks (Host *.prod, Match host
+// web-*) become parent profiles the concrete ones extend. Each concrete
+// profile only stores what differs from its parent chain, so resolving it
+// yields exactly what ssh would use for that alias.
+func Import(cfg *Config, opts ImportOptions) ImportResult {
+	var res ImportResult
+	report := func(action, profile, source, format string, args ...any) {
+		res.Report = append(res.Report, ReportEntry{Action: action, Profile: profile, Source: source, Detail: fmt.Sprintf(format, args...)})
+	}
+
+	// Collect aliases and wildcard parents in file order
+	var aliases []string
+	aliasSource := map[string]string{}
+	var parents []*parent
+	byPatterns := map[string]*parent{}
+	usedNames := map[string]bool{}
+
+	for _, b := range cfg.Blocks {
+		patterns, ok, reason := b.HostPatterns()
+		if !ok {
+			report(ActionDropped, "", b.Source(), "%s: %s; %d option(s) not imported", b, reason, len(b.Options))
+			continue
+		}
+		positive, wildcard := 0, false
+		for _, pat := range patterns {
+			for _, p := range strings.Split(pat, ",") {
+				if strings.HasPrefix(p, "!") || p == "" {
+					continue
+				}
+				positive++
+				if HasWildcard(p) {
+					wildcard = true
+				} else if _, seen := aliasSource[p]; !seen {
+					aliasSource[p] = b.Source()
+					aliases = append(aliases, p)
+				}
+			}
+		}
+		if positive == 0 {
+			report(ActionDropped, "", b.Source(), "%s: only negated patterns, it never matches", b)
+			continue
+		}
+		if !wildcard || len(b.Options) == 0 {
+			continue
+		}
+
+		key := strings.Join(patterns, " ")
+		if par, ok := byPatterns[key]; ok {
+			par.blocks = append(par.blocks, b)
+			report(ActionMerged, par.name, b.Source(), "%s merged into the parent of the earlier block with the same patterns", b)
+			continue
+		}
+		par := &parent{name: opts.Prefix + parentName(patterns, usedNames), patterns: patterns, blocks: []*Block{b}}
+		for _, p := range patterns {
+			if p == "*" {
+				par.global = true
+			}
+		}
+		byPatterns[key] = par
+		parents = append(parents, par)
+	}
+
+	var global *parent
+	for _, par := range parents {
+		if par.global {
+			global = par
+			break
+		}
+	}
+
+	// Parent profiles
+	profiles := map[string]config.Profile{}
+	for _, par := range parents {
+		var options []Option
+		seen := map[string]bool{}
+		for _, b := range par.blocks {
+			for _, o := range b.Options {
+				if !multiValued[o.Key()] {
+					if seen[o.Key()] {
+						continue
+					}
+					seen[o.Key()] = true
+				}
+				options = append(options, o)
+			}
+		}
+		p, drops := profileFromOptions(options, "")
+		for _, d := range drops {
+			report(ActionDropped, par.name, d.Source(), "%s %s: %s", d.opt.Name, d.opt.Value(), d.reason)
+		}
+		p.Name = par.name
+		p.Group = opts.Group
+		p.Description = "ssh config: " + par.blocks[0].String()
+		if global != nil && par != global {
+			p.Extends = config.Parents{global.name}
+		}
+		profiles[p.Name] = p
+		res.Profiles = append(res.Profiles, p)
+		report(ActionImported, p.Name, par.blocks[0].Source(), "parent profile for %s", par.blocks[0])
+	}
+
+	// Concrete hosts
+	for _, alias := range aliases {
+		name := opts.Prefix + alias
+		effective := cfg.Lookup(alias)
+		full, drops := profileFromOptions(effective, alias)
+		for _, d := range drops {
+			report(ActionDropped, name, d.Source(), "%s %s: %s", d.opt.Name, d.opt.Value(), d.reason)
+		}
+		full.Name = name
+		full.Group = opts.Group
+		full.Description = "imported from ssh config"
+
+		// Extend the first wildcard parent that applies, else the global one
+		var chain *parent
+		for _, par := range parents {
+			if !par.global && par.matches(alias) {
+				chain = par
+				break
+			}
+		}
+		if chain == nil && global != nil && global.matches(alias) {
+			chain = global
+		}
+
+		p := full
+		if chain != nil {
+			resolver := config.Config{Profiles: profiles}
+			base, _ := resolver.GetProfile(chain.name)
+			p = diffProfile(full, base)
+			p.Extends = config.Parents{chain.name}
+
+			resolver.Profiles[name] = p
+			got, _ := resolver.GetProfile(name)
+			delete(resolver.Profiles, name)
+			for _, field := range mismatches(got, full) {
+				report(ActionDropped, name, aliasSource[alias], "%s inherited from %s differs from ssh and cannot be overridden", field, chain.name)
+			}
+
+			// Options of other wildcard blocks end up stored in the profile
+			// itself, since imported profiles extend a single parent
+			for _, par := range parents {
+				if par == chain || par == global || !par.matches(alias) {
+					continue
+				}
+				if contributes(par, effective) {
+					report(ActionMerged, name, par.blocks[0].Source(), "options of %s copied into the profile (it extends %s)", par.blocks[0], chain.name)
+				}
+			}
+		}
+
+		profiles[name] = p
+		res.Profiles = append(res.Profiles, p)
+		detail := "Host " + alias
+		if len(p.Extends) > 0 {
+			detail += ", extends " + p.Extends.String()
+		}
+		report(ActionImported, name, aliasSource[alias], "%s", detail)
+	}
+
+	return res
+}
+
+// contributes reports whether any effective option came from the parent's
+// blocks
+func contributes(par *parent, effective []Option) bool {
+	for _, b := range par.blocks {
+		for _, o := range b.Options {
+			for _, e := range effective {
+				if e.File == o.File && e.Line == o.Line {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// mismatches lists the mapped fields where a resolved profile differs from
+// what ssh would use
+func mismatches(got, want config.Profile) []string {
+	var fields []string
+	check := func(name string, a, b any) {
+		if !reflect.DeepEqual(a, b) {
+			fields = append(fields, name)
+		}
+	}
+	check("Hostname", got.Host, want.Host)
+	check("Port", got.Port, want.Port)
+	check("User", got.Username, want.Username)
+	check("IdentityFile", got.IdentityFile, want.IdentityFile)
+	check("ProxyJump", got.ProxyJump, want.ProxyJump)
+	check("RemoteCommand", got.RemoteCommand, want.RemoteCommand)
+	check("ForwardAgent", config.IsTrue(got.ForwardAgent), config.IsTrue(want.ForwardAgent))
+	check("options", got.ExtraArgs, want.ExtraArgs)
+	check("LocalForward", got.LocalForwards, want.LocalForwards)
+	check("RemoteForward", got.RemoteForwards, want.RemoteForwards)
+	check("DynamicForward", got.DynamicForwards, want.DynamicForwards)
+	check("SetEnv", got.SetEnv, want.SetEnv)
+	return fields
+}
+
+// parentName derives a readable profile name from wildcard patterns:
+// "*" -> "all-hosts", "*.prod" -> "prod-hosts", "web-*" -> "web-hosts"
+func parentName(patterns []string, used map[string]bool) string {
+	var parts []string
+	for _, p := range patterns {
+		if strings.HasPrefix(p, "!") {
+			continue
+		}
+		p = strings.Trim(p, "*?.-_")
+		p = strings.NewReplacer("*", "x", "?", "x").Replace(p)
+		if p != "" {
+			parts = append(parts, p)
+		}
+	}
+	base := "all-hosts"
+	if len(parts) > 0 {
+		base = strings.Join(parts, "+") + "-hosts"
+	}
+	name := base
+	for i := 2; used[name]; i++ {
+		name = base + "-" + strconv.Itoa(i)
+	}
+	used[name] = true
+	return name
+}
+
+type drop struct {
+	opt    Option
+	reason string
+}
+
+func (d drop) Source() string { return d.opt.Source() }
+
+// profileFromOptions maps effective ssh options onto a profile. alias is
+// the Host alias, used to expand %h; it is empty for parent profiles.
+// Options without a profile field are passed to ssh as -o Key=Value.
+func profileFromOptions(options []Option, alias string) (config.Profile, []drop) {
+	p := config.Profile{Protocol: config.ProtocolSSH, Host: alias}
+	var drops []drop
+	envSeen := map[string]bool{}
+
+	for _, o := range options {
+		if len(o.Args) == 0 {
+			drops = append(drops, drop{o, "no value"})
+			continue
+		}
+		value := o.Value()
+		switch o.Key() {
+		case "hostname":
+			if alias == "" && strings.Contains(value, "%h") {
+				drops = append(drops, drop{o, "%h depends on the host alias, so it is expanded into each host profile instead"})
+				continue
+			}
+			p.Host = strings.NewReplacer("%h", alias, "%%", "%").Replace(value)
+		case "user":
+			p.Username = value
+		case "port":
+			port, err := strconv.Atoi(value)
+			if err != nil || port <= 0 || port > 65535 {
+				drops = append(drops, drop{o, "invalid port"})
+				continue
+			}
+			p.Port = port
+		case "identityfile":
+			path := expandTilde(value)
+			if p.IdentityFile == "" {
+				p.IdentityFile = path
+			} else {
+				p.ExtraArgs = append(p.ExtraArgs, "-i", path)
+			}
+		case "proxyjump":
+			if !strings.EqualFold(value, "none") {
+				p.ProxyJump = value
+			}
+		case "localforward":
+			p.LocalForwards = append(p.LocalForwards, strings.Join(o.Args, ":"))
+		case "remoteforward":
+			p.RemoteForwards = append(p.RemoteForwards, strings.Join(o.Args, ":"))
+		case "dynamicforward":
+			p.DynamicForwards = append(p.DynamicForwards, value)
+		case "setenv":
+			for _, env := range o.Args {
+				k, _, ok := strings.Cut(env, "=")
+				if !ok || k == "" {
+					drops = append(drops, drop{o, fmt.Sprintf("%q is not NAME=VALUE", env)})
+					continue
+				}
+				if !envSeen[k] {
+					envSeen[k] = true
+					p.SetEnv = append(p.SetEnv, env)
+				}
+			}
+		case "remotecommand":
+			if !strings.EqualFold(value, "none") {
+				p.RemoteCommand = value
+			}
+		case "forwardagent":
+			switch strings.ToLower(value) {
+			case "yes", "true":
+				p.ForwardAgent = config.Bool(true)
+			case "no", "false":
+				p.ForwardAgent = config.Bool(false)
+			default:
+				// A socket path or environment variable
+				p.ExtraArgs = append(p.ExtraArgs, "-o", o.Name+"="+value)
+			}
+		default:
+			p.ExtraArgs = append(p.ExtraArgs, "-o", o.Name+"="+value)
+		}
+	}
+	return p, drops
+}
+
+// diffProfile returns the fields of full that its resolved parent does not
+// already provide. Lists are kept whole because a child's list replaces the
+// parent's when profiles are resolved.
+func diffProfile(full, base config.Profile) config.Profile {
+	out := config.Profile{
+		Name:        full.Name,
+		Protocol:    full.Protocol,
+		Group:       full.Group,
+		Description: full.Description,
+	}
+	if full.Host != base.Host {
+		out.Host = full.Host
+	}
+	if full.Port != base.Port {
+		out.Port = full.Port
+	}
+	if full.Username != base.Username {
+		out.Username = full.Username
+	}
+	if full.IdentityFile != base.IdentityFile {
+		out.IdentityFile = full.IdentityFile
+	}
+	if full.ProxyJump != base.ProxyJump {
+		out.ProxyJump = full.ProxyJump
+	}
+	if full.RemoteCommand != base.RemoteCommand {
+		out.RemoteCommand = full.RemoteCommand
+	}
+	if config.IsTrue(full.ForwardAgent) != config.IsTrue(base.ForwardAgent) {
+		out.ForwardAgent = full.ForwardAgent
+	}
+	if !reflect.DeepEqual(full.ExtraArgs, base.ExtraArgs) {
+		out.ExtraArgs = full.ExtraArgs
+	}
+	if !reflect.DeepEqual(full.LocalForwards, base.LocalForwards) {
+		out.LocalForwards = full.LocalForwards
+	}
+	if !reflect.DeepEqual(full.RemoteForwards, base.RemoteForwards) {
+		out.RemoteForwards = full.RemoteForwards
+	}
+	if !reflect.DeepEqual(full.DynamicForwards, base.DynamicForwards) {
+		out.DynamicForwards = full.DynamicForwards
+	}
+	if !reflect.DeepEqual(full.SetEnv, base.SetEnv) {
+		out.SetEnv = full.SetEnv
+	}
+	return out
+}
+
+// DefaultPath returns ~/.ssh/config
+func DefaultPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".ssh", "config"), nil
+}
@@ -0,0 +1,165 @@
+package sshconfig
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/vee-sh/veessh/internal/config"
+)
+
+const teamConfig = `Host web1.prod
+  Port 2222
+  SetEnv APP=web "GREETING=hello world"
+  RemoteCommand "tmux attach || tmux new"
+
+Host db1.prod
+  User postgres
+  DynamicForward 1080
+
+Host bastion
+  HostName bastion.example.com
+
+Host *.prod
+  User deploy
+  ProxyJump bastion
+  ForwardAgent yes
+  LocalForward 5432 db.internal:5432
+
+Host web*
+  Compression yes
+
+Host legacy
+  HostName %h.old.example.com
+
+Match user root
+  IdentityFile ~/.ssh/root
+
+Host *
+  ServerAliveInterval 30
+  IdentityFile ~/.ssh/id_team
+`
+
+func importTeam(t *testing.T) (ImportResult, config.Config) {
+	t.Helper()
+	dir := writeFiles(t, map[string]string{"config": teamConfig})
+	cfg, err := ParseFile(filepath.Join(dir, "config"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := Import(cfg, ImportOptions{Group: "team"})
+	all := config.Config{Profiles: map[string]config.Profile{}}
+	for _, p := range res.Profiles {
+		all.UpsertProfile(p)
+	}
+	return res, all
+}
+
+func TestImportResolvesLikeSSH(t *testing.T) {
+	res, all := importTeam(t)
+
+	names := []string{}
+	for _, p := range res.Profiles {
+		names = append(names, p.Name)
+	}
+	want := []string{"prod-hosts", "web-hosts", "all-hosts", "web1.prod", "db1.prod", "bastion", "legacy"}
+	if !reflect.DeepEqual(names, want) {
+		t.Fatalf("profiles = %q, want %q", names, want)
+	}
+
+	web, _ := all.GetProfile("web1.prod")
+	if web.Host != "web1.prod" || web.Port != 2222 || web.Username != "deploy" || web.ProxyJump != "bastion" || !config.IsTrue(web.ForwardAgent) {
+		t.Errorf("web1.prod = %+v", web)
+	}
+	if !reflect.DeepEqual(web.LocalForwards, []string{"5432:db.internal:5432"}) {
+		t.Errorf("web1.prod LocalForwards = %q", web.LocalForwards)
+	}
+	if !reflect.DeepEqual(web.SetEnv, []string{"APP=web", "GREETING=hello world"}) || web.RemoteCommand != "tmux attach || tmux new" {
+		t.Errorf("web1.prod SetEnv = %q, RemoteCommand = %q", web.SetEnv, web.RemoteCommand)
+	}
+	// Options of the second wildcard block and of Host * are kept too
+	if !reflect.DeepEqual(web.ExtraArgs, []string{"-o", "Compression=yes", "-o", "ServerAliveInterval=30"}) {
+		t.Errorf("web1.prod ExtraArgs = %q", web.ExtraArgs)
+	}
+	if web.IdentityFile == "" || !strings.HasSuffix(web.IdentityFile, filepath.Join(".ssh", "id_team")) {
+		t.Errorf("web1.prod IdentityFile = %q", web.IdentityFile)
+	}
+
+	raw := all.Profiles["db1.prod"]
+	if raw.Extends.String() != "prod-hosts" || raw.ProxyJump != "" || raw.Username != "postgres" {
+		t.Errorf("db1.prod should only store its own settings, got %+v", raw)
+	}
+	db, _ := all.GetProfile("db1.prod")
+	if db.Username != "postgres" || db.ProxyJump != "bastion" || !reflect.DeepEqual(db.DynamicForwards, []string{"1080"}) {
+		t.Errorf("db1.prod resolved = %+v", db)
+	}
+
+	bastion, _ := all.GetProfile("bastion")
+	if bastion.Host != "bastion.example.com" || bastion.Username != "" || len(bastion.Extends) != 0 {
+		t.Errorf("bastion = %+v", bastion)
+	}
+	if all.Profiles["bastion"].Extends.String() != "all-hosts" {
+		t.Errorf("bastion extends %v, want all-hosts", all.Profiles["bastion"].Extends)
+	}
+
+	legacy, _ := all.GetProfile("legacy")
+	if legacy.Host != "legacy.old.example.com" {
+		t.Errorf("legacy Host = %q, want %%h expanded", legacy.Host)
+	}
+	for _, p := range res.Profiles {
+		if p.Group != "team" {
+			t.Errorf("%s group = %q", p.Name, p.Group)
+		}
+	}
+}
+
+func TestImportReport(t *testing.T) {
+	res, _ := importTeam(t)
+
+	find := func(action, profile, detail string) bool {
+		for _, e := range res.Report {
+			if e.Action == action && e.Profile == profile && strings.Contains(e.Detail, detail) {
+				return true
+			}
+		}
+		return false
+	}
+	if !find(ActionDropped, "", `"user" depends on runtime conditions`) {
+		t.Errorf("Match user block not reported as dropped: %+v", res.Report)
+	}
+	if !find(ActionMerged, "web1.prod", "Host web*") {
+		t.Errorf("web* options copied into web1.prod not reported: %+v", res.Report)
+	}
+	if !find(ActionImported, "db1.prod", "extends prod-hosts") {
+		t.Errorf("db1.prod import not reported: %+v", res.Report)
+	}
+	for _, e := range res.Report {
+		if e.Source == "" {
+			t.Errorf("report entry without source: %+v", e)
+		}
+	}
+}
+
+func TestImportMergesRepeatedWildcards(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"config": "User first\nHost a\n  Port 1\nHost *\n  User second\n  Port 99\n"})
+	cfg, err := ParseFile(filepath.Join(dir, "config"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := Import(cfg, ImportOptions{Prefix: "x-"})
+	all := config.Config{Profiles: map[string]config.Profile{}}
+	for _, p := range res.Profiles {
+		all.UpsertProfile(p)
+	}
+	if len(res.Profiles) != 2 {
+		t.Fatalf("profiles = %+v, want x-all-hosts and x-a", res.Profiles)
+	}
+	a, _ := all.GetProfile("x-a")
+	if a.Username != "first" || a.Port != 1 {
+		t.Errorf("x-a = %+v, want first User and own Port", a)
+	}
+	if all.Profiles["x-a"].Extends.String() != "x-all-hosts" {
+		t.Errorf("x-a extends %v", all.Profiles["x-a"].Extends)
+	}
+}
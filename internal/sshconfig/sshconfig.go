@@ -0,0 +1,358 @@
+// Package sshconfig reads OpenSSH client configuration files (ssh_config(5)),
+// following Include directives and keeping Host and Match blocks in file
+// order so lookups follow OpenSSH's first-match-wins rules.
+package sshconfig
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// maxIncludeDepth mirrors OpenSSH's READCONF_MAX_DEPTH
+const maxIncludeDepth = 16
+
+// Option is one keyword line of a config file
+type Option struct {
+	Name string   // keyword as written, e.g. "LocalForward"
+	Args []string // arguments with quotes removed
+	File string
+	Line int
+}
+
+// Key returns the case-insensitive keyword
+func (o Option) Key() string { return strings.ToLower(o.Name) }
+
+// Value returns the arguments joined by spaces
+func (o Option) Value() string { return strings.Join(o.Args, " ") }
+
+// Source returns "file:line" for reports
+func (o Option) Source() string { return fmt.Sprintf("%s:%d", o.File, o.Line) }
+
+// Block is a Host or Match section. Options before the first Host or Match
+// line of a file form an implicit "Host *" block.
+type Block struct {
+	Match    bool     // Match block rather than Host block
+	Patterns []string // Host patterns, or the Match criteria words
+	Implicit bool     // options before the first Host/Match line
+	Options  []Option
+	File     string
+	Line     int
+}
+
+// Source returns "file:line" for reports
+func (b *Block) Source() string { return fmt.Sprintf("%s:%d", b.File, b.Line) }
+
+// String renders the block header, e.g. "Host *.prod !db.prod"
+func (b *Block) String() string {
+	if b.Match {
+		return "Match " + strings.Join(b.Patterns, " ")
+	}
+	return "Host " + strings.Join(b.Patterns, " ")
+}
+
+// Config is a parsed config file together with everything it includes
+type Config struct {
+	Blocks []*Block
+	Files  []string // every file read, in include order
+}
+
+// ParseFile reads an ssh config file. Relative Include paths are resolved
+// against the directory of path, which is ~/.ssh for the user config.
+func ParseFile(path string) (*Config, error) {
+	p := &parser{cfg: &Config{}, base: filepath.Dir(path)}
+	if err := p.parseFile(path, 0); err != nil {
+		return nil, err
+	}
+	return p.cfg, nil
+}
+
+type parser struct {
+	cfg  *Config
+	base string
+	cur  *Block // nil at top level until the first option
+
+	// resume is the block that continues after an Include which opened
+	// blocks of its own; it is recreated on its next option
+	resume *Block
+}
+
+func (p *parser) parseFile(path string, depth int) error {
+	if depth > maxIncludeDepth {
+		return fmt.Errorf("%s: too many nested Include directives", path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	p.cfg.Files = append(p.cfg.Files, path)
+
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	line := 0
+	for sc.Scan() {
+		line++
+		name, args, err := splitLine(sc.Text())
+		if err != nil {
+			return fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		if name == "" {
+			continue
+		}
+
+		switch strings.ToLower(name) {
+		case "host", "match":
+			if len(args) == 0 {
+				return fmt.Errorf("%s:%d: %s needs an argument", path, line, name)
+			}
+			p.cur = &Block{Match: strings.EqualFold(name, "match"), Patterns: args, File: path, Line: line}
+			p.resume = nil
+			p.cfg.Blocks = append(p.cfg.Blocks, p.cur)
+		case "include":
+			if err := p.include(args, path, line, depth); err != nil {
+				return err
+			}
+		default:
+			if p.cur == nil {
+				if r := p.resume; r != nil {
+					p.cur = &Block{Match: r.Match, Patterns: r.Patterns, Implicit: r.Implicit, File: path, Line: line}
+				} else {
+					p.cur = &Block{Patterns: []string{"*"}, Implicit: true, File: path, Line: line}
+				}
+				p.resume = nil
+				p.cfg.Blocks = append(p.cfg.Blocks, p.cur)
+			}
+			p.cur.Options = append(p.cur.Options, Option{Name: name, Args: args, File: path, Line: line})
+		}
+	}
+	return sc.Err()
+}
+
+// include reads the files matched by an Include line. Options at the top of
+// an included file belong to the enclosing block; once the included file
+// opens its own blocks, the enclosing block resumes after the Include line.
+func (p *parser) include(globs []string, path string, line, depth int) error {
+	enclosing := p.cur
+	if enclosing == nil {
+		enclosing = p.resume
+	}
+	before := p.cur
+	for _, g := range globs {
+		g = expandTilde(g)
+		if !filepath.IsAbs(g) {
+			g = filepath.Join(p.base, g)
+		}
+		matches, err := filepath.Glob(g)
+		if err != nil {
+			return fmt.Errorf("%s:%d: bad Include pattern %q: %w", path, line, g, err)
+		}
+		sort.Strings(matches)
+		for _, m := range matches {
+			if info, err := os.Stat(m); err != nil || info.IsDir() {
+				continue
+			}
+			if err := p.parseFile(m, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+	if p.cur != before {
+		p.cur, p.resume = nil, enclosing
+	}
+	return nil
+}
+
+// splitLine splits a config line into keyword and arguments. It accepts
+// "Key value", "Key=value" and double-quoted arguments, and drops comments.
+func splitLine(s string) (string, []string, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || strings.HasPrefix(s, "#") {
+		return "", nil, nil
+	}
+	end := strings.IndexAny(s, " \t=")
+	if end < 0 {
+		return s, nil, nil
+	}
+	name := s[:end]
+	rest := strings.TrimLeft(s[end:], " \t")
+	rest = strings.TrimPrefix(rest, "=")
+
+	var args []string
+	var cur strings.Builder
+	inQuote, have := false, false
+	for _, r := range rest {
+		switch {
+		case r == '"':
+			inQuote = !inQuote
+			have = true
+		case !inQuote && (r == ' ' || r == '\t'):
+			if have {
+				args = append(args, cur.String())
+				cur.Reset()
+				have = false
+			}
+		case !inQuote && r == '#' && !have:
+			// Comment after the arguments
+			return name, args, nil
+		default:
+			cur.WriteRune(r)
+			have = true
+		}
+	}
+	if inQuote {
+		return "", nil, fmt.Errorf("unterminated quote")
+	}
+	if have {
+		args = append(args, cur.String())
+	}
+	return name, args, nil
+}
+
+// HasWildcard reports whether a host pattern contains * or ?
+func HasWildcard(pattern string) bool {
+	return strings.ContainsAny(pattern, "*?")
+}
+
+// MatchPattern matches host against an ssh_config pattern with * and ?
+func MatchPattern(pattern, host string) bool {
+	pattern, host = strings.ToLower(pattern), strings.ToLower(host)
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			pattern = pattern[1:]
+			if pattern == "" {
+				return true
+			}
+			for i := 0; i <= len(host); i++ {
+				if MatchPattern(pattern, host[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if host == "" {
+				return false
+			}
+		default:
+			if host == "" || host[0] != pattern[0] {
+				return false
+			}
+		}
+		pattern, host = pattern[1:], host[1:]
+	}
+	return host == ""
+}
+
+// matchList matches host against a pattern list with ! negation: any
+// matching negated pattern rejects the host, otherwise one positive match
+// is needed
+func matchList(patterns []string, host string) bool {
+	matched := false
+	for _, pat := range patterns {
+		for _, p := range strings.Split(pat, ",") {
+			if neg, ok := strings.CutPrefix(p, "!"); ok {
+				if MatchPattern(neg, host) {
+					return false
+				}
+			} else if MatchPattern(p, host) {
+				matched = true
+			}
+		}
+	}
+	return matched
+}
+
+// HostPatterns returns the host patterns a block applies to. For Match
+// blocks only "all", "host" and "originalhost" criteria can be expressed
+// as patterns; ok is false for anything else, and reason names the
+// criterion that depends on runtime conditions.
+func (b *Block) HostPatterns() (patterns []string, ok bool, reason string) {
+	if !b.Match {
+		return b.Patterns, true, ""
+	}
+	words := b.Patterns
+	if len(words) == 1 && strings.EqualFold(words[0], "all") {
+		return []string{"*"}, true, ""
+	}
+	for i := 0; i < len(words); i++ {
+		crit := strings.ToLower(words[i])
+		switch crit {
+		case "host", "originalhost":
+			if i+1 >= len(words) {
+				return nil, false, crit + " needs an argument"
+			}
+			if patterns != nil {
+				return nil, false, "several host criteria"
+			}
+			patterns = strings.Split(words[i+1], ",")
+			i++
+		case "canonical", "final":
+			return nil, false, fmt.Sprintf("%q depends on hostname canonicalization", crit)
+		default:
+			return nil, false, fmt.Sprintf("%q depends on runtime conditions", crit)
+		}
+	}
+	if patterns == nil {
+		return nil, false, "no host criteria"
+	}
+	return patterns, true, ""
+}
+
+// Matches reports whether the block applies to host. Match blocks with
+// criteria other than host patterns never match.
+func (b *Block) Matches(host string) bool {
+	patterns, ok, _ := b.HostPatterns()
+	return ok && matchList(patterns, host)
+}
+
+// multiValued keywords accumulate across blocks instead of first-wins
+var multiValued = map[string]bool{
+	"identityfile":    true,
+	"certificatefile": true,
+	"localforward":    true,
+	"remoteforward":   true,
+	"dynamicforward":  true,
+	"sendenv":         true,
+	"setenv":          true,
+}
+
+// IsMultiValued reports whether a keyword may be given several times
+func IsMultiValued(key string) bool {
+	return multiValued[strings.ToLower(key)]
+}
+
+// Lookup returns the options that apply to host in effect order: the first
+// value of a single-valued keyword wins, multi-valued keywords accumulate.
+func (c *Config) Lookup(host string) []Option {
+	var out []Option
+	seen := map[string]bool{}
+	for _, b := range c.Blocks {
+		if !b.Matches(host) {
+			continue
+		}
+		for _, o := range b.Options {
+			key := o.Key()
+			if !multiValued[key] {
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+			}
+			out = append(out, o)
+		}
+	}
+	return out
+}
+
+func expandTilde(p string) string {
+	if strings.HasPrefix(p, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, p[2:])
+		}
+	}
+	return p
+}
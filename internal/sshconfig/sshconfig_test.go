@@ -0,0 +1,158 @@
+package sshconfig
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestSplitLine(t *testing.T) {
+	tests := []struct {
+		line string
+		name string
+		args []string
+	}{
+		{"", "", nil},
+		{"  # comment", "", nil},
+		{"User alice", "User", []string{"alice"}},
+		{"Port=2222", "Port", []string{"2222"}},
+		{"Port = 2222", "Port", []string{"2222"}},
+		{"LocalForward 8080 localhost:80", "LocalForward", []string{"8080", "localhost:80"}},
+		{`RemoteCommand "tmux attach || tmux new"`, "RemoteCommand", []string{"tmux attach || tmux new"}},
+		{`SetEnv A=1 "B=two words" # trailing`, "SetEnv", []string{"A=1", "B=two words"}},
+	}
+	for _, tt := range tests {
+		name, args, err := splitLine(tt.line)
+		if err != nil {
+			t.Errorf("splitLine(%q) error = %v", tt.line, err)
+			continue
+		}
+		if name != tt.name || !reflect.DeepEqual(args, tt.args) {
+			t.Errorf("splitLine(%q) = %q %q, want %q %q", tt.line, name, args, tt.name, tt.args)
+		}
+	}
+	if _, _, err := splitLine(`User "alice`); err == nil {
+		t.Error("unterminated quote should fail")
+	}
+}
+
+func TestMatchPattern(t *testing.T) {
+	tests := []struct {
+		patterns []string
+		host     string
+		want     bool
+	}{
+		{[]string{"*"}, "anything", true},
+		{[]string{"*.prod"}, "web1.prod", true},
+		{[]string{"*.prod"}, "web1.dev", false},
+		{[]string{"web?"}, "web1", true},
+		{[]string{"web?"}, "web12", false},
+		{[]string{"*.prod", "!db*.prod"}, "db1.prod", false},
+		{[]string{"WEB*"}, "web1", true},
+		{[]string{"a,b*"}, "bx", true},
+		{[]string{"!a"}, "b", false},
+	}
+	for _, tt := range tests {
+		if got := matchList(tt.patterns, tt.host); got != tt.want {
+			t.Errorf("matchList(%q, %q) = %v, want %v", tt.patterns, tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestParseFileIncludes(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"config": `User global
+Include conf.d/*.conf
+
+Host bastion
+  HostName 10.0.0.1
+  Include extra
+  Port 2222
+
+Match exec "test -f /tmp/x"
+  User matched
+`,
+		"conf.d/10-web.conf": "Host web1\n  User web\n",
+		"conf.d/20-db.conf":  "Host db1\n  User db\n",
+		"extra":              "IdentityFile ~/.ssh/bastion\nHost other\n  User other\n",
+	})
+
+	cfg, err := ParseFile(filepath.Join(dir, "config"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Files) != 4 {
+		t.Errorf("Files = %v, want 4 files", cfg.Files)
+	}
+
+	var headers []string
+	for _, b := range cfg.Blocks {
+		headers = append(headers, b.String())
+	}
+	want := []string{"Host *", "Host web1", "Host db1", "Host bastion", "Host other", "Host bastion", `Match exec test -f /tmp/x`}
+	if !reflect.DeepEqual(headers, want) {
+		t.Errorf("blocks = %q, want %q", headers, want)
+	}
+
+	// The included file's leading option belongs to bastion; after the
+	// include, bastion resumes
+	opts := map[string]string{}
+	for _, o := range cfg.Lookup("bastion") {
+		if _, ok := opts[o.Key()]; !ok {
+			opts[o.Key()] = o.Value()
+		}
+	}
+	if opts["user"] != "global" || opts["hostname"] != "10.0.0.1" || opts["port"] != "2222" || opts["identityfile"] != "~/.ssh/bastion" {
+		t.Errorf("Lookup(bastion) = %v", opts)
+	}
+	for _, o := range cfg.Lookup("other") {
+		if o.Key() == "port" || o.Key() == "identityfile" {
+			t.Errorf("Lookup(other) picked up bastion's %s", o.Name)
+		}
+	}
+}
+
+func TestParseFileIncludeLoop(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"config": "Include config\n"})
+	if _, err := ParseFile(filepath.Join(dir, "config")); err == nil {
+		t.Error("recursive Include should fail")
+	}
+}
+
+func TestHostPatterns(t *testing.T) {
+	tests := []struct {
+		criteria []string
+		want     []string
+		ok       bool
+	}{
+		{[]string{"all"}, []string{"*"}, true},
+		{[]string{"host", "web-*,api-*"}, []string{"web-*", "api-*"}, true},
+		{[]string{"originalhost", "db"}, []string{"db"}, true},
+		{[]string{"host", "x", "user", "root"}, nil, false},
+		{[]string{"exec", "true"}, nil, false},
+		{[]string{"canonical", "host", "x"}, nil, false},
+	}
+	for _, tt := range tests {
+		b := &Block{Match: true, Patterns: tt.criteria}
+		got, ok, reason := b.HostPatterns()
+		if ok != tt.ok || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("HostPatterns(%q) = %q, %v (%s), want %q, %v", tt.criteria, got, ok, reason, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+// Package state keeps what veessh learns while it is used (favorites, usage
+// counts and the connection history) out of the hand-edited config.yaml. It
+// lives in a JSON file under $XDG_STATE_HOME that every change rewrites
+// under an exclusive file lock, so concurrent veessh processes never lose
+// each other's updates.
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/vee-sh/veessh/internal/util"
+)
+
+// PathEnv overrides the state file location
+const PathEnv = "VEESSH_STATE_FILE"
+
+// MaxHistory is the number of connections kept in the history
+const MaxHistory = 1000
+
+const currentVersion = 1
+
+// ProfileState is the usage of one profile
+type ProfileState struct {
+	Favorite bool      `json:"favorite,omitempty"`
+	LastUsed time.Time `json:"lastUsed,omitzero"`
+	UseCount int       `json:"useCount,omitempty"`
+}
+
+// IsZero reports whether there is nothing to keep
+func (p ProfileState) IsZero() bool {
+	return p == ProfileState{}
+}
+
+// Connection is one entry of the connection history
+type Connection struct {
+	Profile  string        `json:"profile"`
+	Time     time.Time     `json:"time"`
+	Duration time.Duration `json:"duration,omitempty"`
+	ExitCode int           `json:"exitCode,omitempty"`
+}
+
+// State is the content of the state file
+type State struct {
+	Version  int                     `json:"version"`
+	Profiles map[string]ProfileState `json:"profiles"`
+	History  []Connection            `json:"history,omitempty"` // oldest first
+
+	// ConfigImported is set once favorites and usage stats written to
+	// config.yaml by older versions have been moved here
+	ConfigImported bool `json:"configImported,omitempty"`
+}
+
+// DefaultPath returns $XDG_STATE_HOME/veessh/state.json, falling back to
+// ~/.local/state
+func DefaultPath() (string, error) {
+	if p := os.Getenv(PathEnv); p != "" {
+		return p, nil
+	}
+	stateHome := os.Getenv("XDG_STATE_HOME")
+	if strings.TrimSpace(stateHome) == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		stateHome = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(stateHome, "veessh", "state.json"), nil
+}
+
+// Load reads the state at path under a shared lock. A missing file is an
+// empty state.
+func Load(path string) (State, error) {
+	if path == "" {
+		var err error
+		if path, err = DefaultPath(); err != nil {
+			return State{}, err
+		}
+	}
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		return empty(), nil
+	}
+	lock, err := util.Lock(path, false)
+	if err != nil {
+		return State{}, err
+	}
+	defer lock.Unlock()
+	return read(path)
+}
+
+// Update applies fn to the state at path while holding an exclusive lock,
+// and writes the result back unless fn fails
+func Update(path string, fn func(*State) error) error {
+	if path == "" {
+		var err error
+		if path, err = DefaultPath(); err != nil {
+			return err
+		}
+	}
+	lock, err := util.Lock(path, true)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	s, err := read(path)
+	if err != nil {
+		return err
+	}
+	if err := fn(&s); err != nil {
+		return err
+	}
+	if over := len(s.History) - MaxHistory; over > 0 {
+		s.History = append([]Connection(nil), s.History[over:]...)
+	}
+	s.Version = currentVersion
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return util.WriteFileAtomic(path, append(data, '\n'), 0o600)
+}
+
+func read(path string) (State, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return empty(), nil
+	}
+	if err != nil {
+		return State{}, err
+	}
+	s := empty()
+	if err := json.Unmarshal(data, &s); err != nil {
+		return State{}, fmt.Errorf("%s: %w", path, err)
+	}
+	if s.Version > currentVersion {
+		return State{}, fmt.Errorf("%s: written by a newer veessh (state version %d)", path, s.Version)
+	}
+	if s.Profiles == nil {
+		s.Profiles = map[string]ProfileState{}
+	}
+	return s, nil
+}
+
+func empty() State {
+	return State{Version: currentVersion, Profiles: map[string]ProfileState{}}
+}
+
+// RecordConnection counts a connection to a profile and adds it to the
+// history
+func (s *State) RecordConnection(c Connection) {
+	p := s.Profiles[c.Profile]
+	p.UseCount++
+	if c.Time.After(p.LastUsed) {
+		p.LastUsed = c.Time
+	}
+	s.Profiles[c.Profile] = p
+	s.History = append(s.History, c)
+}
+
+// SetFavorite marks or unmarks a profile as favorite
+func (s *State) SetFavorite(name string, favorite bool) {
+	s.Set(name, func(p *ProfileState) { p.Favorite = favorite })
+}
+
+// Set changes the state of a profile, dropping it once it is empty
+func (s *State) Set(name string, fn func(*ProfileState)) {
+	p := s.Profiles[name]
+	fn(&p)
+	if p.IsZero() {
+		delete(s.Profiles, name)
+	} else {
+		s.Profiles[name] = p
+	}
+}
+
+// Recent returns up to n history entries, newest first (all when n <= 0)
+func (s *State) Recent(n int) []Connection {
+	if n <= 0 || n > len(s.History) {
+		n = len(s.History)
+	}
+	out := make([]Connection, 0, n)
+	for i := len(s.History) - 1; i >= 0 && len(out) < n; i-- {
+		out = append(out, s.History[i])
+	}
+	return out
+}
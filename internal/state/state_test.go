@@ -0,0 +1,117 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestUpdateAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "veessh", "state.json")
+
+	st, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() of missing file: %v", err)
+	}
+	if len(st.Profiles) != 0 || st.Profiles == nil {
+		t.Errorf("missing file should load as an empty state, got %+v", st)
+	}
+
+	now := time.Now().Truncate(time.Second)
+	err = Update(path, func(s *State) error {
+		s.RecordConnection(Connection{Profile: "web", Time: now.Add(-time.Hour), Duration: time.Minute})
+		s.RecordConnection(Connection{Profile: "web", Time: now})
+		s.RecordConnection(Connection{Profile: "db", Time: now.Add(-time.Minute), ExitCode: 255})
+		s.SetFavorite("db", true)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	st, err = Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := st.Profiles["web"]; p.UseCount != 2 || !p.LastUsed.Equal(now) {
+		t.Errorf("web = %+v", p)
+	}
+	if !st.Profiles["db"].Favorite {
+		t.Error("db not favorite")
+	}
+	recent := st.Recent(2)
+	if len(recent) != 2 || recent[0].Profile != "db" || recent[1].Profile != "web" {
+		t.Errorf("Recent(2) = %+v", recent)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("state file mode = %v, %v", info.Mode().Perm(), err)
+	}
+
+	// Clearing everything drops the profile entry
+	Update(path, func(s *State) error {
+		s.SetFavorite("db", false)
+		s.Set("db", func(p *ProfileState) { *p = ProfileState{} })
+		return nil
+	})
+	st, _ = Load(path)
+	if _, ok := st.Profiles["db"]; ok {
+		t.Error("empty profile state kept")
+	}
+}
+
+func TestUpdateConcurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	const n = 20
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := Update(path, func(s *State) error {
+				s.RecordConnection(Connection{Profile: "web", Time: time.Now()})
+				return nil
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	st, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := st.Profiles["web"].UseCount; got != n {
+		t.Errorf("UseCount = %d, want %d (lost updates)", got, n)
+	}
+}
+
+func TestHistoryCap(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	err := Update(path, func(s *State) error {
+		for i := 0; i < MaxHistory+5; i++ {
+			s.RecordConnection(Connection{Profile: "web", Time: time.Unix(int64(i), 0)})
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	st, _ := Load(path)
+	if len(st.History) != MaxHistory || st.History[0].Time.Unix() != 5 {
+		t.Errorf("history = %d entries starting at %v", len(st.History), st.History[0].Time)
+	}
+}
+
+func TestLoadNewerVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	if err := os.WriteFile(path, []byte(`{"version": 99}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil {
+		t.Error("Load() should refuse a state written by a newer version")
+	}
+}
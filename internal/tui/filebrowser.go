@@ -0,0 +1,840 @@
+package tui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/progress"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/pkg/sftp"
+
+	"github.com/vee-sh/veessh/internal/config"
+	"github.com/vee-sh/veessh/internal/credentials"
+	"github.com/vee-sh/veessh/internal/hostkeys"
+	"github.com/vee-sh/veessh/internal/sshclient"
+)
+
+// fileSystem is the set of operations the file browser needs, implemented
+// for the local disk and for the remote host over SFTP.
+type fileSystem interface {
+	ReadDir(dir string) ([]os.FileInfo, error)
+	Stat(name string) (os.FileInfo, error)
+	Open(name string) (io.ReadCloser, error)
+	Create(name string) (io.WriteCloser, error)
+	MkdirAll(dir string) error
+	Chmod(name string, mode os.FileMode) error
+	Rename(oldname, newname string) error
+	RemoveAll(name string) error
+	Join(elem ...string) string
+	Dir(name string) string
+}
+
+type localFS struct{}
+
+func (localFS) ReadDir(dir string) ([]os.FileInfo, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]os.FileInfo, 0, len(entries))
+	for _, e := range entries {
+		if info, err := e.Info(); err == nil {
+			infos = append(infos, info)
+		}
+	}
+	return infos, nil
+}
+
+func (localFS) Stat(name string) (os.FileInfo, error)      { return os.Stat(name) }
+func (localFS) Open(name string) (io.ReadCloser, error)    { return os.Open(name) }
+func (localFS) Create(name string) (io.WriteCloser, error) { return os.Create(name) }
+func (localFS) MkdirAll(dir string) error                  { return os.MkdirAll(dir, 0o755) }
+func (localFS) Chmod(name string, mode os.FileMode) error  { return os.Chmod(name, mode) }
+func (localFS) Rename(oldname, newname string) error       { return os.Rename(oldname, newname) }
+func (localFS) RemoveAll(name string) error                { return os.RemoveAll(name) }
+func (localFS) Join(elem ...string) string                 { return filepath.Join(elem...) }
+func (localFS) Dir(name string) string                     { return filepath.Dir(name) }
+
+type remoteFS struct {
+	client *sftp.Client
+}
+
+func (r remoteFS) ReadDir(dir string) ([]os.FileInfo, error)  { return r.client.ReadDir(dir) }
+func (r remoteFS) Stat(name string) (os.FileInfo, error)      { return r.client.Stat(name) }
+func (r remoteFS) Open(name string) (io.ReadCloser, error)    { return r.client.Open(name) }
+func (r remoteFS) Create(name string) (io.WriteCloser, error) { return r.client.Create(name) }
+func (r remoteFS) MkdirAll(dir string) error                  { return r.client.MkdirAll(dir) }
+func (r remoteFS) Chmod(name string, mode os.FileMode) error  { return r.client.Chmod(name, mode) }
+func (r remoteFS) RemoveAll(name string) error                { return r.client.RemoveAll(name) }
+func (r remoteFS) Join(elem ...string) string                 { return path.Join(elem...) }
+func (r remoteFS) Dir(name string) string                     { return path.Dir(name) }
+
+// Rename prefers the posix-rename extension, which replaces an existing
+// target like a local rename does.
+func (r remoteFS) Rename(oldname, newname string) error {
+	if err := r.client.PosixRename(oldname, newname); err == nil {
+		return nil
+	}
+	return r.client.Rename(oldname, newname)
+}
+
+// fileEntry is a single row in a file pane
+type fileEntry struct {
+	name  string
+	size  int64
+	isDir bool
+}
+
+// filePane is one side of the file browser
+type filePane struct {
+	title   string
+	fs      fileSystem
+	dir     string
+	entries []fileEntry
+	cursor  int
+}
+
+// load reads the pane's directory, listing directories first
+func (p *filePane) load() error {
+	infos, err := p.fs.ReadDir(p.dir)
+	if err != nil {
+		return err
+	}
+	entries := make([]fileEntry, 0, len(infos)+1)
+	if p.fs.Dir(p.dir) != p.dir {
+		entries = append(entries, fileEntry{name: "..", isDir: true})
+	}
+	for _, info := range infos {
+		isDir := info.IsDir()
+		if info.Mode()&os.ModeSymlink != 0 {
+			// Follow symlinks so linked directories can be entered
+			if target, err := p.fs.Stat(p.fs.Join(p.dir, info.Name())); err == nil {
+				isDir = target.IsDir()
+			}
+		}
+		entries = append(entries, fileEntry{name: info.Name(), size: info.Size(), isDir: isDir})
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].name == ".." || entries[j].name == ".." {
+			return entries[i].name == ".."
+		}
+		if entries[i].isDir != entries[j].isDir {
+			return entries[i].isDir
+		}
+		return strings.ToLower(entries[i].name) < strings.ToLower(entries[j].name)
+	})
+	p.entries = entries
+	if p.cursor >= len(p.entries) {
+		p.cursor = len(p.entries) - 1
+	}
+	if p.cursor < 0 {
+		p.cursor = 0
+	}
+	return nil
+}
+
+func (p *filePane) selected() *fileEntry {
+	if p.cursor < 0 || p.cursor >= len(p.entries) {
+		return nil
+	}
+	return &p.entries[p.cursor]
+}
+
+// chdir switches to dir, staying in the current directory if it can't be read
+func (p *filePane) chdir(dir string) error {
+	prev, prevCursor := p.dir, p.cursor
+	p.dir, p.cursor = dir, 0
+	if err := p.load(); err != nil {
+		p.dir, p.cursor = prev, prevCursor
+		return err
+	}
+	return nil
+}
+
+// up moves to the parent directory and selects the directory we came from
+func (p *filePane) up() error {
+	child := p.dir
+	if err := p.chdir(p.fs.Dir(p.dir)); err != nil {
+		return err
+	}
+	for i, e := range p.entries {
+		if p.fs.Join(p.dir, e.name) == child {
+			p.cursor = i
+			break
+		}
+	}
+	return nil
+}
+
+// transfer tracks a running upload or download
+type transfer struct {
+	label   string
+	done    int64
+	total   int64
+	updates chan tea.Msg
+	cancel  context.CancelFunc
+}
+
+// fileBrowser is the two-pane local/remote SFTP file manager
+type fileBrowser struct {
+	profile  config.Profile
+	client   *sshclient.Client
+	sftp     *sftp.Client
+	panes    [2]*filePane // local, remote
+	active   int
+	progress progress.Model
+	transfer *transfer
+	input    textinput.Model
+	renaming bool
+	deleting bool
+	status   string
+	isError  bool
+}
+
+// Message types for the file browser
+type fileBrowserOpenedMsg struct {
+	browser *fileBrowser
+}
+
+type transferProgressMsg struct {
+	done  int64
+	total int64
+}
+
+type transferDoneMsg struct {
+	label  string
+	target int
+	bytes  int64
+	err    error
+}
+
+// HostKeyCheck applies the keys pinned for the host of a profile before the
+// file browser connects to it, returning the context that carries the pin
+// to enforce, and HostKeyRefused follows up a failed connection. The cli
+// package sets them, as it applies the host key policies.
+var (
+	HostKeyCheck   func(ctx context.Context, cfg config.Config, p config.Profile) (context.Context, error)
+	HostKeyRefused func(ctx context.Context, p config.Profile, err error) error
+)
+
+// openFileBrowser connects to the profile over SFTP using the same identity,
+// ProxyJump and stored secrets as a regular connection
+func (m *Model) openFileBrowser(name string) tea.Cmd {
+	p, ok := m.config.GetProfile(name)
+	if !ok {
+		m.statusMessage = fmt.Sprintf("Profile '%s' not found", name)
+		return nil
+	}
+	if p.Protocol != config.ProtocolSSH && p.Protocol != config.ProtocolSFTP {
+		m.statusMessage = fmt.Sprintf("File browser is not available for %s profiles", p.Protocol)
+		return nil
+	}
+	m.statusMessage = fmt.Sprintf("Opening SFTP session to %s...", p.Name)
+
+	cfg := m.config
+	return func() tea.Msg {
+		ctx := context.Background()
+		if HostKeyCheck != nil {
+			var err error
+			if ctx, err = HostKeyCheck(ctx, cfg, p); err != nil {
+				return statusMsg{message: fmt.Sprintf("SFTP to %s refused: %v", p.Name, err), isError: true}
+			}
+		}
+		secrets, _ := credentials.GetSecrets(p.Name)
+		// The TUI owns the terminal, so there is no interactive prompt:
+		// hosts must already be in known_hosts and keys must be usable
+		client, err := sshclient.Dial(ctx, p, sshclient.Config{
+			Password:      secrets.Password,
+			KeyPassphrase: secrets.KeyPassphrase,
+			TOTPSeed:      secrets.TOTPSeed,
+			Pin:           hostkeys.FromContext(ctx),
+		})
+		if err != nil {
+			if HostKeyRefused != nil {
+				err = HostKeyRefused(ctx, p, err)
+			}
+			return statusMsg{message: fmt.Sprintf("SFTP to %s failed: %v", p.Name, err), isError: true}
+		}
+		sc, err := sftp.NewClient(client.Client)
+		if err != nil {
+			client.Close()
+			return statusMsg{message: fmt.Sprintf("SFTP to %s failed: %v", p.Name, err), isError: true}
+		}
+		b, err := newFileBrowser(p, client, sc)
+		if err != nil {
+			sc.Close()
+			client.Close()
+			return statusMsg{message: fmt.Sprintf("SFTP to %s failed: %v", p.Name, err), isError: true}
+		}
+		return fileBrowserOpenedMsg{browser: b}
+	}
+}
+
+func newFileBrowser(p config.Profile, client *sshclient.Client, sc *sftp.Client) (*fileBrowser, error) {
+	localDir, err := os.Getwd()
+	if err != nil {
+		if localDir, err = os.UserHomeDir(); err != nil {
+			localDir = string(filepath.Separator)
+		}
+	}
+	remoteDir, err := sc.Getwd()
+	if err != nil || remoteDir == "" {
+		remoteDir = "/"
+	}
+
+	input := textinput.New()
+	input.CharLimit = 255
+
+	b := &fileBrowser{
+		profile: p,
+		client:  client,
+		sftp:    sc,
+		panes: [2]*filePane{
+			{title: "Local", fs: localFS{}, dir: localDir},
+			{title: p.Name, fs: remoteFS{client: sc}, dir: remoteDir},
+		},
+		active:   1,
+		progress: progress.New(progress.WithDefaultGradient(), progress.WithoutPercentage()),
+		input:    input,
+	}
+	for _, pane := range b.panes {
+		if err := pane.load(); err != nil {
+			return nil, fmt.Errorf("failed to list %s: %w", pane.dir, err)
+		}
+	}
+	return b, nil
+}
+
+func (b *fileBrowser) close() {
+	if b.transfer != nil {
+		b.transfer.cancel()
+	}
+	b.sftp.Close()
+	b.client.Close()
+}
+
+func (b *fileBrowser) setStatus(msg string, isError bool) {
+	b.status = msg
+	b.isError = isError
+}
+
+// handleFileBrowserKeys handles keyboard input in the file browser
+func (m *Model) handleFileBrowserKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	b := m.browser
+	if b == nil {
+		m.mode = viewProfiles
+		return m, nil
+	}
+
+	if msg.String() == "ctrl+c" {
+		m.closeFileBrowser()
+		m.quitting = true
+		return m, tea.Quit
+	}
+
+	if b.renaming {
+		return m, b.handleRenameKeys(msg)
+	}
+
+	if b.deleting {
+		b.deleting = false
+		if msg.String() == "y" || msg.String() == "Y" {
+			b.deleteSelected()
+		} else {
+			b.setStatus("Delete cancelled", false)
+		}
+		return m, nil
+	}
+
+	pane := b.panes[b.active]
+	switch {
+	case key.Matches(msg, m.keys.Cancel), msg.String() == "q":
+		if b.transfer != nil {
+			b.transfer.cancel()
+			b.setStatus("Cancelling transfer...", false)
+			return m, nil
+		}
+		m.closeFileBrowser()
+
+	case key.Matches(msg, m.keys.Tab):
+		b.active = 1 - b.active
+
+	case key.Matches(msg, m.keys.Up):
+		if pane.cursor > 0 {
+			pane.cursor--
+		}
+
+	case key.Matches(msg, m.keys.Down):
+		if pane.cursor < len(pane.entries)-1 {
+			pane.cursor++
+		}
+
+	case key.Matches(msg, m.keys.PageUp):
+		pane.cursor -= 10
+		if pane.cursor < 0 {
+			pane.cursor = 0
+		}
+
+	case key.Matches(msg, m.keys.PageDown):
+		pane.cursor += 10
+		if pane.cursor >= len(pane.entries) {
+			pane.cursor = len(pane.entries) - 1
+		}
+
+	case key.Matches(msg, m.keys.Enter), key.Matches(msg, m.keys.Right):
+		e := pane.selected()
+		if e == nil || !e.isDir {
+			break
+		}
+		var err error
+		if e.name == ".." {
+			err = pane.up()
+		} else {
+			err = pane.chdir(pane.fs.Join(pane.dir, e.name))
+		}
+		if err != nil {
+			b.setStatus(err.Error(), true)
+		}
+
+	case key.Matches(msg, m.keys.Left), msg.String() == "backspace":
+		if err := pane.up(); err != nil {
+			b.setStatus(err.Error(), true)
+		}
+
+	case msg.String() == "c":
+		return m, b.startTransfer()
+
+	case msg.String() == "r":
+		if b.busy() {
+			break
+		}
+		if e := pane.selected(); e != nil && e.name != ".." {
+			b.renaming = true
+			b.input.SetValue(e.name)
+			b.input.CursorEnd()
+			b.input.Focus()
+			return m, textinput.Blink
+		}
+
+	case key.Matches(msg, m.keys.Delete):
+		if b.busy() {
+			break
+		}
+		if e := pane.selected(); e != nil && e.name != ".." {
+			b.deleting = true
+			b.setStatus(fmt.Sprintf("Delete %s from %s? (y/n)", e.name, pane.title), false)
+		}
+
+	case msg.String() == "R":
+		for _, p := range b.panes {
+			if err := p.load(); err != nil {
+				b.setStatus(err.Error(), true)
+			}
+		}
+	}
+
+	return m, nil
+}
+
+func (m *Model) closeFileBrowser() {
+	if m.browser != nil {
+		m.browser.close()
+		m.statusMessage = fmt.Sprintf("Closed SFTP session to %s", m.browser.profile.Name)
+	}
+	m.browser = nil
+	m.mode = viewProfiles
+}
+
+// busy reports whether a transfer is running, since renaming or deleting
+// files underneath it would be confusing at best
+func (b *fileBrowser) busy() bool {
+	if b.transfer != nil {
+		b.setStatus("Wait for the current transfer to finish", true)
+		return true
+	}
+	return false
+}
+
+func (b *fileBrowser) handleRenameKeys(msg tea.KeyMsg) tea.Cmd {
+	switch msg.String() {
+	case "esc":
+		b.renaming = false
+		b.input.Blur()
+		return nil
+	case "enter":
+		b.renaming = false
+		b.input.Blur()
+		pane := b.panes[b.active]
+		e := pane.selected()
+		newName := strings.TrimSpace(b.input.Value())
+		if e == nil || newName == "" || newName == e.name {
+			return nil
+		}
+		if strings.ContainsAny(newName, `/\`) {
+			b.setStatus("New name must not contain a path separator", true)
+			return nil
+		}
+		if err := pane.fs.Rename(pane.fs.Join(pane.dir, e.name), pane.fs.Join(pane.dir, newName)); err != nil {
+			b.setStatus(fmt.Sprintf("Rename failed: %v", err), true)
+			return nil
+		}
+		b.setStatus(fmt.Sprintf("Renamed %s to %s", e.name, newName), false)
+		pane.load()
+		for i, entry := range pane.entries {
+			if entry.name == newName {
+				pane.cursor = i
+			}
+		}
+		return nil
+	}
+	var cmd tea.Cmd
+	b.input, cmd = b.input.Update(msg)
+	return cmd
+}
+
+func (b *fileBrowser) deleteSelected() {
+	pane := b.panes[b.active]
+	e := pane.selected()
+	if e == nil || e.name == ".." {
+		return
+	}
+	if err := pane.fs.RemoveAll(pane.fs.Join(pane.dir, e.name)); err != nil {
+		b.setStatus(fmt.Sprintf("Delete failed: %v", err), true)
+	} else {
+		b.setStatus(fmt.Sprintf("Deleted %s", e.name), false)
+	}
+	pane.load()
+}
+
+// startTransfer copies the selected entry into the other pane's directory:
+// an upload from the local pane, a download from the remote one
+func (b *fileBrowser) startTransfer() tea.Cmd {
+	if b.busy() {
+		return nil
+	}
+	src, dst := b.panes[b.active], b.panes[1-b.active]
+	e := src.selected()
+	if e == nil || e.name == ".." {
+		return nil
+	}
+	srcPath := src.fs.Join(src.dir, e.name)
+	dstPath := dst.fs.Join(dst.dir, e.name)
+
+	verb := "Downloading"
+	if b.active == 0 {
+		verb = "Uploading"
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t := &transfer{
+		label:   fmt.Sprintf("%s %s", verb, e.name),
+		updates: make(chan tea.Msg, 16),
+		cancel:  cancel,
+	}
+	b.transfer = t
+	b.setStatus("", false)
+
+	target := 1 - b.active
+	go func() {
+		defer close(t.updates)
+		defer cancel()
+		total, err := treeSize(src.fs, srcPath)
+		var copied int64
+		if err == nil {
+			report := progressReporter(t.updates, total)
+			err = copyTree(ctx, src.fs, srcPath, dst.fs, dstPath, func(n int) {
+				copied += int64(n)
+				report(copied)
+			})
+		}
+		t.updates <- transferDoneMsg{label: e.name, target: target, bytes: copied, err: err}
+	}()
+
+	return waitForTransfer(t.updates)
+}
+
+// waitForTransfer delivers the next progress or completion message
+func waitForTransfer(updates <-chan tea.Msg) tea.Cmd {
+	return func() tea.Msg {
+		msg, ok := <-updates
+		if !ok {
+			return nil
+		}
+		return msg
+	}
+}
+
+// progressReporter returns a callback that sends progress at most every
+// 100ms, dropping updates rather than stalling the copy
+func progressReporter(updates chan<- tea.Msg, total int64) func(done int64) {
+	var last time.Time
+	return func(done int64) {
+		if time.Since(last) < 100*time.Millisecond && done < total {
+			return
+		}
+		last = time.Now()
+		select {
+		case updates <- transferProgressMsg{done: done, total: total}:
+		default:
+		}
+	}
+}
+
+// updateTransfer handles transfer messages for the file browser
+func (b *fileBrowser) updateTransfer(msg tea.Msg) tea.Cmd {
+	if b.transfer == nil {
+		return nil
+	}
+	switch msg := msg.(type) {
+	case transferProgressMsg:
+		b.transfer.done, b.transfer.total = msg.done, msg.total
+		return waitForTransfer(b.transfer.updates)
+	case transferDoneMsg:
+		verb := "Downloaded"
+		if msg.target == 1 {
+			verb = "Uploaded"
+		}
+		switch {
+		case errors.Is(msg.err, context.Canceled):
+			b.setStatus(fmt.Sprintf("Transfer of %s cancelled", msg.label), true)
+		case msg.err != nil:
+			b.setStatus(fmt.Sprintf("Transfer of %s failed: %v", msg.label, msg.err), true)
+		default:
+			b.setStatus(fmt.Sprintf("%s %s (%s)", verb, msg.label, formatSize(msg.bytes)), false)
+		}
+		b.transfer = nil
+		b.panes[msg.target].load()
+	}
+	return nil
+}
+
+// treeSize returns the number of bytes in a file or directory tree
+func treeSize(fsys fileSystem, name string) (int64, error) {
+	info, err := fsys.Stat(name)
+	if err != nil {
+		return 0, err
+	}
+	if !info.IsDir() {
+		return info.Size(), nil
+	}
+	infos, err := fsys.ReadDir(name)
+	if err != nil {
+		return 0, err
+	}
+	var total int64
+	for _, fi := range infos {
+		n, err := treeSize(fsys, fsys.Join(name, fi.Name()))
+		if err != nil {
+			return 0, err
+		}
+		total += n
+	}
+	return total, nil
+}
+
+// copyTree copies a file or directory tree between file systems, keeping
+// permission bits and reporting every chunk written to onWrite
+func copyTree(ctx context.Context, srcFS fileSystem, src string, dstFS fileSystem, dst string, onWrite func(int)) error {
+	info, err := srcFS.Stat(src)
+	if err != nil {
+		return err
+	}
+
+	if info.IsDir() {
+		if err := dstFS.MkdirAll(dst); err != nil {
+			return fmt.Errorf("failed to create %s: %w", dst, err)
+		}
+		infos, err := srcFS.ReadDir(src)
+		if err != nil {
+			return err
+		}
+		for _, fi := range infos {
+			if err := copyTree(ctx, srcFS, srcFS.Join(src, fi.Name()), dstFS, dstFS.Join(dst, fi.Name()), onWrite); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+
+	in, err := srcFS.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := dstFS.Create(dst)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", dst, err)
+	}
+	_, err = io.Copy(out, &progressReader{ctx: ctx, r: in, onRead: onWrite})
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	dstFS.Chmod(dst, info.Mode().Perm())
+	return nil
+}
+
+// progressReader reports bytes read and stops when ctx is cancelled
+type progressReader struct {
+	ctx    context.Context
+	r      io.Reader
+	onRead func(int)
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	if err := p.ctx.Err(); err != nil {
+		return 0, err
+	}
+	n, err := p.r.Read(b)
+	if n > 0 {
+		p.onRead(n)
+	}
+	return n, err
+}
+
+// formatSize renders a byte count for display
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// viewFileBrowser renders the two-pane file manager
+func (m *Model) viewFileBrowser() string {
+	b := m.browser
+	if b == nil {
+		return "Error: No file browser"
+	}
+
+	target := b.profile.Host
+	if b.profile.Username != "" {
+		target = b.profile.Username + "@" + target
+	}
+	header := m.styles.Header.Width(m.width).Render(
+		m.styles.Title.Render("SFTP") + "  " + b.profile.Name + "  " + m.styles.Subtitle.Render(target),
+	)
+
+	paneWidth := m.width/2 - 2
+	if paneWidth < 20 {
+		paneWidth = 20
+	}
+	paneHeight := m.height - 6
+	if paneHeight < 5 {
+		paneHeight = 5
+	}
+	panes := lipgloss.JoinHorizontal(
+		lipgloss.Top,
+		m.viewFilePane(b.panes[0], paneWidth, paneHeight, b.active == 0),
+		m.viewFilePane(b.panes[1], paneWidth, paneHeight, b.active == 1),
+	)
+
+	// Transfer progress, rename prompt or status message
+	var line string
+	switch {
+	case b.transfer != nil:
+		t := b.transfer
+		percent := 0.0
+		if t.total > 0 {
+			percent = float64(t.done) / float64(t.total)
+		}
+		b.progress.Width = m.width / 3
+		line = fmt.Sprintf("%s  %s  %s / %s", t.label, b.progress.ViewAs(percent), formatSize(t.done), formatSize(t.total))
+	case b.renaming:
+		line = m.styles.Label.Render("Rename:") + " " + b.input.View()
+	case b.status != "" && b.isError:
+		line = m.styles.Error.Render(b.status)
+	case b.status != "":
+		line = m.styles.Success.Render(b.status)
+	}
+
+	copyHint := "[c] Download"
+	if b.active == 0 {
+		copyHint = "[c] Upload"
+	}
+	hints := []string{
+		"[↑↓] Navigate",
+		"[Enter] Open",
+		"[←/Backspace] Up",
+		"[Tab] Switch pane",
+		copyHint,
+		"[r] Rename",
+		"[d] Delete",
+		"[R] Refresh",
+		"[Esc] Close",
+	}
+	footer := m.styles.Footer.Width(m.width).Render(strings.Join(hints, "  "))
+
+	return lipgloss.JoinVertical(lipgloss.Left, header, panes, " "+line, footer)
+}
+
+// viewFilePane renders one side of the file browser
+func (m *Model) viewFilePane(pane *filePane, width, height int, active bool) string {
+	var content strings.Builder
+
+	title := pane.title + ": " + pane.dir
+	if len(title) > width-2 {
+		title = "…" + title[len(title)-(width-3):]
+	}
+	content.WriteString(m.styles.Subtitle.Render(title) + "\n")
+	content.WriteString(strings.Repeat("─", width-2) + "\n")
+
+	visibleHeight := height - 2
+	startIdx := 0
+	if pane.cursor >= visibleHeight {
+		startIdx = pane.cursor - visibleHeight + 1
+	}
+	endIdx := startIdx + visibleHeight
+	if endIdx > len(pane.entries) {
+		endIdx = len(pane.entries)
+	}
+
+	nameWidth := width - 16
+	for i := startIdx; i < endIdx; i++ {
+		e := pane.entries[i]
+		name := e.name
+		size := formatSize(e.size)
+		if e.isDir {
+			name += "/"
+			size = ""
+		}
+		if len(name) > nameWidth {
+			name = name[:nameWidth-1] + "…"
+		}
+		display := fmt.Sprintf("%-*s %10s", nameWidth, name, size)
+
+		if i == pane.cursor && active {
+			content.WriteString(m.styles.SelectedItem.Width(width - 4).Render(display))
+		} else {
+			content.WriteString(m.styles.UnselectedItem.Width(width - 4).Render(display))
+		}
+		content.WriteString("\n")
+	}
+
+	style := m.styles.ProfilePane
+	if active {
+		style = m.styles.SearchBox
+	}
+	return style.Width(width).Height(height).Render(content.String())
+}
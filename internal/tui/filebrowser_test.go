@@ -0,0 +1,86 @@
+package tui
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/vee-sh/veessh/internal/config"
+	"github.com/vee-sh/veessh/internal/hostkeys"
+)
+
+func TestOpenFileBrowserPin(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &ssh.ServerConfig{NoClientAuth: true}
+	server.AddHostKey(signer)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			nc, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				ssh.NewServerConn(nc, server)
+				nc.Close()
+			}()
+		}
+	}()
+	port := l.Addr().(*net.TCPAddr).Port
+
+	p := config.Profile{Name: "files", Protocol: config.ProtocolSFTP, Host: "127.0.0.1", Port: port}
+	m := &Model{config: config.Config{Profiles: map[string]config.Profile{p.Name: p}}}
+	t.Cleanup(func() { HostKeyCheck, HostKeyRefused = nil, nil })
+
+	// A check that refuses keeps the browser closed
+	HostKeyCheck = func(ctx context.Context, cfg config.Config, p config.Profile) (context.Context, error) {
+		return ctx, errors.New("host key check failed")
+	}
+	msg, ok := m.openFileBrowser(p.Name)().(statusMsg)
+	if !ok || !msg.isError || !strings.Contains(msg.message, "host key check failed") {
+		t.Errorf("refused check: %#v", msg)
+	}
+
+	// The connection enforces the pin the check returns
+	_, other, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherSigner, err := ssh.NewSignerFromKey(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	HostKeyCheck = func(ctx context.Context, cfg config.Config, p config.Profile) (context.Context, error) {
+		pin := hostkeys.Pin{Host: p.Host, Port: p.Port, Keys: []hostkeys.Key{hostkeys.KeyOf(otherSigner.PublicKey(), time.Now())}}
+		return hostkeys.NewContext(ctx, pin, time.Now())
+	}
+	var refused error
+	HostKeyRefused = func(ctx context.Context, p config.Profile, err error) error {
+		refused = err
+		return err
+	}
+	msg, ok = m.openFileBrowser(p.Name)().(statusMsg)
+	var mismatch *hostkeys.MismatchError
+	if !ok || !msg.isError || !errors.As(refused, &mismatch) {
+		t.Errorf("mismatching key: %#v, refused with %v", msg, refused)
+	}
+}
@@ -0,0 +1,202 @@
+package tui
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/vee-sh/veessh/internal/config"
+)
+
+// forwardPicker lets the user tick which named forwards to open before
+// connecting, like 'veessh connect --forward'. Unnamed forwards cannot be
+// asked for by name, so they are shown greyed out and never ticked.
+type forwardPicker struct {
+	profile config.Profile // resolved
+	checked []bool         // by index into profile.Forwards
+	cursor  int
+}
+
+// openForwardPicker shows the forwards of the named profile, ticked as
+// they would be opened by default
+func (m *Model) openForwardPicker(name string) {
+	p, ok := m.config.GetProfile(name)
+	if !ok {
+		m.statusMessage = fmt.Sprintf("Profile '%s' not found", name)
+		return
+	}
+	if !slices.ContainsFunc(p.Forwards, func(f config.Forward) bool { return f.Name != "" }) {
+		m.statusMessage = fmt.Sprintf("Profile '%s' has no named forwards", name)
+		return
+	}
+	picker := &forwardPicker{profile: p, checked: make([]bool, len(p.Forwards))}
+	for i, f := range p.Forwards {
+		picker.checked[i] = f.Name != "" && f.IsEnabled()
+	}
+	for picker.profile.Forwards[picker.cursor].Name == "" {
+		picker.cursor++
+	}
+	m.forwards = picker
+	m.mode = viewForwards
+	m.statusMessage = ""
+}
+
+// picked returns the names of the ticked forwards
+func (f *forwardPicker) picked() []string {
+	var names []string
+	for i, fw := range f.profile.Forwards {
+		if f.checked[i] && fw.Name != "" {
+			names = append(names, fw.Name)
+		}
+	}
+	return names
+}
+
+// move moves the cursor by step, skipping unnamed forwards
+func (f *forwardPicker) move(step int) {
+	for i := f.cursor + step; i >= 0 && i < len(f.checked); i += step {
+		if f.profile.Forwards[i].Name != "" {
+			f.cursor = i
+			return
+		}
+	}
+}
+
+// handleForwardKeys handles keyboard input for the forward picker
+func (m *Model) handleForwardKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	f := m.forwards
+	if f == nil {
+		m.mode = viewProfiles
+		return m, nil
+	}
+
+	switch {
+	case msg.String() == "ctrl+c":
+		m.quitting = true
+		return m, tea.Quit
+
+	case key.Matches(msg, m.keys.Cancel):
+		m.forwards = nil
+		m.mode = viewProfiles
+
+	case key.Matches(msg, m.keys.Up):
+		f.move(-1)
+
+	case key.Matches(msg, m.keys.Down):
+		f.move(1)
+
+	case key.Matches(msg, m.keys.Space):
+		f.checked[f.cursor] = !f.checked[f.cursor]
+
+	case msg.String() == "a":
+		// Tick all, or none when all are ticked
+		all := true
+		for i, c := range f.checked {
+			all = all && (c || f.profile.Forwards[i].Name == "")
+		}
+		for i, fw := range f.profile.Forwards {
+			f.checked[i] = !all && fw.Name != ""
+		}
+
+	case key.Matches(msg, m.keys.Enter):
+		p := f.profile
+		if err := p.SelectForwards(f.picked()); err != nil {
+			m.statusMessage = err.Error()
+			return m, nil
+		}
+		m.forwards = nil
+		m.mode = viewProfiles
+		return m, m.connectWithForwards(f)
+	}
+	return m, nil
+}
+
+// connectCommand returns the command running self to connect with the
+// ticked forwards
+func (f *forwardPicker) connectCommand(self string) *exec.Cmd {
+	args := []string{"connect", f.profile.Name}
+	if names := f.picked(); len(names) > 0 {
+		args = append(args, "--forward", strings.Join(names, ","))
+	} else {
+		args = append(args, "--no-forward")
+	}
+	return exec.Command(self, args...)
+}
+
+// connectWithForwards runs `veessh connect` with the ticked forwards,
+// suspending the TUI until the connection ends
+func (m *Model) connectWithForwards(f *forwardPicker) tea.Cmd {
+	self, err := os.Executable()
+	if err != nil {
+		return func() tea.Msg {
+			return statusMsg{message: fmt.Sprintf("Failed to connect: %v", err), isError: true}
+		}
+	}
+	name := f.profile.Name
+	return tea.ExecProcess(f.connectCommand(self), func(err error) tea.Msg {
+		if err != nil {
+			return statusMsg{message: fmt.Sprintf("Connection to %s failed: %v", name, err), isError: true}
+		}
+		return statusMsg{message: fmt.Sprintf("Disconnected from %s", name)}
+	})
+}
+
+// viewForwardPicker renders the forward picker
+func (m *Model) viewForwardPicker() string {
+	f := m.forwards
+	if f == nil {
+		return "Error: No forwards"
+	}
+
+	header := m.styles.Header.Width(m.width).Render(
+		m.styles.Title.Render("Forwards") + "  " + f.profile.Name + "  " + m.styles.Subtitle.Render(f.profile.Host),
+	)
+
+	var content strings.Builder
+	for i, fw := range f.profile.Forwards {
+		if fw.Name == "" {
+			// Opened only by a plain 'connect', so it cannot be ticked
+			content.WriteString(m.styles.Subtitle.Render(fmt.Sprintf("[-] %-12s %-7s %s", "(unnamed)", fw.Kind(), fw.Spec())) + "\n")
+			continue
+		}
+		box := "[ ]"
+		if f.checked[i] {
+			box = "[x]"
+		}
+		line := fmt.Sprintf("%s %-12s %-7s %s", box, fw.Name, fw.Kind(), fw.Spec())
+		if fw.Description != "" {
+			line += "  " + m.styles.Subtitle.Render(fw.Description)
+		}
+		if i == f.cursor {
+			line = m.styles.SelectedItem.Render(line)
+		} else {
+			line = m.styles.UnselectedItem.Render(line)
+		}
+		content.WriteString(line + "\n")
+	}
+	paneHeight := m.height - 4
+	if paneHeight < 5 {
+		paneHeight = 5
+	}
+	pane := m.styles.ProfilePane.Width(m.width - 2).Height(paneHeight).Render(content.String())
+
+	hints := []string{
+		"[↑↓] Navigate",
+		"[Space] Toggle",
+		"[a] All/none",
+		"[Enter] Connect",
+		"[Esc] Cancel",
+	}
+	footer := strings.Join(hints, "  ")
+	if m.statusMessage != "" {
+		footer = m.styles.Error.Render(m.statusMessage) + "  " + footer
+	}
+
+	return lipgloss.JoinVertical(lipgloss.Left, header, pane, m.styles.Footer.Width(m.width).Render(footer))
+}
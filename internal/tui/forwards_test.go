@@ -0,0 +1,64 @@
+package tui
+
+import (
+	"slices"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/vee-sh/veessh/internal/config"
+)
+
+func TestForwardPickerConnectCommand(t *testing.T) {
+	cfg := config.Config{Profiles: map[string]config.Profile{
+		"db": {Name: "db", Host: "db.example.com", Forwards: []config.Forward{
+			{LocalPort: 9000, RemoteHost: "localhost", RemotePort: 9000}, // unnamed, skipped
+			{Name: "pg", LocalPort: 5432, RemoteHost: "localhost", RemotePort: 5432},
+			{LocalPort: 9001, RemoteHost: "localhost", RemotePort: 9001},
+			{Name: "redis", LocalPort: 6379, RemoteHost: "localhost", RemotePort: 6379, Enabled: config.Bool(false)},
+			{Name: "admin", LocalPort: 8080, RemoteHost: "localhost", RemotePort: 80},
+		}},
+	}}
+	m := &Model{config: cfg, keys: defaultKeyMap()}
+	m.openForwardPicker("db")
+	if m.forwards == nil {
+		t.Fatalf("picker not opened: %s", m.statusMessage)
+	}
+
+	// pg and admin are ticked as enabled; untick admin, tick redis. The
+	// cursor skips the unnamed forwards.
+	press := func(k tea.KeyMsg) { m.handleForwardKeys(k) }
+	press(tea.KeyMsg{Type: tea.KeyDown})
+	press(tea.KeyMsg{Type: tea.KeySpace, Runes: []rune{' '}})
+	press(tea.KeyMsg{Type: tea.KeyDown})
+	press(tea.KeyMsg{Type: tea.KeySpace, Runes: []rune{' '}})
+
+	want := []string{"veessh", "connect", "db", "--forward", "pg,redis"}
+	if got := m.forwards.connectCommand("veessh").Args; !slices.Equal(got, want) {
+		t.Errorf("args = %q, want %q", got, want)
+	}
+
+	press(tea.KeyMsg{Type: tea.KeyUp})
+	press(tea.KeyMsg{Type: tea.KeyUp})
+	if m.forwards.cursor != 1 {
+		t.Errorf("cursor = %d, want 1 (pg)", m.forwards.cursor)
+	}
+
+	// All ticks only the named forwards
+	press(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'a'}})
+	want = []string{"veessh", "connect", "db", "--forward", "pg,redis,admin"}
+	if got := m.forwards.connectCommand("veessh").Args; !slices.Equal(got, want) {
+		t.Errorf("args with all ticked = %q, want %q", got, want)
+	}
+
+	// Nothing ticked opens nothing, rather than the default forwards
+	press(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'a'}})
+	want = []string{"veessh", "connect", "db", "--no-forward"}
+	if got := m.forwards.connectCommand("veessh").Args; !slices.Equal(got, want) {
+		t.Errorf("args with none ticked = %q, want %q", got, want)
+	}
+
+	if _, cmd := m.handleForwardKeys(tea.KeyMsg{Type: tea.KeyEnter}); cmd == nil || m.forwards != nil {
+		t.Error("Enter did not start the connection")
+	}
+}
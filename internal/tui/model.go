@@ -26,6 +26,8 @@ const (
 	viewEdit
 	viewAdd
 	viewSearch
+	viewFiles
+	viewForwards
 )
 
 // Model represents the main TUI application state
@@ -51,12 +53,15 @@ type Model struct {
 	selectedIndex  int
 	selectedProfile *config.Profile
 	multiSelect    map[string]bool
+	broadcast      bool // Sessions broadcast input to all panes
 
 	// UI components
 	searchInput   textinput.Model
 	help          help.Model
 	keys          keyMap
 	editForm      *editForm
+	browser       *fileBrowser
+	forwards      *forwardPicker
 	
 	// Search and filter
 	searchActive  bool
@@ -97,6 +102,9 @@ type keyMap struct {
 	Import     key.Binding
 	Connect    key.Binding
 	SFTP       key.Binding
+	Forwards   key.Binding
+	Session    key.Binding
+	Broadcast  key.Binding
 	Test       key.Binding
 	Help       key.Binding
 	Quit       key.Binding
@@ -208,6 +216,18 @@ func defaultKeyMap() keyMap {
 			key.WithKeys("s"),
 			key.WithHelp("s", "SFTP"),
 		),
+		Forwards: key.NewBinding(
+			key.WithKeys("p"),
+			key.WithHelp("p", "forwards"),
+		),
+		Session: key.NewBinding(
+			key.WithKeys("o"),
+			key.WithHelp("o", "tmux session"),
+		),
+		Broadcast: key.NewBinding(
+			key.WithKeys("b"),
+			key.WithHelp("b", "broadcast"),
+		),
 		Test: key.NewBinding(
 			key.WithKeys("t"),
 			key.WithHelp("t", "test"),
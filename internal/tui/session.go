@@ -0,0 +1,55 @@
+package tui
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// sessionProfiles returns the multi-selected profiles, or the selected one
+func (m *Model) sessionProfiles() []string {
+	if len(m.multiSelect) == 0 {
+		if m.selectedProfile == nil {
+			return nil
+		}
+		return []string{m.selectedProfile.Name}
+	}
+	names := make([]string, 0, len(m.multiSelect))
+	for name := range m.multiSelect {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// openSession runs `veessh session` for the named profiles, with --sync
+// when broadcast is on, suspending the TUI until tmux detaches
+func (m *Model) openSession(names []string) tea.Cmd {
+	self, err := os.Executable()
+	if err != nil {
+		return func() tea.Msg {
+			return statusMsg{message: fmt.Sprintf("Failed to open session: %v", err), isError: true}
+		}
+	}
+	args := []string{"session"}
+	if m.broadcast {
+		args = append(args, "--sync")
+	}
+	args = append(args, names...)
+	return tea.ExecProcess(exec.Command(self, args...), func(err error) tea.Msg {
+		if err != nil {
+			return statusMsg{message: fmt.Sprintf("Session failed: %v", err), isError: true}
+		}
+		return statusMsg{message: fmt.Sprintf("Session with %d profile(s) closed", len(names))}
+	})
+}
+
+func onOff(b bool) string {
+	if b {
+		return "on"
+	}
+	return "off"
+}
@@ -11,6 +11,7 @@ import (
 
 	"github.com/vee-sh/veessh/internal/config"
 	"github.com/vee-sh/veessh/internal/credentials"
+	"github.com/vee-sh/veessh/internal/state"
 )
 
 // Message types
@@ -44,7 +45,7 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	case tea.KeyMsg:
 		// Handle global keys first
-		if key.Matches(msg, m.keys.Quit) && m.mode != viewEdit && m.mode != viewAdd {
+		if key.Matches(msg, m.keys.Quit) && m.mode != viewEdit && m.mode != viewAdd && m.mode != viewFiles && m.mode != viewForwards {
 			m.quitting = true
 			return m, tea.Quit
 		}
@@ -55,6 +56,10 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m.handleSearchKeys(msg)
 		case viewEdit, viewAdd:
 			return m.handleEditKeys(msg)
+		case viewFiles:
+			return m.handleFileBrowserKeys(msg)
+		case viewForwards:
+			return m.handleForwardKeys(msg)
 		default:
 			return m.handleMainKeys(msg)
 		}
@@ -72,6 +77,18 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.reloadProfiles()
 		return m, nil
 
+	case fileBrowserOpenedMsg:
+		m.browser = msg.browser
+		m.mode = viewFiles
+		m.statusMessage = ""
+		return m, nil
+
+	case transferProgressMsg, transferDoneMsg:
+		if m.browser != nil {
+			return m, m.browser.updateTransfer(msg)
+		}
+		return m, nil
+
 	case profileSavedMsg:
 		m.statusMessage = fmt.Sprintf("Profile '%s' saved", msg.profile.Name)
 		m.reloadProfiles()
@@ -156,6 +173,33 @@ func (m *Model) handleMainKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			return m, m.connectToProfile(*m.selectedProfile)
 		}
 
+	case key.Matches(msg, m.keys.SFTP):
+		// Browse files on the selected profile
+		if m.selectedProfile != nil {
+			return m, m.openFileBrowser(m.selectedProfile.Name)
+		}
+
+	case key.Matches(msg, m.keys.Forwards):
+		// Pick the forwards to open, then connect
+		if m.selectedProfile != nil {
+			m.openForwardPicker(m.selectedProfile.Name)
+		}
+
+	case key.Matches(msg, m.keys.Session):
+		// Open the selected profiles in a tmux session
+		if names := m.sessionProfiles(); len(names) > 0 {
+			return m, m.openSession(names)
+		}
+
+	case key.Matches(msg, m.keys.Broadcast):
+		// Toggle input broadcast for sessions
+		m.broadcast = !m.broadcast
+		if m.broadcast {
+			m.statusMessage = "Sessions broadcast input to all panes"
+		} else {
+			m.statusMessage = "Sessions open one window per profile"
+		}
+
 	case key.Matches(msg, m.keys.Space):
 		// Toggle multi-select
 		if m.selectedProfile != nil {
@@ -336,16 +380,17 @@ func (m *Model) reloadProfiles() {
 }
 
 func (m *Model) toggleFavorite(name string) {
-	p, ok := m.config.GetProfile(name)
+	p, ok := m.config.Profiles[name]
 	if !ok {
 		return
 	}
 	
 	p.Favorite = !p.Favorite
-	m.config.UpsertProfile(p)
-	
-	// Save to disk
-	if err := config.Save(m.configPath, m.config); err != nil {
+	err := state.Update("", func(s *state.State) error {
+		s.SetFavorite(name, p.Favorite)
+		return nil
+	})
+	if err != nil {
 		m.statusMessage = fmt.Sprintf("Error saving: %v", err)
 	} else {
 		m.reloadProfiles()
@@ -434,7 +479,7 @@ func (m *Model) startAddProfile() {
 		profile: config.Profile{
 			Protocol: config.ProtocolSSH,
 			Port:     22,
-			UseAgent: true,
+			UseAgent: config.Bool(true),
 		},
 		inputs: make([]textinput.Model, 9),
 	}
@@ -508,7 +553,7 @@ func (m *Model) saveProfile() tea.Cmd {
 		Group:        strings.TrimSpace(m.editForm.inputs[6].Value()),
 		Description:  strings.TrimSpace(m.editForm.inputs[8].Value()),
 		Protocol:     config.ProtocolSSH,
-		UseAgent:     true,
+		UseAgent:     config.Bool(true),
 	}
 	
 	// Parse port
@@ -597,8 +642,8 @@ func (m *Model) deleteProfile(name string) tea.Cmd {
 				}
 			}
 			
-			// Delete password if exists
-			_ = credentials.DeletePassword(name)
+			// Delete stored secrets if any
+			_ = credentials.DeleteSecrets(name)
 			
 			return profileDeletedMsg{name: name}
 		}
@@ -621,7 +666,7 @@ func (m *Model) deleteMultipleProfiles() tea.Cmd {
 		for _, name := range names {
 			if _, ok := m.config.GetProfile(name); ok {
 				m.config.DeleteProfile(name)
-				_ = credentials.DeletePassword(name)
+				_ = credentials.DeleteSecrets(name)
 				deleted++
 			}
 		}
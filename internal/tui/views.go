@@ -40,6 +40,10 @@ func (m *Model) View() string {
 		return m.viewEditForm()
 	case viewSearch:
 		return m.viewSearchMode()
+	case viewFiles:
+		return m.viewFileBrowser()
+	case viewForwards:
+		return m.viewForwardPicker()
 	default:
 		return m.viewMainScreen()
 	}
@@ -296,6 +300,18 @@ func (m *Model) viewDetailPane(width, height int) string {
 		content.WriteString(m.styles.Label.Render("ProxyJump:") + m.styles.Value.Render(p.ProxyJump) + "\n")
 	}
 	
+	// Named forwards, ticked when opened by default
+	if len(p.Forwards) > 0 {
+		content.WriteString("\n" + m.styles.Label.Render("Forwards:") + "\n")
+		for _, f := range p.Forwards {
+			box := "[ ]"
+			if f.IsEnabled() {
+				box = "[x]"
+			}
+			content.WriteString(m.styles.Value.Render(fmt.Sprintf("%s %s %s", box, f.Label(), f.Spec())) + "\n")
+		}
+	}
+
 	// Description
 	if p.Description != "" {
 		content.WriteString("\n")
@@ -307,6 +323,8 @@ func (m *Model) viewDetailPane(width, height int) string {
 	content.WriteString("\n\n")
 	buttons := []string{
 		m.styles.Button.Render("[Enter] Connect"),
+		m.styles.Button.Render("[s] Files"),
+		m.styles.Button.Render("[p] Forwards"),
 		m.styles.Button.Render("[e] Edit"),
 		m.styles.Button.Render("[c] Clone"),
 		m.styles.Button.Render("[d] Delete"),
@@ -335,6 +353,8 @@ func (m *Model) viewFooter() string {
 			fmt.Sprintf("%d selected", count),
 			"[t] Tag all",
 			"[g] Move to group",
+			"[o] Session",
+			fmt.Sprintf("[b] Broadcast: %s", onOff(m.broadcast)),
 			"[d] Delete selected",
 			"[Esc] Clear selection",
 		}
@@ -342,6 +362,9 @@ func (m *Model) viewFooter() string {
 		hints = []string{
 			"[↑↓] Navigate",
 			"[Enter] Connect",
+			"[s] Files",
+			"[p] Forwards",
+			"[o] Session",
 			"[e] Edit",
 			"[a] Add",
 			"[d] Delete",
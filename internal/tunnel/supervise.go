@@ -0,0 +1,174 @@
+package tunnel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vee-sh/veessh/internal/config"
+)
+
+// Supervisor defaults
+const (
+	DefaultInterval   = 30 * time.Second
+	DefaultMaxBackoff = 5 * time.Minute
+)
+
+const (
+	minBackoff    = time.Second
+	probeFailures = 2 // Consecutive failed probes that restart the connection
+	probeTimeout  = 10 * time.Second
+)
+
+// Supervisor keeps a tunnel up, like autossh: it runs the connection,
+// probes the forwards every Interval and restarts the connection with
+// exponential backoff when it ends or its probes keep failing.
+type Supervisor struct {
+	// Run holds the connection open until ctx is cancelled or it drops
+	Run      func(ctx context.Context) error
+	Forwards []Forward
+
+	Interval   time.Duration // Between probes; DefaultInterval when zero
+	MaxBackoff time.Duration // Longest wait between restarts; DefaultMaxBackoff when zero
+
+	// OnRestart is called before waiting to restart the connection, with
+	// the number of the restart, why it is needed and the wait
+	OnRestart func(attempt int, reason error, wait time.Duration)
+}
+
+// Supervise runs the connection until ctx is cancelled
+func (s *Supervisor) Supervise(ctx context.Context) error {
+	interval, maxBackoff := s.Interval, s.MaxBackoff
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	if maxBackoff <= 0 {
+		maxBackoff = DefaultMaxBackoff
+	}
+
+	attempt := 0
+	for {
+		started := time.Now()
+		reason := s.runOnce(ctx, interval)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		// A connection that stayed up for a while starts the backoff over
+		if time.Since(started) > maxBackoff {
+			attempt = 0
+		}
+		wait := Backoff(attempt, maxBackoff)
+		attempt++
+		if s.OnRestart != nil {
+			s.OnRestart(attempt, reason, wait)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// runOnce runs the connection until it ends or fails its probes, and
+// returns why it ended
+func (s *Supervisor) runOnce(ctx context.Context, interval time.Duration) error {
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- s.Run(runCtx) }()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	failures := 0
+	for {
+		select {
+		case err := <-done:
+			if err == nil {
+				err = errors.New("connection closed")
+			}
+			return err
+		case <-ticker.C:
+			err := s.probe(runCtx)
+			if err == nil {
+				failures = 0
+				continue
+			}
+			if failures++; failures >= probeFailures {
+				cancel()
+				<-done
+				return fmt.Errorf("health check failed: %w", err)
+			}
+		case <-ctx.Done():
+			<-done
+			return ctx.Err()
+		}
+	}
+}
+
+func (s *Supervisor) probe(ctx context.Context) error {
+	for _, f := range s.Forwards {
+		if err := Probe(ctx, f); err != nil {
+			name := f.Name
+			if name == "" {
+				name = f.Listen
+			}
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Probe checks that a forward works: its check command succeeds or, without
+// one, its port accepts connections. Remote forwards listen on the host and
+// are only checked with a command.
+func Probe(ctx context.Context, f Forward) error {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	if f.Check != "" {
+		cmd := shellCommand(ctx, f.Check)
+		cmd.Env = append(os.Environ(), "VEESSH_FORWARD_NAME="+f.Name, "VEESSH_FORWARD_PORT="+strconv.Itoa(f.Port))
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			if msg := strings.TrimSpace(string(out)); msg != "" {
+				return fmt.Errorf("%s: %w: %s", f.Check, err, msg)
+			}
+			return fmt.Errorf("%s: %w", f.Check, err)
+		}
+		return nil
+	}
+	if f.Type == config.ForwardRemote {
+		return nil
+	}
+	var d net.Dialer
+	c, err := d.DialContext(ctx, "tcp", f.Listen)
+	if err != nil {
+		return err
+	}
+	return c.Close()
+}
+
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", command)
+	}
+	return exec.CommandContext(ctx, "sh", "-c", command)
+}
+
+// Backoff returns the wait before restart attempt+1: one second, doubling
+// with every attempt up to limit
+func Backoff(attempt int, limit time.Duration) time.Duration {
+	wait := minBackoff
+	for i := 0; i < attempt && wait < limit; i++ {
+		wait *= 2
+	}
+	return min(wait, limit)
+}
@@ -0,0 +1,94 @@
+package tunnel
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSupervise(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var mu sync.Mutex
+	var reasons []string
+	runs := 0
+	s := &Supervisor{
+		Run: func(ctx context.Context) error {
+			mu.Lock()
+			runs++
+			first := runs == 1
+			mu.Unlock()
+			if first {
+				return errors.New("connection reset")
+			}
+			<-ctx.Done()
+			return ctx.Err()
+		},
+		Forwards:   []Forward{{Name: "pg", Check: "exit 1"}},
+		Interval:   10 * time.Millisecond,
+		MaxBackoff: time.Millisecond,
+		OnRestart: func(attempt int, reason error, wait time.Duration) {
+			mu.Lock()
+			defer mu.Unlock()
+			reasons = append(reasons, reason.Error())
+			if len(reasons) == 2 {
+				cancel()
+			}
+		},
+	}
+	if err := s.Supervise(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Supervise() = %v, want context.Canceled", err)
+	}
+	if len(reasons) != 2 || reasons[0] != "connection reset" || !strings.HasPrefix(reasons[1], "health check failed: pg: exit 1") {
+		t.Errorf("restarts = %q", reasons)
+	}
+}
+
+func TestProbe(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	addr := l.Addr().String()
+	ctx := context.Background()
+
+	if err := Probe(ctx, Forward{Type: "local", Listen: addr}); err != nil {
+		t.Errorf("Probe(listening) = %v", err)
+	}
+	if err := Probe(ctx, Forward{Port: 5432, Check: `test "$VEESSH_FORWARD_PORT" = 5432`}); err != nil {
+		t.Errorf("Probe(check) = %v", err)
+	}
+	if err := Probe(ctx, Forward{Check: "echo refused; exit 3"}); err == nil || !strings.Contains(err.Error(), "refused") {
+		t.Errorf("Probe(failing check) = %v", err)
+	}
+	l.Close()
+	if err := Probe(ctx, Forward{Type: "local", Listen: addr}); err == nil {
+		t.Error("Probe(closed port) = nil")
+	}
+	if err := Probe(ctx, Forward{Type: "remote", Listen: addr}); err != nil {
+		t.Errorf("Probe(remote) = %v", err)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{0, time.Second},
+		{1, 2 * time.Second},
+		{5, 32 * time.Second},
+		{9, 5 * time.Minute},
+		{100, 5 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := Backoff(tt.attempt, DefaultMaxBackoff); got != tt.want {
+			t.Errorf("Backoff(%d) = %s, want %s", tt.attempt, got, tt.want)
+		}
+	}
+}
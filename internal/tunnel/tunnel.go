@@ -0,0 +1,220 @@
+// Package tunnel keeps track of the tunnels started by 'veessh tunnel'.
+// Every running tunnel has a JSON record in a directory next to the state
+// file and holds a lock on it for as long as it runs, so records left
+// behind by tunnels that died are recognized and dropped.
+package tunnel
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"syscall"
+	"time"
+
+	"github.com/vee-sh/veessh/internal/state"
+	"github.com/vee-sh/veessh/internal/util"
+)
+
+// Forward is a forward opened by a tunnel
+type Forward struct {
+	Name   string `json:"name,omitempty"`
+	Type   string `json:"type"`
+	Listen string `json:"listen"`         // Address listened on (on the host for remote forwards)
+	Dest   string `json:"dest,omitempty"` // Where connections go; empty for SOCKS proxies
+	Port   int    `json:"port"`
+	Check  string `json:"check,omitempty"` // Health check command
+}
+
+// Tunnel is a running tunnel
+type Tunnel struct {
+	Profile    string    `json:"profile"`
+	Host       string    `json:"host"`
+	PID        int       `json:"pid"`
+	Forwards   []Forward `json:"forwards"`
+	Started    time.Time `json:"started"`
+	Background bool      `json:"background,omitempty"`
+	Log        string    `json:"log,omitempty"` // Output of background tunnels
+	Restarts   int       `json:"restarts,omitempty"`
+}
+
+// Uptime returns how long the tunnel has been running
+func (t Tunnel) Uptime() time.Duration {
+	return time.Since(t.Started)
+}
+
+// Ports returns the ports the tunnel listens on
+func (t Tunnel) Ports() []int {
+	ports := make([]int, len(t.Forwards))
+	for i, f := range t.Forwards {
+		ports[i] = f.Port
+	}
+	return ports
+}
+
+// Dir returns the directory tunnel records are kept in, next to the state
+// file
+func Dir() (string, error) {
+	statePath, err := state.DefaultPath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(statePath), "tunnels"), nil
+}
+
+// LogPath returns the file background tunnels of profile write to
+func LogPath(dir, profile string) string {
+	return filepath.Join(dir, url.PathEscape(profile)+".log")
+}
+
+func recordPath(dir, profile string) string {
+	return filepath.Join(dir, url.PathEscape(profile)+".json")
+}
+
+// Handle is the record of the tunnel run by this process
+type Handle struct {
+	path   string
+	lock   *util.FileLock
+	tunnel Tunnel
+}
+
+// Register records t as running in dir until the handle is closed. There
+// can be one tunnel per profile.
+func Register(dir string, t Tunnel) (*Handle, error) {
+	path := recordPath(dir, t.Profile)
+	lock, err := util.TryLock(path)
+	if err != nil {
+		return nil, err
+	}
+	if lock == nil {
+		if running, err := read(path); err == nil {
+			return nil, fmt.Errorf("a tunnel to %s is already running (pid %d); stop it with 'veessh tunnel stop %s'", t.Profile, running.PID, t.Profile)
+		}
+		return nil, fmt.Errorf("a tunnel to %s is already running", t.Profile)
+	}
+	h := &Handle{path: path, lock: lock, tunnel: t}
+	if err := h.write(); err != nil {
+		lock.Unlock()
+		return nil, err
+	}
+	return h, nil
+}
+
+// Update changes the record
+func (h *Handle) Update(fn func(*Tunnel)) error {
+	fn(&h.tunnel)
+	return h.write()
+}
+
+func (h *Handle) write() error {
+	data, err := json.MarshalIndent(h.tunnel, "", "  ")
+	if err != nil {
+		return err
+	}
+	return util.WriteFileAtomic(h.path, append(data, '\n'), 0o600)
+}
+
+// Close removes the record
+func (h *Handle) Close() error {
+	err := os.Remove(h.path)
+	h.lock.Unlock()
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// Get returns the running tunnel of profile in dir
+func Get(dir, profile string) (Tunnel, bool, error) {
+	path := recordPath(dir, profile)
+	if !running(path) {
+		return Tunnel{}, false, nil
+	}
+	t, err := read(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return Tunnel{}, false, nil
+	}
+	return t, err == nil, err
+}
+
+// List returns the running tunnels in dir by profile, removing the records
+// of tunnels that are gone
+func List(dir string) ([]Tunnel, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var out []Tunnel
+	for _, path := range paths {
+		if !running(path) {
+			continue
+		}
+		t, err := read(path)
+		if err != nil {
+			continue // Being written or removed
+		}
+		out = append(out, t)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Profile < out[j].Profile })
+	return out, nil
+}
+
+// running reports whether a process holds the record at path. Records
+// nobody holds are removed.
+func running(path string) bool {
+	lock, err := util.TryLock(path)
+	if err != nil {
+		return false
+	}
+	if lock == nil {
+		return true
+	}
+	os.Remove(path)
+	lock.Unlock()
+	return false
+}
+
+func read(path string) (Tunnel, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Tunnel{}, err
+	}
+	var t Tunnel
+	if err := json.Unmarshal(data, &t); err != nil {
+		return Tunnel{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return t, nil
+}
+
+// Stop asks the process running t to close the tunnel and waits up to
+// timeout for it to exit before killing it
+func Stop(dir string, t Tunnel, timeout time.Duration) error {
+	proc, err := os.FindProcess(t.PID)
+	if err != nil {
+		return fmt.Errorf("tunnel to %s: %w", t.Profile, err)
+	}
+	// There is no SIGTERM on Windows
+	err = proc.Signal(syscall.SIGTERM)
+	if err != nil && !errors.Is(err, os.ErrProcessDone) {
+		err = proc.Kill()
+	}
+	if err != nil && !errors.Is(err, os.ErrProcessDone) {
+		return fmt.Errorf("failed to stop tunnel to %s (pid %d): %w", t.Profile, t.PID, err)
+	}
+
+	path := recordPath(dir, t.Profile)
+	deadline := time.Now().Add(timeout)
+	for running(path) {
+		if time.Now().After(deadline) {
+			proc.Kill()
+			os.Remove(path)
+			return fmt.Errorf("tunnel to %s (pid %d) did not exit in %s and was killed", t.Profile, t.PID, timeout)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	return nil
+}
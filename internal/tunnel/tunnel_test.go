@@ -0,0 +1,62 @@
+package tunnel
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRegister(t *testing.T) {
+	dir := t.TempDir()
+	tun := Tunnel{
+		Profile:  "db/prod",
+		Host:     "bastion",
+		PID:      os.Getpid(),
+		Forwards: []Forward{{Name: "pg", Type: "local", Listen: "localhost:5432", Dest: "db:5432", Port: 5432}},
+		Started:  time.Now().Add(-time.Minute),
+	}
+	h, err := Register(dir, tun)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Register(dir, tun); err == nil || !strings.Contains(err.Error(), "already running") {
+		t.Errorf("second Register() = %v, want already running", err)
+	}
+	got, ok, err := Get(dir, "db/prod")
+	if err != nil || !ok || got.PID != tun.PID || got.Ports()[0] != 5432 {
+		t.Errorf("Get() = %+v, %v, %v", got, ok, err)
+	}
+	if got.Uptime() < time.Minute {
+		t.Errorf("Uptime() = %s", got.Uptime())
+	}
+	list, err := List(dir)
+	if err != nil || len(list) != 1 || list[0].Profile != "db/prod" {
+		t.Errorf("List() = %+v, %v", list, err)
+	}
+
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := Get(dir, "db/prod"); ok {
+		t.Error("Get() after Close() found the tunnel")
+	}
+}
+
+func TestListDropsStale(t *testing.T) {
+	dir := t.TempDir()
+	// A record nobody holds the lock of, as left by a killed tunnel
+	stale := filepath.Join(dir, "web.json")
+	if err := os.WriteFile(stale, []byte(`{"profile":"web","pid":1}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	list, err := List(dir)
+	if err != nil || len(list) != 0 {
+		t.Errorf("List() = %+v, %v, want none", list, err)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Error("stale record was not removed")
+	}
+}
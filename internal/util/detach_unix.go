@@ -0,0 +1,14 @@
+//go:build !windows
+
+package util
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// Detach makes cmd run in its own session so it outlives the terminal that
+// started it
+func Detach(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+}
@@ -0,0 +1,113 @@
+package util
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FileLock is an advisory lock held on a "<path>.lock" file next to the
+// file it protects. The data file itself is replaced by rename, so it
+// cannot carry the lock.
+type FileLock struct {
+	f *os.File
+}
+
+// LockTimeout is how long Lock waits for another process to release a lock
+var LockTimeout = 10 * time.Second
+
+// Lock takes a shared or exclusive advisory lock for path, waiting up to
+// LockTimeout
+func Lock(path string, exclusive bool) (*FileLock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path+".lock", os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(LockTimeout)
+	for {
+		ok, err := tryLock(f, exclusive)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+		}
+		if ok {
+			return &FileLock{f: f}, nil
+		}
+		if time.Now().After(deadline) {
+			f.Close()
+			return nil, fmt.Errorf("timed out waiting for the lock on %s (held by another veessh process?)", path)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// TryLock takes an exclusive lock for path without waiting. It returns nil
+// when another process holds the lock.
+func TryLock(path string) (*FileLock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path+".lock", os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	ok, err := tryLock(f, true)
+	if err != nil || !ok {
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+		}
+		return nil, nil
+	}
+	return &FileLock{f: f}, nil
+}
+
+// Unlock releases the lock
+func (l *FileLock) Unlock() error {
+	if l == nil || l.f == nil {
+		return nil
+	}
+	unlock(l.f)
+	err := l.f.Close()
+	l.f = nil
+	return err
+}
+
+// WriteFileAtomic replaces path with data through a uniquely named temp file
+// in the same directory, synced to disk before the rename so a crash leaves
+// either the old or the new content
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+"-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	syncDir(dir)
+	return nil
+}
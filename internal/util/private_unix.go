@@ -0,0 +1,38 @@
+//go:build !windows
+
+package util
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// checkPrivate refuses directories that other users can reach into or that
+// someone else created (e.g. a pre-created directory in a shared /tmp)
+func checkPrivate(dir string, info os.FileInfo) error {
+	if info.Mode().Perm()&0o077 != 0 {
+		return fmt.Errorf("%s is accessible by other users (mode %o), refusing to use it", dir, info.Mode().Perm())
+	}
+	if st, ok := info.Sys().(*syscall.Stat_t); ok && st.Uid != uint32(os.Getuid()) {
+		return fmt.Errorf("%s is owned by another user, refusing to use it", dir)
+	}
+	return nil
+}
+
+// CheckTrusted refuses a file that someone else owns (root aside) or that
+// group or other users can write to, as they could have planted or changed
+// it
+func CheckTrusted(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if info.Mode().Perm()&0o022 != 0 {
+		return fmt.Errorf("%s is writable by other users (mode %o)", path, info.Mode().Perm())
+	}
+	if st, ok := info.Sys().(*syscall.Stat_t); ok && st.Uid != uint32(os.Getuid()) && st.Uid != 0 {
+		return fmt.Errorf("%s is owned by another user", path)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package util
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// RuntimeDir returns the directory for veessh's sockets: $XDG_RUNTIME_DIR/veessh
+// when set, otherwise a per-user veessh-<uid> directory under the system
+// temp dir. Create it with PrivateDir before use.
+func RuntimeDir() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "veessh")
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("veessh-%d", os.Getuid()))
+}
+
+// PrivateDir creates dir with mode 0700 and refuses to use it if other users
+// could reach into it (e.g. a pre-created directory in a shared /tmp)
+func PrivateDir(dir string) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+	return checkPrivate(dir, info)
+}
+
+// CheckPrivate refuses path, a directory or socket made private earlier,
+// if it is missing, owned by another user or reachable by other users. It
+// creates nothing; check before trusting whatever listens on a socket.
+func CheckPrivate(path string) error {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+	return checkPrivate(path, info)
+}
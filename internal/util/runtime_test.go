@@ -0,0 +1,22 @@
+package util
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestPrivateDirRejectsSharedDir(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("directory modes are not enforced on Windows")
+	}
+	dir := filepath.Join(t.TempDir(), "shared")
+	if err := os.Mkdir(dir, 0o777); err != nil {
+		t.Fatal(err)
+	}
+	os.Chmod(dir, 0o777)
+	if err := PrivateDir(dir); err == nil {
+		t.Error("PrivateDir() accepted a world-writable directory")
+	}
+}
@@ -16,16 +16,19 @@ func RunAttached(cmd *exec.Cmd) error {
 	if err := cmd.Start(); err != nil {
 		return err
 	}
-	if err := cmd.Wait(); err != nil {
-		var exitErr *exec.ExitError
-		if errors.As(err, &exitErr) {
-			if status, ok := exitErr.Sys().(syscall.WaitStatus); ok {
-				if status.Signaled() && status.Signal() == syscall.SIGINT {
-					return context.Canceled
-				}
+	return Interrupted(cmd.Wait())
+}
+
+// Interrupted returns context.Canceled when err is a command killed by
+// Ctrl-C, and err otherwise
+func Interrupted(err error) error {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok {
+			if status.Signaled() && status.Signal() == syscall.SIGINT {
+				return context.Canceled
 			}
 		}
-		return err
 	}
-	return nil
+	return err
 }